@@ -1,16 +1,23 @@
 package commands
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"strconv"
 
 	"github.com/filecoin-project/go-address"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	files "github.com/ipfs/go-ipfs-files"
+	"github.com/pkg/errors"
 
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet/history"
 )
 
 var walletCmd = &cmds.Command{
@@ -19,8 +26,13 @@ var walletCmd = &cmds.Command{
 	},
 	Subcommands: map[string]*cmds.Command{
 		"balance": balanceCmd,
+		"history": walletHistoryCmd,
 		"import":  walletImportCmd,
 		"export":  walletExportCmd,
+		"init-hd": walletInitHDCmd,
+		"recover": walletRecoverCmd,
+		// sign-message is run by the CLI without a daemon, see localSubcmds.
+		"sign-message": walletSignMessageCmd,
 	},
 }
 
@@ -32,6 +44,7 @@ var addrsCmd = &cmds.Command{
 		"ls":      addrsLsCmd,
 		"new":     addrsNewCmd,
 		"default": defaultAddressCmd,
+		"book":    addrBookCmd,
 	},
 }
 
@@ -42,9 +55,17 @@ type AddressResult struct {
 // AddressLsResult is the result of running the address list command.
 type AddressLsResult struct {
 	Addresses []address.Address
+	Details   []porcelain.WalletAddressInfo
 }
 
 var addrsNewCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create a new address in the wallet",
+		ShortDescription: `
+Creates an address with a new key. If the wallet has an HD seed, see
+"wallet init-hd", the key is the next one of its derivation path.
+`,
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		protocolName := req.Options["type"].(string)
 		var protocol address.Protocol
@@ -60,15 +81,28 @@ var addrsNewCmd = &cmds.Command{
 		if err != nil {
 			return err
 		}
+		if label, ok := req.Options["label"].(string); ok {
+			if err := GetPorcelainAPI(env).AddressBookSet(label, addr); err != nil {
+				return errors.Wrapf(err, "created %s but failed to label it", addr)
+			}
+		}
 		return re.Emit(&AddressResult{addr})
 	},
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("type", "The type of address to create: bls or secp256k1 (default)").WithDefault("secp256k1"),
+		cmdkit.StringOption("label", "Label to give the new address in the address book"),
 	},
 	Type: &AddressResult{},
 }
 
 var addrsLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the addresses of the wallet",
+		ShortDescription: `
+Lists the addresses of the wallet. The details show the label of each address,
+the ID address of its actor once it exists on chain and its balance.
+`,
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		addrs := GetPorcelainAPI(env).WalletAddresses()
 
@@ -77,11 +111,85 @@ var addrsLsCmd = &cmds.Command{
 			alr.Addresses = append(alr.Addresses, addr)
 		}
 
+		infos, err := GetPorcelainAPI(env).WalletAddressInfos(req.Context)
+		if err != nil {
+			return err
+		}
+		alr.Details = infos
+
 		return re.Emit(&alr)
 	},
 	Type: &AddressLsResult{},
 }
 
+var addrBookCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage labels for addresses",
+		ShortDescription: `
+The address book gives labels to addresses of the wallet and to external
+addresses. Commands accept a label wherever they take an address argument or
+a --from address.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":  addrBookLsCmd,
+		"set": addrBookSetCmd,
+		"rm":  addrBookRmCmd,
+	},
+}
+
+// AddressBookLsResult is the result of listing the address book.
+type AddressBookLsResult struct {
+	Entries []wallet.AddressBookEntry
+}
+
+var addrBookLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List labelled addresses",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return re.Emit(&AddressBookLsResult{Entries: GetPorcelainAPI(env).AddressBookEntries()})
+	},
+	Type: &AddressBookLsResult{},
+}
+
+var addrBookSetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Label an address",
+		ShortDescription: `
+Labels an address, replacing its previous label. Labels are letters, digits,
+'.', '_' and '-', and each names a single address.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("label", true, false, "Label to give the address"),
+		cmdkit.StringArg("address", true, false, "Address to label"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		addr, err := address.NewFromString(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+		if err := GetPorcelainAPI(env).AddressBookSet(req.Arguments[0], addr); err != nil {
+			return err
+		}
+		return re.Emit(&wallet.AddressBookEntry{Label: req.Arguments[0], Address: addr})
+	},
+	Type: &wallet.AddressBookEntry{},
+}
+
+var addrBookRmCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Remove a label from the address book",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("label", true, false, "Label to remove"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return GetPorcelainAPI(env).AddressBookRemove(req.Arguments[0])
+	},
+}
+
 var defaultAddressCmd = &cmds.Command{
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		addr, err := GetPorcelainAPI(env).WalletDefaultAddress()
@@ -99,7 +207,7 @@ var balanceCmd = &cmds.Command{
 		cmdkit.StringArg("address", true, false, "Address to get balance for"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		addr, err := address.NewFromString(req.Arguments[0])
+		addr, err := resolveAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -113,6 +221,85 @@ var balanceCmd = &cmds.Command{
 	Type: &types.AttoFIL{},
 }
 
+// WalletHistoryResult is the result of the wallet history command.
+type WalletHistoryResult struct {
+	Address address.Address
+	Entries []history.Entry
+}
+
+var walletHistoryCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the transactions affecting a wallet or miner address",
+		ShortDescription: `Lists the messages sent from and to an address of the wallet, or the
+configured miner, along with the rewards for blocks it mined. Messages report
+their value, method, exit code and the gas paid by the sender; value only moves
+when the exit code is 0. Rewards are credited to the miner as locked funds.
+
+The history is indexed as the head moves and trails it by one tipset, whose
+receipts are not yet on chain. The range is given as <from>:<to>, inclusive;
+either end may be omitted. Use --enc=csv to output the history as CSV.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("address", true, false, "Address or label to list transactions for"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("range", "Range of epochs as <from>:<to>").WithDefault(":"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		addr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		head, err := GetPorcelainAPI(env).ChainHead()
+		if err != nil {
+			return err
+		}
+		headHeight, err := head.Height()
+		if err != nil {
+			return err
+		}
+		epochRange, _ := req.Options["range"].(string)
+		from, to, err := parseEpochRange(epochRange, headHeight)
+		if err != nil {
+			return err
+		}
+
+		entries, err := GetPorcelainAPI(env).WalletHistory(addr, from, to)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&WalletHistoryResult{Address: addr, Entries: entries})
+	},
+	Type: &WalletHistoryResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.EncodingType("csv"): cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *WalletHistoryResult) error {
+			cw := csv.NewWriter(w)
+			_ = cw.Write([]string{"epoch", "tipset", "kind", "message", "from", "to", "value", "method", "exit_code", "gas_used", "gas_paid"})
+			for _, e := range res.Entries {
+				msg := ""
+				if e.Message.Defined() {
+					msg = e.Message.String()
+				}
+				_ = cw.Write([]string{
+					strconv.FormatInt(int64(e.Epoch), 10),
+					e.TipSet.String(),
+					string(e.Kind),
+					msg,
+					e.From.String(),
+					e.To.String(),
+					e.Value.String(),
+					strconv.FormatUint(uint64(e.Method), 10),
+					strconv.FormatInt(int64(e.ExitCode), 10),
+					strconv.FormatInt(int64(e.GasUsed), 10),
+					e.GasPaid.String(),
+				})
+			}
+			cw.Flush()
+			return cw.Error()
+		}),
+	},
+}
+
 // WalletSerializeResult is the type wallet export and import return and expect.
 type WalletSerializeResult struct {
 	KeyInfo []*crypto.KeyInfo
@@ -165,7 +352,7 @@ var walletExportCmd = &cmds.Command{
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		addrs := make([]address.Address, len(req.Arguments))
 		for i, arg := range req.Arguments {
-			addr, err := address.NewFromString(arg)
+			addr, err := resolveAddr(env, arg)
 			if err != nil {
 				return err
 			}
@@ -184,3 +371,130 @@ var walletExportCmd = &cmds.Command{
 	},
 	Type: &WalletSerializeResult{},
 }
+
+// WalletInitHDResult is the result of initializing an HD wallet.
+type WalletInitHDResult struct {
+	Mnemonic string
+}
+
+var walletInitHDCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Make the wallet derive new addresses from a new mnemonic",
+		ShortDescription: `
+Generates a 24 word mnemonic and derives every address created afterwards
+from it: secp256k1 addresses along m/44'/461'/0'/0/i and BLS addresses along
+m/12381/461/0/i. Write the mnemonic down; with the passphrase, if any, it
+restores these addresses with "wallet recover". Addresses created before are
+not covered and must be exported separately.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("passphrase", "Passphrase protecting the mnemonic"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		passphrase, _ := req.Options["passphrase"].(string)
+		mnemonic, err := GetPorcelainAPI(env).WalletInitSeed(passphrase)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&WalletInitHDResult{Mnemonic: mnemonic})
+	},
+	Type: &WalletInitHDResult{},
+}
+
+var walletRecoverCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Restore the addresses derived from a mnemonic",
+		ShortDescription: `
+Makes the wallet derive addresses from a mnemonic and adds the addresses
+derived from it that have actors on chain. The search along each derivation
+path stops after --gap consecutive addresses without an actor. The first
+secp256k1 address is always added.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("mnemonic", true, false, "Mnemonic words, in quotes").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("passphrase", "Passphrase protecting the mnemonic"),
+		cmdkit.UintOption("gap", "Number of consecutive unused addresses after which to stop searching").WithDefault(uint(porcelain.DefaultRecoveryGap)),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		passphrase, _ := req.Options["passphrase"].(string)
+		gap, _ := req.Options["gap"].(uint)
+		addrs, err := GetPorcelainAPI(env).WalletRecover(req.Context, req.Arguments[0], passphrase, uint32(gap))
+		if err != nil {
+			return err
+		}
+		return re.Emit(&AddressLsResult{Addresses: addrs})
+	},
+	Type: &AddressLsResult{},
+}
+
+var walletSignMessageCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Sign a message with a key of the repo's wallet, without a daemon",
+		ShortDescription: `
+Signs an unsigned message, such as one made by "message create", with a key
+held in the wallet of the repo. The command opens the repo itself so that it
+can run on a machine that is not connected to the network; it fails if a
+daemon is using the repo. Send the result with "message sendsigned".
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("message", true, false, "File containing the JSON unsigned message").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("signer", "Key address or label to sign with, if the sender is given by its ID address"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		iter := req.Files.Entries()
+		if !iter.Next() {
+			return fmt.Errorf("no file given: %s", iter.Err())
+		}
+		fi, ok := iter.Node().(files.File)
+		if !ok {
+			return fmt.Errorf("given file was not a files.File")
+		}
+		var msg types.UnsignedMessage
+		if err := json.NewDecoder(fi).Decode(&msg); err != nil {
+			return err
+		}
+
+		rep, err := getRepo(req)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rep.Close() }()
+
+		signer := msg.From
+		if s, ok := req.Options["signer"].(string); ok {
+			book, err := wallet.NewAddressBook(rep.Datastore())
+			if err != nil {
+				return err
+			}
+			if signer, err = book.Resolve(s); err != nil {
+				return err
+			}
+		}
+		if signer.Protocol() != address.SECP256K1 && signer.Protocol() != address.BLS {
+			return fmt.Errorf("cannot sign for %s without the chain, pass the key address with --signer", signer)
+		}
+
+		backend, err := wallet.NewDSBackend(rep.WalletDatastore())
+		if err != nil {
+			return err
+		}
+
+		msgCid, err := msg.Cid()
+		if err != nil {
+			return err
+		}
+		sig, err := wallet.New(backend).SignBytes(msgCid.Bytes(), signer)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&types.SignedMessage{Message: msg, Signature: sig})
+	},
+	Type: &types.SignedMessage{},
+}
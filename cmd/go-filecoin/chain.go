@@ -2,15 +2,24 @@
 package commands
 
 import (
+	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 
-	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/ipfs/go-cid"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	files "github.com/ipfs/go-ipfs-files"
 	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainreplay"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/forkmon"
 )
 
 var chainCmd = &cmds.Command{
@@ -19,12 +28,15 @@ var chainCmd = &cmds.Command{
 	},
 	Subcommands: map[string]*cmds.Command{
 		"export":   storeExportCmd,
+		"forks":    storeForksCmd,
 		"head":     storeHeadCmd,
 		"import":   storeImportCmd,
 		"ls":       storeLsCmd,
 		"status":   storeStatusCmd,
 		"set-head": storeSetHeadCmd,
+		"stats":    storeStatsCmd,
 		"sync":     storeSyncCmd,
+		"validate": storeValidateCmd,
 	},
 }
 
@@ -84,6 +96,28 @@ var storeStatusCmd = &cmds.Command{
 	},
 }
 
+var storeForksCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List recent reorgs and the tipsets they orphaned.",
+		ShortDescription: `Lists reorgs observed by the syncer, most recent first, including their depth,
+the dropped and added tipsets, messages that were reverted and not re-included,
+and the miners of the dropped blocks.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.UintOption("limit", "n", "Maximum number of reorgs to list; 0 lists all retained reorgs").WithDefault(uint(0)),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		limit, _ := req.Options["limit"].(uint)
+		for _, reorg := range GetPorcelainAPI(env).ChainForks(int(limit)) {
+			if err := re.Emit(reorg); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: &forkmon.Reorg{},
+}
+
 var storeSetHeadCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Set the chain head to a specific tipset key.",
@@ -132,6 +166,139 @@ var storeSyncCmd = &cmds.Command{
 	},
 }
 
+var storeValidateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Re-validate a range of the stored chain.",
+		ShortDescription: `Replays the state transitions of the tipsets on the current chain with heights
+between --from and --to (inclusive) and compares the computed state and receipt
+roots with the ones recorded when the tipsets were first synced. Reports the
+first divergence found along with a diff of the differing actors.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("from", "Height of the first tipset to validate").WithDefault(uint64(1)),
+		cmdkit.Uint64Option("to", "Height of the last tipset to validate; defaults to the head"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		api := GetPorcelainAPI(env)
+		from, _ := req.Options["from"].(uint64)
+		to, ok := req.Options["to"].(uint64)
+		if !ok {
+			head, err := api.ChainHead()
+			if err != nil {
+				return err
+			}
+			h, err := head.Height()
+			if err != nil {
+				return err
+			}
+			to = uint64(h)
+		}
+
+		result, err := api.ChainValidate(req.Context, abi.ChainEpoch(from), abi.ChainEpoch(to))
+		if err != nil {
+			return err
+		}
+		return re.Emit(result)
+	},
+	Type: &chainreplay.Result{},
+}
+
+var storeStatsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Compute network health statistics over a range of the chain.",
+		ShortDescription: `Reports null round frequency, the distribution of tipset widths, each
+miner's share of blocks against its share of quality-adjusted power at the end
+of the range, block lateness relative to the start of its epoch, and message
+throughput and gas used per epoch.
+
+The range is given as <from>:<to>, inclusive; either end may be omitted and
+defaults to genesis and the head respectively. Use --enc=csv to output the
+per-epoch and per-miner tables as CSV.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("range", "Range of epochs as <from>:<to>").WithDefault(":"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		api := GetPorcelainAPI(env)
+		head, err := api.ChainHead()
+		if err != nil {
+			return err
+		}
+		headHeight, err := head.Height()
+		if err != nil {
+			return err
+		}
+		epochRange, _ := req.Options["range"].(string)
+		from, to, err := parseEpochRange(epochRange, headHeight)
+		if err != nil {
+			return err
+		}
+
+		stats, err := api.ChainStats(req.Context, from, to)
+		if err != nil {
+			return err
+		}
+		return re.Emit(stats)
+	},
+	Type: &porcelain.ChainStats{},
+	Encoders: cmds.EncoderMap{
+		cmds.EncodingType("csv"): cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, stats *porcelain.ChainStats) error {
+			cw := csv.NewWriter(w)
+			_ = cw.Write([]string{"epoch", "null", "width", "messages", "gas_used", "max_lateness_ms"})
+			for _, es := range stats.Epochs {
+				_ = cw.Write([]string{
+					strconv.FormatInt(int64(es.Epoch), 10),
+					strconv.FormatBool(es.Null),
+					strconv.Itoa(es.Width),
+					strconv.Itoa(es.Messages),
+					strconv.FormatInt(int64(es.GasUsed), 10),
+					strconv.FormatInt(es.MaxLateness.Milliseconds(), 10),
+				})
+			}
+			cw.Flush()
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+			_ = cw.Write([]string{"miner", "blocks", "block_share", "power_share"})
+			for _, ms := range stats.Miners {
+				_ = cw.Write([]string{
+					ms.Miner.String(),
+					strconv.Itoa(ms.Blocks),
+					strconv.FormatFloat(ms.BlockShare, 'f', 6, 64),
+					strconv.FormatFloat(ms.PowerShare, 'f', 6, 64),
+				})
+			}
+			cw.Flush()
+			return cw.Error()
+		}),
+	},
+}
+
+// parseEpochRange parses a range of the form <from>:<to>. An omitted lower
+// bound defaults to genesis and an omitted upper bound to `head`.
+func parseEpochRange(s string, head abi.ChainEpoch) (abi.ChainEpoch, abi.ChainEpoch, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid range %q, expected <from>:<to>", s)
+	}
+	from, to := abi.ChainEpoch(0), head
+	if parts[0] != "" {
+		v, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid range start %q: %s", parts[0], err)
+		}
+		from = abi.ChainEpoch(v)
+	}
+	if parts[1] != "" {
+		v, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid range end %q: %s", parts[1], err)
+		}
+		to = abi.ChainEpoch(v)
+	}
+	return from, to, nil
+}
+
 var storeExportCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Export the chain store to a car file.",
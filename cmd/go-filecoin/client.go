@@ -1,8 +1,12 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"strconv"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
@@ -10,11 +14,20 @@ import (
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/pkg/errors"
 
+	"github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-cid"
+	badgerds "github.com/ipfs/go-ds-badger2"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
 	files "github.com/ipfs/go-ipfs-files"
+	"github.com/ipfs/go-merkledag"
 	p2pcore "github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/dag"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage"
 )
 
 var clientCmd = &cmds.Command{
@@ -23,7 +36,10 @@ var clientCmd = &cmds.Command{
 	},
 	Subcommands: map[string]*cmds.Command{
 		"cat":                  clientCatCmd,
+		"deal":                 clientDealCmd,
 		"import":               clientImportDataCmd,
+		"generate-car":         clientGenerateCarCmd,
+		"piece-info":           clientPieceInfoCmd,
 		"propose-storage-deal": ClientProposeStorageDealCmd,
 		"query-storage-deal":   ClientQueryStorageDealCmd,
 		"verify-storage-deal":  clientVerifyStorageDealCmd,
@@ -65,23 +81,48 @@ var clientImportDataCmd = &cmds.Command{
 Imports data previously exported with the client cat command into the storage
 market. This command takes only one argument, the path of the file to import.
 See the go-filecoin client cat command for more details.
+`,
+		LongDescription: `
+Imports data previously exported with the client cat command into the storage
+market. This command takes only one argument, the path of the file to import.
+See the go-filecoin client cat command for more details.
+
+The data is split into leaves by the --chunker, either "size-<bytes>" for fixed
+size chunks (the default is size-262144) or "rabin", "rabin-<avg>" or
+"rabin-<min>-<avg>-<max>" for content defined chunks. With --raw-leaves the
+leaves are stored as raw blocks.
+
+With --car the file is a CAR file, such as one written by client generate-car,
+whose blocks are imported as they are. The CAR file must have a single root,
+which is printed.
+
+Use client piece-info to learn the size and commitment of the piece a deal for
+the data stores.
 `,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.FileArg("file", true, false, "Path to file to import").EnableStdin(),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("chunker", "How to split the data into leaves: size-<bytes>, rabin, rabin-<avg> or rabin-<min>-<avg>-<max>"),
+		cmdkit.BoolOption("raw-leaves", "Store the leaves as raw blocks"),
+		cmdkit.BoolOption("car", "Import the blocks of a CAR file as they are"),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		iter := req.Files.Entries()
-		if !iter.Next() {
-			return fmt.Errorf("no file given: %s", iter.Err())
+		fi, err := requestFile(req)
+		if err != nil {
+			return err
 		}
 
-		fi, ok := iter.Node().(files.File)
-		if !ok {
-			return fmt.Errorf("given file was not a files.File")
+		if isCar, _ := req.Options["car"].(bool); isCar {
+			root, err := GetPorcelainAPI(env).DAGImportCar(req.Context, fi)
+			if err != nil {
+				return err
+			}
+			return re.Emit(root)
 		}
 
-		out, err := GetPorcelainAPI(env).DAGImportData(req.Context, fi)
+		out, err := GetPorcelainAPI(env).DAGImportData(req.Context, fi, importOptions(req))
 		if err != nil {
 			return err
 		}
@@ -91,6 +132,117 @@ See the go-filecoin client cat command for more details.
 	Type: cid.Cid{},
 }
 
+var clientPieceInfoCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the size and commitment of the piece a deal for imported data stores",
+		ShortDescription: `
+Computes the piece commitment (CommP) and padded size of the piece a storage
+deal for the data stores: the CAR file holding all of the data's blocks, padded
+to a power of two. Miners only accept deals whose piece fits in their sectors.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "CID of the imported data"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		root, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		info, err := GetPorcelainAPI(env).DAGPieceInfo(req.Context, root)
+		if err != nil {
+			return err
+		}
+		return re.Emit(info)
+	},
+	Type: dag.PieceInfo{},
+}
+
+var clientGenerateCarCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Write the CAR file a deal for some data transfers, without a daemon",
+		ShortDescription: `
+Imports a file as client import does, with the same --chunker and --raw-leaves
+options, and writes the CAR file a storage deal for it transfers to <output>.
+Prints the root of the data along with the commitment and padded size of the
+piece. The command runs without a daemon, keeping the blocks in a temporary
+directory until the CAR file is written, so that data can be prepared offline
+and imported later with client import --car.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("file", true, false, "Path to the file to write as a CAR file").EnableStdin(),
+		cmdkit.StringArg("output", true, false, "Path of the CAR file to write"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("chunker", "How to split the data into leaves: size-<bytes>, rabin, rabin-<avg> or rabin-<min>-<avg>-<max>"),
+		cmdkit.BoolOption("raw-leaves", "Store the leaves as raw blocks"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		fi, err := requestFile(req)
+		if err != nil {
+			return err
+		}
+
+		tmp, err := ioutil.TempDir("", "generate-car")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = os.RemoveAll(tmp) }()
+		ds, err := badgerds.NewDatastore(tmp, nil)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = ds.Close() }()
+		bs := blockstore.NewBlockstore(ds)
+		d := dag.NewDAG(merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs))), bs)
+
+		nd, err := d.ImportData(req.Context, fi, importOptions(req))
+		if err != nil {
+			return err
+		}
+
+		out, err := os.Create(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		if err := d.WriteCar(req.Context, nd.Cid(), out); err != nil {
+			_ = out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+
+		info, err := d.PieceInfo(req.Context, nd.Cid())
+		if err != nil {
+			return err
+		}
+		return re.Emit(info)
+	},
+	Type: dag.PieceInfo{},
+}
+
+// requestFile returns the file given as the first argument of a request.
+func requestFile(req *cmds.Request) (files.File, error) {
+	iter := req.Files.Entries()
+	if !iter.Next() {
+		return nil, fmt.Errorf("no file given: %s", iter.Err())
+	}
+
+	fi, ok := iter.Node().(files.File)
+	if !ok {
+		return nil, fmt.Errorf("given file was not a files.File")
+	}
+	return fi, nil
+}
+
+func importOptions(req *cmds.Request) dag.ImportOptions {
+	chunker, _ := req.Options["chunker"].(string)
+	rawLeaves, _ := req.Options["raw-leaves"].(bool)
+	return dag.ImportOptions{Chunker: chunker, RawLeaves: rawLeaves}
+}
+
 var ClientProposeStorageDealCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline:          "Propose a storage deal with a storage miner",
@@ -102,6 +254,13 @@ Start and end should be specified with the number of blocks for which to store t
 data. New blocks are generated about every 30 seconds, so the time given should
 be represented as a count of 30 second intervals. For example, 1 minute would
 be 2, 1 hour would be 120, and 1 day would be 2880.
+
+With --manual the miner does not fetch the data over the network but waits for
+it to be imported with deals import-data, so that large amounts of data can be
+shipped to the miner offline as the CAR file client generate-car writes. The
+piece commitment and padded size of the data are computed from the imported
+data unless they are given with --piece-cid and --piece-size, as printed by
+client generate-car.
 `,
 	},
 	Arguments: []cmdkit.Argument{
@@ -114,6 +273,9 @@ be 2, 1 hour would be 120, and 1 day would be 2880.
 	},
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("peerid", "Override miner's peer id stored on chain"),
+		cmdkit.BoolOption("manual", "Transfer the data to the miner offline rather than over the network"),
+		cmdkit.StringOption("piece-cid", "Commitment of the piece the deal stores, if already computed"),
+		cmdkit.Uint64Option("piece-size", "Padded size of the piece the deal stores, if already computed"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		addr, err := GetPorcelainAPI(env).WalletDefaultAddress()
@@ -121,7 +283,7 @@ be 2, 1 hour would be 120, and 1 day would be 2880.
 			return err
 		}
 
-		maddr, err := address.NewFromString(req.Arguments[0])
+		maddr, err := resolveAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -133,9 +295,9 @@ be 2, 1 hour would be 120, and 1 day would be 2880.
 			return errors.Wrap(err, "could not decode data cid")
 		}
 
-		data := &storagemarket.DataRef{
-			TransferType: "graphsync",
-			Root:         dataCID,
+		data, err := dealDataRef(req, env, dataCID)
+		if err != nil {
+			return err
 		}
 
 		status, err := GetPorcelainAPI(env).MinerGetStatus(req.Context, maddr, chainHead)
@@ -200,6 +362,191 @@ be 2, 1 hour would be 120, and 1 day would be 2880.
 	Type: storagemarket.ProposeStorageDealResult{},
 }
 
+// dealDataRef describes the data a proposed storage deal stores and how it is
+// transferred to the miner. Deals with a manual transfer must carry the piece
+// commitment and size, which are computed from the imported data if not given.
+func dealDataRef(req *cmds.Request, env cmds.Environment, root cid.Cid) (*storagemarket.DataRef, error) {
+	data := &storagemarket.DataRef{
+		TransferType: storagemarket.TTGraphsync,
+		Root:         root,
+	}
+	if manual, _ := req.Options["manual"].(bool); manual {
+		data.TransferType = storagemarket.TTManual
+	}
+
+	pieceCidStr, _ := req.Options["piece-cid"].(string)
+	pieceSize, hasSize := req.Options["piece-size"].(uint64)
+	if pieceCidStr == "" {
+		if hasSize {
+			return nil, errors.New("--piece-size requires --piece-cid")
+		}
+		if data.TransferType != storagemarket.TTManual {
+			return data, nil
+		}
+		info, err := GetPorcelainAPI(env).DAGPieceInfo(req.Context, root)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not compute piece commitment")
+		}
+		data.PieceCid = &info.PieceCID
+		data.PieceSize = info.PieceSize.Unpadded()
+		return data, nil
+	}
+
+	pieceCid, err := cid.Decode(pieceCidStr)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode piece cid")
+	}
+	if !hasSize {
+		return nil, errors.New("--piece-cid requires --piece-size")
+	}
+	padded := abi.PaddedPieceSize(pieceSize)
+	if err := padded.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid piece size")
+	}
+	data.PieceCid = &pieceCid
+	data.PieceSize = padded.Unpadded()
+	return data, nil
+}
+
+// dealPollInterval is how often client deal checks the states of its deals.
+const dealPollInterval = 10 * time.Second
+
+// clientDealAPI is the porcelain and storage APIs client deal finds miners
+// and makes deals with.
+type clientDealAPI struct {
+	*porcelain.API
+	storage *storage.API
+}
+
+func (a clientDealAPI) ListStorageProviders(ctx context.Context) ([]storagemarket.StorageProviderInfo, error) {
+	return a.storage.ListStorageProviders(ctx)
+}
+
+func (a clientDealAPI) GetStorageAsk(ctx context.Context, info storagemarket.StorageProviderInfo) (*storagemarket.StorageAsk, error) {
+	return a.storage.GetStorageAsk(ctx, info)
+}
+
+func (a clientDealAPI) ProposeStorageDeal(
+	ctx context.Context,
+	addr address.Address,
+	info *storagemarket.StorageProviderInfo,
+	data *storagemarket.DataRef,
+	startEpoch abi.ChainEpoch,
+	endEpoch abi.ChainEpoch,
+	price abi.TokenAmount,
+	collateral abi.TokenAmount,
+	rt abi.RegisteredProof,
+) (*storagemarket.ProposeStorageDealResult, error) {
+	return a.storage.ProposeStorageDeal(ctx, addr, info, data, startEpoch, endEpoch, price, collateral, rt)
+}
+
+func (a clientDealAPI) GetStorageDeal(ctx context.Context, c cid.Cid) (storagemarket.ClientDeal, error) {
+	return a.storage.GetStorageDeal(ctx, c)
+}
+
+var clientDealCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Store data with several miners, chosen by their asks and history",
+		ShortDescription: `
+Proposes storage deals for imported data to as many miners as --replicas and
+waits for the deals to become active. Miners whose asks accept the piece of the
+data and who answer pings are ranked by the price of their ask, then by fewest
+faulty sectors, then by most power and then by ping latency. Deals are proposed
+to the best ranked miners, and to the next best miners in place of the miners
+that reject or fail their deals. Every change in the state of a deal is printed.
+
+Start and end are chain epochs, as for propose-storage-deal, as are --manual,
+--piece-cid and --piece-size. Deals made with --manual wait for their data to be
+imported by each miner with deals import-data.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("data", true, false, "CID of the data to be stored"),
+		cmdkit.StringArg("start", true, false, "Chain epoch at which deals should start"),
+		cmdkit.StringArg("end", true, false, "Chain epoch at which deals should end"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.UintOption("replicas", "Number of miners to store the data with").WithDefault(uint(1)),
+		cmdkit.StringOption("max-price", "Highest ask price per GiB per epoch in FIL to accept"),
+		cmdkit.BoolOption("manual", "Transfer the data to the miners offline rather than over the network"),
+		cmdkit.StringOption("piece-cid", "Commitment of the piece the deals store, if already computed"),
+		cmdkit.Uint64Option("piece-size", "Padded size of the piece the deals store, if already computed"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		api := clientDealAPI{GetPorcelainAPI(env), GetStorageAPI(env)}
+		addr, err := api.WalletDefaultAddress()
+		if err != nil {
+			return err
+		}
+
+		dataCID, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return errors.Wrap(err, "could not decode data cid")
+		}
+		start, err := strconv.ParseUint(req.Arguments[1], 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "could not parse deal start")
+		}
+		end, err := strconv.ParseUint(req.Arguments[2], 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "could not parse deal end")
+		}
+		replicas, _ := req.Options["replicas"].(uint)
+		if replicas == 0 {
+			return errors.New("--replicas must be at least 1")
+		}
+
+		data, err := dealDataRef(req, env, dataCID)
+		if err != nil {
+			return err
+		}
+		// All miners are proposed the same piece, so its commitment is
+		// computed once up front.
+		if data.PieceCid == nil {
+			info, err := api.DAGPieceInfo(req.Context, dataCID)
+			if err != nil {
+				return errors.Wrap(err, "could not compute piece commitment")
+			}
+			data.PieceCid = &info.PieceCID
+			data.PieceSize = info.PieceSize.Unpadded()
+		}
+		pieceSize := data.PieceSize.Padded()
+
+		offers, err := porcelain.ClientFindMiners(req.Context, api, pieceSize)
+		if err != nil {
+			return err
+		}
+		if maxPriceStr, _ := req.Options["max-price"].(string); maxPriceStr != "" {
+			maxPrice, valid := types.NewAttoFILFromFILString(maxPriceStr)
+			if !valid {
+				return errors.Errorf("could not parse max price %s", maxPriceStr)
+			}
+			maxPiecePrice := porcelain.PiecePrice(maxPrice, pieceSize)
+			affordable := offers[:0]
+			for _, offer := range offers {
+				if !offer.Price.GreaterThan(maxPiecePrice) {
+					affordable = append(affordable, offer)
+				}
+			}
+			offers = affordable
+		}
+
+		params := porcelain.ReplicationParams{
+			Client:       addr,
+			Data:         data,
+			StartEpoch:   abi.ChainEpoch(start),
+			EndEpoch:     abi.ChainEpoch(end),
+			Replicas:     int(replicas),
+			PollInterval: dealPollInterval,
+		}
+		_, err = porcelain.ClientReplicate(req.Context, api, params, offers, func(replica porcelain.DealReplica) {
+			_ = re.Emit(replica)
+		})
+		return err
+	},
+	Type: porcelain.DealReplica{},
+}
+
 var ClientQueryStorageDealCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Query a storage deal's status",
@@ -267,21 +614,25 @@ var clientListAsksCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "List all asks in the storage market",
 		ShortDescription: `
-Lists all asks in the storage market. This command takes no arguments.
+Queries the current asks of the storage miners with power on chain, ordered by
+price, and lists them with their piece sizes and expiry. Miners which tell it
+also list the price verified deals must pay at least. Miners which cannot be
+reached are left out. This command takes no arguments.
 `,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		// Nodes which do not mine have no ask of their own.
 		minerAddr, err := GetBlockAPI(env).MinerAddress()
 		if err != nil {
-			return err
+			minerAddr = address.Undef
 		}
 
-		asks, err := GetStorageAPI(env).ListAsks(minerAddr)
+		asks, err := GetStorageAPI(env).ListStorageAsks(req.Context, minerAddr)
 		if err != nil {
 			return err
 		}
 
 		return re.Emit(asks)
 	},
-	Type: []*storagemarket.SignedStorageAsk{},
+	Type: []storage.StorageAsk{},
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/node/test"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/dag"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
@@ -44,7 +45,7 @@ func TestProposeDeal(t *testing.T) {
 
 	// import empty 1K of bytes to create piece
 	input := bytes.NewBuffer(make([]byte, 1024))
-	node, err := client.PorcelainAPI.DAGImportData(ctx, input)
+	node, err := client.PorcelainAPI.DAGImportData(ctx, input, dag.ImportOptions{})
 	require.NoError(t, err)
 
 	// propose deal
@@ -19,6 +19,7 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/node"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paths"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/rpcapi"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
@@ -37,6 +38,7 @@ var daemonCmd = &cmds.Command{
 		cmdkit.BoolOption(IsRelay, "advertise and allow filecoin network traffic to be relayed through this node"),
 		cmdkit.StringOption(BlockTime, "period a node waits between mining successive blocks").WithDefault(clock.DefaultEpochDuration.String()),
 		cmdkit.StringOption(PropagationDelay, "time a node waits after the start of an epoch for blocks to arrive").WithDefault(clock.DefaultPropagationDelay.String()),
+		cmdkit.BoolOption(MockProofs, "seal, prove and verify with mock proofs; requires a genesis block generated for mock proofs"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		return daemonRun(req, re)
@@ -82,6 +84,10 @@ func daemonRun(req *cmds.Request, re cmds.ResponseEmitter) error {
 		opts = append(opts, node.IsRelay())
 	}
 
+	if mockProofs, ok := req.Options[MockProofs].(bool); ok && mockProofs {
+		opts = append(opts, node.MockProofs(true))
+	}
+
 	durStr, ok := req.Options[BlockTime].(string)
 	if !ok {
 		return fmt.Errorf("invalid %s: %v", BlockTime, req.Options[BlockTime])
@@ -102,10 +108,12 @@ func daemonRun(req *cmds.Request, re cmds.ResponseEmitter) error {
 	}
 	opts = append(opts, node.PropagationDelay(propDelay))
 
-	journal, err := journal.NewZapJournal(rep.JournalPath())
+	journalCfg := config.Observability.Journal
+	journal, err := journal.NewFileJournal(rep.JournalPath(), journalCfg.MaxFileSize, journalCfg.MaxFiles, clock.NewSystemClock())
 	if err != nil {
 		return err
 	}
+	defer func() { _ = journal.Close() }()
 	opts = append(opts, node.JournalConfigOption(journal))
 
 	// Monkey-patch network parameters option will set package variables during node build
@@ -190,6 +198,13 @@ func RunAPIAndWait(ctx context.Context, nd *node.Node, config *config.APIConfig,
 	handler := http.NewServeMux()
 	handler.Handle("/debug/pprof/", http.DefaultServeMux)
 	handler.Handle(APIPrefix+"/", cmdhttp.NewHandler(servenv, rootCmdDaemon, cfg))
+	rpcServer, err := rpcapi.NewServer(config.AccessControlAllowOrigin, manet.IsIPLoopback(apiListener.Multiaddr()), nd.PorcelainAPI, nd.StorageAPI)
+	if err != nil {
+		return err
+	}
+	handler.Handle(rpcapi.Path, rpcServer)
+	handler.HandleFunc("/health/live", serveLiveness)
+	handler.Handle("/health/ready", readinessHandler(nd.PorcelainAPI))
 
 	apiserv := http.Server{
 		Handler: handler,
@@ -10,6 +10,8 @@ import (
 	"github.com/ipfs/go-cid"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage/tracker"
 )
 
 const (
@@ -22,8 +24,10 @@ var dealsCmd = &cmds.Command{
 		Tagline: "Manage and inspect deals made by or with this node",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"list": dealsListCmd,
-		"show": dealsShowCmd,
+		"import-data": dealsImportDataCmd,
+		"list":        dealsListCmd,
+		"show":        dealsShowCmd,
+		"status":      dealsStatusCmd,
 	},
 }
 
@@ -35,6 +39,9 @@ type DealsListResult struct {
 	IsMiner     bool            `json:"isMiner"`
 	State       string          `json:"state"`
 	Message     string          `json:"message"`
+	// ChainStatus is the status of published deals on chain.
+	ChainStatus  tracker.Status `json:"chainStatus,omitempty"`
+	NeedsRestore bool           `json:"needsRestore,omitempty"`
 }
 
 var dealsListCmd = &cmds.Command{
@@ -69,24 +76,32 @@ deals, active deals, finished deals and cancelled deals.
 		}
 		formattedDeals := []DealsListResult{}
 		for _, deal := range clientDeals {
-			formattedDeals = append(formattedDeals, DealsListResult{
+			result := DealsListResult{
 				Miner:       deal.Proposal.Provider,
 				PieceCid:    deal.Proposal.PieceCID,
 				ProposalCid: deal.ProposalCid,
 				IsMiner:     false,
 				State:       storagemarket.DealStates[deal.State],
 				Message:     deal.Message,
-			})
+			}
+			if status, ok := GetStorageAPI(env).DealChainStatus(tracker.Client, deal.ProposalCid); ok {
+				result.ChainStatus, result.NeedsRestore = status.Status, status.NeedsRestore
+			}
+			formattedDeals = append(formattedDeals, result)
 		}
 		for _, deal := range minerDeals {
-			formattedDeals = append(formattedDeals, DealsListResult{
+			result := DealsListResult{
 				Miner:       deal.Proposal.Provider,
 				PieceCid:    deal.Proposal.PieceCID,
 				ProposalCid: deal.ProposalCid,
 				IsMiner:     true,
 				State:       storagemarket.DealStates[deal.State],
 				Message:     deal.Message,
-			})
+			}
+			if status, ok := GetStorageAPI(env).DealChainStatus(tracker.Provider, deal.ProposalCid); ok {
+				result.ChainStatus, result.NeedsRestore = status.Status, status.NeedsRestore
+			}
+			formattedDeals = append(formattedDeals, result)
 		}
 		return re.Emit(formattedDeals)
 	},
@@ -114,3 +129,71 @@ var dealsShowCmd = &cmds.Command{
 	},
 	Type: storagemarket.ClientDeal{},
 }
+
+var dealsStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the status on chain of published deals",
+		ShortDescription: `
+Shows the status on chain of the published deals made by or with this node, or
+of the deal with proposal CID <proposal-cid>. The status of a deal is published
+until its sector is proven, then active until it expires or is slashed. Active
+deals show the sector hosting them and whether the sector is faulty. Deals which
+are slashed or terminated early need their data stored again.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("proposal-cid", false, false, "CID of the deal proposal"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		statuses := GetStorageAPI(env).DealChainStatuses()
+		if len(req.Arguments) == 0 {
+			return re.Emit(statuses)
+		}
+
+		proposalCid, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid cid "+req.Arguments[0])
+		}
+		matching := []tracker.DealStatus{}
+		for _, status := range statuses {
+			if status.ProposalCid.Equals(proposalCid) {
+				matching = append(matching, status)
+			}
+		}
+		if len(matching) == 0 {
+			return fmt.Errorf("deal %s is not published or not made by or with this node", proposalCid)
+		}
+		return re.Emit(matching)
+	},
+	Type: []tracker.DealStatus{},
+}
+
+var dealsImportDataCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Import the data of an offline deal made with this miner",
+		ShortDescription: `
+Imports the data of a deal proposed to this miner with a manual transfer, which
+waits for its data once accepted. The file must be the CAR file of the data, as
+written by client generate-car. The deal proceeds to be sealed if the
+commitment of the file matches the piece commitment of the proposal.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("proposal-cid", true, false, "CID of the deal proposal"),
+		cmdkit.FileArg("file", true, false, "Path to the CAR file of the deal data").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		proposalCid, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid cid "+req.Arguments[0])
+		}
+
+		fi, err := requestFile(req)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = fi.Close() }()
+
+		return GetStorageAPI(env).ImportDataForDeal(req.Context, proposalCid, fi)
+	},
+}
@@ -18,6 +18,7 @@ import (
 
 	commands "github.com/filecoin-project/go-filecoin/cmd/go-filecoin"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/node/test"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/dag"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
@@ -134,12 +135,12 @@ func createDeal(ctx context.Context, t *testing.T) (*test.Client, *test.Client,
 
 	// import some data to create first piece
 	input1 := bytes.NewBuffer([]byte("HODLHODLHODL"))
-	node1, err := client.PorcelainAPI.DAGImportData(ctx, input1)
+	node1, err := client.PorcelainAPI.DAGImportData(ctx, input1, dag.ImportOptions{})
 	require.NoError(t, err)
 
 	// import some data to create second piece
 	input2 := bytes.NewBuffer([]byte("FREEASINBEER"))
-	node2, err := client.PorcelainAPI.DAGImportData(ctx, input2)
+	node2, err := client.PorcelainAPI.DAGImportData(ctx, input2, dag.ImportOptions{})
 	require.NoError(t, err)
 
 	// propose 2 deals
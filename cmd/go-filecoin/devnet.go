@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/devnet"
+)
+
+var devnetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Run a local network of mining nodes in a single process",
+		ShortDescription: `Generates a genesis block with --miners presealed miners and runs a mining
+node for each of them in this process, connected over an in-memory network.
+Each node serves its API on its own port, starting at --api-port, and keeps its
+repo in a subdirectory of --dir, so other commands can target a node with
+--repodir=<dir>/node-<i>.
+
+The network uses mock proofs, so it can be brought up in seconds with a short
+block time.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.UintOption("miners", "number of mining nodes").WithDefault(uint(3)),
+		cmdkit.UintOption("sectors", "number of presealed sectors committed to each miner").WithDefault(uint(2)),
+		cmdkit.UintOption("api-port", "API port of the first node; node i listens on api-port+i, or an OS chosen port if 0").WithDefault(uint(3453)),
+		cmdkit.StringOption("dir", "directory for the nodes' repos; defaults to a new temporary directory"),
+		cmdkit.StringOption(BlockTime, "period a node waits between mining successive blocks").WithDefault("2s"),
+		cmdkit.StringOption(PropagationDelay, "time a node waits after the start of an epoch for blocks to arrive").WithDefault("500ms"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return devnetRun(req, re)
+	},
+}
+
+func devnetRun(req *cmds.Request, re cmds.ResponseEmitter) error {
+	miners, _ := req.Options["miners"].(uint)
+	sectors, _ := req.Options["sectors"].(uint)
+	apiPort, _ := req.Options["api-port"].(uint)
+
+	blockTime, err := durationOption(req, BlockTime)
+	if err != nil {
+		return err
+	}
+	propDelay, err := durationOption(req, PropagationDelay)
+	if err != nil {
+		return err
+	}
+
+	dir, _ := req.Options["dir"].(string)
+	if dir == "" {
+		if dir, err = ioutil.TempDir("", "go-filecoin-devnet"); err != nil {
+			return err
+		}
+	}
+
+	dn, err := devnet.New(req.Context, devnet.Config{
+		Miners:           int(miners),
+		SectorsPerMiner:  int(sectors),
+		BlockTime:        blockTime,
+		PropagationDelay: propDelay,
+		APIPort:          int(apiPort),
+		Dir:              dir,
+		Seed:             time.Now().UnixNano(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := dn.Start(req.Context); err != nil {
+		return err
+	}
+	defer dn.Stop(req.Context)
+
+	// Run an API server around each node.
+	errs := make(chan error, len(dn.Nodes))
+	terminates := make([]chan os.Signal, len(dn.Nodes))
+	for i, nd := range dn.Nodes {
+		ready := make(chan interface{}, 1)
+		terminates[i] = make(chan os.Signal, 1)
+		go func(i int, ready chan interface{}) {
+			errs <- RunAPIAndWait(req.Context, dn.Nodes[i], dn.Nodes[i].Repo.Config().API, ready, terminates[i])
+		}(i, ready)
+
+		select {
+		case <-ready:
+		case err := <-errs:
+			return err
+		}
+		_ = re.Emit(fmt.Sprintf("node %d: miner %s, peer %s, API %s, repo %s\n",
+			i, dn.Miners[i], nd.Host().ID().Pretty(), nd.Repo.Config().API.Address, filepath.Join(dir, fmt.Sprintf("node-%d", i))))
+	}
+	_ = re.Emit(fmt.Sprintf("devnet with genesis %s running with %d miners\n", dn.Genesis, len(dn.Nodes)))
+
+	var terminate = make(chan os.Signal, 1)
+	signal.Notify(terminate, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(terminate)
+
+	select {
+	case <-terminate:
+	case err := <-errs:
+		if err != nil {
+			return err
+		}
+	}
+	for _, t := range terminates {
+		close(t)
+	}
+	return nil
+}
+
+func durationOption(req *cmds.Request, name string) (time.Duration, error) {
+	durStr, ok := req.Options[name].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid %s: %v", name, req.Options[name])
+	}
+	d, err := time.ParseDuration(durStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, durStr)
+	}
+	return d, nil
+}
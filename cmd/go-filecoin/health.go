@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+)
+
+type nodeHealthAPI interface {
+	NodeHealth(ctx context.Context) (*porcelain.NodeHealth, error)
+}
+
+// serveLiveness responds to liveness probes. The daemon is live whenever its
+// API server is answering requests.
+func serveLiveness(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// readinessHandler responds to readiness probes with the node's health checks,
+// using status 503 while any check fails.
+func readinessHandler(api nodeHealthAPI) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		health, err := api.NodeHealth(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if health.Ready {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(health)
+	})
+}
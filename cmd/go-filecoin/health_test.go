@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+type fakeNodeHealthAPI struct {
+	health *porcelain.NodeHealth
+}
+
+func (f *fakeNodeHealthAPI) NodeHealth(_ context.Context) (*porcelain.NodeHealth, error) {
+	return f.health, nil
+}
+
+func TestReadinessHandler(t *testing.T) {
+	tf.UnitTest(t)
+
+	probe := func(health *porcelain.NodeHealth) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		readinessHandler(&fakeNodeHealthAPI{health}).ServeHTTP(rec, httptest.NewRequest("GET", "/health/ready", nil))
+		return rec
+	}
+
+	ready := probe(&porcelain.NodeHealth{Ready: true, Checks: []porcelain.HealthCheck{{Name: "sync", OK: true}}})
+	assert.Equal(t, http.StatusOK, ready.Code)
+
+	notReady := probe(&porcelain.NodeHealth{Checks: []porcelain.HealthCheck{{Name: "peers", Detail: "0 peers connected (min 1)"}}})
+	assert.Equal(t, http.StatusServiceUnavailable, notReady.Code)
+	var body porcelain.NodeHealth
+	require.NoError(t, json.NewDecoder(notReady.Body).Decode(&body))
+	assert.Equal(t, "peers", body.Checks[0].Name)
+	assert.False(t, body.Checks[0].OK)
+}
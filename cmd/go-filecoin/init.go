@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 
 	"github.com/filecoin-project/go-address"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
@@ -27,6 +28,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/genesis"
 	drandapi "github.com/filecoin-project/go-filecoin/internal/pkg/protocol/drand"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet/hd"
 	gengen "github.com/filecoin-project/go-filecoin/tools/gengen/util"
 )
 
@@ -40,6 +42,7 @@ var initCmd = &cmds.Command{
 		cmdkit.StringOption(GenesisFile, "path of file or HTTP(S) URL containing archive of genesis block DAG data"),
 		cmdkit.StringOption(PeerKeyFile, "path of file containing key to use for new node's libp2p identity"),
 		cmdkit.StringOption(WalletKeyFile, "path of file containing keys to import into the wallet on initialization"),
+		cmdkit.StringOption(WalletMnemonicFile, "path of file containing a mnemonic, and optionally a passphrase on the next line, to derive the wallet's addresses from"),
 		cmdkit.StringOption(OptionSectorDir, "path of directory into which staged and sealed sectors will be written"),
 		cmdkit.StringOption(MinerActorAddress, "when set, sets the daemons's miner actor address to the provided address"),
 		cmdkit.UintOption(AutoSealIntervalSeconds, "when set to a number > 0, configures the daemon to check for and seal any staged sectors on an interval.").WithDefault(uint(120)),
@@ -75,7 +78,8 @@ var initCmd = &cmds.Command{
 
 		peerKeyFile, _ := req.Options[PeerKeyFile].(string)
 		walletKeyFile, _ := req.Options[WalletKeyFile].(string)
-		initopts, err := getNodeInitOpts(peerKeyFile, walletKeyFile)
+		walletMnemonicFile, _ := req.Options[WalletMnemonicFile].(string)
+		initopts, err := getNodeInitOpts(peerKeyFile, walletKeyFile, walletMnemonicFile)
 		if err != nil {
 			return err
 		}
@@ -198,7 +202,7 @@ func loadGenesis(ctx context.Context, rep repo.Repo, sourceName string) (genesis
 
 }
 
-func getNodeInitOpts(peerKeyFile string, walletKeyFile string) ([]node.InitOpt, error) {
+func getNodeInitOpts(peerKeyFile string, walletKeyFile string, walletMnemonicFile string) ([]node.InitOpt, error) {
 	var initOpts []node.InitOpt
 	if peerKeyFile != "" {
 		data, err := ioutil.ReadFile(peerKeyFile)
@@ -232,6 +236,23 @@ func getNodeInitOpts(peerKeyFile string, walletKeyFile string) ([]node.InitOpt,
 		}
 	}
 
+	if walletMnemonicFile != "" {
+		data, err := ioutil.ReadFile(walletMnemonicFile)
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.SplitN(strings.TrimSpace(string(data)), "\n", 2)
+		passphrase := ""
+		if len(lines) > 1 {
+			passphrase = strings.TrimSpace(lines[1])
+		}
+		seed, err := hd.SeedFromMnemonic(lines[0], passphrase)
+		if err != nil {
+			return nil, err
+		}
+		initOpts = append(initOpts, node.WalletSeedOpt(seed))
+	}
+
 	return initOpts, nil
 }
 
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
+)
+
+var journalCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Query the daemon's journal of notable events.",
+		ShortDescription: `
+The journal records structured events from the node's subsystems, such as head
+changes and reorgs at or above the fork monitor's alert depth (topic chainsync), mined blocks (mining), deal state
+transitions (storagemarket), PoSt submissions (poster), sent messages (outbox)
+and peers disconnected for a bad genesis (discovery). It is kept on disk in the repo as a bounded set of rotated files.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"query": journalQueryCmd,
+		"tail":  journalTailCmd,
+	},
+}
+
+var journalQueryCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List recorded journal events, oldest first.",
+		ShortDescription: `
+Lists the events retained in the journal. --since accepts either an RFC3339
+time or a duration, such as 1h30m, counted back from now.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("topic", "only list events recorded for this topic"),
+		cmdkit.StringOption("event", "only list events with this name"),
+		cmdkit.StringOption("since", "only list events recorded at or after this time"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		filter, err := journalFilterFromOptions(req)
+		if err != nil {
+			return err
+		}
+		entries, err := GetPorcelainAPI(env).JournalQuery(filter)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := re.Emit(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: journal.Entry{},
+}
+
+var journalTailCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Stream journal events as they are recorded.",
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("topic", "only stream events recorded for this topic"),
+		cmdkit.StringOption("event", "only stream events with this name"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		filter, err := journalFilterFromOptions(req)
+		if err != nil {
+			return err
+		}
+		entries, err := GetPorcelainAPI(env).JournalSubscribe(req.Context, filter)
+		if err != nil {
+			return err
+		}
+		for e := range entries {
+			if err := re.Emit(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: journal.Entry{},
+}
+
+func journalFilterFromOptions(req *cmds.Request) (journal.Filter, error) {
+	var filter journal.Filter
+	filter.Topic, _ = req.Options["topic"].(string)
+	filter.Event, _ = req.Options["event"].(string)
+
+	since, _ := req.Options["since"].(string)
+	if since == "" {
+		return filter, nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		filter.Since = t
+	} else if d, err := time.ParseDuration(since); err == nil {
+		filter.Since = time.Now().Add(-d)
+	} else {
+		return journal.Filter{}, fmt.Errorf("invalid since %q: expected an RFC3339 time or a duration", since)
+	}
+	return filter, nil
+}
@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"strings"
 	"syscall"
 
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
@@ -66,6 +67,11 @@ const (
 	// with testing as we won't be able to set blocktime in production.
 	BlockTime = "block-time"
 
+	// MockProofs runs the daemon with mock sealing, PoSt generation and
+	// verification. It may only be used with a genesis block generated for
+	// mock proofs.
+	MockProofs = "mock-proofs"
+
 	// PropagationDelay is the duration the miner will wait for blocks to arrive before attempting to mine a new one
 	PropagationDelay = "prop-delay"
 
@@ -75,6 +81,9 @@ const (
 	// WalletKeyFile is the path of file containing wallet keys that may be imported on initialization
 	WalletKeyFile = "wallet-keyfile"
 
+	// WalletMnemonicFile is the path of a file containing a mnemonic to derive wallet addresses from
+	WalletMnemonicFile = "wallet-mnemonic-file"
+
 	// MinerActorAddress when set, sets the daemons's miner address to the provided address
 	MinerActorAddress = "miner-actor-address"
 
@@ -109,6 +118,7 @@ START RUNNING FILECOIN
   go-filecoin init                   - Initialize a filecoin repo
   go-filecoin config <key> [<value>] - Get and set filecoin config values
   go-filecoin daemon                 - Start a long-running daemon process
+  go-filecoin devnet                 - Run a local network of mining nodes in one process
   go-filecoin wallet                 - Manage your filecoin wallets
   go-filecoin address                - Interact with addresses
 
@@ -119,12 +129,14 @@ STORE AND RETRIEVE DATA
 MINE
   go-filecoin miner                  - Manage a single miner actor
   go-filecoin mining                 - Manage all mining operations for a node
+  go-filecoin sectors                - Inspect and manage the sectors of a miner
 
 VIEW DATA STRUCTURES
   go-filecoin chain                  - Inspect the filecoin blockchain
   go-filecoin dag                    - Interact with IPLD DAG objects
   go-filecoin deals                  - Manage deals made by or with this node
   go-filecoin show                   - Get human-readable representations of filecoin objects
+  go-filecoin state actor <address>  - Show the decoded state of an actor
 
 NETWORK COMMANDS
   go-filecoin bootstrap              - Interact with bootstrap addresses
@@ -139,6 +151,7 @@ NETWORK COMMANDS
 ACTOR COMMANDS
   go-filecoin actor                  - Interact with actors. Actors are built-in smart contracts
   go-filecoin paych                  - Payment channel operations
+  go-filecoin msig                   - Manage multisig wallets
 
 MESSAGE COMMANDS
   go-filecoin message                - Manage messages
@@ -147,8 +160,10 @@ MESSAGE COMMANDS
 
 TOOL COMMANDS
   go-filecoin inspect                - Show info about the go-filecoin node
+  go-filecoin journal                - Query the journal of notable node events
   go-filecoin leb128                 - Leb128 cli encode/decode
   go-filecoin log                    - Interact with the daemon event log output
+  go-filecoin node status            - Show whether the node is ready and why
   go-filecoin protocol               - Show protocol parameter details
   go-filecoin version                - Show go-filecoin version information
 `,
@@ -171,6 +186,7 @@ var rootCmdDaemon = &cmds.Command{
 // all top level commands, not available to daemon
 var rootSubcmdsLocal = map[string]*cmds.Command{
 	"daemon":  daemonCmd,
+	"devnet":  devnetCmd,
 	"init":    initCmd,
 	"version": versionCmd,
 	"leb128":  leb128Cmd,
@@ -190,17 +206,23 @@ var rootSubcmdsDaemon = map[string]*cmds.Command{
 	"dht":              dhtCmd,
 	"id":               idCmd,
 	"inspect":          inspectCmd,
+	"journal":          journalCmd,
 	"leb128":           leb128Cmd,
 	"log":              logCmd,
 	"message":          msgCmd,
 	"miner":            minerCmd,
 	"mining":           miningCmd,
 	"mpool":            mpoolCmd,
+	"msig":             msigCmd,
+	"node":             nodeCmd,
 	"outbox":           outboxCmd,
+	"paych":            paychCmd,
 	"ping":             pingCmd,
 	"protocol":         protocolCmd,
 	"retrieval-client": retrievalClientCmd,
+	"sectors":          sectorsCmd,
 	"show":             showCmd,
+	"state":            stateCmd,
 	"stats":            statsCmd,
 	"swarm":            swarmCmd,
 	"wallet":           walletCmd,
@@ -328,12 +350,24 @@ func getAPIAddress(req *cmds.Request) (string, error) {
 	return host, nil
 }
 
+// localSubcmds lists the paths of subcommands of daemon commands that the CLI
+// runs itself, without a daemon.
+var localSubcmds = [][]string{
+	{"wallet", "sign-message"},
+	{"client", "generate-car"},
+}
+
 func requiresDaemon(req *cmds.Request) bool {
 	for cmd := range rootSubcmdsLocal {
 		if len(req.Path) > 0 && req.Path[0] == cmd {
 			return false
 		}
 	}
+	for _, path := range localSubcmds {
+		if len(req.Path) == len(path) && strings.Join(req.Path, " ") == strings.Join(path, " ") {
+			return false
+		}
+	}
 	return true
 }
 
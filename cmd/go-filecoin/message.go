@@ -2,8 +2,10 @@ package commands
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/filecoin-project/go-address"
@@ -30,6 +32,7 @@ var msgCmd = &cmds.Command{
 		Tagline: "Send and monitor messages",
 	},
 	Subcommands: map[string]*cmds.Command{
+		"create":     msgCreateCmd,
 		"send":       msgSendCmd,
 		"sendsigned": signedMsgSendCmd,
 		"status":     msgStatusCmd,
@@ -61,7 +64,7 @@ var msgSendCmd = &cmds.Command{
 		// TODO: (per dignifiedquire) add an option to set the nonce and method explicitly
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		target, err := address.NewFromString(req.Arguments[0])
+		target, err := resolveAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -131,14 +134,93 @@ var msgSendCmd = &cmds.Command{
 	Type: &MessageSendResult{},
 }
 
+var msgCreateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create an unsigned message to sign elsewhere",
+		ShortDescription: `
+Builds a message from an address whose key is not held by the daemon, taking
+its nonce from the chain and the outbound queue. Without --gas-limit, the gas
+limit is estimated from recent messages invoking the same method on the same
+type of actor. Sign the message with "wallet sign-message" and send it with
+"message sendsigned".
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("target", true, false, "Address of the actor to send the message to"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address to send the message from"),
+		cmdkit.StringOption("value", "Value to send with message in FIL").WithDefault("0"),
+		cmdkit.Uint64Option("method", "Method to invoke on the target").WithDefault(uint64(builtin.MethodSend)),
+		cmdkit.StringOption("params", "Hex encoded parameters of the method"),
+		priceOption,
+		cmdkit.Int64Option("gas-limit", "Maximum GasUnits this message is allowed to consume; estimated if not set"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		target, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		fromAddr, err := optionalAddrOrLabel(env, req.Options["from"])
+		if err != nil {
+			return err
+		}
+		if fromAddr.Empty() {
+			return errors.New("from address is required")
+		}
+		val, ok := types.NewAttoFILFromFILString(req.Options["value"].(string))
+		if !ok {
+			return errors.New("mal-formed value")
+		}
+		price, ok := req.Options["gas-price"].(string)
+		if !ok {
+			return errors.New("gas-price option is required")
+		}
+		gasPrice, ok := types.NewAttoFILFromFILString(price)
+		if !ok {
+			return errors.New("invalid gas price (specify FIL as a decimal number)")
+		}
+		gasLimit, _ := req.Options["gas-limit"].(int64)
+		method, _ := req.Options["method"].(uint64)
+		var params []byte
+		if p, ok := req.Options["params"].(string); ok {
+			params, err = hex.DecodeString(strings.TrimPrefix(p, "0x"))
+			if err != nil {
+				return errors.Wrap(err, "invalid params")
+			}
+		}
+
+		msg, err := GetPorcelainAPI(env).MessageCreate(req.Context, fromAddr, target, val, gasPrice, gas.NewGas(gasLimit), abi.MethodNum(method), params)
+		if err != nil {
+			return err
+		}
+		return re.Emit(msg)
+	},
+	Type: &types.UnsignedMessage{},
+}
+
+// SignedMessageSendResult is the return type for message sendsigned.
+type SignedMessageSendResult struct {
+	Cid     cid.Cid
+	Message *types.SignedMessage
+	DryRun  bool
+}
+
 var signedMsgSendCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Send a signed message",
+		ShortDescription: `
+Checks the syntax and signature of a signed message, that its nonce is not
+used and that the sender can pay for it, then publishes it. The output shows
+the decoded message; use --dry-run to only check and show it.
+`,
 	},
 	Arguments: []cmdkit.Argument{
-		cmdkit.StringArg("message", true, false, "Signed Json message"),
+		cmdkit.StringArg("message", true, false, "Signed Json message").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("dry-run", "Check and show the message without sending it"),
 	},
-	Options: []cmdkit.Option{},
 
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		msg := req.Arguments[0]
@@ -152,6 +234,18 @@ var signedMsgSendCmd = &cmds.Command{
 		}
 		signed := &m
 
+		if err := GetPorcelainAPI(env).SignedMessageCheck(req.Context, signed); err != nil {
+			return errors.Wrap(err, "refusing to send message")
+		}
+
+		if dryRun, _ := req.Options["dry-run"].(bool); dryRun {
+			c, err := signedMessageCid(signed)
+			if err != nil {
+				return err
+			}
+			return re.Emit(&SignedMessageSendResult{Cid: c, Message: signed, DryRun: true})
+		}
+
 		c, _, err := GetPorcelainAPI(env).SignedMessageSend(
 			req.Context,
 			signed,
@@ -160,13 +254,18 @@ var signedMsgSendCmd = &cmds.Command{
 			return err
 		}
 
-		return re.Emit(&MessageSendResult{
-			Cid:     c,
-			GasUsed: gas.NewGas(0),
-			Preview: false,
-		})
+		return re.Emit(&SignedMessageSendResult{Cid: c, Message: signed})
 	},
-	Type: &MessageSendResult{},
+	Type: &SignedMessageSendResult{},
+}
+
+// signedMessageCid returns the cid a signed message has on chain. BLS messages
+// are included without their signature.
+func signedMessageCid(smsg *types.SignedMessage) (cid.Cid, error) {
+	if smsg.Message.From.Protocol() == address.BLS {
+		return smsg.Message.Cid()
+	}
+	return smsg.Cid()
 }
 
 // WaitResult is the result of a message wait call.
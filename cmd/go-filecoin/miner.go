@@ -3,6 +3,7 @@ package commands
 import (
 	"fmt"
 	"math/big"
+	"strconv"
 
 	address "github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/sector-storage/ffiwrapper"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/constants"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage/asks"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
 )
@@ -26,6 +28,7 @@ var minerCmd = &cmds.Command{
 		Tagline: "Manage a single miner actor",
 	},
 	Subcommands: map[string]*cmds.Command{
+		"ask":           minerAskCmd,
 		"create":        minerCreateCmd,
 		"status":        minerStatusCommand,
 		"set-price":     minerSetPriceCmd,
@@ -182,6 +185,185 @@ This command waits for the ask to be mined.`,
 	Type: &MinerSetPriceResult{},
 }
 
+var minerAskCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the storage ask of the miner",
+		ShortDescription: `
+The ask of the miner offers storage at a price per GiB per epoch for pieces
+within a range of sizes, and is valid for a number of epochs. The node signs a
+new ask with the same terms before the current one expires, and applies the
+terms scheduled for an epoch once the chain reaches it.
+
+Verified deals must also pay the verified deal price. The signed ask carries the
+regular price only, which every deal must pay; the node tells other nodes the
+verified deal price on a separate protocol, and 'client list-asks' shows both.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"schedule":   minerAskScheduleCmd,
+		"set":        minerAskSetCmd,
+		"show":       minerAskShowCmd,
+		"unschedule": minerAskUnscheduleCmd,
+	},
+}
+
+var askTermsOptions = []cmdkit.Option{
+	cmdkit.StringOption("verified-price", "Price in FIL per GiB per epoch verified deals must pay at least"),
+	cmdkit.Uint64Option("min-piece-size", "Smallest padded piece size accepted, in bytes"),
+	cmdkit.Uint64Option("max-piece-size", "Largest padded piece size accepted, in bytes"),
+}
+
+var minerAskShowCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the current ask of the miner, its terms and the changes scheduled",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		status, err := GetStorageAPI(env).AskStatus()
+		if err != nil {
+			return err
+		}
+		return re.Emit(status)
+	},
+	Type: asks.Status{},
+}
+
+var minerAskSetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Sign a new ask of the miner",
+		ShortDescription: `
+Signs a new ask at a price, valid for a number of epochs. Terms not given keep
+their current value.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("price", true, false, "Price in FIL per GiB per epoch"),
+		cmdkit.StringArg("duration", true, false, "Number of epochs each signed ask is valid for"),
+	},
+	Options: askTermsOptions,
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		terms, err := askTerms(req, env, req.Arguments[0], req.Arguments[1])
+		if err != nil {
+			return err
+		}
+		if err := GetStorageAPI(env).SetAskTerms(terms); err != nil {
+			return err
+		}
+		status, err := GetStorageAPI(env).AskStatus()
+		if err != nil {
+			return err
+		}
+		return re.Emit(status)
+	},
+	Type: asks.Status{},
+}
+
+var minerAskScheduleCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Schedule the terms of the miner's ask to change at an epoch",
+		ShortDescription: `
+Schedules a new ask to be signed once the chain reaches an epoch, replacing the
+change scheduled at that epoch if any. Terms not given keep the value they have
+when the change is scheduled, not the value they have when it applies.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("epoch", true, false, "Epoch from which the terms apply"),
+		cmdkit.StringArg("price", true, false, "Price in FIL per GiB per epoch"),
+		cmdkit.StringArg("duration", true, false, "Number of epochs each signed ask is valid for"),
+	},
+	Options: askTermsOptions,
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		epoch, err := parseEpoch(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		terms, err := askTerms(req, env, req.Arguments[1], req.Arguments[2])
+		if err != nil {
+			return err
+		}
+		if err := GetStorageAPI(env).ScheduleAsk(epoch, terms); err != nil {
+			return err
+		}
+		status, err := GetStorageAPI(env).AskStatus()
+		if err != nil {
+			return err
+		}
+		return re.Emit(status)
+	},
+	Type: asks.Status{},
+}
+
+var minerAskUnscheduleCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Cancel the change of the miner's ask scheduled at an epoch",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("epoch", true, false, "Epoch of the scheduled change"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		epoch, err := parseEpoch(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		if err := GetStorageAPI(env).UnscheduleAsk(epoch); err != nil {
+			return err
+		}
+		status, err := GetStorageAPI(env).AskStatus()
+		if err != nil {
+			return err
+		}
+		return re.Emit(status)
+	},
+	Type: asks.Status{},
+}
+
+// askTerms reads the terms of an ask from the arguments and options of a
+// command, keeping the current value of the terms not given. Scheduled terms
+// are filled in when scheduled, so a change signed in between is not carried
+// over to them.
+func askTerms(req *cmds.Request, env cmds.Environment, priceArg, durationArg string) (asks.Terms, error) {
+	status, err := GetStorageAPI(env).AskStatus()
+	if err != nil {
+		return asks.Terms{}, err
+	}
+	terms := asks.Terms{}
+	if status.Terms != nil {
+		terms = *status.Terms
+	}
+
+	price, ok := types.NewAttoFILFromFILString(priceArg)
+	if !ok {
+		return asks.Terms{}, ErrInvalidPrice
+	}
+	terms.Price = price
+	terms.Duration, err = parseEpoch(durationArg)
+	if err != nil {
+		return asks.Terms{}, err
+	}
+
+	if verifiedPrice, ok := req.Options["verified-price"].(string); ok {
+		terms.VerifiedPrice, ok = types.NewAttoFILFromFILString(verifiedPrice)
+		if !ok {
+			return asks.Terms{}, ErrInvalidPrice
+		}
+	}
+	if size, ok := req.Options["min-piece-size"].(uint64); ok {
+		terms.MinPieceSize = abi.PaddedPieceSize(size)
+	}
+	if size, ok := req.Options["max-piece-size"].(uint64); ok {
+		terms.MaxPieceSize = abi.PaddedPieceSize(size)
+	}
+	return terms, nil
+}
+
+func parseEpoch(arg string) (abi.ChainEpoch, error) {
+	epoch, err := strconv.ParseUint(arg, 10, 63)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid epoch "+arg)
+	}
+	return abi.ChainEpoch(epoch), nil
+}
+
 // MinerUpdatePeerIDResult is the return type for miner update-peerid command
 type MinerUpdatePeerIDResult struct {
 	Cid     cid.Cid
@@ -205,7 +387,7 @@ var minerUpdatePeerIDCmd = &cmds.Command{
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		minerAddr, err := address.NewFromString(req.Arguments[0])
+		minerAddr, err := resolveAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -274,7 +456,7 @@ var minerStatusCommand = &cmds.Command{
 		Tagline: "Get the status of a miner",
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		minerAddr, err := optionalAddr(req.Arguments[0])
+		minerAddr, err := resolveAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -305,7 +487,7 @@ var minerSetWorkerAddressCmd = &cmds.Command{
 		limitOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		newWorker, err := address.NewFromString(req.Arguments[0])
+		newWorker, err := resolveAddr(env, req.Arguments[0])
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,261 @@
+package commands
+
+import (
+	"context"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+)
+
+// MsigCreateResult is the return type for msig create.
+type MsigCreateResult struct {
+	Address address.Address
+}
+
+// MsigProposeResult is the return type for msig propose.
+type MsigProposeResult struct {
+	TxnID int64
+}
+
+var msigCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage multisig wallets",
+		ShortDescription: `
+A multisig actor holds funds that are spent by transactions approved by a
+threshold of its signers. Any signer proposes a transaction, which counts as
+its first approval, and the transaction executes when enough signers approve.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"create":  msigCreateCmd,
+		"propose": msigProposeCmd,
+		"approve": msigApproveCmd,
+		"cancel":  msigCancelCmd,
+		"inspect": msigInspectCmd,
+	},
+}
+
+var msigCreateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create a multisig wallet",
+		ShortDescription: `
+Creates a multisig actor controlled by the given signers through the init
+actor and waits for it to appear on chain. The value sent becomes the initial
+balance, which unlocks linearly over --unlock-duration epochs.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("signers", true, true, "Addresses of the signers"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Int64Option("required", "Number of approvals a transaction needs; defaults to all signers"),
+		cmdkit.StringOption("value", "Initial balance of the wallet in FIL").WithDefault("0"),
+		cmdkit.Int64Option("unlock-duration", "Number of epochs over which the initial balance unlocks").WithDefault(int64(0)),
+		cmdkit.StringOption("from", "Address to send the creation message from"),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		signers := make([]address.Address, len(req.Arguments))
+		for i, arg := range req.Arguments {
+			signer, err := resolveAddr(env, arg)
+			if err != nil {
+				return errors.Wrapf(err, "invalid signer %s", arg)
+			}
+			signers[i] = signer
+		}
+		threshold, ok := req.Options["required"].(int64)
+		if !ok {
+			threshold = int64(len(signers))
+		}
+		value, ok := types.NewAttoFILFromFILString(req.Options["value"].(string))
+		if !ok {
+			return errors.New("mal-formed value")
+		}
+		unlockDuration, _ := req.Options["unlock-duration"].(int64)
+
+		fromAddr, err := fromAddrOrDefault(req, env)
+		if err != nil {
+			return err
+		}
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		addr, err := GetPorcelainAPI(env).MultisigCreate(req.Context, fromAddr, signers, threshold, abi.ChainEpoch(unlockDuration), value, gasPrice, gasLimit)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&MsigCreateResult{Address: addr})
+	},
+	Type: &MsigCreateResult{},
+}
+
+var msigProposeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Propose a transaction from a multisig wallet",
+		ShortDescription: `
+Proposes that the multisig wallet sends <value> FIL to <target>, optionally
+invoking --method with hex encoded --params, and waits for the proposal to
+appear on chain. Prints the ID signers pass to approve or cancel it.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("wallet", true, false, "Address of the multisig wallet"),
+		cmdkit.StringArg("target", true, false, "Address to send the value to"),
+		cmdkit.StringArg("value", true, false, "Value to send in FIL"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("method", "Method to invoke on the target"),
+		cmdkit.StringOption("params", "Hex encoded parameters of the method"),
+		cmdkit.StringOption("from", "Signer proposing the transaction"),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		msigAddr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		target, err := resolveAddr(env, req.Arguments[1])
+		if err != nil {
+			return err
+		}
+		value, ok := types.NewAttoFILFromFILString(req.Arguments[2])
+		if !ok {
+			return errors.New("mal-formed value")
+		}
+
+		method := builtin.MethodSend
+		if m, ok := req.Options["method"].(uint64); ok {
+			method = abi.MethodNum(m)
+		}
+		var params []byte
+		if p, ok := req.Options["params"].(string); ok {
+			params, err = hex.DecodeString(strings.TrimPrefix(p, "0x"))
+			if err != nil {
+				return errors.Wrap(err, "invalid params")
+			}
+		}
+
+		fromAddr, err := fromAddrOrDefault(req, env)
+		if err != nil {
+			return err
+		}
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		txnID, err := GetPorcelainAPI(env).MultisigPropose(req.Context, fromAddr, msigAddr, target, value, method, params, gasPrice, gasLimit)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&MsigProposeResult{TxnID: txnID})
+	},
+	Type: &MsigProposeResult{},
+}
+
+var msigApproveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Approve a pending transaction of a multisig wallet",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("wallet", true, false, "Address of the multisig wallet"),
+		cmdkit.StringArg("txnid", true, false, "ID of the pending transaction"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Signer approving the transaction"),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return runMsigTxnCmd(req, env, GetPorcelainAPI(env).MultisigApprove)
+	},
+}
+
+var msigCancelCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Cancel a pending transaction of a multisig wallet",
+		ShortDescription: `
+Cancels a transaction awaiting approval. Only the signer who proposed it may
+cancel it.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("wallet", true, false, "Address of the multisig wallet"),
+		cmdkit.StringArg("txnid", true, false, "ID of the pending transaction"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Signer cancelling the transaction"),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return runMsigTxnCmd(req, env, GetPorcelainAPI(env).MultisigCancel)
+	},
+}
+
+type msigTxnFunc func(ctx context.Context, from, msigAddr address.Address, txnID int64, gasPrice types.AttoFIL, gasLimit gas.Unit) error
+
+// runMsigTxnCmd runs a command acting on a pending transaction given by the
+// wallet and txnid arguments.
+func runMsigTxnCmd(req *cmds.Request, env cmds.Environment, act msigTxnFunc) error {
+	msigAddr, err := resolveAddr(env, req.Arguments[0])
+	if err != nil {
+		return err
+	}
+	txnID, err := strconv.ParseInt(req.Arguments[1], 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "invalid transaction id")
+	}
+	fromAddr, err := fromAddrOrDefault(req, env)
+	if err != nil {
+		return err
+	}
+	gasPrice, gasLimit, _, err := parseGasOptions(req)
+	if err != nil {
+		return err
+	}
+	return act(req.Context, fromAddr, msigAddr, txnID, gasPrice, gasLimit)
+}
+
+var msigInspectCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the signers, vesting schedule and pending transactions of a multisig wallet",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("wallet", true, false, "Address of the multisig wallet"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("tipset", "Comma separated CIDs of the blocks of the tipset to read the state at; defaults to the head"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		msigAddr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		key, err := tipSetKeyOrHead(req, env)
+		if err != nil {
+			return err
+		}
+		info, err := GetPorcelainAPI(env).MultisigInspect(req.Context, msigAddr, key)
+		if err != nil {
+			return err
+		}
+		return re.Emit(info)
+	},
+	Type: porcelain.MultisigInfo{},
+}
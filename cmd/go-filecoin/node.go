@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+)
+
+var nodeCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect the state of the running node",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"status": nodeStatusCmd,
+	},
+}
+
+var nodeStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show whether the node is ready and the checks deciding it",
+		ShortDescription: `
+Runs the checks behind the API server's /health/ready endpoint: the head is
+within api.readinessMaxEpochLag epochs of the chain being synced and of the
+wall clock, at least bootstrap.minPeerThreshold peers are connected and the
+drand network serves recent entries. Use --enc=text for a summary.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		health, err := GetPorcelainAPI(env).NodeHealth(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(health)
+	},
+	Type: porcelain.NodeHealth{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, health *porcelain.NodeHealth) error {
+			state := "not ready"
+			if health.Ready {
+				state = "ready"
+			}
+			if _, err := fmt.Fprintf(w, "node is %s\n", state); err != nil {
+				return err
+			}
+			for _, c := range health.Checks {
+				result := "FAIL"
+				if c.OK {
+					result = "ok"
+				}
+				if _, err := fmt.Fprintf(w, "  %-6s %-4s %s\n", c.Name, result, c.Detail); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
@@ -73,7 +73,7 @@ var outboxClearCmd = &cmds.Command{
 func queueAddressesFromArg(req *cmds.Request, env cmds.Environment, argIndex int) ([]address.Address, error) {
 	var addresses []address.Address
 	if len(req.Arguments) > argIndex {
-		addr, e := address.NewFromString(req.Arguments[argIndex])
+		addr, e := resolveAddr(env, req.Arguments[argIndex])
 		if e != nil {
 			return nil, e
 		}
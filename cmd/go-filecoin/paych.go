@@ -0,0 +1,366 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// PaychListEntry is a payment channel listed by paych ls.
+type PaychListEntry struct {
+	Address  address.Address
+	From     address.Address
+	To       address.Address
+	Lanes    uint64
+	Vouchers int
+}
+
+// PaychVoucherResult is a voucher printed by the paych voucher commands, encoded
+// as the voucher argument of paych voucher check and submit.
+type PaychVoucherResult struct {
+	Voucher string
+	Lane    uint64
+	Nonce   uint64
+	Amount  abi.TokenAmount
+}
+
+// PaychVoucherCheckResult is the return type for paych voucher check.
+type PaychVoucherCheckResult struct {
+	Redeemable abi.TokenAmount
+}
+
+var paychCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Payment channel operations",
+		ShortDescription: `
+A payment channel holds funds its payer pays out to its payee through signed
+vouchers, such as those paying for retrievals. The payee submits vouchers to
+the channel, then either party settles it and, once the settle delay has
+passed, collects it, sending the redeemed amount to the payee and the rest back
+to the payer.
+
+The node submits the best vouchers it holds for channels paying it as soon as
+they start settling, so that they are redeemed before the channel is collected.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":      paychLsCmd,
+		"status":  paychStatusCmd,
+		"settle":  paychSettleCmd,
+		"collect": paychCollectCmd,
+		"voucher": paychVoucherCmd,
+	},
+}
+
+var paychLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the payment channels the node pays or is paid through",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		chinfos, err := GetPorcelainAPI(env).PaychList()
+		if err != nil {
+			return err
+		}
+		entries := make([]PaychListEntry, len(chinfos))
+		for i, chinfo := range chinfos {
+			entries[i] = PaychListEntry{
+				Address:  chinfo.UniqueAddr,
+				From:     chinfo.From,
+				To:       chinfo.To,
+				Lanes:    chinfo.NextLane,
+				Vouchers: len(chinfo.Vouchers),
+			}
+		}
+		return re.Emit(entries)
+	},
+	Type: []PaychListEntry{},
+}
+
+var paychStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the state of a payment channel and what its vouchers redeem",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("channel", true, false, "Address of the payment channel"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		paychAddr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		info, err := GetPorcelainAPI(env).PaychStatus(req.Context, paychAddr)
+		if err != nil {
+			return err
+		}
+		return re.Emit(info)
+	},
+	Type: porcelain.PaychInfo{},
+}
+
+var paychSettleCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Start settling a payment channel",
+		ShortDescription: `
+Starts settling the channel and waits for the message to appear on chain. The
+channel may be collected once the settle delay has passed; until then the payee
+may still submit vouchers.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("channel", true, false, "Address of the payment channel"),
+	},
+	Options: []cmdkit.Option{
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		paychAddr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+		return GetPorcelainAPI(env).PaychSettle(req.Context, paychAddr, gasPrice, gasLimit)
+	},
+}
+
+var paychCollectCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pay out a settled payment channel",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("channel", true, false, "Address of the payment channel"),
+	},
+	Options: []cmdkit.Option{
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		paychAddr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+		return GetPorcelainAPI(env).PaychCollect(req.Context, paychAddr, gasPrice, gasLimit)
+	},
+}
+
+var paychVoucherCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create, check and submit payment channel vouchers",
+		ShortDescription: `
+Vouchers are printed and read as the base64url encoding of their CBOR
+serialization. The amount of a voucher is the total it redeems on its lane, so
+a later voucher on the same lane supersedes the earlier ones.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"create":         paychVoucherCreateCmd,
+		"check":          paychVoucherCheckCmd,
+		"submit":         paychVoucherSubmitCmd,
+		"best-spendable": paychVoucherBestSpendableCmd,
+	},
+}
+
+var paychVoucherCreateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create a voucher paying the payee of a channel",
+		ShortDescription: `
+Signs a voucher redeeming <amount> FIL in total on a lane of a channel the
+node pays through. Without --lane a new lane is allocated.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("channel", true, false, "Address of the payment channel"),
+		cmdkit.StringArg("amount", true, false, "Total amount the voucher redeems on its lane in FIL"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("lane", "Lane of the voucher"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		paychAddr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		amount, ok := types.NewAttoFILFromFILString(req.Arguments[1])
+		if !ok {
+			return errors.New("mal-formed amount")
+		}
+		var lane *uint64
+		if l, ok := req.Options["lane"].(uint64); ok {
+			lane = &l
+		}
+
+		voucher, err := GetPorcelainAPI(env).PaychVoucherCreate(req.Context, paychAddr, amount, lane)
+		if err != nil {
+			return err
+		}
+		res, err := newPaychVoucherResult(voucher)
+		if err != nil {
+			return err
+		}
+		return re.Emit(res)
+	},
+	Type: PaychVoucherResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *PaychVoucherResult) error {
+			_, err := fmt.Fprintln(w, res.Voucher)
+			return err
+		}),
+	},
+}
+
+var paychVoucherCheckCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Check that a channel would accept a voucher",
+		ShortDescription: `
+Checks the signature, time locks, nonce and amount of the voucher against the
+state of the channel at the head of the chain, and prints how much submitting
+it would add to what the channel pays out.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("channel", true, false, "Address of the payment channel"),
+		cmdkit.StringArg("voucher", true, false, "Encoded voucher"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		paychAddr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		voucher, err := decodePaychVoucher(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+		redeemable, err := GetPorcelainAPI(env).PaychVoucherCheck(req.Context, paychAddr, voucher)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&PaychVoucherCheckResult{Redeemable: redeemable})
+	},
+	Type: PaychVoucherCheckResult{},
+}
+
+var paychVoucherSubmitCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Redeem a voucher on chain",
+		ShortDescription: `
+Submits the voucher to the channel from the payee's address and waits for the
+message to appear on chain. The redeemed amount is paid out when the channel is
+collected.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("channel", true, false, "Address of the payment channel"),
+		cmdkit.StringArg("voucher", true, false, "Encoded voucher"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("proof", "Hex encoded proof for the voucher's extra verification"),
+		priceOption,
+		limitOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		paychAddr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		voucher, err := decodePaychVoucher(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+		var proof []byte
+		if p, ok := req.Options["proof"].(string); ok {
+			proof, err = hex.DecodeString(strings.TrimPrefix(p, "0x"))
+			if err != nil {
+				return errors.Wrap(err, "invalid proof")
+			}
+		}
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+		return GetPorcelainAPI(env).PaychVoucherSubmit(req.Context, paychAddr, voucher, proof, gasPrice, gasLimit)
+	},
+}
+
+var paychVoucherBestSpendableCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the vouchers redeeming the most on each lane of a channel",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("channel", true, false, "Address of the payment channel"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		paychAddr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		best, err := GetPorcelainAPI(env).PaychBestSpendable(req.Context, paychAddr)
+		if err != nil {
+			return err
+		}
+		results := make([]PaychVoucherResult, len(best))
+		for i, vi := range best {
+			res, err := newPaychVoucherResult(vi.Voucher)
+			if err != nil {
+				return err
+			}
+			results[i] = *res
+		}
+		return re.Emit(results)
+	},
+	Type: []PaychVoucherResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, results *[]PaychVoucherResult) error {
+			for _, res := range *results {
+				if _, err := fmt.Fprintf(w, "lane %d, nonce %d, %s attoFIL: %s\n", res.Lane, res.Nonce, res.Amount, res.Voucher); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+func newPaychVoucherResult(voucher *paych.SignedVoucher) (*PaychVoucherResult, error) {
+	var buf bytes.Buffer
+	if err := voucher.MarshalCBOR(&buf); err != nil {
+		return nil, err
+	}
+	return &PaychVoucherResult{
+		Voucher: base64.RawURLEncoding.EncodeToString(buf.Bytes()),
+		Lane:    voucher.Lane,
+		Nonce:   voucher.Nonce,
+		Amount:  voucher.Amount,
+	}, nil
+}
+
+func decodePaychVoucher(s string) (*paych.SignedVoucher, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid voucher encoding")
+	}
+	var voucher paych.SignedVoucher
+	if err := voucher.UnmarshalCBOR(bytes.NewReader(data)); err != nil {
+		return nil, errors.Wrap(err, "invalid voucher")
+	}
+	return &voucher, nil
+}
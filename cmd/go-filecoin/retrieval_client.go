@@ -1,8 +1,17 @@
 package commands
 
 import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/ipfs/go-cid"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/retrieval"
 )
 
 var retrievalClientCmd = &cmds.Command{
@@ -17,34 +26,96 @@ var retrievalClientCmd = &cmds.Command{
 var clientRetrievePieceCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Read out piece data stored by a miner on the network",
+		ShortDescription: `
+Retrieves the payload with the given CID from a miner, paying for it through a
+payment channel to the miner that is created on the first retrieval from that
+miner and topped up on later ones. The miner is paid by vouchers as the data
+arrives.
+
+The payload is written as it arrives, to stdout without --output. With
+--output it is written to the given file and the progress of the deal is
+printed.
+
+Retrieved data is kept by the node, so retrieving a payload again does not pay
+for it again. If the command is interrupted, the deal continues and running the
+command again waits for it to finish. Deals are not resumed across a restart of
+the daemon: a payload is retrieved as a whole, so running the command again
+after a restart makes a new deal paying for the whole payload, with the same
+payment channel.
+`,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("miner", true, false, "Retrieval miner actor address"),
-		cmdkit.StringArg("cid", true, false, "Content identifier of piece to read"),
+		cmdkit.StringArg("cid", true, false, "Content identifier of the payload to read"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address to pay the miner from"),
+		cmdkit.StringOption("output", "File to write the payload to, printing progress instead"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		panic("TODO: go-fil-markets integration")
-
-		//minerAddr, err := address.NewFromString(req.Arguments[0])
-		//if err != nil {
-		//	return err
-		//}
-		//
-		//pieceCID, err := cid.Decode(req.Arguments[1])
-		//if err != nil {
-		//	return err
-		//}
-		//
-		//mpid, err := GetPorcelainAPI(env).MinerGetPeerID(req.Context, minerAddr)
-		//if err != nil {
-		//	return err
-		//}
-		//
-		//readCloser, err := GetRetrievalAPI(env).RetrievePiece(req.Context, pieceCID, mpid, minerAddr)
-		//if err != nil {
-		//	return err
-		//}
-		//
-		//return re.Emit(readCloser)
+		minerAddr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		payload, err := cid.Decode(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+
+		fromAddr, err := fromAddrOrDefault(req, env)
+		if err != nil {
+			return err
+		}
+
+		porcelainAPI := GetPorcelainAPI(env)
+		status, err := porcelainAPI.MinerGetStatus(req.Context, minerAddr, porcelainAPI.ChainHeadKey())
+		if err != nil {
+			return errors.Wrapf(err, "failed to get miner %s", minerAddr)
+		}
+
+		miner := retrievalmarket.RetrievalPeer{Address: minerAddr, ID: status.PeerID}
+		rt, err := GetRetrievalAPI(env).Retriever().Open(req.Context, miner, payload, fromAddr)
+		if err != nil {
+			return err
+		}
+		dr, err := rt.Read(req.Context)
+		if err != nil {
+			return err
+		}
+
+		output, _ := req.Options["output"].(string)
+		if output == "" {
+			return re.Emit(dr)
+		}
+
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		copied := make(chan error, 1)
+		go func() {
+			_, err := io.Copy(f, dr)
+			copied <- err
+		}()
+
+		waitErr := rt.Wait(req.Context, func(p retrieval.Progress) { _ = re.Emit(&p) })
+		if err := <-copied; err != nil {
+			return err
+		}
+		return waitErr
+	},
+	Type: retrieval.Progress{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, p *retrieval.Progress) error {
+			if p.Local {
+				_, err := fmt.Fprintln(w, "payload is stored locally")
+				return err
+			}
+			_, err := fmt.Fprintf(w, "deal %d: %s, received %d of %d bytes, paid %s of %s attoFIL through %s %s\n",
+				p.DealID, p.Status, p.Received, p.Size, p.FundsSpent, p.TotalFunds, p.PaymentChannel, p.Message)
+			return err
+		}),
 	},
 }
@@ -0,0 +1,292 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	fsm "github.com/filecoin-project/storage-fsm"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/piecemanager"
+)
+
+// sectorStates are the sealing states sectors may be moved to.
+var sectorStates = map[fsm.SectorState]bool{
+	fsm.Packing:             true,
+	fsm.PreCommit1:          true,
+	fsm.PreCommit2:          true,
+	fsm.PreCommitting:       true,
+	fsm.WaitSeed:            true,
+	fsm.Committing:          true,
+	fsm.CommitWait:          true,
+	fsm.FinalizeSector:      true,
+	fsm.Proving:             true,
+	fsm.FailedUnrecoverable: true,
+	fsm.SealFailed:          true,
+	fsm.PreCommitFailed:     true,
+	fsm.ComputeProofFailed:  true,
+	fsm.CommitFailed:        true,
+	fsm.PackingFailed:       true,
+	fsm.Faulty:              true,
+	fsm.FaultReported:       true,
+	fsm.FaultedFinal:        true,
+}
+
+var sectorsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect and manage the sectors of this node's miner",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"list":         sectorsListCmd,
+		"move":         sectorsMoveCmd,
+		"remove":       sectorsRemoveCmd,
+		"status":       sectorsStatusCmd,
+		"storage":      sectorsStorageCmd,
+		"update-state": sectorsUpdateStateCmd,
+	},
+}
+
+var sectorsListCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the sectors of the miner",
+		ShortDescription: `
+Lists the sectors being sealed or sealed by the miner, with their sealing
+state, deals, messages, errors and retries, and their state on chain.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sectors, err := GetStorageAPI(env).ListSectors(req.Context)
+		if err != nil {
+			return err
+		}
+		statuses, err := sectorStatuses(req, env, sectors...)
+		if err != nil {
+			return err
+		}
+		return re.Emit(statuses)
+	},
+	Type: []porcelain.SectorStatus{},
+}
+
+var sectorsStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the status of a sector of the miner",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("sector", true, false, "Number of the sector"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("log", "Include the sealing events of the sector"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sectorNum, err := parseSectorNumber(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		sector, err := GetStorageAPI(env).GetSector(req.Context, sectorNum)
+		if err != nil {
+			return err
+		}
+		statuses, err := sectorStatuses(req, env, sector)
+		if err != nil {
+			return err
+		}
+
+		status := statuses[0]
+		if withLog, _ := req.Options["log"].(bool); withLog {
+			status.Log = sector.Log
+		}
+		return re.Emit(status)
+	},
+	Type: porcelain.SectorStatus{},
+}
+
+var sectorsUpdateStateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Force a sector of the miner into a sealing state",
+		ShortDescription: `
+Moves a sector to a sealing state, from which sealing carries on. Moving a
+failed sector back to the state that failed retries it, e.g. PreCommit1 after
+SealFailed or Committing after ComputeProofFailed.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("sector", true, false, "Number of the sector"),
+		cmdkit.StringArg("state", true, false, "Sealing state to move the sector to"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sectorNum, err := parseSectorNumber(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		state := fsm.SectorState(req.Arguments[1])
+		if !sectorStates[state] {
+			return fmt.Errorf("unknown sector state %s", state)
+		}
+
+		if err := GetStorageAPI(env).UpdateSectorState(req.Context, sectorNum, state); err != nil {
+			return err
+		}
+		return re.Emit(fmt.Sprintf("moved sector %d to %s", sectorNum, state))
+	},
+	Type: "",
+}
+
+var sectorsRemoveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Stop sealing a sector of the miner and forget it",
+		ShortDescription: `
+Stops sealing a sector and removes it from the sectors of the miner. Data
+already written to storage for the sector is left in place. Sectors committed
+on chain are only removed with --force, as the miner will fail to prove them.
+A sector is removed once its current sealing step completes. If that takes too
+long the command fails and may be run again later.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("sector", true, false, "Number of the sector"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("force", "Remove the sector even if it is committed on chain"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sectorNum, err := parseSectorNumber(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		sector, err := GetStorageAPI(env).GetSector(req.Context, sectorNum)
+		if err != nil {
+			return err
+		}
+		statuses, err := sectorStatuses(req, env, sector)
+		if err != nil {
+			return err
+		}
+		if force, _ := req.Options["force"].(bool); !force && statuses[0].ChainState == porcelain.SectorCommitted {
+			return fmt.Errorf("sector %d is committed on chain, use --force to remove it", sectorNum)
+		}
+
+		if err := GetStorageAPI(env).RemoveSector(req.Context, sectorNum); err != nil {
+			return err
+		}
+		return re.Emit(fmt.Sprintf("removed sector %d", sectorNum))
+	},
+	Type: "",
+}
+
+var sectorsMoveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Move a sealed sector of the miner to another storage path",
+		ShortDescription: `
+Moves the sealed and cache files of a sector in the Proving state to the
+storage path with the provided ID, listed by 'sectors storage list'. Other
+copies of the files are removed. The move waits for a window PoSt being
+generated to complete, but a block mined while the files move may fail to
+prove them.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("sector", true, false, "Number of the sector"),
+		cmdkit.StringArg("storage-id", true, false, "ID of the storage path to move the sector to"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sectorNum, err := parseSectorNumber(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		storageID := req.Arguments[1]
+
+		if err := GetStorageAPI(env).MoveSector(req.Context, sectorNum, storageID); err != nil {
+			return err
+		}
+		return re.Emit(fmt.Sprintf("moved sector %d to %s", sectorNum, storageID))
+	},
+	Type: "",
+}
+
+var sectorsStorageCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the storage paths holding sector data",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"attach": sectorsStorageAttachCmd,
+		"list":   sectorsStorageListCmd,
+	},
+}
+
+var sectorsStorageListCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the storage paths holding sector data",
+		ShortDescription: `
+Lists the storage paths of the miner with their weight, roles, the space of
+their file system and the space reserved for the sectors they hold.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		paths, err := GetStorageAPI(env).StoragePaths(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(paths)
+	},
+	Type: []piecemanager.StoragePath{},
+}
+
+var sectorsStorageAttachCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Add a storage path to hold sector data",
+		ShortDescription: `
+Adds a directory to the storage paths of the miner, creating it if needed. New
+sectors are placed in the path with the most free space, scaled by its weight,
+among those with the role for them and under their maximum storage. Paths with
+--seal hold sectors being sealed; paths with --store hold sealed sectors.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("path", true, false, "Directory to hold sector data"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("weight", "Weight of the path when placing new sectors").WithDefault(uint64(10)),
+		cmdkit.Uint64Option("max-storage", "Bytes of sector data the path may hold; unlimited if 0"),
+		cmdkit.BoolOption("seal", "Use the path for sectors being sealed"),
+		cmdkit.BoolOption("store", "Use the path for sealed sectors"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		cfg := config.StoragePathConfig{Path: req.Arguments[0]}
+		cfg.Weight, _ = req.Options["weight"].(uint64)
+		cfg.MaxStorage, _ = req.Options["max-storage"].(uint64)
+		cfg.CanSeal, _ = req.Options["seal"].(bool)
+		cfg.CanStore, _ = req.Options["store"].(bool)
+		if !cfg.CanSeal && !cfg.CanStore {
+			return errors.New("storage path needs --seal, --store or both")
+		}
+
+		if err := GetStorageAPI(env).AttachStoragePath(req.Context, cfg); err != nil {
+			return err
+		}
+		return re.Emit(fmt.Sprintf("attached storage path %s", cfg.Path))
+	},
+	Type: "",
+}
+
+// sectorStatuses joins sectors of the miner with their state on chain.
+func sectorStatuses(req *cmds.Request, env cmds.Environment, sectors ...fsm.SectorInfo) ([]porcelain.SectorStatus, error) {
+	minerAddr, err := GetBlockAPI(env).MinerAddress()
+	if err != nil {
+		return nil, err
+	}
+	return GetPorcelainAPI(env).SectorStatuses(req.Context, minerAddr, sectors)
+}
+
+func parseSectorNumber(arg string) (abi.SectorNumber, error) {
+	num, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid sector number "+arg)
+	}
+	return abi.SectorNumber(num), nil
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/state"
+)
+
+// StateActorResult is the decoded state of an actor, with one of its
+// collections when expanded.
+type StateActorResult struct {
+	*state.ActorState
+	Expanded *state.CollectionPage `json:",omitempty"`
+}
+
+var stateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect the state of actors",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"actor": stateActorCmd,
+	},
+}
+
+var stateActorCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the decoded state of an actor",
+		ShortDescription: `
+Decodes the state of any builtin actor, after the messages of a tipset are
+applied. The output lists the HAMT and AMT collections the state references,
+such as a miner's sectors or the market's deal proposals. Pass the name of
+one to --expand to list its entries, paged with --offset and --limit.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("address", true, false, "Address of the actor"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("tipset", "Comma separated CIDs of the blocks of the tipset to read the state at; defaults to the head"),
+		cmdkit.StringOption("expand", "Name of a collection of the actor's state to list the entries of"),
+		cmdkit.IntOption("offset", "Number of collection entries to skip").WithDefault(0),
+		cmdkit.IntOption("limit", "Maximum number of collection entries to list, 0 for all").WithDefault(100),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		addr, err := resolveAddr(env, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		key, err := tipSetKeyOrHead(req, env)
+		if err != nil {
+			return err
+		}
+		view, err := GetPorcelainAPI(env).StateView(key)
+		if err != nil {
+			return err
+		}
+
+		actorState, err := view.ActorState(req.Context, addr)
+		if err != nil {
+			return err
+		}
+		result := &StateActorResult{ActorState: actorState}
+		if name, _ := req.Options["expand"].(string); name != "" {
+			offset, _ := req.Options["offset"].(int)
+			limit, _ := req.Options["limit"].(int)
+			result.Expanded, err = view.ActorCollection(req.Context, addr, name, offset, limit)
+			if err != nil {
+				return err
+			}
+		}
+		return re.Emit(result)
+	},
+	Type: StateActorResult{},
+}
@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/ipfs/go-cid"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 )
 
 // SilentWriter writes to a stream, stopping after the first error and discarding output until
@@ -100,6 +103,24 @@ func optionalAddr(o interface{}) (ret address.Address, err error) {
 	return
 }
 
+// resolveAddr parses an address argument, which may also be a label from the
+// address book.
+func resolveAddr(env cmds.Environment, s string) (address.Address, error) {
+	return GetPorcelainAPI(env).AddressResolve(s)
+}
+
+// optionalAddrOrLabel is optionalAddr accepting labels from the address book.
+func optionalAddrOrLabel(env cmds.Environment, o interface{}) (address.Address, error) {
+	if o == nil {
+		return address.Undef, nil
+	}
+	addr, err := resolveAddr(env, o.(string))
+	if err != nil {
+		return address.Undef, errors.Wrap(err, "invalid from address")
+	}
+	return addr, nil
+}
+
 func optionalSectorSizeWithDefault(o interface{}, def abi.SectorSize) (abi.SectorSize, error) {
 	if o != nil {
 		n, err := strconv.ParseUint(o.(string), 10, 64)
@@ -114,7 +135,7 @@ func optionalSectorSizeWithDefault(o interface{}, def abi.SectorSize) (abi.Secto
 }
 
 func fromAddrOrDefault(req *cmds.Request, env cmds.Environment) (address.Address, error) {
-	addr, err := optionalAddr(req.Options["from"])
+	addr, err := optionalAddrOrLabel(env, req.Options["from"])
 	if err != nil {
 		return address.Undef, err
 	}
@@ -124,6 +145,20 @@ func fromAddrOrDefault(req *cmds.Request, env cmds.Environment) (address.Address
 	return addr, nil
 }
 
+// tipSetKeyOrHead returns the key given by the comma separated CIDs of the
+// "tipset" option, or the head's key if the option is not set.
+func tipSetKeyOrHead(req *cmds.Request, env cmds.Environment) (block.TipSetKey, error) {
+	tipset, _ := req.Options["tipset"].(string)
+	if tipset == "" {
+		return GetPorcelainAPI(env).ChainHeadKey(), nil
+	}
+	cids, err := cidsFromSlice(strings.Split(tipset, ","))
+	if err != nil {
+		return block.TipSetKey{}, err
+	}
+	return block.NewTipSetKey(cids...), nil
+}
+
 func cidsFromSlice(args []string) ([]cid.Cid, error) {
 	out := make([]cid.Cid, len(args))
 	for i, arg := range args {
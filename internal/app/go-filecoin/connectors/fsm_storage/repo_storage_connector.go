@@ -1,46 +1,218 @@
 package fsmstorage
 
 import (
-	"errors"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paths"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 )
 
 type RepoStorageConnector struct {
 	inner repo.Repo
+	lk    sync.Mutex
 }
 
 var _ stores.LocalStorage = new(RepoStorageConnector)
 
-func NewRepoStorageConnector(r repo.Repo) *RepoStorageConnector {
-	return &RepoStorageConnector{inner: r}
+// NewRepoStorageConnector creates a connector to the storage paths of a repo.
+// The configuration of storage paths is kept in their metadata, where the
+// sector store reads it from, so the metadata is brought up to date with the
+// configuration.
+func NewRepoStorageConnector(r repo.Repo) (*RepoStorageConnector, error) {
+	for _, p := range r.Config().SectorBase.StoragePaths {
+		if err := writeStorageMeta(p); err != nil {
+			return nil, err
+		}
+	}
+	return &RepoStorageConnector{inner: r}, nil
+}
+
+// localPath is a directory holding sector data with its storage metadata.
+type localPath struct {
+	path       string
+	meta       stores.LocalStorageMeta
+	maxStorage uint64
 }
 
 func (b *RepoStorageConnector) GetStorage() (stores.StorageConfig, error) {
-	rpt, err := b.inner.Path()
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	out := stores.StorageConfig{}
+	all, err := b.storagePaths()
 	if err != nil {
 		return stores.StorageConfig{}, err
 	}
+	for _, p := range all {
+		out.StoragePaths = append(out.StoragePaths, stores.LocalPath{Path: p})
+	}
+	return out, nil
+}
+
+// SetStorage records the paths added to the storage configuration as storage
+// paths of the repo, with the weight and roles of their metadata.
+func (b *RepoStorageConnector) SetStorage(f func(*stores.StorageConfig)) error {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	known, err := b.storagePaths()
+	if err != nil {
+		return err
+	}
+	sc := stores.StorageConfig{}
+	for _, p := range known {
+		sc.StoragePaths = append(sc.StoragePaths, stores.LocalPath{Path: p})
+	}
+	f(&sc)
+
+	cfg := b.inner.Config()
+	added := false
+	for _, lp := range sc.StoragePaths {
+		if containsPath(known, lp.Path) {
+			continue
+		}
+		meta, err := readStorageMeta(lp.Path)
+		if err != nil {
+			return err
+		}
+		cfg.SectorBase.StoragePaths = append(cfg.SectorBase.StoragePaths, &config.StoragePathConfig{
+			Path:     lp.Path,
+			Weight:   meta.Weight,
+			CanSeal:  meta.CanSeal,
+			CanStore: meta.CanStore,
+		})
+		added = true
+	}
+	if !added {
+		return nil
+	}
+	return b.inner.ReplaceConfig(cfg)
+}
+
+// addStoragePath writes the metadata of a new storage path and records it in
+// the configuration.
+func (b *RepoStorageConnector) addStoragePath(p config.StoragePathConfig) error {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	known, err := b.storagePaths()
+	if err != nil {
+		return err
+	}
+	if containsPath(known, p.Path) {
+		return errors.Errorf("%s is already a storage path", p.Path)
+	}
+	if err := writeStorageMeta(&p); err != nil {
+		return err
+	}
+
+	cfg := b.inner.Config()
+	cfg.SectorBase.StoragePaths = append(cfg.SectorBase.StoragePaths, &p)
+	return b.inner.ReplaceConfig(cfg)
+}
+
+// localPaths reads the metadata of the storage paths, by storage ID.
+func (b *RepoStorageConnector) localPaths() (map[stores.ID]localPath, error) {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	all, err := b.storagePaths()
+	if err != nil {
+		return nil, err
+	}
+	maxStorage := make(map[string]uint64)
+	for _, p := range b.inner.Config().SectorBase.StoragePaths {
+		maxStorage[p.Path] = p.MaxStorage
+	}
+
+	out := make(map[stores.ID]localPath, len(all))
+	for _, p := range all {
+		meta, err := readStorageMeta(p)
+		if err != nil {
+			return nil, err
+		}
+		out[meta.ID] = localPath{path: p, meta: meta, maxStorage: maxStorage[p]}
+	}
+	return out, nil
+}
+
+// storagePaths lists the sector directory of the repo, the pre-sealed sectors
+// directory and the configured storage paths.
+func (b *RepoStorageConnector) storagePaths() ([]string, error) {
+	rpt, err := b.inner.Path()
+	if err != nil {
+		return nil, err
+	}
 
 	scg := b.inner.Config().SectorBase
 
 	spt, err := paths.GetSectorPath(scg.RootDirPath, rpt)
 	if err != nil {
-		return stores.StorageConfig{}, err
+		return nil, err
 	}
 
-	out := stores.StorageConfig{StoragePaths: []stores.LocalPath{{Path: spt}}}
-
+	out := []string{spt}
 	if scg.PreSealedSectorsDirPath != "" {
-		out.StoragePaths = append(out.StoragePaths, stores.LocalPath{Path: scg.PreSealedSectorsDirPath})
+		out = append(out, scg.PreSealedSectorsDirPath)
+	}
+	for _, p := range scg.StoragePaths {
+		out = append(out, p.Path)
 	}
-
 	return out, nil
 }
 
-func (b *RepoStorageConnector) SetStorage(f func(*stores.StorageConfig)) error {
-	return errors.New("unsupported operation: manipulating store paths must happen through go-filecoin")
+func readStorageMeta(path string) (stores.LocalStorageMeta, error) {
+	var meta stores.LocalStorageMeta
+	b, err := ioutil.ReadFile(filepath.Join(path, stores.MetaFile))
+	if err != nil {
+		return meta, errors.Wrapf(err, "reading storage metadata of %s", path)
+	}
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return meta, errors.Wrapf(err, "decoding storage metadata of %s", path)
+	}
+	return meta, nil
+}
+
+// writeStorageMeta writes the weight and roles of a storage path to its
+// metadata, creating the path and its storage ID if it has none.
+func writeStorageMeta(p *config.StoragePathConfig) error {
+	meta, err := readStorageMeta(p.Path)
+	if os.IsNotExist(errors.Cause(err)) {
+		if err := os.MkdirAll(p.Path, 0755); err != nil {
+			return err
+		}
+		meta = stores.LocalStorageMeta{ID: stores.ID(uuid.New().String())}
+	} else if err != nil {
+		return err
+	}
+
+	updated := meta
+	updated.Weight, updated.CanSeal, updated.CanStore = p.Weight, p.CanSeal, p.CanStore
+	if _, err := os.Stat(filepath.Join(p.Path, stores.MetaFile)); err == nil && updated == meta {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(&updated, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(p.Path, stores.MetaFile), b, 0644)
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if filepath.Clean(p) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
 }
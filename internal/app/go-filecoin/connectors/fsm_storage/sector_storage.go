@@ -0,0 +1,348 @@
+package fsmstorage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/piecemanager"
+)
+
+// movedFileTypes are the files of a sealed sector moved between storage paths.
+var movedFileTypes = []stores.SectorFileType{stores.FTSealed, stores.FTCache}
+
+// PathOpener opens new storage paths for the sector store to use.
+type PathOpener interface {
+	AddLocalStorage(ctx context.Context, path string) error
+}
+
+// SectorStorage indexes the sector files in the storage paths of the repo. It
+// places new sector files in the path with the most weighted free space among
+// those with room for them, and keeps the index up to date as sectors move
+// between paths.
+type SectorStorage struct {
+	*stores.Index
+	local         *RepoStorageConnector
+	sealProofType abi.RegisteredProof
+	opener        PathOpener
+
+	lk sync.Mutex
+	// provingLk is read locked while PoSts read sector files and locked while
+	// sector files move.
+	provingLk sync.RWMutex
+}
+
+var _ stores.SectorIndex = new(SectorStorage)
+var _ piecemanager.SectorStorage = new(SectorStorage)
+
+// NewSectorStorage creates an empty index of the sector files in the storage
+// paths of a repo.
+func NewSectorStorage(local *RepoStorageConnector, sealProofType abi.RegisteredProof) *SectorStorage {
+	return &SectorStorage{
+		Index:         stores.NewIndex(),
+		local:         local,
+		sealProofType: sealProofType,
+	}
+}
+
+// ProvingLock returns the lock to hold while generating PoSts, which holds off
+// moving sector files until they are proven.
+func (s *SectorStorage) ProvingLock() sync.Locker {
+	return s.provingLk.RLocker()
+}
+
+// SetPathOpener sets what opens storage paths attached to the index.
+func (s *SectorStorage) SetPathOpener(opener PathOpener) {
+	s.opener = opener
+}
+
+// StorageBestAlloc produces the storage path to place new sector files in,
+// skipping paths which would exceed their maximum storage.
+func (s *SectorStorage) StorageBestAlloc(ctx context.Context, allocate stores.SectorFileType, spt abi.RegisteredProof, sealing bool) ([]stores.StorageInfo, error) {
+	candidates, err := s.Index.StorageBestAlloc(ctx, allocate, spt, sealing)
+	if err != nil {
+		return nil, err
+	}
+	need, err := allocate.SealSpaceUse(spt)
+	if err != nil {
+		return nil, err
+	}
+	lps, err := s.local.localPaths()
+	if err != nil {
+		return nil, err
+	}
+	used, err := s.usage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		if max := lps[candidate.ID].maxStorage; max > 0 && used[candidate.ID]+need > max {
+			continue
+		}
+		// The sector store allocates in the last path it is given, so only the
+		// best one is.
+		return []stores.StorageInfo{candidate}, nil
+	}
+	return nil, errors.New("no storage path has room for the sector")
+}
+
+// StoragePaths produces the storage paths with their use.
+func (s *SectorStorage) StoragePaths(ctx context.Context) ([]piecemanager.StoragePath, error) {
+	lps, err := s.local.localPaths()
+	if err != nil {
+		return nil, err
+	}
+	decls, err := s.StorageList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]piecemanager.StoragePath, 0, len(lps))
+	for id, lp := range lps {
+		fst, err := stores.Stat(lp.path)
+		if err != nil {
+			return nil, err
+		}
+		sp := piecemanager.StoragePath{
+			ID:         string(id),
+			Path:       lp.path,
+			Weight:     lp.meta.Weight,
+			MaxStorage: lp.maxStorage,
+			CanSeal:    lp.meta.CanSeal,
+			CanStore:   lp.meta.CanStore,
+			Capacity:   fst.Capacity,
+			Available:  fst.Available,
+			Sectors:    []abi.SectorNumber{},
+		}
+		for _, decl := range decls[id] {
+			use, err := decl.SectorFileType.SealSpaceUse(s.sealProofType)
+			if err != nil {
+				return nil, err
+			}
+			sp.Used += use
+			sp.Sectors = append(sp.Sectors, decl.Number)
+		}
+		sort.Slice(sp.Sectors, func(i, j int) bool { return sp.Sectors[i] < sp.Sectors[j] })
+		out = append(out, sp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out, nil
+}
+
+// AttachStoragePath records a new storage path in the configuration and opens
+// it for sector files.
+func (s *SectorStorage) AttachStoragePath(ctx context.Context, cfg config.StoragePathConfig) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	if s.opener == nil {
+		return errors.New("storage paths cannot be opened")
+	}
+	path, err := homedir.Expand(cfg.Path)
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(path) {
+		return errors.Errorf("storage path %s is not absolute", cfg.Path)
+	}
+	cfg.Path = path
+
+	if err := s.local.addStoragePath(cfg); err != nil {
+		return err
+	}
+	return s.opener.AddLocalStorage(ctx, path)
+}
+
+// MoveSector moves every copy of the sealed and cache files of a sector to a
+// storage path, redeclaring them in the index once moved. Copies beyond the
+// one moved, or all of them if the path already holds the file, are removed.
+// The move waits for PoSts in progress to complete.
+func (s *SectorStorage) MoveSector(ctx context.Context, sector abi.SectorID, storageID string) error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	lps, err := s.local.localPaths()
+	if err != nil {
+		return err
+	}
+	dest, ok := lps[stores.ID(storageID)]
+	if !ok {
+		return errors.Errorf("no storage path with ID %s", storageID)
+	}
+	if !dest.meta.CanStore {
+		return errors.Errorf("storage path %s does not store sectors", dest.path)
+	}
+
+	type move struct {
+		ft  stores.SectorFileType
+		src stores.ID
+	}
+	var moves []move
+	// placed holds the file types the destination has a copy of.
+	placed := make(map[stores.SectorFileType]bool)
+	var need stores.SectorFileType
+	for _, ft := range movedFileTypes {
+		infos, err := s.StorageFindSector(ctx, sector, ft, false)
+		if err != nil {
+			return err
+		}
+		if len(infos) == 0 {
+			return errors.Errorf("sector %d has no %s file", sector.Number, ft)
+		}
+		for _, info := range infos {
+			if info.ID == dest.meta.ID {
+				placed[ft] = true
+			} else {
+				moves = append(moves, move{ft: ft, src: info.ID})
+			}
+		}
+		if !placed[ft] {
+			need |= ft
+		}
+	}
+	if len(moves) == 0 {
+		return nil
+	}
+
+	if need != 0 {
+		space, err := need.SealSpaceUse(s.sealProofType)
+		if err != nil {
+			return err
+		}
+		used, err := s.usage(ctx)
+		if err != nil {
+			return err
+		}
+		if dest.maxStorage > 0 && used[dest.meta.ID]+space > dest.maxStorage {
+			return errors.Errorf("storage path %s has no room for sector %d", dest.path, sector.Number)
+		}
+	}
+
+	s.provingLk.Lock()
+	defer s.provingLk.Unlock()
+	for _, m := range moves {
+		src, ok := lps[m.src]
+		if !ok {
+			return errors.Errorf("sector %d %s file is not in a local storage path", sector.Number, m.ft)
+		}
+		from := filepath.Join(src.path, m.ft.String(), stores.SectorName(sector))
+		to := filepath.Join(dest.path, m.ft.String(), stores.SectorName(sector))
+		if placed[m.ft] {
+			if err := os.RemoveAll(from); err != nil {
+				return errors.Wrapf(err, "removing copy of sector %d %s file", sector.Number, m.ft)
+			}
+		} else {
+			if err := movePath(from, to); err != nil {
+				return errors.Wrapf(err, "moving sector %d %s file", sector.Number, m.ft)
+			}
+			placed[m.ft] = true
+		}
+		if err := s.StorageDropSector(ctx, m.src, sector, m.ft); err != nil {
+			return err
+		}
+		if err := s.StorageDeclareSector(ctx, dest.meta.ID, sector, m.ft); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnsealedSectorPath produces the path of the unsealed file of a sector.
+func (s *SectorStorage) UnsealedSectorPath(ctx context.Context, sector abi.SectorID) (string, error) {
+	lps, err := s.local.localPaths()
+	if err != nil {
+		return "", err
+	}
+	infos, err := s.StorageFindSector(ctx, sector, stores.FTUnsealed, false)
+	if err != nil {
+		return "", err
+	}
+	for _, info := range infos {
+		if lp, ok := lps[info.ID]; ok {
+			return filepath.Join(lp.path, stores.FTUnsealed.String(), stores.SectorName(sector)), nil
+		}
+	}
+	return "", errors.Errorf("no unsealed file of sector %d in the storage paths", sector.Number)
+}
+
+// usage sums the space reserved for the sector files in each storage path.
+func (s *SectorStorage) usage(ctx context.Context) (map[stores.ID]uint64, error) {
+	decls, err := s.StorageList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[stores.ID]uint64, len(decls))
+	for id, ds := range decls {
+		for _, decl := range ds {
+			use, err := decl.SectorFileType.SealSpaceUse(s.sealProofType)
+			if err != nil {
+				return nil, err
+			}
+			used[id] += use
+		}
+	}
+	return used, nil
+}
+
+// movePath renames a file or directory, copying it when it moves to another
+// file system.
+func movePath(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	err := os.Rename(from, to)
+	if err == nil {
+		return nil
+	}
+	if linkErr, ok := err.(*os.LinkError); !ok || linkErr.Err != syscall.EXDEV {
+		return err
+	}
+
+	if err := copyPath(from, to); err != nil {
+		_ = os.RemoveAll(to)
+		return err
+	}
+	return os.RemoveAll(from)
+}
+
+func copyPath(from, to string) error {
+	return filepath.Walk(from, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode())
+		}
+
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = src.Close() }()
+		dst, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(dst, src); err != nil {
+			_ = dst.Close()
+			return err
+		}
+		return dst.Close()
+	})
+}
@@ -0,0 +1,279 @@
+package fsmstorage_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/connectors/fsm_storage"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+const testProof = abi.RegisteredProof_StackedDRG2KiBSeal
+
+// attachingOpener attaches opened paths to the index with plenty of space,
+// the way the sector store does.
+type attachingOpener struct {
+	t       *testing.T
+	index   *SectorStorage
+	opened  []string
+	freeFor map[string]uint64
+}
+
+func (o *attachingOpener) AddLocalStorage(ctx context.Context, path string) error {
+	meta := readMeta(o.t, path)
+	o.opened = append(o.opened, path)
+	return o.index.StorageAttach(ctx, stores.StorageInfo{
+		ID:       meta.ID,
+		Weight:   meta.Weight,
+		CanSeal:  meta.CanSeal,
+		CanStore: meta.CanStore,
+	}, stores.FsStat{Capacity: 1 << 30, Available: o.freeFor[path]})
+}
+
+func readMeta(t *testing.T, path string) stores.LocalStorageMeta {
+	b, err := ioutil.ReadFile(filepath.Join(path, stores.MetaFile))
+	require.NoError(t, err)
+	var meta stores.LocalStorageMeta
+	require.NoError(t, json.Unmarshal(b, &meta))
+	return meta
+}
+
+func setupSectorStorage(t *testing.T) (*SectorStorage, *attachingOpener, string) {
+	dir, err := ioutil.TempDir("", "sector-storage")
+	require.NoError(t, err)
+
+	root := filepath.Join(dir, "root")
+	require.NoError(t, os.MkdirAll(root, 0755))
+	b, err := json.Marshal(&stores.LocalStorageMeta{ID: "root", Weight: 1})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, stores.MetaFile), b, 0644))
+
+	r := repo.NewInMemoryRepo()
+	r.C.SectorBase.RootDirPath = root
+
+	local, err := NewRepoStorageConnector(r)
+	require.NoError(t, err)
+	storage := NewSectorStorage(local, testProof)
+	opener := &attachingOpener{t: t, index: storage, freeFor: map[string]uint64{
+		root:                    1 << 20,
+		filepath.Join(dir, "a"): 1 << 20,
+		filepath.Join(dir, "b"): 1 << 21,
+	}}
+	storage.SetPathOpener(opener)
+	require.NoError(t, opener.AddLocalStorage(context.Background(), root))
+	return storage, opener, dir
+}
+
+func TestSectorStorageAttachesPaths(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	storage, opener, dir := setupSectorStorage(t)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	a := filepath.Join(dir, "a")
+	require.NoError(t, storage.AttachStoragePath(ctx, config.StoragePathConfig{Path: a, Weight: 10, MaxStorage: 1000, CanSeal: true}))
+	assert.Contains(t, opener.opened, a)
+
+	meta := readMeta(t, a)
+	assert.NotEmpty(t, meta.ID)
+	assert.Equal(t, uint64(10), meta.Weight)
+	assert.True(t, meta.CanSeal)
+	assert.False(t, meta.CanStore)
+
+	err := storage.AttachStoragePath(ctx, config.StoragePathConfig{Path: a, CanStore: true})
+	assert.Error(t, err)
+
+	paths, err := storage.StoragePaths(ctx)
+	require.NoError(t, err)
+	require.Len(t, paths, 2)
+	assert.Equal(t, a, paths[0].Path)
+	assert.Equal(t, string(meta.ID), paths[0].ID)
+	assert.Equal(t, uint64(1000), paths[0].MaxStorage)
+	assert.Equal(t, filepath.Join(dir, "root"), paths[1].Path)
+}
+
+func TestSectorStorageAllocatesWithinMaxStorage(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	storage, _, dir := setupSectorStorage(t)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	all := stores.FTUnsealed | stores.FTSealed | stores.FTCache
+	need, err := all.SealSpaceUse(testProof)
+	require.NoError(t, err)
+
+	// a has less free space than b but outweighs it.
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	require.NoError(t, storage.AttachStoragePath(ctx, config.StoragePathConfig{Path: a, Weight: 10, MaxStorage: need + need/2, CanSeal: true, CanStore: true}))
+	require.NoError(t, storage.AttachStoragePath(ctx, config.StoragePathConfig{Path: b, Weight: 1, CanSeal: true, CanStore: true}))
+	aID, bID := readMeta(t, a).ID, readMeta(t, b).ID
+
+	best, err := storage.StorageBestAlloc(ctx, all, testProof, true)
+	require.NoError(t, err)
+	require.Len(t, best, 1)
+	assert.Equal(t, aID, best[0].ID)
+
+	sector := abi.SectorID{Miner: 1000, Number: 1}
+	require.NoError(t, storage.StorageDeclareSector(ctx, aID, sector, all))
+
+	best, err = storage.StorageBestAlloc(ctx, all, testProof, true)
+	require.NoError(t, err)
+	require.Len(t, best, 1)
+	assert.Equal(t, bID, best[0].ID)
+}
+
+func TestSectorStorageMovesSectors(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	storage, _, dir := setupSectorStorage(t)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	require.NoError(t, storage.AttachStoragePath(ctx, config.StoragePathConfig{Path: a, Weight: 10, CanSeal: true}))
+	require.NoError(t, storage.AttachStoragePath(ctx, config.StoragePathConfig{Path: b, Weight: 10, CanStore: true}))
+	aID, bID := readMeta(t, a).ID, readMeta(t, b).ID
+
+	sector := abi.SectorID{Miner: 1000, Number: 1}
+	name := stores.SectorName(sector)
+	for _, ft := range []stores.SectorFileType{stores.FTUnsealed, stores.FTSealed} {
+		require.NoError(t, os.MkdirAll(filepath.Join(a, ft.String()), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(a, ft.String(), name), []byte(ft.String()), 0644))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(a, stores.FTCache.String(), name), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(a, stores.FTCache.String(), name, "p_aux"), []byte("aux"), 0644))
+	require.NoError(t, storage.StorageDeclareSector(ctx, aID, sector, stores.FTUnsealed|stores.FTSealed|stores.FTCache))
+
+	t.Run("refuses paths which do not store sectors", func(t *testing.T) {
+		assert.Error(t, storage.MoveSector(ctx, sector, string(aID)))
+	})
+
+	require.NoError(t, storage.MoveSector(ctx, sector, string(bID)))
+
+	sealed, err := ioutil.ReadFile(filepath.Join(b, stores.FTSealed.String(), name))
+	require.NoError(t, err)
+	assert.Equal(t, stores.FTSealed.String(), string(sealed))
+	_, err = os.Stat(filepath.Join(b, stores.FTCache.String(), name, "p_aux"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(a, stores.FTSealed.String(), name))
+	assert.True(t, os.IsNotExist(err))
+
+	for _, ft := range []stores.SectorFileType{stores.FTSealed, stores.FTCache} {
+		infos, err := storage.StorageFindSector(ctx, sector, ft, false)
+		require.NoError(t, err)
+		require.Len(t, infos, 1)
+		assert.Equal(t, bID, infos[0].ID)
+	}
+
+	unsealed, err := storage.UnsealedSectorPath(ctx, sector)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(a, stores.FTUnsealed.String(), name), unsealed)
+
+	paths, err := storage.StoragePaths(ctx)
+	require.NoError(t, err)
+	require.Len(t, paths, 3)
+	assert.Equal(t, []abi.SectorNumber{1}, paths[0].Sectors)
+	assert.Equal(t, []abi.SectorNumber{1}, paths[1].Sectors)
+	assert.True(t, paths[1].Used > paths[0].Used)
+}
+
+func TestSectorStorageMovesEveryCopy(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	storage, _, dir := setupSectorStorage(t)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	require.NoError(t, storage.AttachStoragePath(ctx, config.StoragePathConfig{Path: a, Weight: 10, CanSeal: true, CanStore: true}))
+	require.NoError(t, storage.AttachStoragePath(ctx, config.StoragePathConfig{Path: b, Weight: 10, CanStore: true}))
+	root := filepath.Join(dir, "root")
+	rootID, aID, bID := readMeta(t, root).ID, readMeta(t, a).ID, readMeta(t, b).ID
+
+	// The sealed file is in the root and in a, the cache in a only.
+	sector := abi.SectorID{Miner: 1000, Number: 1}
+	name := stores.SectorName(sector)
+	for _, p := range []string{root, a} {
+		require.NoError(t, os.MkdirAll(filepath.Join(p, stores.FTSealed.String()), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(p, stores.FTSealed.String(), name), []byte(p), 0644))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(a, stores.FTCache.String(), name), 0755))
+	require.NoError(t, storage.StorageDeclareSector(ctx, rootID, sector, stores.FTSealed))
+	require.NoError(t, storage.StorageDeclareSector(ctx, aID, sector, stores.FTSealed|stores.FTCache))
+
+	t.Log("moving to a path holding a copy removes the others")
+	require.NoError(t, storage.MoveSector(ctx, sector, string(aID)))
+	infos, err := storage.StorageFindSector(ctx, sector, stores.FTSealed, false)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, aID, infos[0].ID)
+	_, err = os.Stat(filepath.Join(root, stores.FTSealed.String(), name))
+	assert.True(t, os.IsNotExist(err))
+	sealed, err := ioutil.ReadFile(filepath.Join(a, stores.FTSealed.String(), name))
+	require.NoError(t, err)
+	assert.Equal(t, a, string(sealed))
+
+	t.Log("moving to another path moves every copy")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, stores.FTSealed.String()), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, stores.FTSealed.String(), name), []byte(root), 0644))
+	require.NoError(t, storage.StorageDeclareSector(ctx, rootID, sector, stores.FTSealed))
+	require.NoError(t, storage.MoveSector(ctx, sector, string(bID)))
+	for _, ft := range []stores.SectorFileType{stores.FTSealed, stores.FTCache} {
+		infos, err := storage.StorageFindSector(ctx, sector, ft, false)
+		require.NoError(t, err)
+		require.Len(t, infos, 1)
+		assert.Equal(t, bID, infos[0].ID)
+	}
+	for _, p := range []string{root, a} {
+		_, err = os.Stat(filepath.Join(p, stores.FTSealed.String(), name))
+		assert.True(t, os.IsNotExist(err))
+	}
+}
+
+func TestSectorStorageMoveWaitsForProving(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	storage, _, dir := setupSectorStorage(t)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	require.NoError(t, storage.AttachStoragePath(ctx, config.StoragePathConfig{Path: a, Weight: 10, CanSeal: true}))
+	require.NoError(t, storage.AttachStoragePath(ctx, config.StoragePathConfig{Path: b, Weight: 10, CanStore: true}))
+
+	sector := abi.SectorID{Miner: 1000, Number: 1}
+	name := stores.SectorName(sector)
+	require.NoError(t, os.MkdirAll(filepath.Join(a, stores.FTSealed.String()), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(a, stores.FTSealed.String(), name), []byte("sealed"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(a, stores.FTCache.String(), name), 0755))
+	require.NoError(t, storage.StorageDeclareSector(ctx, readMeta(t, a).ID, sector, stores.FTSealed|stores.FTCache))
+
+	proving := storage.ProvingLock()
+	proving.Lock()
+	moved := make(chan error)
+	go func() {
+		moved <- storage.MoveSector(ctx, sector, string(readMeta(t, b).ID))
+	}()
+
+	select {
+	case <-moved:
+		t.Fatal("sector moved while it was being proven")
+	case <-time.After(100 * time.Millisecond):
+	}
+	_, err := os.Stat(filepath.Join(a, stores.FTSealed.String(), name))
+	assert.NoError(t, err)
+
+	proving.Unlock()
+	require.NoError(t, <-moved)
+	_, err = os.Stat(filepath.Join(b, stores.FTSealed.String(), name))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,329 @@
+package storagemarketconnector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	notinit "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+)
+
+// policyChain reads the chain head the start epochs of deals are checked against.
+type policyChain interface {
+	Head() block.TipSetKey
+	GetTipSet(block.TipSetKey) (block.TipSet, error)
+}
+
+// PolicyStateView resolves the addresses of deal clients to their ID
+// addresses.
+type PolicyStateView interface {
+	InitResolveAddress(ctx context.Context, a address.Address) (address.Address, error)
+}
+
+// PolicyStateViewer provides state views at tipsets.
+type PolicyStateViewer func(block.TipSetKey) (PolicyStateView, error)
+
+// verifiedPricer produces the price per GiB per epoch verified deals must pay
+// at least, if the miner has set one.
+type verifiedPricer interface {
+	VerifiedPrice() (abi.TokenAmount, bool)
+}
+
+// DealLister lists the deals the storage provider has received.
+type DealLister func() ([]storagemarket.MinerDeal, error)
+
+// DealDecisionRequest is what the decision command or endpoint of a deal policy
+// is given to decide on a deal.
+type DealDecisionRequest struct {
+	ProposalCid  cid.Cid             `json:"proposalCid"`
+	Proposal     market.DealProposal `json:"proposal"`
+	TransferType string              `json:"transferType"`
+	// Height is the height of the chain head.
+	Height abi.ChainEpoch `json:"height"`
+	// SealingDeals is the number of deals accepted and not yet sealed.
+	SealingDeals uint64 `json:"sealingDeals"`
+}
+
+// DealDecision is the answer of the decision endpoint of a deal policy.
+type DealDecision struct {
+	Accept bool   `json:"accept"`
+	Reason string `json:"reason"`
+}
+
+// DealPolicy decides whether the storage provider accepts deals matching its
+// ask, by the rules of the deal policy configuration.
+type DealPolicy struct {
+	cfg    *config.DealPolicyConfig
+	chain  policyChain
+	views  PolicyStateViewer
+	deals  DealLister
+	prices verifiedPricer
+}
+
+// NewDealPolicy creates a deal policy applying the rules of a configuration
+// and holding verified deals to the verified price of the miner's ask. A nil
+// configuration accepts every deal matching the ask.
+func NewDealPolicy(cfg *config.DealPolicyConfig, chain policyChain, views PolicyStateViewer, deals DealLister, prices verifiedPricer) *DealPolicy {
+	if cfg == nil {
+		cfg = &config.DealPolicyConfig{}
+	}
+	return &DealPolicy{
+		cfg:    cfg,
+		chain:  chain,
+		views:  views,
+		deals:  deals,
+		prices: prices,
+	}
+}
+
+// sealingStates are the states of deals accepted and not yet sealed.
+var sealingStates = map[storagemarket.StorageDealStatus]bool{
+	storagemarket.StorageDealProposalAccepted:    true,
+	storagemarket.StorageDealTransferring:        true,
+	storagemarket.StorageDealWaitingForData:      true,
+	storagemarket.StorageDealVerifyData:          true,
+	storagemarket.StorageDealEnsureProviderFunds: true,
+	storagemarket.StorageDealProviderFunding:     true,
+	storagemarket.StorageDealPublish:             true,
+	storagemarket.StorageDealPublishing:          true,
+	storagemarket.StorageDealStaged:              true,
+	storagemarket.StorageDealSealing:             true,
+}
+
+// Decide decides whether to accept a deal, returning the reason for the
+// decision. Deals passing the rules of the policy are accepted if the decision
+// command and endpoint, when configured, accept them too.
+func (p *DealPolicy) Decide(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
+	proposal := deal.Proposal
+	head, err := p.chain.GetTipSet(p.chain.Head())
+	if err != nil {
+		return false, "", err
+	}
+	height, err := head.Height()
+	if err != nil {
+		return false, "", err
+	}
+
+	if len(p.cfg.AllowedClients) > 0 || len(p.cfg.DeniedClients) > 0 {
+		view, err := p.views(head.Key())
+		if err != nil {
+			return false, "", err
+		}
+		client, err := idAddress(ctx, view, proposal.Client)
+		if err != nil {
+			return false, "", err
+		}
+		if len(p.cfg.AllowedClients) > 0 {
+			allowed, err := containsAddress(ctx, view, p.cfg.AllowedClients, client)
+			if err != nil {
+				return false, "", err
+			}
+			if !allowed {
+				return false, fmt.Sprintf("client %s is not allowed", proposal.Client), nil
+			}
+		}
+		denied, err := containsAddress(ctx, view, p.cfg.DeniedClients, client)
+		if err != nil {
+			return false, "", err
+		}
+		if denied {
+			return false, fmt.Sprintf("client %s is denied", proposal.Client), nil
+		}
+	}
+
+	size := uint64(proposal.PieceSize)
+	if size < p.cfg.MinPieceSize {
+		return false, fmt.Sprintf("piece size %d is below the minimum of %d", size, p.cfg.MinPieceSize), nil
+	}
+	if p.cfg.MaxPieceSize > 0 && size > p.cfg.MaxPieceSize {
+		return false, fmt.Sprintf("piece size %d is above the maximum of %d", size, p.cfg.MaxPieceSize), nil
+	}
+
+	duration := uint64(proposal.Duration())
+	if duration < p.cfg.MinDuration {
+		return false, fmt.Sprintf("duration %d is below the minimum of %d", duration, p.cfg.MinDuration), nil
+	}
+	if p.cfg.MaxDuration > 0 && duration > p.cfg.MaxDuration {
+		return false, fmt.Sprintf("duration %d is above the maximum of %d", duration, p.cfg.MaxDuration), nil
+	}
+
+	if earliest := height + abi.ChainEpoch(p.cfg.MinStartDelay); proposal.StartEpoch < earliest {
+		return false, fmt.Sprintf("start epoch %d is before the earliest accepted start epoch %d", proposal.StartEpoch, earliest), nil
+	}
+
+	if proposal.VerifiedDeal && p.cfg.RejectVerified {
+		return false, "verified deals are rejected", nil
+	}
+	if !proposal.VerifiedDeal && p.cfg.RejectUnverified {
+		return false, "unverified deals are rejected", nil
+	}
+	if proposal.VerifiedDeal && p.prices != nil {
+		if price, ok := p.prices.VerifiedPrice(); ok {
+			minPrice := big.Div(big.Mul(price, big.NewIntUnsigned(size)), big.NewInt(1<<30))
+			if proposal.StoragePricePerEpoch.LessThan(minPrice) {
+				return false, fmt.Sprintf("price per epoch %s is below the verified deal price of %s", proposal.StoragePricePerEpoch, minPrice), nil
+			}
+		}
+	}
+
+	sealing, err := p.sealingDeals()
+	if err != nil {
+		return false, "", err
+	}
+	if p.cfg.MaxSealingDeals > 0 && sealing >= p.cfg.MaxSealingDeals {
+		return false, fmt.Sprintf("%d deals are waiting to be sealed, the most allowed is %d", sealing, p.cfg.MaxSealingDeals), nil
+	}
+
+	if p.cfg.DecisionCommand == "" && p.cfg.DecisionURL == "" {
+		return true, "deal satisfies the deal policy", nil
+	}
+
+	if p.cfg.DecisionTimeout != "" {
+		timeout, err := time.ParseDuration(p.cfg.DecisionTimeout)
+		if err != nil {
+			return false, "", errors.Wrap(err, "invalid deal decision timeout")
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	request, err := json.Marshal(DealDecisionRequest{
+		ProposalCid:  deal.ProposalCid,
+		Proposal:     proposal,
+		TransferType: transferType(deal),
+		Height:       height,
+		SealingDeals: sealing,
+	})
+	if err != nil {
+		return false, "", err
+	}
+
+	decision := DealDecision{Accept: true}
+	if p.cfg.DecisionCommand != "" {
+		decision, err = runDecisionCommand(ctx, p.cfg.DecisionCommand, request)
+		if err != nil || !decision.Accept {
+			return false, decision.Reason, err
+		}
+	}
+	if p.cfg.DecisionURL != "" {
+		decision, err = postDecisionRequest(ctx, p.cfg.DecisionURL, request)
+		if err != nil {
+			return false, "", err
+		}
+	}
+	return decision.Accept, decision.Reason, nil
+}
+
+// sealingDeals counts the deals accepted and not yet sealed.
+func (p *DealPolicy) sealingDeals() (uint64, error) {
+	deals, err := p.deals()
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, deal := range deals {
+		if sealingStates[deal.State] {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// runDecisionCommand runs a decision command with a decision request on its
+// standard input. The deal is accepted if the command exits successfully, and
+// the output of the command is the reason for the decision either way.
+func runDecisionCommand(ctx context.Context, command string, request []byte) (DealDecision, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return DealDecision{}, errors.New("deal decision command is empty")
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(request)
+	out, err := cmd.Output()
+	reason := strings.TrimSpace(string(out))
+	if _, exited := err.(*exec.ExitError); exited {
+		if reason == "" {
+			reason = "rejected by the deal decision command"
+		}
+		return DealDecision{Accept: false, Reason: reason}, nil
+	}
+	if err != nil {
+		return DealDecision{}, errors.Wrap(err, "running deal decision command")
+	}
+	if reason == "" {
+		reason = "accepted by the deal decision command"
+	}
+	return DealDecision{Accept: true, Reason: reason}, nil
+}
+
+// postDecisionRequest posts a decision request to a decision endpoint and
+// returns its decision.
+func postDecisionRequest(ctx context.Context, url string, request []byte) (DealDecision, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(request))
+	if err != nil {
+		return DealDecision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return DealDecision{}, errors.Wrap(err, "posting to deal decision endpoint")
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return DealDecision{}, errors.Errorf("deal decision endpoint responded with %s", resp.Status)
+	}
+	var decision DealDecision
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return DealDecision{}, errors.Wrap(err, "decoding deal decision")
+	}
+	return decision, nil
+}
+
+func transferType(deal storagemarket.MinerDeal) string {
+	if deal.Ref == nil {
+		return ""
+	}
+	return deal.Ref.TransferType
+}
+
+// containsAddress returns whether any of a list of addresses resolves to an ID
+// address.
+func containsAddress(ctx context.Context, view PolicyStateView, addrs []address.Address, id address.Address) (bool, error) {
+	for _, a := range addrs {
+		resolved, err := idAddress(ctx, view, a)
+		if err != nil {
+			return false, err
+		}
+		if resolved == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// idAddress resolves an address to its ID address, leaving addresses with no
+// actor on chain as they are.
+func idAddress(ctx context.Context, view PolicyStateView, a address.Address) (address.Address, error) {
+	id, err := view.InitResolveAddress(ctx, a)
+	if errors.Cause(err) == notinit.ErrAddressNotFound {
+		return a, nil
+	}
+	return id, err
+}
@@ -0,0 +1,295 @@
+package storagemarketconnector_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	notinit "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/connectors/storage_market"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+type fakePolicyChain struct {
+	head block.TipSet
+}
+
+func (c *fakePolicyChain) Head() block.TipSetKey {
+	return c.head.Key()
+}
+
+func (c *fakePolicyChain) GetTipSet(block.TipSetKey) (block.TipSet, error) {
+	return c.head, nil
+}
+
+// fakePolicyView resolves key addresses to the ID addresses in a map and
+// leaves ID addresses as they are.
+type fakePolicyView struct {
+	ids map[address.Address]address.Address
+}
+
+func (v *fakePolicyView) InitResolveAddress(_ context.Context, a address.Address) (address.Address, error) {
+	if a.Protocol() == address.ID {
+		return a, nil
+	}
+	if id, ok := v.ids[a]; ok {
+		return id, nil
+	}
+	return address.Undef, notinit.ErrAddressNotFound
+}
+
+type fakeVerifiedPricer struct {
+	price *abi.TokenAmount
+}
+
+func (p *fakeVerifiedPricer) VerifiedPrice() (abi.TokenAmount, bool) {
+	if p.price == nil {
+		return abi.TokenAmount{}, false
+	}
+	return *p.price, true
+}
+
+type policyHarness struct {
+	cfg    *config.DealPolicyConfig
+	policy *DealPolicy
+	deals  []storagemarket.MinerDeal
+	prices *fakeVerifiedPricer
+	view   *fakePolicyView
+}
+
+func newPolicyHarness(t *testing.T) *policyHarness {
+	head, err := block.NewTipSet(&block.Block{Height: 100})
+	require.NoError(t, err)
+	h := &policyHarness{
+		cfg:    &config.DealPolicyConfig{},
+		prices: &fakeVerifiedPricer{},
+		view:   &fakePolicyView{ids: map[address.Address]address.Address{}},
+	}
+	views := func(block.TipSetKey) (PolicyStateView, error) {
+		return h.view, nil
+	}
+	h.policy = NewDealPolicy(h.cfg, &fakePolicyChain{head: head}, views, func() ([]storagemarket.MinerDeal, error) {
+		return h.deals, nil
+	}, h.prices)
+	return h
+}
+
+func policyDeal(t *testing.T, client address.Address) storagemarket.MinerDeal {
+	return storagemarket.MinerDeal{
+		ClientDealProposal: market.ClientDealProposal{
+			Proposal: market.DealProposal{
+				PieceCID:             types.CidFromString(t, "piece"),
+				PieceSize:            abi.PaddedPieceSize(2048),
+				Client:               client,
+				Provider:             vmaddr.RequireIDAddress(t, 1000),
+				StartEpoch:           200,
+				EndEpoch:             1200,
+				StoragePricePerEpoch: abi.NewTokenAmount(1),
+				ProviderCollateral:   abi.NewTokenAmount(0),
+				ClientCollateral:     abi.NewTokenAmount(0),
+			},
+		},
+		ProposalCid: types.CidFromString(t, "proposal"),
+		Ref:         &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync},
+	}
+}
+
+func TestDealPolicyRules(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	client, other := vmaddr.RequireIDAddress(t, 100), vmaddr.RequireIDAddress(t, 101)
+	deal := policyDeal(t, client)
+
+	h := newPolicyHarness(t)
+	accept, _, err := h.policy.Decide(ctx, deal)
+	require.NoError(t, err)
+	assert.True(t, accept)
+
+	rejects := map[string]func(cfg *config.DealPolicyConfig){
+		"allowed clients":   func(cfg *config.DealPolicyConfig) { cfg.AllowedClients = []address.Address{other} },
+		"denied clients":    func(cfg *config.DealPolicyConfig) { cfg.DeniedClients = []address.Address{client} },
+		"min piece size":    func(cfg *config.DealPolicyConfig) { cfg.MinPieceSize = 4096 },
+		"max piece size":    func(cfg *config.DealPolicyConfig) { cfg.MaxPieceSize = 1024 },
+		"min duration":      func(cfg *config.DealPolicyConfig) { cfg.MinDuration = 1001 },
+		"max duration":      func(cfg *config.DealPolicyConfig) { cfg.MaxDuration = 999 },
+		"min start delay":   func(cfg *config.DealPolicyConfig) { cfg.MinStartDelay = 101 },
+		"reject unverified": func(cfg *config.DealPolicyConfig) { cfg.RejectUnverified = true },
+	}
+	for name, configure := range rejects {
+		t.Run(name, func(t *testing.T) {
+			h := newPolicyHarness(t)
+			configure(h.cfg)
+			accept, reason, err := h.policy.Decide(ctx, deal)
+			require.NoError(t, err)
+			assert.False(t, accept)
+			assert.NotEmpty(t, reason)
+		})
+	}
+
+	t.Log("deals are rejected while too many deals wait to be sealed")
+	h.cfg.MaxSealingDeals = 2
+	h.deals = []storagemarket.MinerDeal{
+		{State: storagemarket.StorageDealSealing},
+		{State: storagemarket.StorageDealActive},
+	}
+	accept, _, err = h.policy.Decide(ctx, deal)
+	require.NoError(t, err)
+	assert.True(t, accept)
+
+	h.deals = append(h.deals, storagemarket.MinerDeal{State: storagemarket.StorageDealWaitingForData})
+	accept, reason, err := h.policy.Decide(ctx, deal)
+	require.NoError(t, err)
+	assert.False(t, accept)
+	assert.Contains(t, reason, "2 deals are waiting to be sealed")
+}
+
+func TestDealPolicyResolvesClientAddresses(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	id := vmaddr.RequireIDAddress(t, 100)
+	addrGetter := vmaddr.NewForTestGetter()
+	key, unknown := addrGetter(), addrGetter()
+
+	t.Log("a client proposing from its key address matches lists of its ID address")
+	h := newPolicyHarness(t)
+	h.view.ids[key] = id
+	h.cfg.DeniedClients = []address.Address{id}
+	accept, _, err := h.policy.Decide(ctx, policyDeal(t, key))
+	require.NoError(t, err)
+	assert.False(t, accept)
+
+	t.Log("a client proposing from its ID address matches lists of its key address")
+	h = newPolicyHarness(t)
+	h.view.ids[key] = id
+	h.cfg.AllowedClients = []address.Address{key}
+	accept, _, err = h.policy.Decide(ctx, policyDeal(t, id))
+	require.NoError(t, err)
+	assert.True(t, accept)
+
+	t.Log("addresses with no actor are compared as they are")
+	h.cfg.AllowedClients = []address.Address{key, unknown}
+	accept, _, err = h.policy.Decide(ctx, policyDeal(t, unknown))
+	require.NoError(t, err)
+	assert.True(t, accept)
+	accept, _, err = h.policy.Decide(ctx, policyDeal(t, vmaddr.RequireIDAddress(t, 101)))
+	require.NoError(t, err)
+	assert.False(t, accept)
+}
+
+func TestDealPolicyNilConfig(t *testing.T) {
+	tf.UnitTest(t)
+	head, err := block.NewTipSet(&block.Block{Height: 100})
+	require.NoError(t, err)
+	policy := NewDealPolicy(nil, &fakePolicyChain{head: head}, nil, func() ([]storagemarket.MinerDeal, error) {
+		return nil, nil
+	}, &fakeVerifiedPricer{})
+	accept, _, err := policy.Decide(context.Background(), policyDeal(t, vmaddr.RequireIDAddress(t, 100)))
+	require.NoError(t, err)
+	assert.True(t, accept)
+}
+
+func TestDealPolicyVerifiedPrice(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	deal := policyDeal(t, vmaddr.RequireIDAddress(t, 100))
+	deal.Proposal.VerifiedDeal = true
+
+	h := newPolicyHarness(t)
+	// 2048 bytes at 1<<20 per GiB per epoch cost 2 per epoch.
+	price := abi.NewTokenAmount(1 << 20)
+	h.prices.price = &price
+	accept, reason, err := h.policy.Decide(ctx, deal)
+	require.NoError(t, err)
+	assert.False(t, accept)
+	assert.Contains(t, reason, "verified deal price")
+
+	deal.Proposal.StoragePricePerEpoch = abi.NewTokenAmount(2)
+	accept, _, err = h.policy.Decide(ctx, deal)
+	require.NoError(t, err)
+	assert.True(t, accept)
+
+	t.Log("unverified deals are left to the market's check of the ask price")
+	deal.Proposal.VerifiedDeal = false
+	deal.Proposal.StoragePricePerEpoch = abi.NewTokenAmount(1)
+	accept, _, err = h.policy.Decide(ctx, deal)
+	require.NoError(t, err)
+	assert.True(t, accept)
+}
+
+func TestDealPolicyDecisionCommand(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "deal-policy")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	script := filepath.Join(dir, "decide.sh")
+	require.NoError(t, ioutil.WriteFile(script, []byte(`#!/bin/sh
+if grep -q '"transferType":"manual"'; then
+	echo "offline deals are welcome"
+	exit 0
+fi
+echo "only offline deals are accepted"
+exit 1
+`), 0700))
+
+	h := newPolicyHarness(t)
+	h.cfg.DecisionCommand = script
+	deal := policyDeal(t, vmaddr.RequireIDAddress(t, 100))
+	accept, reason, err := h.policy.Decide(ctx, deal)
+	require.NoError(t, err)
+	assert.False(t, accept)
+	assert.Equal(t, "only offline deals are accepted", reason)
+
+	deal.Ref.TransferType = storagemarket.TTManual
+	accept, reason, err = h.policy.Decide(ctx, deal)
+	require.NoError(t, err)
+	assert.True(t, accept)
+	assert.Equal(t, "offline deals are welcome", reason)
+
+	t.Log("deals are not decided when the command cannot run")
+	h.cfg.DecisionCommand = filepath.Join(dir, "missing")
+	_, _, err = h.policy.Decide(ctx, deal)
+	assert.Error(t, err)
+	h.cfg.DecisionCommand = " "
+	_, _, err = h.policy.Decide(ctx, deal)
+	assert.Error(t, err)
+}
+
+func TestDealPolicyDecisionURL(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	var received DealDecisionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		_ = json.NewEncoder(w).Encode(DealDecision{Accept: false, Reason: "busy"})
+	}))
+	defer server.Close()
+
+	h := newPolicyHarness(t)
+	h.cfg.DecisionURL = server.URL
+	deal := policyDeal(t, vmaddr.RequireIDAddress(t, 100))
+	accept, reason, err := h.policy.Decide(ctx, deal)
+	require.NoError(t, err)
+	assert.False(t, accept)
+	assert.Equal(t, "busy", reason)
+	assert.Equal(t, deal.ProposalCid, received.ProposalCid)
+	assert.Equal(t, abi.ChainEpoch(100), received.Height)
+	assert.Equal(t, deal.Proposal.Client, received.Proposal.Client)
+}
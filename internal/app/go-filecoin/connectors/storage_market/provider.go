@@ -3,6 +3,7 @@ package storagemarketconnector
 import (
 	"context"
 	"io"
+	"sync"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-fil-markets/shared"
@@ -36,6 +37,12 @@ type StorageProviderNodeConnector struct {
 	chainStore   chainReader
 	outbox       *message.Outbox
 	pieceManager piecemanager.PieceManager
+	policy       *DealPolicy
+
+	// decisions holds the decisions on deals not yet logged with the event
+	// accepting or rejecting them.
+	decisionsLk sync.Mutex
+	decisions   map[cid.Cid]string
 }
 
 var _ storagemarket.StorageProviderNode = &StorageProviderNodeConnector{}
@@ -48,6 +55,7 @@ func NewStorageProviderNodeConnector(ma address.Address,
 	pm piecemanager.PieceManager,
 	s types.Signer,
 	sv *appstate.Viewer,
+	policy *DealPolicy,
 ) *StorageProviderNodeConnector {
 	return &StorageProviderNodeConnector{
 		connectorCommon: connectorCommon{cs, sv, w, s, ob},
@@ -55,6 +63,8 @@ func NewStorageProviderNodeConnector(ma address.Address,
 		minerAddr:       ma,
 		outbox:          ob,
 		pieceManager:    pm,
+		policy:          policy,
+		decisions:       make(map[cid.Cid]string),
 	}
 }
 
@@ -198,7 +208,42 @@ func (s *StorageProviderNodeConnector) LocatePieceForDealWithinSector(ctx contex
 	return
 }
 
+// DecideOnDeal decides whether to accept a deal matching the ask by the deal
+// policy, recording the decision to be logged with the event it results in.
+func (s *StorageProviderNodeConnector) DecideOnDeal(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error) {
+	accept, reason, err := s.policy.Decide(ctx, deal)
+	if err != nil {
+		return false, "", err
+	}
+
+	decision := "rejected: " + reason
+	if accept {
+		decision = "accepted: " + reason
+	}
+	s.decisionsLk.Lock()
+	s.decisions[deal.ProposalCid] = decision
+	s.decisionsLk.Unlock()
+	return accept, reason, nil
+}
+
 // EventLogger logs new events on the storage provider
 func (s *StorageProviderNodeConnector) EventLogger(event storagemarket.ProviderEvent, deal storagemarket.MinerDeal) {
+	if decision, ok := s.takeDecision(event, deal.ProposalCid); ok {
+		log.Infof("Event: %s, Proposal CID: %s, State: %s, Message: %s, Decision: %s", storagemarket.ProviderEvents[event], deal.ProposalCid, storagemarket.DealStates[deal.State], deal.Message, decision)
+		return
+	}
 	log.Infof("Event: %s, Proposal CID: %s, State: %s, Message: %s", storagemarket.ProviderEvents[event], deal.ProposalCid, storagemarket.DealStates[deal.State], deal.Message)
 }
+
+// takeDecision returns and forgets the decision on a deal if the event is the
+// one accepting or rejecting it.
+func (s *StorageProviderNodeConnector) takeDecision(event storagemarket.ProviderEvent, proposalCid cid.Cid) (string, bool) {
+	if event != storagemarket.ProviderEventDealAccepted && event != storagemarket.ProviderEventDealRejected {
+		return "", false
+	}
+	s.decisionsLk.Lock()
+	defer s.decisionsLk.Unlock()
+	decision, ok := s.decisions[proposalCid]
+	delete(s.decisions, proposalCid)
+	return decision, ok
+}
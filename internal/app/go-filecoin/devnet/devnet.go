@@ -0,0 +1,256 @@
+// Package devnet runs a local network of several mining nodes in a single
+// process, connected over an in-memory mock network.
+package devnet
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	logging "github.com/ipfs/go-log/v2"
+	acrypto "github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/node"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/constants"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/drand"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	gengen "github.com/filecoin-project/go-filecoin/tools/gengen/util"
+)
+
+var log = logging.Logger("devnet")
+
+// Config configures a devnet.
+type Config struct {
+	// Miners is the number of mining nodes.
+	Miners int
+	// SectorsPerMiner is the number of presealed sectors committed to each
+	// miner in the genesis state.
+	SectorsPerMiner int
+	// BlockTime is the epoch duration of the network.
+	BlockTime time.Duration
+	// PropagationDelay is the time nodes wait for blocks after the start of
+	// an epoch before mining.
+	PropagationDelay time.Duration
+	// APIPort is the port of the first node's API. Node i listens on
+	// APIPort+i. If zero, ports are chosen by the OS.
+	APIPort int
+	// Dir is the directory holding each node's repo.
+	Dir string
+	// Seed seeds the generation of keys.
+	Seed int64
+}
+
+// Devnet is a running local network.
+type Devnet struct {
+	// Nodes are the network's nodes; node i mines with miner i.
+	Nodes []*node.Node
+	// Miners are the addresses of the miner actors created at genesis.
+	Miners []address.Address
+	// Genesis is the cid of the genesis block.
+	Genesis cid.Cid
+
+	// repos are closed by the nodes once built, and only closed directly if
+	// construction fails.
+	repos []repo.Repo
+	mn    mocknet.Mocknet
+}
+
+// New generates a genesis block with cfg.Miners presealed miners and builds a
+// node for each of them, connected over a mock network. The nodes are not
+// started.
+//
+// The network uses mock proofs.
+func New(ctx context.Context, cfg Config) (*Devnet, error) {
+	if cfg.Miners < 1 {
+		return nil, fmt.Errorf("devnet requires at least one miner")
+	}
+	rnd := rand.New(rand.NewSource(cfg.Seed))
+
+	peerKeys := make([]acrypto.PrivKey, cfg.Miners)
+	genCfg := &gengen.GenesisCfg{
+		Seed:       cfg.Seed,
+		KeysToGen:  cfg.Miners,
+		Network:    "devnet",
+		Time:       uint64(time.Now().Unix()),
+		MockProofs: true,
+	}
+	for i := 0; i < cfg.Miners; i++ {
+		sk, _, err := acrypto.GenerateEd25519Key(rnd)
+		if err != nil {
+			return nil, err
+		}
+		peerKeys[i] = sk
+		pid, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			return nil, err
+		}
+		commits, err := gengen.MakeCommitCfgs(cfg.SectorsPerMiner)
+		if err != nil {
+			return nil, err
+		}
+		genCfg.Miners = append(genCfg.Miners, &gengen.CreateStorageMinerConfig{
+			Owner:            i,
+			PeerID:           pid.Pretty(),
+			CommittedSectors: commits,
+			SealProofType:    constants.DevSealProofType,
+		})
+		genCfg.PreallocatedFunds = append(genCfg.PreallocatedFunds, "1000000")
+	}
+
+	genBlocks := blockstore.NewBlockstore(ds.NewMapDatastore())
+	info, err := gengen.GenGen(ctx, genCfg, genBlocks)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate genesis")
+	}
+	genesisInit := func(cst cbor.IpldStore, bs blockstore.Blockstore) (*block.Block, error) {
+		return copyGenesis(ctx, genBlocks, cst, bs, info.GenesisCid)
+	}
+
+	dn := &Devnet{
+		Genesis: info.GenesisCid,
+		mn:      mocknet.New(ctx),
+	}
+	genesisTime := time.Unix(int64(genCfg.Time), 0)
+	for i := 0; i < cfg.Miners; i++ {
+		minerInfo := info.Miners[i]
+		nd, rep, err := dn.buildNode(ctx, cfg, i, peerKeys[i], genesisInit, genesisTime, minerInfo.Address, info.Keys[minerInfo.Owner])
+		if err != nil {
+			_ = dn.closeRepos()
+			return nil, errors.Wrapf(err, "failed to build node %d", i)
+		}
+		dn.Nodes = append(dn.Nodes, nd)
+		dn.Miners = append(dn.Miners, minerInfo.Address)
+		dn.repos = append(dn.repos, rep)
+	}
+
+	if err := dn.mn.LinkAll(); err != nil {
+		_ = dn.closeRepos()
+		return nil, err
+	}
+	return dn, nil
+}
+
+func (dn *Devnet) buildNode(ctx context.Context, cfg Config, i int, peerKey acrypto.PrivKey, gen func(cbor.IpldStore, blockstore.Blockstore) (*block.Block, error),
+	genesisTime time.Time, minerAddr address.Address, ownerKey *crypto.KeyInfo) (*node.Node, repo.Repo, error) {
+	repoDir := filepath.Join(cfg.Dir, fmt.Sprintf("node-%d", i))
+	nodeCfg := config.NewDefaultConfig()
+	nodeCfg.API.Address = "/ip4/127.0.0.1/tcp/0"
+	if cfg.APIPort != 0 {
+		nodeCfg.API.Address = fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", cfg.APIPort+i)
+	}
+	nodeCfg.Mining.MinerAddress = minerAddr
+	nodeCfg.SectorBase.RootDirPath = filepath.Join(repoDir, "sectors")
+	if err := repo.InitFSRepoDirect(repoDir, repo.Version, nodeCfg); err != nil {
+		return nil, nil, err
+	}
+	rep, err := repo.OpenFSRepo(repoDir, repo.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := node.Init(ctx, rep, gen, node.PeerKeyOpt(peerKey), node.DefaultKeyOpt(ownerKey)); err != nil {
+		_ = rep.Close()
+		return nil, nil, err
+	}
+
+	// Each mock peer needs a distinct address.
+	h, err := dn.mn.AddPeer(peerKey, ma.StringCast(fmt.Sprintf("/ip4/10.0.0.%d/tcp/4001", i+1)))
+	if err != nil {
+		_ = rep.Close()
+		return nil, nil, err
+	}
+
+	opts, err := node.OptionsFromRepo(rep)
+	if err != nil {
+		_ = rep.Close()
+		return nil, nil, err
+	}
+	opts = append(opts,
+		node.Libp2pHostOption(h),
+		node.BlockTime(cfg.BlockTime),
+		node.PropagationDelay(cfg.PropagationDelay),
+		node.DrandConfigOption(drand.NewFake(genesisTime)),
+		node.MockProofs(true),
+		node.MonkeyPatchSetProofTypeOption(constants.DevRegisteredSealProof),
+	)
+
+	nd, err := node.New(ctx, opts...)
+	if err != nil {
+		_ = rep.Close()
+		return nil, nil, err
+	}
+	return nd, rep, nil
+}
+
+// Start starts every node, connects them to each other and starts mining.
+func (dn *Devnet) Start(ctx context.Context) error {
+	for i, nd := range dn.Nodes {
+		if err := nd.Start(ctx); err != nil {
+			return errors.Wrapf(err, "failed to start node %d", i)
+		}
+	}
+	if err := dn.mn.ConnectAllButSelf(); err != nil {
+		return errors.Wrap(err, "failed to connect nodes")
+	}
+	for i, nd := range dn.Nodes {
+		if err := nd.StartMining(ctx); err != nil {
+			return errors.Wrapf(err, "failed to start mining on node %d", i)
+		}
+		log.Infof("node %d (%s) mining with miner %s", i, nd.Host().ID(), dn.Miners[i])
+	}
+	return nil
+}
+
+// Stop stops every node. Stopping a node closes its repo.
+func (dn *Devnet) Stop(ctx context.Context) {
+	for _, nd := range dn.Nodes {
+		nd.Stop(ctx)
+	}
+}
+
+func (dn *Devnet) closeRepos() error {
+	var firstErr error
+	for _, rep := range dn.repos {
+		if err := rep.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// copyGenesis copies the generated genesis state into a node's blockstore.
+func copyGenesis(ctx context.Context, from blockstore.Blockstore, cst cbor.IpldStore, to blockstore.Blockstore, genesis cid.Cid) (*block.Block, error) {
+	keys, err := from.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for k := range keys {
+		blk, err := from.Get(k)
+		if err != nil {
+			return nil, err
+		}
+		if err := to.Put(blk); err != nil {
+			return nil, err
+		}
+	}
+
+	var blk block.Block
+	if err := cst.Get(ctx, genesis, &blk); err != nil {
+		return nil, err
+	}
+	return &blk, nil
+}
@@ -0,0 +1,55 @@
+package devnet_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/devnet"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestDevnetMinesAndSyncs(t *testing.T) {
+	tf.IntegrationTest(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	dir, err := ioutil.TempDir("", "devnet-test")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	dn, err := devnet.New(ctx, devnet.Config{
+		Miners:           2,
+		SectorsPerMiner:  2,
+		BlockTime:        time.Second,
+		PropagationDelay: 200 * time.Millisecond,
+		Dir:              dir,
+		Seed:             42,
+	})
+	require.NoError(t, err)
+	require.Len(t, dn.Nodes, 2)
+	require.Len(t, dn.Miners, 2)
+	assert.NotEqual(t, dn.Miners[0], dn.Miners[1])
+
+	require.NoError(t, dn.Start(ctx))
+	defer dn.Stop(ctx)
+
+	// Both nodes converge on a chain that has grown past genesis.
+	require.Eventually(t, func() bool {
+		first, err := dn.Nodes[0].PorcelainAPI.ChainHead()
+		if err != nil {
+			return false
+		}
+		second, err := dn.Nodes[1].PorcelainAPI.ChainHead()
+		if err != nil {
+			return false
+		}
+		height, err := first.Height()
+		return err == nil && height >= 3 && first.Key().Equals(second.Key())
+	}, 45*time.Second, 200*time.Millisecond)
+}
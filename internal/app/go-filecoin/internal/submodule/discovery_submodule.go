@@ -7,6 +7,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/discovery"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/net"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/util/moresync"
 	"github.com/ipfs/go-cid"
@@ -30,6 +31,7 @@ type DiscoverySubmodule struct {
 
 type discoveryConfig interface {
 	GenesisCid() cid.Cid
+	Journal() journal.Journal
 }
 
 // NewDiscoverySubmodule creates a new discovery submodule.
@@ -59,7 +61,7 @@ func NewDiscoverySubmodule(ctx context.Context, config discoveryConfig, bsConfig
 		Bootstrapper:   bootstrapper,
 		BootstrapReady: moresync.NewLatch(uint(minPeerThreshold)),
 		PeerTracker:    peerTracker,
-		HelloHandler:   discovery.NewHelloProtocolHandler(network.Host, config.GenesisCid(), network.NetworkName),
+		HelloHandler:   discovery.NewHelloProtocolHandler(network.Host, config.GenesisCid(), network.NetworkName, config.Journal().Topic("discovery")),
 	}, nil
 }
 
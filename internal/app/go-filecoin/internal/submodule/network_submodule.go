@@ -65,6 +65,7 @@ type networkConfig interface {
 	OfflineMode() bool
 	IsRelay() bool
 	Libp2pOpts() []libp2p.Option
+	Libp2pHost() host.Host
 }
 
 type networkRepo interface {
@@ -103,10 +104,18 @@ func NewNetworkSubmodule(ctx context.Context, config networkConfig, repo network
 			return r, err
 		}
 
-		var err error
-		peerHost, err = buildHost(ctx, config, libP2pOpts, repo, makeDHT)
-		if err != nil {
-			return NetworkSubmodule{}, err
+		if h := config.Libp2pHost(); h != nil {
+			// Use the provided host, adding only routing.
+			if _, err := makeDHT(h); err != nil {
+				return NetworkSubmodule{}, err
+			}
+			peerHost = rhost.Wrap(h, router)
+		} else {
+			var err error
+			peerHost, err = buildHost(ctx, config, libP2pOpts, repo, makeDHT)
+			if err != nil {
+				return NetworkSubmodule{}, err
+			}
 		}
 		// require message signing in online mode when we have priv key
 		pubsubMessageSigning = true
@@ -7,11 +7,16 @@ import (
 // ProofVerificationSubmodule adds proof verification capabilities to the node.
 type ProofVerificationSubmodule struct {
 	ProofVerifier ffiwrapper.Verifier
+
+	// MockProofs is true if the node generates and verifies mock proofs
+	// rather than real ones.
+	MockProofs bool
 }
 
 // NewProofVerificationSubmodule creates a new proof verification submodule.
-func NewProofVerificationSubmodule(verifier ffiwrapper.Verifier) ProofVerificationSubmodule {
+func NewProofVerificationSubmodule(verifier ffiwrapper.Verifier, mockProofs bool) ProofVerificationSubmodule {
 	return ProofVerificationSubmodule{
 		ProofVerifier: verifier,
+		MockProofs:    mockProofs,
 	}
 }
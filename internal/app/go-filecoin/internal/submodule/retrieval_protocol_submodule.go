@@ -1,6 +1,9 @@
 package submodule
 
 import (
+	"context"
+	"sync"
+
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-fil-markets/piecestore"
 	iface "github.com/filecoin-project/go-fil-markets/retrievalmarket"
@@ -12,10 +15,14 @@ import (
 	"github.com/ipfs/go-datastore/namespace"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
 
 	retmkt "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/connectors/retrieval_market"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paymentchannel"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/piecemanager"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/retrieval"
 )
 
 // RetrievalProviderDSPrefix is a prefix for all datastore keys related to the retrieval provider
@@ -27,45 +34,108 @@ const RetrievalCounterDSKey = "/retrievalmarket/client/counter"
 // RetrievalClientDSPrefix is a prefix for all datastore keys related to the retrieval clients
 const RetrievalClientDSPrefix = "/retrievalmarket/client"
 
+var retrievalBytesServedCnt = metrics.NewInt64SumCounter("markets/retrieval_bytes_served", "Number of bytes sent to retrieval clients", metrics.PeerKey)
+
 // RetrievalProtocolSubmodule enhances the node with retrieval protocol
 // capabilities.
 type RetrievalProtocolSubmodule struct {
-	client   iface.RetrievalClient
-	provider iface.RetrievalProvider
+	client    iface.RetrievalClient
+	provider  iface.RetrievalProvider
+	retriever *retrieval.Retriever
+
+	// PaychMgr manages the payment channels paying for and paid by retrievals.
+	PaychMgr *paymentchannel.Manager
+
+	netwk network.RetrievalMarketNetwork
+	bs    blockstore.Blockstore
+	ds    datastore.Batching
+	cr    *cst.ChainStateReadWriter
 }
 
-// NewRetrievalProtocolSubmodule creates a new retrieval protocol submodule.
+// NewRetrievalProtocolSubmodule creates a new retrieval protocol submodule
+// with a retrieval client. The provider is added when mining starts.
 func NewRetrievalProtocolSubmodule(
 	bs blockstore.Blockstore,
 	ds datastore.Batching,
 	cr *cst.ChainStateReadWriter,
 	host host.Host,
-	providerAddr address.Address,
 	signer retmkt.RetrievalSigner,
-	pchMgrAPI retmkt.PaychMgrAPI,
-	pieceManager piecemanager.PieceManager,
+	pchMgr *paymentchannel.Manager,
 ) (*RetrievalProtocolSubmodule, error) {
-
-	retrievalDealPieceStore := piecestore.NewPieceStore(namespace.Wrap(ds, datastore.NewKey(PieceStoreDSPrefix)))
-
 	netwk := network.NewFromLibp2pHost(host)
-	pnode := retmkt.NewRetrievalProviderConnector(netwk, pieceManager, bs, pchMgrAPI, nil)
+	cnode := retmkt.NewRetrievalClientConnector(bs, cr, signer, pchMgr)
+	counter := storedcounter.New(ds, datastore.NewKey(RetrievalCounterDSKey))
 
-	marketProvider, err := impl.NewProvider(providerAddr, pnode, netwk, retrievalDealPieceStore, bs, namespace.Wrap(ds, datastore.NewKey(RetrievalProviderDSPrefix)))
+	resolver := discovery.Multi(discovery.NewLocal(namespace.Wrap(ds, datastore.NewKey(DiscoveryDSPrefix))))
+	marketClient, err := impl.NewClient(netwk, bs, cnode, resolver, namespace.Wrap(ds, datastore.NewKey(RetrievalClientDSPrefix)), counter)
 	if err != nil {
 		return nil, err
 	}
 
-	cnode := retmkt.NewRetrievalClientConnector(bs, cr, signer, pchMgrAPI)
-	counter := storedcounter.New(ds, datastore.NewKey(RetrievalCounterDSKey))
+	return &RetrievalProtocolSubmodule{
+		client:    marketClient,
+		retriever: retrieval.NewRetriever(marketClient, bs),
+		PaychMgr:  pchMgr,
+		netwk:     netwk,
+		bs:        bs,
+		ds:        ds,
+		cr:        cr,
+	}, nil
+}
 
-	resolver := discovery.Multi(discovery.NewLocal(namespace.Wrap(ds, datastore.NewKey(DiscoveryDSPrefix))))
-	marketClient, err := impl.NewClient(netwk, bs, cnode, resolver, namespace.Wrap(ds, datastore.NewKey(RetrievalClientDSPrefix)), counter)
+// AddRetrievalProvider creates and starts the retrieval provider serving the
+// pieces of the miner at `providerAddr`.
+func (rps *RetrievalProtocolSubmodule) AddRetrievalProvider(providerAddr address.Address, pieceManager piecemanager.PieceManager) error {
+	retrievalDealPieceStore := piecestore.NewPieceStore(namespace.Wrap(rps.ds, datastore.NewKey(PieceStoreDSPrefix)))
+	pnode := retmkt.NewRetrievalProviderConnector(rps.netwk, pieceManager, rps.bs, rps.PaychMgr, rps.cr)
+
+	marketProvider, err := impl.NewProvider(providerAddr, pnode, rps.netwk, retrievalDealPieceStore, rps.bs, namespace.Wrap(rps.ds, datastore.NewKey(RetrievalProviderDSPrefix)))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return &RetrievalProtocolSubmodule{marketClient, marketProvider}, nil
+	sent := &bytesSentTracker{sent: make(map[retrievalDealKey]uint64)}
+	marketProvider.SubscribeToEvents(sent.update)
+	if err := marketProvider.Start(); err != nil {
+		return err
+	}
+	rps.provider = marketProvider
+	return nil
+}
+
+// Stop stops the retrieval provider, if any, from handling queries and deals.
+func (rps *RetrievalProtocolSubmodule) Stop() error {
+	if rps.provider == nil {
+		return nil
+	}
+	return rps.provider.Stop()
+}
+
+type retrievalDealKey struct {
+	receiver peer.ID
+	id       iface.DealID
+}
+
+// bytesSentTracker counts the bytes served by the retrieval provider from the
+// running totals reported in each deal's state.
+type bytesSentTracker struct {
+	lk   sync.Mutex
+	sent map[retrievalDealKey]uint64
+}
+
+func (t *bytesSentTracker) update(_ iface.ProviderEvent, state iface.ProviderDealState) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	key := retrievalDealKey{receiver: state.Receiver, id: state.ID}
+	if state.TotalSent > t.sent[key] {
+		ctx := metrics.WithTag(context.Background(), metrics.PeerKey, state.Receiver.String())
+		retrievalBytesServedCnt.Inc(ctx, int64(state.TotalSent-t.sent[key]))
+		t.sent[key] = state.TotalSent
+	}
+	if state.Status == iface.DealStatusCompleted {
+		delete(t.sent, key)
+	}
 }
 
 func (rps *RetrievalProtocolSubmodule) Client() iface.RetrievalClient {
@@ -75,3 +145,7 @@ func (rps *RetrievalProtocolSubmodule) Client() iface.RetrievalClient {
 func (rps *RetrievalProtocolSubmodule) Provider() iface.RetrievalProvider {
 	return rps.provider
 }
+
+func (rps *RetrievalProtocolSubmodule) Retriever() *retrieval.Retriever {
+	return rps.retriever
+}
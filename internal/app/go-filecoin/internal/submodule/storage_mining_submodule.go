@@ -7,7 +7,6 @@ import (
 	"github.com/filecoin-project/go-address"
 	sectorstorage "github.com/filecoin-project/sector-storage"
 	"github.com/filecoin-project/sector-storage/ffiwrapper"
-	"github.com/filecoin-project/sector-storage/stores"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
 	fsm "github.com/filecoin-project/storage-fsm"
@@ -21,9 +20,11 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsampler"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/piecemanager"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/poster"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/postgenerator"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/proofs"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
 )
@@ -55,22 +56,44 @@ func NewStorageMiningSubmodule(
 	sealProofType abi.RegisteredProof,
 	r repo.Repo,
 	postGeneratorOverride postgenerator.PoStGenerator,
+	mockProofs bool,
+	jrl journal.Journal,
 ) (*StorageMiningSubmodule, error) {
 	chainThresholdScheduler := chainsampler.NewHeightThresholdScheduler(c.ChainReader)
 
 	ccn := fsmchain.NewChainConnector(c.ChainReader)
 
-	sdx := stores.NewIndex()
-
-	fcg := ffiwrapper.Config{
-		SealProofType: sealProofType,
-	}
-
-	scg := sectorstorage.SealerConfig{AllowPreCommit1: true, AllowPreCommit2: true, AllowCommit: true}
-
-	mgr, err := sectorstorage.New(context.TODO(), fsmstorage.NewRepoStorageConnector(r), sdx, &fcg, scg, []string{}, nil)
-	if err != nil {
-		return nil, err
+	var mgr sectorstorage.SectorManager
+	var prover postgenerator.PoStGenerator
+	var verifier ffiwrapper.Verifier = ffiwrapper.ProofVerifier
+	var storage piecemanager.SectorStorage
+	// Mock proofs keep no sector files for PoSts to guard.
+	var proving sync.Locker = new(sync.Mutex)
+	if mockProofs {
+		sectorSize, err := sealProofType.SectorSize()
+		if err != nil {
+			return nil, err
+		}
+		mockProver := proofs.NewMockProver(sectorSize)
+		mgr, prover, verifier = mockProver, mockProver, proofs.MockVerifier{}
+	} else {
+		fcg := ffiwrapper.Config{
+			SealProofType: sealProofType,
+		}
+
+		scg := sectorstorage.SealerConfig{AllowPreCommit1: true, AllowPreCommit2: true, AllowCommit: true}
+
+		local, err := fsmstorage.NewRepoStorageConnector(r)
+		if err != nil {
+			return nil, err
+		}
+		sdx := fsmstorage.NewSectorStorage(local, sealProofType)
+		ffiMgr, err := sectorstorage.New(context.TODO(), local, sdx, &fcg, scg, []string{}, nil)
+		if err != nil {
+			return nil, err
+		}
+		sdx.SetPathOpener(ffiMgr)
+		mgr, prover, storage, proving = ffiMgr, ffiMgr.Prover, sdx, sdx.ProvingLock()
 	}
 
 	sid := sectors.NewPersistedSectorNumberCounter(ds)
@@ -92,20 +115,20 @@ func NewStorageMiningSubmodule(
 	pcp := fsm.NewBasicPreCommitPolicy(&ccn, abi.ChainEpoch(2*60*24), ppStart%miner.WPoStProvingPeriod)
 
 	fsmConnector := fsmeventsconnector.New(chainThresholdScheduler, c.State)
-	fsm := fsm.New(ncn, fsmConnector, minerAddrID, ds, mgr, sid, ffiwrapper.ProofVerifier, &pcp)
+	fsm := fsm.New(ncn, fsmConnector, minerAddrID, ds, mgr, sid, verifier, &pcp)
 
-	bke := piecemanager.NewFiniteStateMachineBackEnd(fsm, sid)
+	bke := piecemanager.NewFiniteStateMachineBackEnd(fsm, sid, ds, storage)
 
 	modu := &StorageMiningSubmodule{
 		PieceManager: &bke,
 		hs:           chainThresholdScheduler,
 		fsm:          fsm,
-		poster:       poster.NewPoster(minerAddr, m.Outbox, mgr, c.State, stateViewer, mw),
+		poster:       poster.NewPoster(minerAddr, m.Outbox, mgr, proving, c.State, stateViewer, mw, jrl.Topic("poster")),
 	}
 
 	// allow the caller to provide a thing which generates fake PoSts
 	if postGeneratorOverride == nil {
-		modu.PoStGenerator = mgr.Prover
+		modu.PoStGenerator = prover
 	} else {
 		modu.PoStGenerator = postGeneratorOverride
 	}
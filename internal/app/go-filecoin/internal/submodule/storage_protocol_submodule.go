@@ -3,6 +3,7 @@ package submodule
 import (
 	"context"
 	"os"
+	"sync"
 
 	"github.com/filecoin-project/go-statestore"
 	"github.com/filecoin-project/go-storedcounter"
@@ -19,6 +20,7 @@ import (
 	"github.com/filecoin-project/go-fil-markets/storagemarket/impl/storedask"
 	smnetwork "github.com/filecoin-project/go-fil-markets/storagemarket/network"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/namespace"
 	"github.com/ipfs/go-graphsync"
@@ -29,8 +31,14 @@ import (
 	storagemarketconnector "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/connectors/storage_market"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paths"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/piecemanager"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage/asks"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage/tracker"
 	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 )
@@ -59,8 +67,14 @@ type StorageProtocolSubmodule struct {
 	StorageClient    iface.StorageClient
 	StorageProvider  iface.StorageProvider
 	dataTransfer     datatransfer.Manager
+	host             host.Host
 	requestValidator *smvalid.UnifiedRequestValidator
 	pieceManager     piecemanager.PieceManager
+	journal          journal.Writer
+	dealTracker      *tracker.Tracker
+	askManager       *asks.Manager
+	clientDeals      *dealStateCounter
+	providerDeals    *dealStateCounter
 }
 
 // NewStorageProtocolSubmodule creates a new storage protocol submodule.
@@ -76,6 +90,7 @@ func NewStorageProtocolSubmodule(
 	bs blockstore.Blockstore,
 	gsync graphsync.GraphExchange,
 	stateViewer *appstate.Viewer,
+	jw journal.Writer,
 ) (*StorageProtocolSubmodule, error) {
 	cnode := storagemarketconnector.NewStorageClientNodeConnector(cborutil.NewIpldStore(bs), c.State, mw, s, m.Outbox, clientAddr, stateViewer)
 	dtStoredCounter := storedcounter.New(ds, datastore.NewKey(DTCounterDSKey))
@@ -96,9 +111,18 @@ func NewStorageProtocolSubmodule(
 	sm := &StorageProtocolSubmodule{
 		StorageClient:    client,
 		dataTransfer:     dt,
+		host:             h,
 		requestValidator: validator,
+		journal:          jw,
+		clientDeals:      newDealStateCounter("client"),
+		providerDeals:    newDealStateCounter("provider"),
+	}
+	sm.dealTracker, err = tracker.New(sm, ds, jw)
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading tracked storage deals")
 	}
 	sm.StorageClient.SubscribeToEvents(cnode.EventLogger)
+	sm.StorageClient.SubscribeToEvents(sm.handleClientDealEvent)
 	return sm, nil
 }
 
@@ -117,10 +141,21 @@ func (sm *StorageProtocolSubmodule) AddStorageProvider(
 	repoPath string,
 	sealProofType abi.RegisteredProof,
 	stateViewer *appstate.Viewer,
+	policyCfg *config.DealPolicyConfig,
 ) error {
 	sm.pieceManager = pm
 
-	pnode := storagemarketconnector.NewStorageProviderNodeConnector(minerAddr, c.State, m.Outbox, mw, pm, s, stateViewer)
+	askManager, err := asks.New(sm, minerAddr, ds, sm.journal)
+	if err != nil {
+		return errors.Wrap(err, "error loading storage ask terms")
+	}
+	views := func(key block.TipSetKey) (storagemarketconnector.PolicyStateView, error) {
+		return c.State.StateView(key)
+	}
+	policy := storagemarketconnector.NewDealPolicy(policyCfg, c.State, views, func() ([]iface.MinerDeal, error) {
+		return sm.StorageProvider.ListLocalDeals()
+	}, askManager)
+	pnode := storagemarketconnector.NewStorageProviderNodeConnector(minerAddr, c.State, m.Outbox, mw, pm, s, stateViewer, policy)
 
 	pieceStagingPath, err := paths.PieceStagingDir(repoPath)
 	if err != nil {
@@ -141,17 +176,108 @@ func (sm *StorageProtocolSubmodule) AddStorageProvider(
 	providerDs := namespace.Wrap(ds, datastore.NewKey(ProviderDSPrefix))
 	sm.requestValidator.SetPushDeals(statestore.New(providerDs))
 	ps := piecestore.NewPieceStore(namespace.Wrap(ds, datastore.NewKey(PieceStoreDSPrefix)))
-	storedAsk, err := storedask.NewStoredAsk(ds, datastore.NewKey(AskDSKey), pnode, minerAddr)
+	// The stored ask ignores the key it is given and keeps the ask under the
+	// empty key, which badger refuses, so it is given a datastore namespaced
+	// to the key instead.
+	askDs := namespace.Wrap(ds, datastore.NewKey(AskDSKey))
+	if err := migrateStoredAsk(ds, askDs); err != nil {
+		return errors.Wrap(err, "failed to migrate the stored ask")
+	}
+	storedAsk, err := storedask.NewStoredAsk(askDs, datastore.NewKey(AskDSKey), pnode, minerAddr)
 	if err != nil {
 		return err
 	}
-	sm.StorageProvider, err = impl.NewProvider(smnetwork.NewFromLibp2pHost(h), providerDs, bs, fs, ps, sm.dataTransfer, pnode, minerAddr, sealProofType, storedAsk)
+	sm.StorageProvider, err = impl.NewProvider(smnetwork.NewFromLibp2pHost(h), providerDs, bs, fs, ps, sm.dataTransfer, pnode, minerAddr, sealProofType, storedAsk, impl.CustomDealDecisionLogic(pnode.DecideOnDeal))
 	if err == nil {
+		sm.askManager = askManager
+		h.SetStreamHandler(asks.VerifiedPriceProtocolID, askManager.HandleVerifiedPriceStream)
 		sm.StorageProvider.SubscribeToEvents(pnode.EventLogger)
+		sm.StorageProvider.SubscribeToEvents(sm.handleProviderDealEvent)
 	}
 	return err
 }
 
+// migrateStoredAsk moves an ask stored under the empty key of the repo
+// datastore, where the stored ask kept it before being given its own
+// namespace, into that namespace. Datastores refusing the empty key, such as
+// badger, never held an ask there.
+func migrateStoredAsk(repoDs, askDs datastore.Batching) error {
+	has, err := askDs.Has(datastore.Key{})
+	if err != nil || has {
+		return err
+	}
+	old, err := repoDs.Get(datastore.Key{})
+	if err != nil {
+		return nil
+	}
+	if err := askDs.Put(datastore.Key{}, old); err != nil {
+		return err
+	}
+	return repoDs.Delete(datastore.Key{})
+}
+
+// handleClientDealEvent records a client deal's state transition in the journal
+// and deal metrics.
+func (sm *StorageProtocolSubmodule) handleClientDealEvent(event iface.ClientEvent, deal iface.ClientDeal) {
+	sm.clientDeals.update(deal.ProposalCid, deal.State)
+	sm.journal.Write("client-deal-state",
+		"proposal", deal.ProposalCid.String(),
+		"event", iface.ClientEvents[event],
+		"state", iface.DealStates[deal.State],
+		"provider", deal.Proposal.Provider.String(),
+		"message", deal.Message,
+	)
+}
+
+// handleProviderDealEvent records a provider deal's state transition in the
+// journal and deal metrics.
+func (sm *StorageProtocolSubmodule) handleProviderDealEvent(event iface.ProviderEvent, deal iface.MinerDeal) {
+	sm.providerDeals.update(deal.ProposalCid, deal.State)
+	sm.journal.Write("provider-deal-state",
+		"proposal", deal.ProposalCid.String(),
+		"event", iface.ProviderEvents[event],
+		"state", iface.DealStates[deal.State],
+		"client", deal.Proposal.Client.String(),
+		"message", deal.Message,
+	)
+}
+
+var storageDealsGauge = metrics.NewInt64Gauge("markets/storage_deals", "Number of storage deals seen since the node started in each state", metrics.RoleKey, metrics.StateKey)
+
+// dealStateCounter reports the number of deals in each state for one market
+// role.
+type dealStateCounter struct {
+	lk     sync.Mutex
+	role   string
+	states map[cid.Cid]iface.StorageDealStatus
+	counts map[iface.StorageDealStatus]int64
+}
+
+func newDealStateCounter(role string) *dealStateCounter {
+	return &dealStateCounter{
+		role:   role,
+		states: make(map[cid.Cid]iface.StorageDealStatus),
+		counts: make(map[iface.StorageDealStatus]int64),
+	}
+}
+
+func (c *dealStateCounter) update(proposal cid.Cid, state iface.StorageDealStatus) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	ctx := metrics.WithTag(context.Background(), metrics.RoleKey, c.role)
+	if old, ok := c.states[proposal]; ok {
+		if old == state {
+			return
+		}
+		c.counts[old]--
+		storageDealsGauge.Set(metrics.WithTag(ctx, metrics.StateKey, iface.DealStates[old]), c.counts[old])
+	}
+	c.states[proposal] = state
+	c.counts[state]++
+	storageDealsGauge.Set(metrics.WithTag(ctx, metrics.StateKey, iface.DealStates[state]), c.counts[state])
+}
+
 func (sm *StorageProtocolSubmodule) Provider() (iface.StorageProvider, error) {
 	if sm.StorageProvider == nil {
 		return nil, errors.New("Mining has not been started so storage provider is not available")
@@ -169,3 +295,22 @@ func (sm *StorageProtocolSubmodule) PieceManager() (piecemanager.PieceManager, e
 	}
 	return sm.pieceManager, nil
 }
+
+// AskManager returns the manager of the storage miner's asks.
+func (sm *StorageProtocolSubmodule) AskManager() (*asks.Manager, error) {
+	if sm.askManager == nil {
+		return nil, errors.New("Mining has not been started so asks are not available")
+	}
+	return sm.askManager, nil
+}
+
+// VerifiedPrice queries a storage miner for the price verified deals must pay
+// at least.
+func (sm *StorageProtocolSubmodule) VerifiedPrice(ctx context.Context, info iface.StorageProviderInfo) (abi.TokenAmount, bool, error) {
+	return asks.QueryVerifiedPrice(ctx, sm.host, info)
+}
+
+// DealTracker returns the tracker reconciling the node's deals with the chain.
+func (sm *StorageProtocolSubmodule) DealTracker() *tracker.Tracker {
+	return sm.dealTracker
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-graphsync"
@@ -12,11 +13,15 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainreplay"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/fetcher"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/drand"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/forkmon"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/net/blocksub"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/net/pubsub"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/slashing"
@@ -32,6 +37,8 @@ type SyncerSubmodule struct {
 	FaultDetector    slashing.ConsensusFaultDetector
 	ChainSyncManager *chainsync.Manager
 	Drand            drand.IFace
+	// Replayer re-validates stored tipsets against their recorded state.
+	Replayer *chainreplay.Replayer
 
 	// cancelChainSync cancels the context for chain sync subscriptions and handlers.
 	CancelChainSync context.CancelFunc
@@ -44,6 +51,11 @@ type syncerConfig interface {
 	BlockTime() time.Duration
 	ChainClock() clock.ChainEpochClock
 	Drand() drand.IFace
+	Journal() journal.Journal
+}
+
+type syncerRepo interface {
+	Config() *config.Config
 }
 
 type nodeChainSelector interface {
@@ -52,7 +64,7 @@ type nodeChainSelector interface {
 }
 
 // NewSyncerSubmodule creates a new chain submodule.
-func NewSyncerSubmodule(ctx context.Context, config syncerConfig, blockstore *BlockstoreSubmodule, network *NetworkSubmodule,
+func NewSyncerSubmodule(ctx context.Context, config syncerConfig, repo syncerRepo, blockstore *BlockstoreSubmodule, network *NetworkSubmodule,
 	discovery *DiscoverySubmodule, chn *ChainSubmodule, postVerifier consensus.EPoStVerifier) (SyncerSubmodule, error) {
 	// setup validation
 	blkValid := consensus.NewDefaultBlockValidator(config.ChainClock(), chn.MessageStore, chn.State)
@@ -104,7 +116,10 @@ func NewSyncerSubmodule(ctx context.Context, config syncerConfig, blockstore *Bl
 	faultCh := make(chan slashing.ConsensusFault)
 	faultDetector := slashing.NewConsensusFaultDetector(faultCh)
 
-	chainSyncManager, err := chainsync.NewManager(nodeConsensus, blkValid, nodeChainSelector, chn.ChainReader, chn.MessageStore, fetcher, config.ChainClock(), faultDetector)
+	forkCfg := repo.Config().Observability.ForkMonitor
+	forks := forkmon.NewMonitor(chn.ChainReader, chn.MessageStore, config.Journal().Topic("chainsync"), config.ChainClock(), abi.ChainEpoch(forkCfg.AlertDepth), forkCfg.HistorySize)
+
+	chainSyncManager, err := chainsync.NewManager(nodeConsensus, blkValid, nodeChainSelector, chn.ChainReader, chn.MessageStore, fetcher, config.ChainClock(), faultDetector, forks, config.Journal().Topic("chainsync"))
 	if err != nil {
 		return SyncerSubmodule{}, err
 	}
@@ -116,6 +131,7 @@ func NewSyncerSubmodule(ctx context.Context, config syncerConfig, blockstore *Bl
 		ChainSelector:    nodeChainSelector,
 		ChainSyncManager: &chainSyncManager,
 		Drand:            d,
+		Replayer:         chainreplay.NewReplayer(chn.ChainReader, chn.MessageStore, nodeConsensus, nodeChainSelector, blockstore.CborStore),
 		// cancelChainSync: nil,
 		faultCh: faultCh,
 	}, nil
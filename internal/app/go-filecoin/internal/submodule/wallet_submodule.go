@@ -3,33 +3,59 @@ package submodule
 import (
 	"context"
 
+	"github.com/filecoin-project/go-address"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/state"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet"
-	"github.com/pkg/errors"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet/history"
 )
 
 // WalletSubmodule enhances the `Node` with a "Wallet" and FIL transfer capabilities.
 type WalletSubmodule struct {
-	Wallet *wallet.Wallet
-	Signer types.Signer
+	Wallet      *wallet.Wallet
+	AddressBook *wallet.AddressBook
+	Signer      types.Signer
+
+	// History indexes the transactions of the wallet addresses and of the
+	// configured miner.
+	History *history.Index
 }
 
 type walletRepo interface {
+	Config() *config.Config
+	Datastore() ds.Batching
 	WalletDatastore() repo.Datastore
 }
 
 // NewWalletSubmodule creates a new storage protocol submodule.
-func NewWalletSubmodule(ctx context.Context, repo walletRepo, chain *ChainSubmodule) (WalletSubmodule, error) {
-	backend, err := wallet.NewDSBackend(repo.WalletDatastore())
+func NewWalletSubmodule(ctx context.Context, repo walletRepo, blockstore *BlockstoreSubmodule, chain *ChainSubmodule) (WalletSubmodule, error) {
+	backend, err := wallet.NewDSBackendWithHD(repo.WalletDatastore(), repo.Datastore())
 	if err != nil {
 		return WalletSubmodule{}, errors.Wrap(err, "failed to set up wallet backend")
 	}
 	fcWallet := wallet.New(backend)
+	book, err := wallet.NewAddressBook(repo.Datastore())
+	if err != nil {
+		return WalletSubmodule{}, errors.Wrap(err, "failed to load address book")
+	}
+	tracked := func() []address.Address {
+		addrs := fcWallet.Addresses()
+		if minerAddr := repo.Config().Mining.MinerAddress; !minerAddr.Empty() {
+			addrs = append(addrs, minerAddr)
+		}
+		return addrs
+	}
+	viewer := history.AsDefaultStateViewer(state.NewViewer(blockstore.CborStore))
 
 	return WalletSubmodule{
-		Wallet: fcWallet,
-		Signer: state.NewSigner(chain.ActorState, chain.ChainReader, fcWallet),
+		Wallet:      fcWallet,
+		AddressBook: book,
+		Signer:      state.NewSigner(chain.ActorState, chain.ChainReader, fcWallet),
+		History:     history.NewIndex(repo.Datastore(), chain.ChainReader, chain.MessageStore, viewer, tracked),
 	}, nil
 }
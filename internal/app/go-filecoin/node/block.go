@@ -28,6 +28,13 @@ func (node *Node) AddNewBlock(ctx context.Context, o mining.FullBlock) (err erro
 	if err != nil {
 		return errors.Wrap(err, "could not add new block to online storage")
 	}
+	node.Journal.Topic("mining").Write("mined-block",
+		"block", blkCid.String(),
+		"height", b.Height,
+		"miner", b.Miner.String(),
+		"parents", b.Parents.String(),
+		"messages", len(o.BLSMessages)+len(o.SECPMessages),
+	)
 
 	// Publish blocksub message
 	log.Debugf("publishing new block: %s", b.Cid().String())
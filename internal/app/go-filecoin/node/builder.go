@@ -10,22 +10,28 @@ import (
 	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
 	"github.com/filecoin-project/specs-actors/actors/builtin/power"
 	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/ipfs/go-merkledag"
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/internal/submodule"
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paymentchannel"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cfg"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/dag"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/drand"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/genesis"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/postgenerator"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/proofs"
 	drandapi "github.com/filecoin-project/go-filecoin/internal/pkg/protocol/drand"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
@@ -37,8 +43,10 @@ import (
 type Builder struct {
 	blockTime   time.Duration
 	libp2pOpts  []libp2p.Option
+	libp2pHost  host.Host
 	offlineMode bool
 	verifier    ffiwrapper.Verifier
+	mockProofs  bool
 	postGen     postgenerator.PoStGenerator
 	propDelay   time.Duration
 	repo        repo.Repo
@@ -96,6 +104,16 @@ func Libp2pOptions(opts ...libp2p.Option) BuilderOpt {
 	}
 }
 
+// Libp2pHostOption returns a builder option that sets a pre-built libp2p host
+// for the node to use instead of constructing one from its libp2p options,
+// e.g. a host on an in-process mock network.
+func Libp2pHostOption(h host.Host) BuilderOpt {
+	return func(b *Builder) error {
+		b.libp2pHost = h
+		return nil
+	}
+}
+
 // VerifierConfigOption returns a function that sets the verifier to use in the node consensus
 func VerifierConfigOption(verifier ffiwrapper.Verifier) BuilderOpt {
 	return func(c *Builder) error {
@@ -104,6 +122,16 @@ func VerifierConfigOption(verifier ffiwrapper.Verifier) BuilderOpt {
 	}
 }
 
+// MockProofs configures the node to seal, prove and verify with mock proofs.
+// The node will only run on a network whose genesis block is marked as using
+// mock proofs, and a node without this option will refuse such a network.
+func MockProofs(mockProofs bool) BuilderOpt {
+	return func(b *Builder) error {
+		b.mockProofs = mockProofs
+		return nil
+	}
+}
+
 // PoStGeneratorOption returns a builder option that sets the post generator to
 // use during block generation
 func PoStGeneratorOption(generator postgenerator.PoStGenerator) BuilderOpt {
@@ -212,6 +240,7 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 	nd := &Node{
 		OfflineMode: b.offlineMode,
 		Repo:        b.repo,
+		Journal:     b.journal,
 	}
 
 	nd.Blockstore, err = submodule.NewBlockstoreSubmodule(ctx, b.repo)
@@ -239,7 +268,13 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 		return nil, errors.Wrap(err, "failed to build node.Blockservice")
 	}
 
-	nd.ProofVerification = submodule.NewProofVerificationSubmodule(b.verifier)
+	if err := checkProofMode(ctx, nd.Blockstore.CborStore, b.genCid, b.mockProofs); err != nil {
+		return nil, err
+	}
+	if b.mockProofs {
+		b.verifier = proofs.MockVerifier{}
+	}
+	nd.ProofVerification = submodule.NewProofVerificationSubmodule(b.verifier, b.mockProofs)
 
 	nd.chain, err = submodule.NewChainSubmodule((*builder)(b), b.repo, &nd.Blockstore, &nd.ProofVerification)
 	if err != nil {
@@ -267,12 +302,12 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 	}
 	nd.ChainClock = b.chainClock
 
-	nd.syncer, err = submodule.NewSyncerSubmodule(ctx, (*builder)(b), &nd.Blockstore, &nd.network, &nd.Discovery, &nd.chain, nd.ProofVerification.ProofVerifier)
+	nd.syncer, err = submodule.NewSyncerSubmodule(ctx, (*builder)(b), b.repo, &nd.Blockstore, &nd.network, &nd.Discovery, &nd.chain, nd.ProofVerification.ProofVerifier)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build node.Syncer")
 	}
 
-	nd.Wallet, err = submodule.NewWalletSubmodule(ctx, b.repo, &nd.chain)
+	nd.Wallet, err = submodule.NewWalletSubmodule(ctx, b.repo, &nd.Blockstore, &nd.chain)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build node.Wallet")
 	}
@@ -294,18 +329,35 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 
 	waiter := msg.NewWaiter(nd.chain.ChainReader, nd.chain.MessageStore, nd.Blockstore.Blockstore, nd.Blockstore.CborStore)
 
+	// Only some journals, such as those written to disk, can be read back.
+	journalReader, _ := b.journal.(journal.Reader)
+
+	paychMgr := paymentchannel.NewManager(
+		ctx,
+		nd.Repo.Datastore(),
+		waiter,
+		nd.Messaging.Outbox,
+		paymentchannel.NewManagerStateViewer(nd.chain.ChainReader, nd.Blockstore.CborStore))
+
 	nd.PorcelainAPI = porcelain.New(plumbing.New(&plumbing.APIDeps{
+		AddressBook:  nd.Wallet.AddressBook,
 		Chain:        nd.chain.State,
+		ChainClock:   b.chainClock,
 		Sync:         cst.NewChainSyncProvider(nd.syncer.ChainSyncManager),
 		Config:       cfg.NewConfig(b.repo),
-		DAG:          dag.NewDAG(merkledag.NewDAGService(nd.Blockservice.Blockservice)),
+		DAG:          dag.NewDAG(merkledag.NewDAGService(nd.Blockservice.Blockservice), nd.Blockstore.Blockstore),
+		Drand:        b.drand,
 		Expected:     nd.syncer.Consensus,
+		History:      nd.Wallet.History,
+		Journal:      journalReader,
 		MsgPool:      nd.Messaging.MsgPool,
 		MsgPreviewer: msg.NewPreviewer(nd.chain.ChainReader, nd.Blockstore.CborStore, nd.Blockstore.Blockstore, nd.chain.Processor),
 		MsgWaiter:    waiter,
 		Network:      nd.network.Network,
 		Outbox:       nd.Messaging.Outbox,
+		PaychMgr:     paychMgr,
 		PieceManager: nd.PieceManager,
+		Replayer:     nd.syncer.Replayer,
 		Wallet:       nd.Wallet.Wallet,
 	}))
 
@@ -321,12 +373,25 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 		nd.Blockstore.Blockstore,
 		nd.network.GraphExchange,
 		state.NewViewer(nd.Blockstore.CborStore),
+		b.journal.Topic("storagemarket"),
 	)
 	if err != nil {
 		return nil, err
 	}
 
 	nd.StorageAPI = storage.NewAPI(nd.StorageProtocol)
+
+	nd.RetrievalProtocol, err = submodule.NewRetrievalProtocolSubmodule(
+		nd.Blockstore.Blockstore,
+		nd.Repo.Datastore(),
+		nd.chain.State,
+		nd.Host(),
+		nd.Wallet.Signer,
+		paychMgr,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build node.RetrievalProtocol")
+	}
 	nd.DrandAPI = drandapi.New(b.drand, nd.PorcelainAPI)
 
 	return nd, nil
@@ -369,6 +434,10 @@ func (b builder) Libp2pOpts() []libp2p.Option {
 	return b.libp2pOpts
 }
 
+func (b builder) Libp2pHost() host.Host {
+	return b.libp2pHost
+}
+
 func (b builder) OfflineMode() bool {
 	return b.offlineMode
 }
@@ -377,6 +446,22 @@ func (b builder) Drand() drand.IFace {
 	return b.drand
 }
 
+// checkProofMode ensures the node's proof mode matches the one recorded in
+// the genesis block.
+func checkProofMode(ctx context.Context, cborStore cbor.IpldStore, genCid cid.Cid, mockProofs bool) error {
+	var genBlk block.Block
+	if err := cborStore.Get(ctx, genCid, &genBlk); err != nil {
+		return errors.Wrap(err, "failed to load genesis block")
+	}
+	if genesis.UsesMockProofs(&genBlk) && !mockProofs {
+		return errors.New("genesis block is for a network using mock proofs, run with mock proofs enabled to join it")
+	}
+	if !genesis.UsesMockProofs(&genBlk) && mockProofs {
+		return errors.New("mock proofs are enabled but the genesis block is for a network using real proofs")
+	}
+	return nil
+}
+
 func DefaultDrandIfaceFromConfig(cfg *config.Config, fcGenTS uint64) (drand.IFace, error) {
 	drandConfig := cfg.Drand
 	addrs := make([]drand.Address, len(drandConfig.Addresses))
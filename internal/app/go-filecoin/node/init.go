@@ -40,6 +40,7 @@ type initCfg struct {
 	peerKey     acrypto.PrivKey
 	defaultKey  *crypto.KeyInfo
 	initImports []*crypto.KeyInfo
+	walletSeed  []byte
 }
 
 // InitOpt is an option for initialization of a node's repo.
@@ -61,6 +62,14 @@ func DefaultKeyOpt(ki *crypto.KeyInfo) InitOpt {
 	}
 }
 
+// WalletSeedOpt makes the wallet derive its addresses from an HD seed. Unless
+// a default key is given, the default address is the first one derived.
+func WalletSeedOpt(seed []byte) InitOpt {
+	return func(opts *initCfg) {
+		opts.walletSeed = seed
+	}
+}
+
 // ImportKeyOpt imports the provided key during initialization.
 func ImportKeyOpt(ki *crypto.KeyInfo) InitOpt {
 	return func(opts *initCfg) {
@@ -89,10 +98,15 @@ func Init(ctx context.Context, r repo.Repo, gen genesis.InitFunc, opts ...InitOp
 		return err
 	}
 
-	backend, err := wallet.NewDSBackend(r.WalletDatastore())
+	backend, err := wallet.NewDSBackendWithHD(r.WalletDatastore(), r.Datastore())
 	if err != nil {
 		return errors.Wrap(err, "failed to open wallet datastore")
 	}
+	if cfg.walletSeed != nil {
+		if err := backend.SetSeed(cfg.walletSeed); err != nil {
+			return errors.Wrap(err, "failed to set wallet seed")
+		}
+	}
 	w := wallet.New(backend)
 
 	defaultKey, err := initDefaultKey(w, cfg.defaultKey)
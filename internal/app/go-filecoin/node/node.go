@@ -15,7 +15,6 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/internal/submodule"
-	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paymentchannel"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
@@ -23,6 +22,8 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/mining"
@@ -56,6 +57,9 @@ type Node struct {
 	// It contains all persistent artifacts of the filecoin node.
 	Repo repo.Repo
 
+	// Journal records the node's notable events.
+	Journal journal.Journal
+
 	PorcelainAPI *porcelain.API
 	DrandAPI     *drand.API
 	StorageAPI   *storage.API
@@ -135,6 +139,10 @@ func (node *Node) Start(ctx context.Context) error {
 		return errors.Wrap(err, "failed to get chain head")
 	}
 	go node.handleNewChainHeads(syncCtx, head)
+	go node.indexWalletHistory(syncCtx, head)
+	go node.submitSettlingVouchers(syncCtx, head)
+	go node.trackDeals(syncCtx, head)
+	go node.manageAsks(syncCtx, head)
 
 	if !node.OfflineMode {
 
@@ -253,6 +261,112 @@ func (node *Node) handleNewChainHeads(ctx context.Context, firstHead block.TipSe
 	}
 }
 
+// indexWalletHistory keeps the wallet history up to date with the head. It runs apart from the
+// other head handlers since indexing the whole chain for a new address may take a while, and
+// skips to the latest head when several arrive meanwhile, each update catching up the whole way.
+func (node *Node) indexWalletHistory(ctx context.Context, firstHead block.TipSet) {
+	node.followLatestHead(ctx, firstHead, func(head block.TipSet) {
+		if err := node.Wallet.History.HandleNewHead(ctx, head); err != nil {
+			log.Errorf("failed to index wallet history: %s", err)
+		}
+	})
+}
+
+// submitSettlingVouchers submits the best vouchers of the payment channels paying the node as
+// soon as they start settling, so that they are redeemed before the channels are collected.
+func (node *Node) submitSettlingVouchers(ctx context.Context, firstHead block.TipSet) {
+	node.followLatestHead(ctx, firstHead, func(head block.TipSet) {
+		height, err := head.Height()
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		tok, err := encoding.Encode(head.Key())
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if err := node.RetrievalProtocol.PaychMgr.SubmitSettlingVouchers(ctx, tok, height, node.Wallet.Wallet.HasAddress); err != nil {
+			log.Errorf("failed to submit vouchers of settling payment channels: %s", err)
+		}
+	})
+}
+
+// trackDeals reconciles the storage deals made by or with the node with the state of each new
+// head, so that clients learn when their data must be stored again.
+func (node *Node) trackDeals(ctx context.Context, firstHead block.TipSet) {
+	node.followLatestHead(ctx, firstHead, func(head block.TipSet) {
+		height, err := head.Height()
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		view, err := node.PorcelainAPI.StateView(head.Key())
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if err := node.StorageProtocol.DealTracker().HandleNewHead(ctx, height, view); err != nil {
+			log.Errorf("failed to reconcile storage deals with chain state: %s", err)
+		}
+	})
+}
+
+// manageAsks applies the scheduled changes of the miner's ask and renews it before it expires
+// as the chain head moves, once the node mines.
+func (node *Node) manageAsks(ctx context.Context, firstHead block.TipSet) {
+	node.followLatestHead(ctx, firstHead, func(head block.TipSet) {
+		askManager, err := node.StorageProtocol.AskManager()
+		if err != nil {
+			// The node does not mine.
+			return
+		}
+		height, err := head.Height()
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if err := askManager.HandleNewHead(height); err != nil {
+			log.Errorf("failed to update storage ask: %s", err)
+		}
+	})
+}
+
+// followLatestHead calls handle with the first head and each new head until the context ends,
+// skipping to the latest head when several arrive while handle runs.
+func (node *Node) followLatestHead(ctx context.Context, firstHead block.TipSet, handle func(block.TipSet)) {
+	newHeadCh := node.chain.ChainReader.HeadEvents().Sub(chain.NewHeadTopic)
+	defer node.chain.ChainReader.HeadEvents().Unsub(newHeadCh)
+
+	handle(firstHead)
+	for {
+		select {
+		case ts, ok := <-newHeadCh:
+			if !ok {
+				return
+			}
+			for pending := true; pending; {
+				select {
+				case next, ok := <-newHeadCh:
+					if !ok {
+						return
+					}
+					ts = next
+				default:
+					pending = false
+				}
+			}
+			newHead, ok := ts.(block.TipSet)
+			if !ok {
+				continue
+			}
+			handle(newHead)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (node *Node) cancelSubscriptions() {
 	if node.syncer.CancelChainSync != nil {
 		node.syncer.CancelChainSync()
@@ -283,6 +397,10 @@ func (node *Node) Stop(ctx context.Context) {
 		node.StorageMining = nil
 	}
 
+	if err := node.RetrievalProtocol.Stop(); err != nil {
+		fmt.Printf("error stopping retrieval provider: %s\n", err)
+	}
+
 	if err := node.Host().Close(); err != nil {
 		fmt.Printf("error closing host: %s\n", err)
 	}
@@ -349,7 +467,7 @@ func (node *Node) SetupMining(ctx context.Context) error {
 		}
 	}
 
-	if node.RetrievalProtocol == nil {
+	if node.RetrievalProtocol.Provider() == nil {
 		if err := node.setupRetrievalMining(ctx); err != nil {
 			return err
 		}
@@ -402,7 +520,7 @@ func (node *Node) setupStorageMining(ctx context.Context) error {
 	// TODO: rework these modules so they can be at least partially constructed during the building phase #3738
 	stateViewer := state.NewViewer(cborStore)
 
-	node.StorageMining, err = submodule.NewStorageMiningSubmodule(minerAddr, node.Repo.Datastore(), &node.chain, &node.Messaging, waiter, stateViewer, sealProofType, node.Repo, node.BlockMining.PoStGenerator)
+	node.StorageMining, err = submodule.NewStorageMiningSubmodule(minerAddr, node.Repo.Datastore(), &node.chain, &node.Messaging, waiter, stateViewer, sealProofType, node.Repo, node.BlockMining.PoStGenerator, node.ProofVerification.MockProofs, node.Journal)
 	if err != nil {
 		return err
 	}
@@ -422,6 +540,7 @@ func (node *Node) setupStorageMining(ctx context.Context) error {
 		repoPath,
 		sealProofType,
 		stateViewer,
+		node.Repo.Config().Mining.DealPolicy,
 	)
 }
 
@@ -431,30 +550,9 @@ func (node *Node) setupRetrievalMining(ctx context.Context) error {
 		return errors.Wrap(err, "failed to get mining address")
 	}
 
-	waiter := msg.NewWaiter(node.chain.ChainReader, node.chain.MessageStore, node.Blockstore.Blockstore, node.Blockstore.CborStore)
-
-	mgrStateViewer := paymentchannel.NewManagerStateViewer(node.Chain().ChainReader, node.Blockstore.CborStore)
-	paychMgr := paymentchannel.NewManager(
-		ctx,
-		node.Repo.Datastore(),
-		waiter,
-		node.Messaging.Outbox,
-		mgrStateViewer)
-
-	rp, err := submodule.NewRetrievalProtocolSubmodule(
-		node.Blockstore.Blockstore,
-		node.Repo.Datastore(),
-		node.chain.State,
-		node.Host(),
-		providerAddr,
-		node.Wallet.Signer,
-		paychMgr,
-		node.PieceManager(),
-	)
-	if err != nil {
-		return errors.Wrap(err, "failed to build node.RetrievalProtocol")
+	if err := node.RetrievalProtocol.AddRetrievalProvider(providerAddr, node.PieceManager()); err != nil {
+		return errors.Wrap(err, "failed to build retrieval provider")
 	}
-	node.RetrievalProtocol = rp
 	return nil
 }
 
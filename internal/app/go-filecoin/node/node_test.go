@@ -7,16 +7,23 @@ import (
 	"testing"
 	"time"
 
+	"github.com/filecoin-project/specs-actors/actors/builtin"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/node"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/node/test"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/constants"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/drand"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/genesis"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/proofs"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/version"
 	gengen "github.com/filecoin-project/go-filecoin/tools/gengen/util"
 )
 
@@ -199,6 +206,74 @@ func TestOptionWithError(t *testing.T) {
 
 }
 
+func TestNodeProofModeMustMatchGenesis(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	build := func(gen genesis.InitFunc, opts ...node.BuilderOpt) error {
+		r := repo.NewInMemoryRepo()
+		require.NoError(t, node.Init(ctx, r, gen))
+		repoOpts, err := node.OptionsFromRepo(r)
+		require.NoError(t, err)
+		nd, err := node.New(ctx, append(repoOpts, opts...)...)
+		if err == nil {
+			nd.Stop(ctx)
+		}
+		return err
+	}
+	mockGenesis := gengen.MakeGenesisFunc(gengen.NetworkName("gfctest"), gengen.MockProofs())
+
+	assert.Error(t, build(mockGenesis))
+	assert.Error(t, build(gengen.DefaultGenesis, node.MockProofs(true)))
+	assert.NoError(t, build(mockGenesis, node.MockProofs(true)))
+}
+
+func TestNodeMinesWithMockProofs(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	genTime := int64(1000000000)
+	commits, err := gengen.MakeCommitCfgs(2)
+	require.NoError(t, err)
+	seed := node.MakeChainSeed(t, &gengen.GenesisCfg{
+		KeysToGen:         1,
+		PreallocatedFunds: []string{"1000000"},
+		Miners: []*gengen.CreateStorageMinerConfig{{
+			Owner:            0,
+			CommittedSectors: commits,
+			SealProofType:    constants.DevSealProofType,
+		}},
+		Network:    version.TEST,
+		Time:       uint64(genTime),
+		MockProofs: true,
+	})
+
+	blockTime := builtin.EpochDurationSeconds * time.Second
+	fakeClock := clock.NewFake(time.Unix(genTime, 0))
+	chainClock := clock.NewChainClockFromClock(uint64(genTime), blockTime, 6*time.Second, fakeClock)
+
+	nd := test.NewNodeBuilder(t).
+		WithGenesisInit(seed.GenesisInitFunc).
+		WithBuilderOpt(node.MockProofs(true)).
+		WithBuilderOpt(node.ChainClockConfigOption(chainClock)).
+		WithBuilderOpt(node.DrandConfigOption(drand.NewFake(chainClock.StartTimeOfEpoch(0)))).
+		WithBuilderOpt(node.MonkeyPatchSetProofTypeOption(constants.DevRegisteredSealProof)).
+		WithConfig(seed.MinerConfigOpt(0)).
+		Build(ctx)
+	owner := seed.GiveKey(t, nd, 0)
+	require.NoError(t, nd.PorcelainAPI.ConfigSet("wallet.defaultAddress", owner.String()))
+	require.NoError(t, nd.Start(ctx))
+	defer nd.Stop(ctx)
+
+	// The mined block only becomes the head once the syncer has validated
+	// it, its winning PoSt included.
+	fakeClock.Advance(blockTime)
+	blk, err := nd.BlockMining.BlockMiningAPI.MiningOnce(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, blk.PoStProofs)
+	assert.Equal(t, block.NewTipSetKey(blk.Cid()), nd.PorcelainAPI.ChainHeadKey())
+}
+
 func TestNodeConfig(t *testing.T) {
 	tf.UnitTest(t)
 
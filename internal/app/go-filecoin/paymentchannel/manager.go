@@ -41,6 +41,9 @@ type Manager struct {
 	sender          MsgSender
 	waiter          MsgWaiter
 	stateViewer     ActorStateViewer
+
+	submittedLk sync.Mutex
+	submitted   map[submissionKey]submission
 }
 
 // PaymentChannelStorePrefix is the prefix used in the datastore
@@ -75,7 +78,14 @@ func NewManager(ctx context.Context, ds datastore.Batching, waiter MsgWaiter, se
 
 	store := paychStore{store: s}
 
-	return &Manager{ctx, &store, sender, waiter, viewer}
+	return &Manager{
+		ctx:             ctx,
+		paymentChannels: &store,
+		sender:          sender,
+		waiter:          waiter,
+		stateViewer:     viewer,
+		submitted:       make(map[submissionKey]submission),
+	}
 }
 
 // AllocateLane adds a new lane to a payment channel entry
@@ -107,6 +117,16 @@ func (pm *Manager) GetPaymentChannelByAccounts(payer, payee address.Address) (*C
 	return &found, nil
 }
 
+// ListChannels returns the payment channels the manager tracks, paying or
+// paid by the node.
+func (pm *Manager) ListChannels() ([]ChannelInfo, error) {
+	var chinfos []ChannelInfo
+	if err := pm.paymentChannels.List(&chinfos); err != nil {
+		return nil, err
+	}
+	return chinfos, nil
+}
+
 // GetPaymentChannelInfo retrieves channel info from the paymentChannels.
 // Assumes channel exists.
 func (pm *Manager) GetPaymentChannelInfo(paychAddr address.Address) (*ChannelInfo, error) {
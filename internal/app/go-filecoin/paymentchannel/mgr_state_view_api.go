@@ -6,6 +6,7 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-fil-markets/shared"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
 	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
@@ -25,6 +26,8 @@ type ManagerStateViewer struct {
 type ManagerStateView interface {
 	PaychActorParties(ctx context.Context, paychAddr address.Address) (from, to address.Address, err error)
 	MinerControlAddresses(ctx context.Context, addr address.Address) (owner, worker address.Address, err error)
+	PaychActorState(ctx context.Context, paychAddr address.Address) (*paych.State, error)
+	AccountSignerAddress(ctx context.Context, a address.Address) (address.Address, error)
 }
 
 // ChainReader is the subset of the ChainReadWriter API that the Manager uses
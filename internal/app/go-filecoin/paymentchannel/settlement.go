@@ -0,0 +1,162 @@
+package paymentchannel
+
+import (
+	"context"
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/shared"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	paychActor "github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	xerrors "github.com/pkg/errors"
+	"github.com/prometheus/common/log"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+// voucherRetryEpochs is the number of epochs after which a voucher submitted
+// for a settling channel is submitted again if the channel has not redeemed it.
+const voucherRetryEpochs = 10
+
+type submissionKey struct {
+	paych address.Address
+	lane  uint64
+}
+
+type submission struct {
+	amount abi.TokenAmount
+	height abi.ChainEpoch
+}
+
+// VoucherRedeemable returns the amount a voucher adds to what a channel pays
+// out when redeemed at `epoch`, or an error saying why the channel would reject
+// it. It checks neither the voucher's signature nor the channel's balance.
+func VoucherRedeemable(voucher *paychActor.SignedVoucher, st *paychActor.State, epoch abi.ChainEpoch) (abi.TokenAmount, error) {
+	if epoch < voucher.TimeLockMin {
+		return zeroAmt, xerrors.Errorf("voucher cannot be redeemed before epoch %d", voucher.TimeLockMin)
+	}
+	if voucher.TimeLockMax != 0 && epoch > voucher.TimeLockMax {
+		return zeroAmt, xerrors.Errorf("voucher expired at epoch %d", voucher.TimeLockMax)
+	}
+
+	redeemed := big.Zero()
+	if lane := findLane(st, voucher.Lane); lane != nil {
+		if lane.Nonce > voucher.Nonce {
+			return zeroAmt, xerrors.Errorf("voucher nonce %d is lower than lane %d's nonce %d", voucher.Nonce, voucher.Lane, lane.Nonce)
+		}
+		redeemed = lane.Redeemed
+	}
+	for _, merge := range voucher.Merges {
+		other := findLane(st, merge.Lane)
+		if merge.Lane == voucher.Lane || other == nil {
+			return zeroAmt, xerrors.Errorf("voucher merges invalid lane %d", merge.Lane)
+		}
+		if other.Nonce >= merge.Nonce {
+			return zeroAmt, xerrors.Errorf("voucher merges lane %d with outdated nonce %d", merge.Lane, merge.Nonce)
+		}
+		redeemed = big.Add(redeemed, other.Redeemed)
+	}
+
+	delta := big.Sub(voucher.Amount, redeemed)
+	if !delta.GreaterThan(big.Zero()) {
+		return zeroAmt, xerrors.Errorf("voucher amount %s does not exceed the %s already redeemed", voucher.Amount, redeemed)
+	}
+	return delta, nil
+}
+
+// BestSpendable returns the stored voucher redeeming the most for each lane of
+// a channel at `epoch`, ordered by lane.
+func BestSpendable(chinfo *ChannelInfo, st *paychActor.State, epoch abi.ChainEpoch) []*VoucherInfo {
+	best := make(map[uint64]*VoucherInfo)
+	for _, vi := range chinfo.Vouchers {
+		if _, err := VoucherRedeemable(vi.Voucher, st, epoch); err != nil {
+			continue
+		}
+		if b, ok := best[vi.Voucher.Lane]; !ok || vi.Voucher.Amount.GreaterThan(b.Voucher.Amount) {
+			best[vi.Voucher.Lane] = vi
+		}
+	}
+
+	var res []*VoucherInfo
+	for _, vi := range best {
+		res = append(res, vi)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Voucher.Lane < res[j].Voucher.Lane })
+	return res
+}
+
+// SubmitSettlingVouchers redeems the best vouchers of the settling channels
+// paying an address the node holds the key of, so that the payee does not lose
+// them when the channel is collected. The state is read at the tipset for `tok`
+// at `height`; `own` tells whether the node holds the key of an address.
+func (pm *Manager) SubmitSettlingVouchers(ctx context.Context, tok shared.TipSetToken, height abi.ChainEpoch, own func(address.Address) bool) error {
+	view, err := pm.stateViewer.GetStateView(ctx, tok)
+	if err != nil {
+		return err
+	}
+	chinfos, err := pm.ListChannels()
+	if err != nil {
+		return err
+	}
+
+	for i := range chinfos {
+		chinfo := &chinfos[i]
+		if len(chinfo.Vouchers) == 0 {
+			continue
+		}
+		st, err := view.PaychActorState(ctx, chinfo.UniqueAddr)
+		if err != nil {
+			log.Warnf("failed to load payment channel %s: %s", chinfo.UniqueAddr, err)
+			continue
+		}
+		if st.SettlingAt == 0 || height >= st.SettlingAt {
+			continue
+		}
+		payee, err := view.AccountSignerAddress(ctx, st.To)
+		if err != nil {
+			return err
+		}
+		if !own(payee) {
+			continue
+		}
+
+		// A message sent now applies at the next epoch at the earliest.
+		for _, vi := range BestSpendable(chinfo, st, height+1) {
+			if !pm.startSubmission(chinfo.UniqueAddr, vi.Voucher, height) {
+				continue
+			}
+			params := paychActor.UpdateChannelStateParams{Sv: *vi.Voucher, Proof: vi.Proof}
+			mcid, _, err := pm.sender.Send(pm.ctx, payee, chinfo.UniqueAddr, types.ZeroAttoFIL, defaultGasPrice, defaultGasLimit, true, builtin.MethodsPaych.UpdateChannelState, &params)
+			if err != nil {
+				return xerrors.Wrapf(err, "failed to submit voucher for lane %d of settling channel %s", vi.Voucher.Lane, chinfo.UniqueAddr)
+			}
+			log.Infof("submitted voucher for %s on lane %d of settling channel %s in message %s", vi.Voucher.Amount, vi.Voucher.Lane, chinfo.UniqueAddr, mcid)
+		}
+	}
+	return nil
+}
+
+// startSubmission records the submission of a voucher at `height`, returning
+// false if a submission of at least its amount on its lane is still pending.
+func (pm *Manager) startSubmission(paychAddr address.Address, voucher *paychActor.SignedVoucher, height abi.ChainEpoch) bool {
+	pm.submittedLk.Lock()
+	defer pm.submittedLk.Unlock()
+
+	key := submissionKey{paych: paychAddr, lane: voucher.Lane}
+	if prev, ok := pm.submitted[key]; ok && !prev.amount.LessThan(voucher.Amount) && height < prev.height+voucherRetryEpochs {
+		return false
+	}
+	pm.submitted[key] = submission{amount: voucher.Amount, height: height}
+	return true
+}
+
+func findLane(st *paychActor.State, id uint64) *paychActor.LaneState {
+	for _, lane := range st.LaneStates {
+		if lane.ID == id {
+			return lane
+		}
+	}
+	return nil
+}
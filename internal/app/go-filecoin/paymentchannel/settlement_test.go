@@ -0,0 +1,125 @@
+package paymentchannel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/shared_testutil"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	paychActor "github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	spect "github.com/filecoin-project/specs-actors/support/testing"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dss "github.com/ipfs/go-datastore/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paymentchannel"
+	paychtest "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paymentchannel/testing"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+)
+
+func TestBestSpendable(t *testing.T) {
+	tf.UnitTest(t)
+
+	voucher := func(lane, nonce uint64, amount int64, timeLockMin abi.ChainEpoch) *VoucherInfo {
+		return &VoucherInfo{Voucher: &paychActor.SignedVoucher{Lane: lane, Nonce: nonce, Amount: abi.NewTokenAmount(amount), TimeLockMin: timeLockMin}}
+	}
+	chinfo := &ChannelInfo{Vouchers: []*VoucherInfo{
+		voucher(0, 2, 5, 0),
+		voucher(0, 2, 20, 0),
+		voucher(0, 1, 30, 0),
+		voucher(1, 1, 7, 100),
+		voucher(1, 1, 4, 0),
+	}}
+	st := &paychActor.State{LaneStates: []*paychActor.LaneState{{ID: 0, Redeemed: abi.NewTokenAmount(10), Nonce: 2}}}
+
+	best := BestSpendable(chinfo, st, 50)
+	require.Len(t, best, 2)
+	assert.Equal(t, abi.NewTokenAmount(20), best[0].Voucher.Amount)
+	assert.Equal(t, abi.NewTokenAmount(4), best[1].Voucher.Amount)
+
+	delta, err := VoucherRedeemable(best[0].Voucher, st, 50)
+	require.NoError(t, err)
+	assert.Equal(t, abi.NewTokenAmount(10), delta)
+	_, err = VoucherRedeemable(chinfo.Vouchers[2].Voucher, st, 50)
+	assert.EqualError(t, err, "voucher nonce 1 is lower than lane 0's nonce 2")
+}
+
+func TestManager_SubmitSettlingVouchers(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	paychAddr := spect.NewActorAddr(t, "abcd123")
+	clientAddr := spect.NewIDAddr(t, 99)
+	minerAddr := spect.NewIDAddr(t, 100)
+
+	tok, err := encoding.Encode(block.NewTipSetKey(shared_testutil.GenerateCids(1)[0]))
+	require.NoError(t, err)
+
+	testAPI := paychtest.NewFakePaymentChannelAPI(ctx, t)
+	viewer := paychtest.NewFakeStateViewer(t)
+	manager := NewManager(ctx, dss.MutexWrap(datastore.NewMapDatastore()), testAPI, testAPI, viewer)
+	viewer.GetFakeStateView().AddActorWithState(paychAddr, clientAddr, minerAddr, address.Undef)
+
+	sig := crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: []byte("doesntmatter")}
+	var best paychActor.SignedVoucher
+	for i := uint64(1); i <= 2; i++ {
+		v := paychActor.SignedVoucher{Lane: 0, Nonce: i, Amount: abi.NewTokenAmount(int64(10 * i)), Signature: &sig, SecretPreimage: []byte{}}
+		_, err := manager.AddVoucher(paychAddr, &v, []byte("proof"), abi.NewTokenAmount(100), tok)
+		require.NoError(t, err)
+		best = v
+	}
+	own := func(a address.Address) bool { return a == minerAddr }
+
+	t.Log("vouchers of open channels are not submitted")
+	testAPI.MsgSendErr = errors.New("unexpected send")
+	require.NoError(t, manager.SubmitSettlingVouchers(ctx, tok, 10, own))
+
+	t.Log("the payee submits the best voucher of a settling channel once")
+	viewer.GetFakeStateView().SetPaychState(paychAddr, &paychActor.State{
+		From:       clientAddr,
+		To:         minerAddr,
+		ToSend:     big.Zero(),
+		SettlingAt: 30,
+		LaneStates: []*paychActor.LaneState{{ID: 0, Redeemed: abi.NewTokenAmount(10), Nonce: 1}},
+	})
+	require.NoError(t, manager.SubmitSettlingVouchers(ctx, tok, 10, func(address.Address) bool { return false }))
+
+	testAPI.MsgSendErr = nil
+	testAPI.ExpectedMsgCid, testAPI.ExpectedResult = genUpdateChannelStateMessage(minerAddr, paychAddr, &paychActor.UpdateChannelStateParams{Sv: best, Proof: []byte("proof")})
+	require.NoError(t, manager.SubmitSettlingVouchers(ctx, tok, 10, own))
+
+	testAPI.MsgSendErr = errors.New("unexpected send")
+	require.NoError(t, manager.SubmitSettlingVouchers(ctx, tok, 11, own))
+
+	t.Log("vouchers still not redeemed are submitted again")
+	testAPI.MsgSendErr = nil
+	require.NoError(t, manager.SubmitSettlingVouchers(ctx, tok, 20, own))
+
+	t.Log("vouchers are not submitted once the channel has settled")
+	testAPI.MsgSendErr = errors.New("unexpected send")
+	require.NoError(t, manager.SubmitSettlingVouchers(ctx, tok, 30, own))
+}
+
+func genUpdateChannelStateMessage(from, paychAddr address.Address, params *paychActor.UpdateChannelStateParams) (mcid cid.Cid, res paychtest.MsgResult) {
+	mcid = shared_testutil.GenerateCids(1)[0]
+	msg := types.NewUnsignedMessage(from, paychAddr, 0, types.ZeroAttoFIL, builtin.MethodsPaych.UpdateChannelState, []byte{})
+	msg.GasPrice = types.NewAttoFILFromFIL(100)
+	msg.GasLimit = gas.NewGas(5000)
+	return mcid, paychtest.MsgResult{
+		Msg:           &types.SignedMessage{Message: *msg},
+		DecodedParams: params,
+		MsgCid:        mcid,
+		Rcpt:          &vm.MessageReceipt{},
+	}
+}
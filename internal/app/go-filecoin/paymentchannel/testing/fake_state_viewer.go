@@ -6,6 +6,7 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-fil-markets/shared"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paymentchannel"
 )
@@ -45,6 +46,8 @@ var _ paymentchannel.ManagerStateView = new(FakeStateView)
 // FakeActorState is a mock actor state containing test info
 type FakeActorState struct {
 	To, From, IDAddr, MinerWorker address.Address
+	// PaychState is the state of a payment channel actor, if set.
+	PaychState *paych.State
 }
 
 // MinerControlAddresses mocks returning miner worker and miner actor address
@@ -65,9 +68,33 @@ func (f *FakeStateView) PaychActorParties(_ context.Context, paychAddr address.A
 	return st.From, st.To, f.PaychActorPartiesErr
 }
 
+// PaychActorState mocks returning the state of a paych.Actor, which is a new
+// channel between its parties unless set.
+func (f *FakeStateView) PaychActorState(_ context.Context, paychAddr address.Address) (*paych.State, error) {
+	st, ok := f.actors[paychAddr]
+	if !ok {
+		f.t.Fatalf("actor does not exist %s", paychAddr.String())
+	}
+	if st.PaychState != nil {
+		return st.PaychState, nil
+	}
+	return paych.ConstructState(st.From, st.To), nil
+}
+
+// AccountSignerAddress mocks resolving an account address to its key address,
+// which is the address itself.
+func (f *FakeStateView) AccountSignerAddress(_ context.Context, a address.Address) (address.Address, error) {
+	return a, nil
+}
+
 // AddActorWithState sets up a mock state for actorAddr
 func (f *FakeStateView) AddActorWithState(actorAddr, from, to, id address.Address) {
-	f.actors[actorAddr] = &FakeActorState{to, from, id, address.Undef}
+	f.actors[actorAddr] = &FakeActorState{To: to, From: from, IDAddr: id}
+}
+
+// SetPaychState sets the state of the payment channel actor at actorAddr.
+func (f *FakeStateView) SetPaychState(actorAddr address.Address, st *paych.State) {
+	f.actors[actorAddr].PaychState = st
 }
 
 // AddMinerWithState sets up a mock state for a miner actor with a worker address
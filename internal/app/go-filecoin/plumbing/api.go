@@ -2,11 +2,13 @@ package plumbing
 
 import (
 	"context"
+	"errors"
 	"io"
 	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
 	acrypto "github.com/filecoin-project/specs-actors/actors/crypto"
 	"github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
@@ -16,15 +18,21 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 	ma "github.com/multiformats/go-multiaddr"
 
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paymentchannel"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cfg"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/dag"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainreplay"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/drand"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/forkmon"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/net"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/piecemanager"
@@ -35,8 +43,12 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet/history"
 )
 
+// ErrJournalUnavailable is returned when the node's journal cannot be read back.
+var ErrJournalUnavailable = errors.New("the node's journal is not queryable")
+
 // API is the plumbing implementation, the irreducible set of calls required
 // to implement protocols and user/network-facing features. You probably should
 // depend on the higher level porcelain.API instead of this api, as it includes
@@ -46,32 +58,46 @@ type API struct {
 	logger logging.EventLogger
 
 	chain        *cst.ChainStateReadWriter
+	chainClock   clock.ChainEpochClock
 	syncer       *cst.ChainSyncProvider
 	config       *cfg.Config
 	dag          *dag.DAG
+	drand        drand.IFace
 	expected     consensus.Protocol
+	journal      journal.Reader
 	msgPool      *message.Pool
 	msgPreviewer *msg.Previewer
 	msgWaiter    *msg.Waiter
 	network      *net.Network
 	outbox       *message.Outbox
+	paychMgr     *paymentchannel.Manager
 	pieceManager func() piecemanager.PieceManager
+	replayer     *chainreplay.Replayer
 	wallet       *wallet.Wallet
+	addressBook  *wallet.AddressBook
+	history      *history.Index
 }
 
 // APIDeps contains all the API's dependencies
 type APIDeps struct {
+	AddressBook  *wallet.AddressBook
 	Chain        *cst.ChainStateReadWriter
+	ChainClock   clock.ChainEpochClock
 	Sync         *cst.ChainSyncProvider
 	Config       *cfg.Config
 	DAG          *dag.DAG
+	Drand        drand.IFace
 	Expected     consensus.Protocol
+	History      *history.Index
+	Journal      journal.Reader
 	MsgPool      *message.Pool
 	MsgPreviewer *msg.Previewer
 	MsgWaiter    *msg.Waiter
 	Network      *net.Network
 	Outbox       *message.Outbox
+	PaychMgr     *paymentchannel.Manager
 	PieceManager func() piecemanager.PieceManager
+	Replayer     *chainreplay.Replayer
 	Wallet       *wallet.Wallet
 }
 
@@ -80,17 +106,24 @@ func New(deps *APIDeps) *API {
 	return &API{
 		logger:       logging.Logger("porcelain"),
 		chain:        deps.Chain,
+		chainClock:   deps.ChainClock,
 		syncer:       deps.Sync,
 		config:       deps.Config,
 		dag:          deps.DAG,
+		drand:        deps.Drand,
 		expected:     deps.Expected,
+		journal:      deps.Journal,
 		msgPool:      deps.MsgPool,
 		msgPreviewer: deps.MsgPreviewer,
 		msgWaiter:    deps.MsgWaiter,
 		network:      deps.Network,
 		outbox:       deps.Outbox,
+		paychMgr:     deps.PaychMgr,
 		pieceManager: deps.PieceManager,
+		replayer:     deps.Replayer,
 		wallet:       deps.Wallet,
+		addressBook:  deps.AddressBook,
+		history:      deps.History,
 	}
 }
 
@@ -99,6 +132,11 @@ func (api *API) ActorGet(ctx context.Context, addr address.Address) (*actor.Acto
 	return api.chain.GetActor(ctx, addr)
 }
 
+// ActorGetAt returns an actor from the state at the given tipset
+func (api *API) ActorGetAt(ctx context.Context, key block.TipSetKey, addr address.Address) (*actor.Actor, error) {
+	return api.chain.GetActorAt(ctx, key, addr)
+}
+
 // ActorGetSignature returns the signature of the given actor's given method.
 // The function signature is typically used to enable a caller to decode the
 // output of an actor method call (message).
@@ -152,6 +190,37 @@ func (api *API) ChainHeadKey() block.TipSetKey {
 	return api.chain.Head()
 }
 
+// ChainNotify returns a channel receiving each new head tipset until the
+// context is done.
+func (api *API) ChainNotify(ctx context.Context) <-chan block.TipSet {
+	heads := api.chain.HeadEvents().Sub(chain.NewHeadTopic)
+	out := make(chan block.TipSet)
+	go func() {
+		defer close(out)
+		defer api.chain.HeadEvents().Unsub(heads, chain.NewHeadTopic)
+		for {
+			select {
+			case h, ok := <-heads:
+				if !ok {
+					return
+				}
+				ts, ok := h.(block.TipSet)
+				if !ok {
+					continue
+				}
+				select {
+				case out <- ts:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
 // ChainSetHead sets `key` as the new head of this chain iff it exists in the nodes chain store.
 func (api *API) ChainSetHead(ctx context.Context, key block.TipSetKey) error {
 	return api.chain.SetHead(ctx, key)
@@ -177,6 +246,22 @@ func (api *API) SyncerStatus() status.Status {
 	return api.syncer.Status()
 }
 
+// ChainForks returns up to `limit` of the most recent reorgs observed by the syncer.
+func (api *API) ChainForks(limit int) []*forkmon.Reorg {
+	return api.syncer.Reorgs(limit)
+}
+
+// ChainValidate re-runs the state transitions of the stored tipsets with heights
+// in [from, to] and compares the results with the recorded state and receipt roots.
+func (api *API) ChainValidate(ctx context.Context, from, to abi.ChainEpoch) (*chainreplay.Result, error) {
+	return api.replayer.Replay(ctx, from, to)
+}
+
+// ChainEpochStartTime returns the time at which an epoch starts.
+func (api *API) ChainEpochStartTime(epoch abi.ChainEpoch) time.Time {
+	return api.chainClock.StartTimeOfEpoch(epoch)
+}
+
 // ChainSyncHandleNewTipSet submits a chain head to the syncer for processing.
 func (api *API) ChainSyncHandleNewTipSet(ci *block.ChainInfo) error {
 	return api.syncer.HandleNewTipSet(ci)
@@ -243,11 +328,31 @@ func (api *API) MessageSend(ctx context.Context, from, to address.Address, value
 	return api.outbox.Send(ctx, from, to, value, gasPrice, gasLimit, true, method, params)
 }
 
+// MessageSendEncoded is MessageSend for parameters that are already encoded.
+func (api *API) MessageSendEncoded(ctx context.Context, from, to address.Address, value types.AttoFIL, gasPrice types.AttoFIL, gasLimit gas.Unit, method abi.MethodNum, encodedParams []byte) (cid.Cid, chan error, error) {
+	return api.outbox.SendEncoded(ctx, from, to, value, gasPrice, gasLimit, true, method, encodedParams)
+}
+
 //SignedMessageSend sends a siged message.
 func (api *API) SignedMessageSend(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, chan error, error) {
 	return api.outbox.SignedSend(ctx, smsg, true)
 }
 
+// MessageNextNonce returns the nonce of the next message sent from an address, accounting for
+// messages from it in the outbound queue.
+func (api *API) MessageNextNonce(ctx context.Context, from address.Address) (uint64, error) {
+	return api.outbox.NextNonce(ctx, from)
+}
+
+// SignedMessageValidate checks a signed message's syntax and that its signature is the sender's,
+// resolving the sender's key at the head.
+func (api *API) SignedMessageValidate(ctx context.Context, smsg *types.SignedMessage) error {
+	if err := consensus.NewMessageSyntaxValidator().ValidateSignedMessageSyntax(ctx, smsg); err != nil {
+		return err
+	}
+	return consensus.NewMessageSignatureValidator(api.chain).Validate(ctx, smsg)
+}
+
 // MessageWait invokes the callback when a message with the given cid appears on chain.
 // It will find the message in both the case that it is already on chain and
 // the case that it appears in a newly mined block. An error is returned if one is
@@ -322,6 +427,76 @@ func (api *API) WalletExport(addrs []address.Address) ([]*crypto.KeyInfo, error)
 	return api.wallet.Export(addrs)
 }
 
+// WalletSetSeed makes the wallet derive new addresses from an HD seed.
+func (api *API) WalletSetSeed(seed []byte) error {
+	return wallet.SetSeed(api.wallet, seed)
+}
+
+// WalletDeriveAddress returns the address at an index of the wallet's derivation path.
+func (api *API) WalletDeriveAddress(protocol address.Protocol, index uint32) (address.Address, error) {
+	return wallet.DeriveAddress(api.wallet, protocol, index)
+}
+
+// WalletImportDerived adds the address at an index of the wallet's derivation path to the wallet.
+func (api *API) WalletImportDerived(protocol address.Protocol, index uint32) (address.Address, error) {
+	return wallet.ImportDerived(api.wallet, protocol, index)
+}
+
+// WalletSignBytes signs data with the key of a wallet address.
+func (api *API) WalletSignBytes(data []byte, addr address.Address) (crypto.Signature, error) {
+	return api.wallet.SignBytes(data, addr)
+}
+
+// WalletHistory returns the transactions affecting a wallet or miner address between two epochs.
+func (api *API) WalletHistory(addr address.Address, from, to abi.ChainEpoch) ([]history.Entry, error) {
+	return api.history.History(addr, from, to)
+}
+
+// AddressBookSet labels an address in the address book.
+func (api *API) AddressBookSet(label string, addr address.Address) error {
+	return api.addressBook.Set(label, addr)
+}
+
+// AddressBookRemove removes a label from the address book.
+func (api *API) AddressBookRemove(label string) error {
+	return api.addressBook.Remove(label)
+}
+
+// AddressBookEntries lists the labelled addresses of the address book.
+func (api *API) AddressBookEntries() []wallet.AddressBookEntry {
+	return api.addressBook.Entries()
+}
+
+// AddressBookLabel returns the label of an address, if it has one.
+func (api *API) AddressBookLabel(addr address.Address) (string, bool) {
+	return api.addressBook.Label(addr)
+}
+
+// AddressResolve parses an address, or looks it up in the address book by label.
+func (api *API) AddressResolve(s string) (address.Address, error) {
+	return api.addressBook.Resolve(s)
+}
+
+// PaychList returns the payment channels the node pays or is paid through.
+func (api *API) PaychList() ([]paymentchannel.ChannelInfo, error) {
+	return api.paychMgr.ListChannels()
+}
+
+// PaychGet returns the node's record of a payment channel and its vouchers.
+func (api *API) PaychGet(paychAddr address.Address) (*paymentchannel.ChannelInfo, error) {
+	return api.paychMgr.GetPaymentChannelInfo(paychAddr)
+}
+
+// PaychAllocateLane allocates a new lane of a payment channel the node pays through.
+func (api *API) PaychAllocateLane(paychAddr address.Address) (uint64, error) {
+	return api.paychMgr.AllocateLane(paychAddr)
+}
+
+// PaychAddVoucher records a voucher the node created for a payment channel.
+func (api *API) PaychAddVoucher(paychAddr address.Address, voucher *paych.SignedVoucher) error {
+	return api.paychMgr.AddVoucherToChannel(paychAddr, voucher)
+}
+
 // DAGGetNode returns the associated DAG node for the passed in CID.
 func (api *API) DAGGetNode(ctx context.Context, ref string) (interface{}, error) {
 	return api.dag.GetNode(ctx, ref)
@@ -341,11 +516,61 @@ func (api *API) DAGCat(ctx context.Context, c cid.Cid) (io.Reader, error) {
 // DAGImportData adds data from an io reader to the merkledag and returns the
 // Cid of the given data. Once the data is in the DAG, it can fetched from the
 // node via Bitswap and a copy will be kept in the blockstore.
-func (api *API) DAGImportData(ctx context.Context, data io.Reader) (ipld.Node, error) {
-	return api.dag.ImportData(ctx, data)
+func (api *API) DAGImportData(ctx context.Context, data io.Reader, opts dag.ImportOptions) (ipld.Node, error) {
+	return api.dag.ImportData(ctx, data, opts)
+}
+
+// DAGImportCar adds the blocks of a CAR file to the merkledag as they are and
+// returns the root of the DAG they form.
+func (api *API) DAGImportCar(ctx context.Context, r io.Reader) (cid.Cid, error) {
+	return api.dag.ImportCar(ctx, r)
+}
+
+// DAGWriteCar writes the DAG under a root to a CAR file as storage deals
+// transfer it.
+func (api *API) DAGWriteCar(ctx context.Context, root cid.Cid, w io.Writer) error {
+	return api.dag.WriteCar(ctx, root, w)
+}
+
+// DAGPieceInfo computes the piece commitment and padded size of the piece a
+// storage deal for the DAG under a root stores.
+func (api *API) DAGPieceInfo(ctx context.Context, root cid.Cid) (*dag.PieceInfo, error) {
+	return api.dag.PieceInfo(ctx, root)
 }
 
 // PieceManager returns the piece manager
 func (api *API) PieceManager() piecemanager.PieceManager {
 	return api.pieceManager()
 }
+
+// DrandReadEntry fetches the entry for a round from the drand network.
+func (api *API) DrandReadEntry(ctx context.Context, round drand.Round) (*drand.Entry, error) {
+	return api.drand.ReadEntry(ctx, round)
+}
+
+// DrandStartTimeOfRound returns the time at which a drand round starts.
+func (api *API) DrandStartTimeOfRound(round drand.Round) time.Time {
+	return api.drand.StartTimeOfRound(round)
+}
+
+// DrandRoundsInInterval returns the drand rounds starting in [startTime, endTime).
+func (api *API) DrandRoundsInInterval(startTime, endTime time.Time) []drand.Round {
+	return api.drand.RoundsInInterval(startTime, endTime)
+}
+
+// JournalQuery returns the journal entries selected by the filter, oldest first.
+func (api *API) JournalQuery(filter journal.Filter) ([]journal.Entry, error) {
+	if api.journal == nil {
+		return nil, ErrJournalUnavailable
+	}
+	return api.journal.Query(filter)
+}
+
+// JournalSubscribe returns a channel receiving journal entries selected by the
+// filter as they are recorded, until the context is done.
+func (api *API) JournalSubscribe(ctx context.Context, filter journal.Filter) (<-chan journal.Entry, error) {
+	if api.journal == nil {
+		return nil, ErrJournalUnavailable
+	}
+	return api.journal.Subscribe(ctx, filter), nil
+}
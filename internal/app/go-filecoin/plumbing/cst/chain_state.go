@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/cskr/pubsub"
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/builtin"
@@ -38,6 +39,7 @@ var logStore = logging.Logger("plumbing/chain_store")
 
 type chainReadWriter interface {
 	GetHead() block.TipSetKey
+	HeadEvents() *pubsub.PubSub
 	GetGenesisBlock(ctx context.Context) (*block.Block, error)
 	GetTipSet(block.TipSetKey) (block.TipSet, error)
 	GetTipSetState(context.Context, block.TipSetKey) (vmstate.Tree, error)
@@ -109,6 +111,12 @@ func NewChainStateReadWriter(crw chainReadWriter, messages chain.MessageProvider
 	}
 }
 
+// HeadEvents returns a pubsub publishing each new head tipset on
+// chain.NewHeadTopic.
+func (chn *ChainStateReadWriter) HeadEvents() *pubsub.PubSub {
+	return chn.readWriter.HeadEvents()
+}
+
 // Head returns the head tipset
 func (chn *ChainStateReadWriter) Head() block.TipSetKey {
 	return chn.readWriter.GetHead()
@@ -329,7 +337,7 @@ func (chn *ChainStateReadWriter) ChainStateTree(ctx context.Context, c cid.Cid)
 	offl := offline.Exchange(chn.bstore)
 	blkserv := blockservice.New(chn.bstore, offl)
 	dserv := merkdag.NewDAGService(blkserv)
-	return dag.NewDAG(dserv).RecursiveGet(ctx, c)
+	return dag.NewDAG(dserv, chn.bstore).RecursiveGet(ctx, c)
 }
 
 func (chn *ChainStateReadWriter) StateView(key block.TipSetKey) (*state.View, error) {
@@ -4,11 +4,13 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/forkmon"
 )
 
 type chainSync interface {
 	BlockProposer() chainsync.BlockProposer
 	Status() status.Status
+	Reorgs(limit int) []*forkmon.Reorg
 }
 
 // ChainSyncProvider provides access to chain sync operations and their status.
@@ -29,6 +31,11 @@ func (chs *ChainSyncProvider) Status() status.Status {
 	return chs.sync.Status()
 }
 
+// Reorgs returns up to `limit` of the most recent reorgs, most recent first.
+func (chs *ChainSyncProvider) Reorgs(limit int) []*forkmon.Reorg {
+	return chs.sync.Reorgs(limit)
+}
+
 // HandleNewTipSet extends the Syncer's chain store with the given tipset if they
 // represent a valid extension. It limits the length of new chains it will
 // attempt to validate and caches invalid blocks it has encountered to
@@ -0,0 +1,77 @@
+package dag
+
+import (
+	"context"
+	"io"
+
+	"github.com/filecoin-project/go-fil-markets/pieceio"
+	"github.com/filecoin-project/go-fil-markets/pieceio/cario"
+	"github.com/filecoin-project/go-fil-markets/shared"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// pieceCommitmentProof is the proof the piece commitment of a CAR file is
+// computed with. The commitment does not depend on the proof, which only
+// bounds the size of the piece, so the proof with the largest sectors is used.
+const pieceCommitmentProof = abi.RegisteredProof_StackedDRG64GiBSeal
+
+// PieceInfo describes the piece a storage deal for a DAG stores: the CAR file
+// holding all of the DAG's blocks, padded to a power of two.
+type PieceInfo struct {
+	Root      cid.Cid
+	PieceCID  cid.Cid
+	PieceSize abi.PaddedPieceSize
+	// CarSize is the size of the CAR file before padding.
+	CarSize uint64
+}
+
+// ImportCar adds the blocks of a CAR file to the blockstore as they are and
+// returns the root of the DAG they form. The file must have a single root.
+// Blocks are not decoded, so blocks of any codec are imported.
+func (dag *DAG) ImportCar(ctx context.Context, r io.Reader) (cid.Cid, error) {
+	return cario.NewCarIO().LoadCar(dag.bstore, r)
+}
+
+// WriteCar writes the DAG under a root to a CAR file as a storage deal for the
+// DAG transfers it.
+func (dag *DAG) WriteCar(ctx context.Context, root cid.Cid, w io.Writer) error {
+	return cario.NewCarIO().WriteCar(ctx, dagStore{ctx: ctx, dserv: dag.dserv}, root, shared.AllSelector(), w)
+}
+
+// PieceInfo computes the piece commitment and padded size of the piece a
+// storage deal for the DAG under a root stores.
+func (dag *DAG) PieceInfo(ctx context.Context, root cid.Cid) (*PieceInfo, error) {
+	car, err := cario.NewCarIO().PrepareCar(ctx, dagStore{ctx: ctx, dserv: dag.dserv}, root, shared.AllSelector())
+	if err != nil {
+		return nil, err
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		_ = w.CloseWithError(car.Dump(w))
+	}()
+	commP, size, err := pieceio.GeneratePieceCommitment(pieceCommitmentProof, r, car.Size())
+	_ = r.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &PieceInfo{
+		Root:      root,
+		PieceCID:  commP,
+		PieceSize: size.Padded(),
+		CarSize:   car.Size(),
+	}, nil
+}
+
+// dagStore adapts a DAG service to the block stores CAR files are read from.
+type dagStore struct {
+	ctx   context.Context
+	dserv ipld.DAGService
+}
+
+func (s dagStore) Get(c cid.Cid) (blocks.Block, error) {
+	return s.dserv.Get(s.ctx, c)
+}
@@ -0,0 +1,105 @@
+package dag
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	"github.com/ipfs/go-merkledag"
+	"github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func newTestDAG() *DAG {
+	bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+	return NewDAG(merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs))), bs)
+}
+
+func TestImportDataOptions(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	data := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	dag := newTestDAG()
+	nd, err := dag.ImportData(ctx, bytes.NewReader(data), ImportOptions{Chunker: "size-1000", RawLeaves: true})
+	require.NoError(t, err)
+	require.Len(t, nd.Links(), 10)
+	assert.Equal(t, uint64(cid.Raw), nd.Links()[0].Cid.Prefix().Codec)
+
+	rabin, err := dag.ImportData(ctx, bytes.NewReader(data), ImportOptions{Chunker: "rabin-256-1024-2048"})
+	require.NoError(t, err)
+	assert.NotEqual(t, nd.Cid(), rabin.Cid())
+
+	_, err = dag.ImportData(ctx, bytes.NewReader(data), ImportOptions{Chunker: "fixed"})
+	assert.Error(t, err)
+
+	r, err := dag.Cat(ctx, rabin.Cid())
+	require.NoError(t, err)
+	out, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, data, out)
+}
+
+func TestCarRoundTrip(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	data := make([]byte, 5000)
+	rand.New(rand.NewSource(2)).Read(data)
+
+	src := newTestDAG()
+	nd, err := src.ImportData(ctx, bytes.NewReader(data), ImportOptions{Chunker: "size-1024"})
+	require.NoError(t, err)
+	var car bytes.Buffer
+	require.NoError(t, src.WriteCar(ctx, nd.Cid(), &car))
+
+	info, err := src.PieceInfo(ctx, nd.Cid())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(car.Len()), info.CarSize)
+	assert.Equal(t, abi.PaddedPieceSize(8192), info.PieceSize)
+	assert.True(t, info.PieceCID.Defined())
+
+	t.Log("importing the CAR file recreates the same DAG and piece")
+	dst := newTestDAG()
+	root, err := dst.ImportCar(ctx, &car)
+	require.NoError(t, err)
+	assert.Equal(t, nd.Cid(), root)
+
+	imported, err := dst.PieceInfo(ctx, root)
+	require.NoError(t, err)
+	assert.Equal(t, info, imported)
+}
+
+func TestImportCarKeepsBlocksOfAnyCodec(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	// No decoder is registered for git objects.
+	data := []byte("blob 5\x00hello")
+	c, err := cid.Prefix{Version: 1, Codec: cid.GitRaw, MhType: multihash.SHA2_256, MhLength: -1}.Sum(data)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{c}, Version: 1}, &buf))
+	require.NoError(t, carutil.LdWrite(&buf, c.Bytes(), data))
+
+	dag := newTestDAG()
+	root, err := dag.ImportCar(ctx, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, c, root)
+	blk, err := dag.bstore.Get(c)
+	require.NoError(t, err)
+	assert.Equal(t, data, blk.RawData())
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 
 	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	chunk "github.com/ipfs/go-ipfs-chunker"
 	format "github.com/ipfs/go-ipld-format"
 	ipld "github.com/ipfs/go-ipld-format"
@@ -14,7 +15,8 @@ import (
 	"github.com/ipfs/go-path"
 	"github.com/ipfs/go-path/resolver"
 	"github.com/ipfs/go-unixfs"
-	imp "github.com/ipfs/go-unixfs/importer"
+	"github.com/ipfs/go-unixfs/importer/balanced"
+	h "github.com/ipfs/go-unixfs/importer/helpers"
 	uio "github.com/ipfs/go-unixfs/io"
 	"github.com/pkg/errors"
 )
@@ -22,12 +24,17 @@ import (
 // DAG is a service for accessing the merkledag
 type DAG struct {
 	dserv format.DAGService // Provides access to state tree.
+	// bstore is the blockstore under dserv, which blocks of any codec are
+	// put into as they are.
+	bstore blockstore.Blockstore
 }
 
-// NewDAG creates a DAG with a given DAGService
-func NewDAG(dserv ipld.DAGService) *DAG {
+// NewDAG creates a DAG with a given DAGService and the blockstore it keeps
+// blocks in.
+func NewDAG(dserv ipld.DAGService, bstore blockstore.Blockstore) *DAG {
 	return &DAG{
-		dserv: dserv,
+		dserv:  dserv,
+		bstore: bstore,
 	}
 }
 
@@ -92,14 +99,37 @@ func (dag *DAG) Cat(ctx context.Context, c cid.Cid) (uio.DagReader, error) {
 	return uio.NewDagReader(ctx, data, dag.dserv)
 }
 
+// ImportOptions controls how ImportData lays out data in the merkledag. The
+// zero value imports data as before, in fixed size chunks of 256KiB.
+type ImportOptions struct {
+	// Chunker splits the data into leaves: "size-<bytes>" for fixed size
+	// chunks, or "rabin", "rabin-<avg>" or "rabin-<min>-<avg>-<max>" for
+	// content defined chunks.
+	Chunker string
+	// RawLeaves stores leaves as raw blocks rather than unixfs nodes.
+	RawLeaves bool
+}
+
 // ImportData adds data from an io stream to the merkledag and returns the Cid
 // of the given data
-func (dag *DAG) ImportData(ctx context.Context, data io.Reader) (ipld.Node, error) {
+func (dag *DAG) ImportData(ctx context.Context, data io.Reader, opts ImportOptions) (ipld.Node, error) {
 	bufds := ipld.NewBufferedDAG(ctx, dag.dserv)
 
-	spl := chunk.DefaultSplitter(data)
+	spl, err := chunk.FromString(data, opts.Chunker)
+	if err != nil {
+		return nil, err
+	}
 
-	nd, err := imp.BuildDagFromReader(bufds, spl)
+	params := h.DagBuilderParams{
+		Dagserv:   bufds,
+		Maxlinks:  h.DefaultLinksPerBlock,
+		RawLeaves: opts.RawLeaves,
+	}
+	db, err := params.New(spl)
+	if err != nil {
+		return nil, err
+	}
+	nd, err := balanced.Layout(db)
 	if err != nil {
 		return nil, err
 	}
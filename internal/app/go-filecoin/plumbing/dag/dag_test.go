@@ -30,7 +30,7 @@ func TestDAGGet(t *testing.T) {
 		offl := offline.Exchange(bs)
 		blkserv := blockservice.New(bs, offl)
 		dserv := merkledag.NewDAGService(blkserv)
-		dag := NewDAG(dserv)
+		dag := NewDAG(dserv, bs)
 
 		_, err := dag.GetNode(ctx, "awful")
 		assert.EqualError(t, err, "invalid path \"awful\": selected encoding not supported")
@@ -45,7 +45,7 @@ func TestDAGGet(t *testing.T) {
 		offl := offline.Exchange(bs)
 		blkserv := blockservice.New(bs, offl)
 		dserv := merkledag.NewDAGService(blkserv)
-		dag := NewDAG(dserv)
+		dag := NewDAG(dserv, bs)
 
 		someCid := types.CidFromString(t, "somecid")
 
@@ -61,7 +61,7 @@ func TestDAGGet(t *testing.T) {
 		offl := offline.Exchange(bs)
 		blkserv := blockservice.New(bs, offl)
 		dserv := merkledag.NewDAGService(blkserv)
-		dag := NewDAG(dserv)
+		dag := NewDAG(dserv, bs)
 
 		ipldnode := chain.NewBuilder(t, address.Undef).NewGenesis().At(0).ToNode()
 
@@ -7,9 +7,12 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	fsm "github.com/filecoin-project/storage-fsm"
 	"github.com/ipfs/go-cid"
 	"github.com/libp2p/go-libp2p-core/peer"
 
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paymentchannel"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
@@ -51,6 +54,16 @@ func (a *API) ChainHead() (block.TipSet, error) {
 	return ChainHead(a)
 }
 
+// ChainStats computes network health statistics over the epochs in [from, to]
+func (a *API) ChainStats(ctx context.Context, from, to abi.ChainEpoch) (*ChainStats, error) {
+	return ChainStatsRange(ctx, a, from, to)
+}
+
+// NodeHealth runs the node's readiness checks
+func (a *API) NodeHealth(ctx context.Context) (*NodeHealth, error) {
+	return CheckNodeHealth(ctx, a, time.Now())
+}
+
 // ChainGetFullBlock returns the full block given the header cid
 func (a *API) ChainGetFullBlock(ctx context.Context, id cid.Cid) (*block.FullBlock, error) {
 	return GetFullBlock(ctx, a, id)
@@ -106,6 +119,21 @@ func (a *API) WalletDefaultAddress() (address.Address, error) {
 	return WalletDefaultAddress(a)
 }
 
+// WalletAddressInfos describes the addresses of the wallet with their labels, ID addresses and balances.
+func (a *API) WalletAddressInfos(ctx context.Context) ([]WalletAddressInfo, error) {
+	return WalletAddressInfos(ctx, a)
+}
+
+// WalletInitSeed generates a mnemonic and makes the wallet derive new addresses from it.
+func (a *API) WalletInitSeed(passphrase string) (string, error) {
+	return WalletInitSeed(a, passphrase)
+}
+
+// WalletRecover restores the addresses derived from a mnemonic that have actors on chain.
+func (a *API) WalletRecover(ctx context.Context, mnemonic, passphrase string, gap uint32) ([]address.Address, error) {
+	return WalletRecover(ctx, a, mnemonic, passphrase, gap)
+}
+
 // SealPieceIntoNewSector writes the provided piece into a new sector
 func (a *API) SealPieceIntoNewSector(ctx context.Context, dealID abi.DealID, dealStart, dealEnd abi.ChainEpoch, pieceSize abi.UnpaddedPieceSize, pieceReader io.Reader) error {
 	return SealPieceIntoNewSector(ctx, a, dealID, dealStart, dealEnd, pieceSize, pieceReader)
@@ -126,6 +154,11 @@ func (a *API) MinerSetWorkerAddress(ctx context.Context, toAddr address.Address,
 	return MinerSetWorkerAddress(ctx, a, toAddr, gasPrice, gasLimit)
 }
 
+// SectorStatuses joins the sealing states of sectors of a miner with their state on chain
+func (a *API) SectorStatuses(ctx context.Context, maddr address.Address, sectors []fsm.SectorInfo) ([]SectorStatus, error) {
+	return SectorStatuses(ctx, a, maddr, sectors)
+}
+
 // MessageWaitDone blocks until the message is on chain
 func (a *API) MessageWaitDone(ctx context.Context, msgCid cid.Cid) (*vm.MessageReceipt, error) {
 	return MessageWaitDone(ctx, a, msgCid)
@@ -139,6 +172,22 @@ func (a *API) MinerStateView(baseKey block.TipSetKey) (MinerStateView, error) {
 	return a.StateView(baseKey)
 }
 
+func (a *API) MultisigStateView(baseKey block.TipSetKey) (MultisigStateView, error) {
+	return a.StateView(baseKey)
+}
+
+func (a *API) PaychStateView(baseKey block.TipSetKey) (PaychStateView, error) {
+	return a.StateView(baseKey)
+}
+
+func (a *API) ClientDealStateView(baseKey block.TipSetKey) (ClientDealStateView, error) {
+	return a.StateView(baseKey)
+}
+
+func (a *API) SectorStateView(baseKey block.TipSetKey) (SectorStateView, error) {
+	return a.StateView(baseKey)
+}
+
 func (a *API) FaultsStateView(baseKey block.TipSetKey) (consensus.FaultStateView, error) {
 	return a.StateView(baseKey)
 }
@@ -146,3 +195,105 @@ func (a *API) FaultsStateView(baseKey block.TipSetKey) (consensus.FaultStateView
 func (a *API) ProtocolStateView(baseKey block.TipSetKey) (ProtocolStateView, error) {
 	return a.StateView(baseKey)
 }
+
+func (a *API) AddressStateView(baseKey block.TipSetKey) (AddressStateView, error) {
+	return a.StateView(baseKey)
+}
+
+// MultisigCreate creates a multisig actor and returns its address
+func (a *API) MultisigCreate(
+	ctx context.Context,
+	from address.Address,
+	signers []address.Address,
+	threshold int64,
+	unlockDuration abi.ChainEpoch,
+	value types.AttoFIL,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+) (address.Address, error) {
+	return MultisigCreate(ctx, a, from, signers, threshold, unlockDuration, value, gasPrice, gasLimit)
+}
+
+// MultisigPropose proposes a transaction to a multisig actor and returns its ID
+func (a *API) MultisigPropose(
+	ctx context.Context,
+	from address.Address,
+	msigAddr address.Address,
+	to address.Address,
+	value types.AttoFIL,
+	method abi.MethodNum,
+	params []byte,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+) (int64, error) {
+	return MultisigPropose(ctx, a, from, msigAddr, to, value, method, params, gasPrice, gasLimit)
+}
+
+// MultisigApprove approves a pending transaction of a multisig actor
+func (a *API) MultisigApprove(ctx context.Context, from, msigAddr address.Address, txnID int64, gasPrice types.AttoFIL, gasLimit gas.Unit) error {
+	return MultisigApprove(ctx, a, from, msigAddr, txnID, gasPrice, gasLimit)
+}
+
+// MultisigCancel cancels a pending transaction of a multisig actor
+func (a *API) MultisigCancel(ctx context.Context, from, msigAddr address.Address, txnID int64, gasPrice types.AttoFIL, gasLimit gas.Unit) error {
+	return MultisigCancel(ctx, a, from, msigAddr, txnID, gasPrice, gasLimit)
+}
+
+// MultisigInspect describes a multisig actor and its pending transactions at a tipset
+func (a *API) MultisigInspect(ctx context.Context, msigAddr address.Address, key block.TipSetKey) (*MultisigInfo, error) {
+	return MultisigInspect(ctx, a, msigAddr, key)
+}
+
+// MessageCreate builds an unsigned message with the sender's next nonce, estimating the gas
+// limit if it is zero
+func (a *API) MessageCreate(
+	ctx context.Context,
+	from, to address.Address,
+	value types.AttoFIL,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+	method abi.MethodNum,
+	params []byte,
+) (*types.UnsignedMessage, error) {
+	return MessageCreate(ctx, a, from, to, value, gasPrice, gasLimit, method, params)
+}
+
+// SignedMessageCheck validates a signed message against the head before it is sent
+func (a *API) SignedMessageCheck(ctx context.Context, smsg *types.SignedMessage) error {
+	return SignedMessageCheck(ctx, a, smsg)
+}
+
+// PaychStatus reads the state of a payment channel at the head of the chain
+func (a *API) PaychStatus(ctx context.Context, paychAddr address.Address) (*PaychInfo, error) {
+	return PaychStatus(ctx, a, paychAddr)
+}
+
+// PaychSettle starts settling a payment channel
+func (a *API) PaychSettle(ctx context.Context, paychAddr address.Address, gasPrice types.AttoFIL, gasLimit gas.Unit) error {
+	return PaychSettle(ctx, a, paychAddr, gasPrice, gasLimit)
+}
+
+// PaychCollect pays out a settled payment channel
+func (a *API) PaychCollect(ctx context.Context, paychAddr address.Address, gasPrice types.AttoFIL, gasLimit gas.Unit) error {
+	return PaychCollect(ctx, a, paychAddr, gasPrice, gasLimit)
+}
+
+// PaychVoucherCreate creates a voucher for a payment channel the node pays through
+func (a *API) PaychVoucherCreate(ctx context.Context, paychAddr address.Address, amount abi.TokenAmount, lane *uint64) (*paych.SignedVoucher, error) {
+	return PaychVoucherCreate(ctx, a, paychAddr, amount, lane)
+}
+
+// PaychVoucherCheck returns what a voucher would redeem from a payment channel
+func (a *API) PaychVoucherCheck(ctx context.Context, paychAddr address.Address, voucher *paych.SignedVoucher) (abi.TokenAmount, error) {
+	return PaychVoucherCheck(ctx, a, paychAddr, voucher)
+}
+
+// PaychVoucherSubmit redeems a voucher of a payment channel
+func (a *API) PaychVoucherSubmit(ctx context.Context, paychAddr address.Address, voucher *paych.SignedVoucher, proof []byte, gasPrice types.AttoFIL, gasLimit gas.Unit) error {
+	return PaychVoucherSubmit(ctx, a, paychAddr, voucher, proof, gasPrice, gasLimit)
+}
+
+// PaychBestSpendable returns the best vouchers for each lane of a payment channel
+func (a *API) PaychBestSpendable(ctx context.Context, paychAddr address.Address) ([]*paymentchannel.VoucherInfo, error) {
+	return PaychBestSpendable(ctx, a, paychAddr)
+}
@@ -0,0 +1,262 @@
+package porcelain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+)
+
+// ChainStats summarizes network health over a range of epochs.
+type ChainStats struct {
+	From abi.ChainEpoch
+	To   abi.ChainEpoch
+
+	// NullRounds is the number of epochs in the range without a tipset.
+	NullRounds int
+	// NullRoundFrequency is NullRounds as a fraction of the epochs in the range.
+	NullRoundFrequency float64
+	// TipSetWidths maps a tipset width to the number of tipsets of that width.
+	TipSetWidths map[int]int
+
+	// MeanLateness and MaxLateness measure block timestamps against the
+	// start time of their epoch.
+	MeanLateness time.Duration
+	MaxLateness  time.Duration
+
+	Messages int
+	GasUsed  gas.Unit
+
+	// Miners lists the miners that produced blocks in the range, ordered by
+	// decreasing block count.
+	Miners []MinerChainStats
+	// Epochs has one entry per epoch in the range, in increasing order.
+	Epochs []EpochStats
+}
+
+// MinerChainStats compares a miner's share of blocks in a range with its
+// share of quality-adjusted power at the end of the range.
+type MinerChainStats struct {
+	Miner      address.Address
+	Blocks     int
+	BlockShare float64
+	PowerShare float64
+}
+
+// EpochStats describes a single epoch. Gas is only known for tipsets whose
+// messages have been executed, i.e. those with a child on the chain.
+type EpochStats struct {
+	Epoch       abi.ChainEpoch
+	Null        bool
+	Width       int
+	Messages    int
+	GasUsed     gas.Unit
+	MaxLateness time.Duration
+}
+
+type chainStatsAPI interface {
+	ChainHeadKey() block.TipSetKey
+	ChainTipSet(key block.TipSetKey) (block.TipSet, error)
+	ChainGetMessages(ctx context.Context, metaCid cid.Cid) ([]*types.UnsignedMessage, []*types.SignedMessage, error)
+	ChainGetReceipts(ctx context.Context, id cid.Cid) ([]vm.MessageReceipt, error)
+	ChainEpochStartTime(epoch abi.ChainEpoch) time.Time
+	PowerStateView(baseKey block.TipSetKey) (consensus.PowerStateView, error)
+}
+
+// ChainStatsRange computes statistics over the epochs in [from, to] of the
+// chain ending at the current head. `to` is clamped to the head height.
+func ChainStatsRange(ctx context.Context, plumbing chainStatsAPI, from, to abi.ChainEpoch) (*ChainStats, error) {
+	if from < 0 || from > to {
+		return nil, fmt.Errorf("invalid range: %d to %d", from, to)
+	}
+	head, err := plumbing.ChainTipSet(plumbing.ChainHeadKey())
+	if err != nil {
+		return nil, err
+	}
+	headHeight, err := head.Height()
+	if err != nil {
+		return nil, err
+	}
+	if to > headHeight {
+		to = headHeight
+	}
+	if from > to {
+		return nil, fmt.Errorf("range starts at %d, after head height %d", from, headHeight)
+	}
+
+	stats := &ChainStats{
+		From:         from,
+		To:           to,
+		TipSetWidths: make(map[int]int),
+	}
+	epochs := make([]EpochStats, to-from+1)
+	for i := range epochs {
+		epochs[i] = EpochStats{Epoch: from + abi.ChainEpoch(i), Null: true}
+	}
+
+	var top block.TipSet
+	blocks := make(map[address.Address]int)
+	totalBlocks := 0
+	var totalLateness time.Duration
+
+	// Walk from the head down, remembering each tipset's child so that its
+	// receipts can be read from the child's headers.
+	var child block.TipSet
+	ts := head
+	for {
+		height, err := ts.Height()
+		if err != nil {
+			return nil, err
+		}
+		if height < from {
+			break
+		}
+		if height <= to {
+			if !top.Defined() {
+				top = ts
+			}
+			es := &epochs[height-from]
+			es.Null = false
+			es.Width = ts.Len()
+			stats.TipSetWidths[ts.Len()]++
+
+			es.Messages, err = countMessages(ctx, plumbing, ts)
+			if err != nil {
+				return nil, err
+			}
+			if child.Defined() {
+				receipts, err := plumbing.ChainGetReceipts(ctx, child.At(0).MessageReceipts.Cid)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to load receipts for tipset %s", ts.Key())
+				}
+				for _, r := range receipts {
+					es.GasUsed += r.GasUsed
+				}
+			}
+
+			start := plumbing.ChainEpochStartTime(height)
+			for i := 0; i < ts.Len(); i++ {
+				blk := ts.At(i)
+				lateness := time.Unix(int64(blk.Timestamp), 0).Sub(start)
+				if lateness > es.MaxLateness {
+					es.MaxLateness = lateness
+				}
+				totalLateness += lateness
+				blocks[blk.Miner]++
+				totalBlocks++
+			}
+			if es.MaxLateness > stats.MaxLateness {
+				stats.MaxLateness = es.MaxLateness
+			}
+			stats.Messages += es.Messages
+			stats.GasUsed += es.GasUsed
+		}
+
+		parentKey, err := ts.Parents()
+		if err != nil {
+			return nil, err
+		}
+		if parentKey.Empty() {
+			break
+		}
+		child = ts
+		if ts, err = plumbing.ChainTipSet(parentKey); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, es := range epochs {
+		if es.Null {
+			stats.NullRounds++
+		}
+	}
+	stats.NullRoundFrequency = float64(stats.NullRounds) / float64(len(epochs))
+	if totalBlocks > 0 {
+		stats.MeanLateness = totalLateness / time.Duration(totalBlocks)
+	}
+	stats.Epochs = epochs
+
+	if top.Defined() {
+		stats.Miners, err = minerShares(ctx, plumbing, top.Key(), blocks, totalBlocks)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// countMessages counts the distinct messages included in a tipset.
+func countMessages(ctx context.Context, plumbing chainStatsAPI, ts block.TipSet) (int, error) {
+	seen := make(map[cid.Cid]struct{})
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		blsMsgs, secpMsgs, err := plumbing.ChainGetMessages(ctx, blk.Messages.Cid)
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to load messages for block %s", blk.Cid())
+		}
+		for _, msg := range blsMsgs {
+			c, err := msg.Cid()
+			if err != nil {
+				return 0, err
+			}
+			seen[c] = struct{}{}
+		}
+		for _, msg := range secpMsgs {
+			c, err := msg.Cid()
+			if err != nil {
+				return 0, err
+			}
+			seen[c] = struct{}{}
+		}
+	}
+	return len(seen), nil
+}
+
+// minerShares compares each miner's share of blocks with its share of
+// quality-adjusted power in the state after the tipset `key`.
+func minerShares(ctx context.Context, plumbing chainStatsAPI, key block.TipSetKey, blocks map[address.Address]int, totalBlocks int) ([]MinerChainStats, error) {
+	view, err := plumbing.PowerStateView(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load power state")
+	}
+	total, err := view.PowerNetworkTotal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var miners []MinerChainStats
+	for miner, count := range blocks {
+		ms := MinerChainStats{
+			Miner:      miner,
+			Blocks:     count,
+			BlockShare: float64(count) / float64(totalBlocks),
+		}
+		_, qa, err := view.MinerClaimedPower(ctx, miner)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load power for miner %s", miner)
+		}
+		if total.QualityAdjustedPower.GreaterThan(abi.NewStoragePower(0)) {
+			ms.PowerShare, _ = new(big.Rat).SetFrac(qa.Int, total.QualityAdjustedPower.Int).Float64()
+		}
+		miners = append(miners, ms)
+	}
+	sort.Slice(miners, func(i, j int) bool {
+		if miners[i].Blocks != miners[j].Blocks {
+			return miners[i].Blocks > miners[j].Blocks
+		}
+		return miners[i].Miner.String() < miners[j].Miner.String()
+	})
+	return miners, nil
+}
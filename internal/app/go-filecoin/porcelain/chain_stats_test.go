@@ -0,0 +1,122 @@
+package porcelain_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/state"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+type testChainStatsPlumbing struct {
+	builder *chain.Builder
+	head    block.TipSetKey
+	clock   clock.ChainEpochClock
+	view    *state.FakeStateView
+}
+
+func (p *testChainStatsPlumbing) ChainHeadKey() block.TipSetKey {
+	return p.head
+}
+
+func (p *testChainStatsPlumbing) ChainTipSet(key block.TipSetKey) (block.TipSet, error) {
+	return p.builder.GetTipSet(key)
+}
+
+func (p *testChainStatsPlumbing) ChainGetMessages(ctx context.Context, metaCid cid.Cid) ([]*types.UnsignedMessage, []*types.SignedMessage, error) {
+	secp, bls, err := p.builder.LoadMessages(ctx, metaCid)
+	return bls, secp, err
+}
+
+// ChainGetReceipts reports a single receipt using 7 gas for every tipset.
+func (p *testChainStatsPlumbing) ChainGetReceipts(_ context.Context, _ cid.Cid) ([]vm.MessageReceipt, error) {
+	return []vm.MessageReceipt{{GasUsed: 7}}, nil
+}
+
+func (p *testChainStatsPlumbing) ChainEpochStartTime(epoch abi.ChainEpoch) time.Time {
+	return p.clock.StartTimeOfEpoch(epoch)
+}
+
+func (p *testChainStatsPlumbing) PowerStateView(_ block.TipSetKey) (consensus.PowerStateView, error) {
+	return p.view, nil
+}
+
+func TestChainStats(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	miner, err := address.NewIDAddress(100)
+	require.NoError(t, err)
+	builder := chain.NewBuilder(t, miner)
+	mm := vm.NewMessageMaker(t, types.MustGenerateKeyInfo(1, 42))
+	msg := mm.NewSignedMessage(mm.Addresses()[0], 0)
+
+	// Epoch 1 has two blocks including the same message, 2 seconds late.
+	// Epoch 2 is a null round and epoch 3 has a single block, 5 seconds late.
+	genesis := builder.NewGenesis()
+	ts1 := builder.BuildOn(genesis, 2, func(bb *chain.BlockBuilder, i int) {
+		bb.AddMessages([]*types.SignedMessage{msg}, []*types.UnsignedMessage{})
+		bb.SetTimestamp(1012)
+	})
+	ts3 := builder.BuildOneOn(ts1, func(bb *chain.BlockBuilder) {
+		bb.IncHeight(1)
+		bb.AddMessages([]*types.SignedMessage{msg}, []*types.UnsignedMessage{})
+		bb.SetTimestamp(1035)
+	})
+
+	view := state.NewFakeStateView(abi.NewStoragePower(4), abi.NewStoragePower(4), 2, 2)
+	view.Miners[miner] = &state.FakeMinerState{
+		ClaimedRawPower: abi.NewStoragePower(1),
+		ClaimedQAPower:  abi.NewStoragePower(1),
+	}
+	plumbing := &testChainStatsPlumbing{
+		builder: builder,
+		head:    ts3.Key(),
+		clock:   clock.NewChainClockFromClock(1000, 10*time.Second, 0, clock.NewFake(time.Unix(1000, 0))),
+		view:    view,
+	}
+
+	stats, err := porcelain.ChainStatsRange(ctx, plumbing, 1, 10)
+	require.NoError(t, err)
+
+	assert.Equal(t, abi.ChainEpoch(1), stats.From)
+	assert.Equal(t, abi.ChainEpoch(3), stats.To)
+	assert.Equal(t, 1, stats.NullRounds)
+	assert.InDelta(t, 1.0/3, stats.NullRoundFrequency, 1e-9)
+	assert.Equal(t, map[int]int{1: 1, 2: 1}, stats.TipSetWidths)
+	assert.Equal(t, 2, stats.Messages)
+	assert.Equal(t, 5*time.Second, stats.MaxLateness)
+	assert.Equal(t, 3*time.Second, stats.MeanLateness)
+
+	require.Len(t, stats.Epochs, 3)
+	assert.False(t, stats.Epochs[0].Null)
+	assert.Equal(t, 2, stats.Epochs[0].Width)
+	assert.Equal(t, 1, stats.Epochs[0].Messages)
+	// Only tipsets with a child have known gas usage.
+	assert.EqualValues(t, 7, stats.Epochs[0].GasUsed)
+	assert.True(t, stats.Epochs[1].Null)
+	assert.EqualValues(t, 0, stats.Epochs[2].GasUsed)
+
+	require.Len(t, stats.Miners, 1)
+	assert.Equal(t, miner, stats.Miners[0].Miner)
+	assert.Equal(t, 3, stats.Miners[0].Blocks)
+	assert.InDelta(t, 1.0, stats.Miners[0].BlockShare, 1e-9)
+	assert.InDelta(t, 0.25, stats.Miners[0].PowerShare, 1e-9)
+
+	_, err = porcelain.ChainStatsRange(ctx, plumbing, 4, 2)
+	assert.Error(t, err)
+}
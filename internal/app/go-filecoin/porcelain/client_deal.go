@@ -0,0 +1,302 @@
+package porcelain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/state"
+)
+
+// minerPingTimeout bounds how long miners have to answer a ping to be
+// considered for a deal.
+const minerPingTimeout = 5 * time.Second
+
+// ClientDealStateView is the subset of the state view storage miners are
+// ranked for deals with.
+type ClientDealStateView interface {
+	MinerSectorConfiguration(ctx context.Context, maddr address.Address) (*state.MinerSectorConfiguration, error)
+	MinerClaimedPower(ctx context.Context, miner address.Address) (raw, qa abi.StoragePower, err error)
+	MinerFaults(ctx context.Context, maddr address.Address) ([]uint64, error)
+}
+
+// clientFindMinersPlumbing is the subset of the plumbing and storage APIs that
+// finds storage miners to make deals with.
+type clientFindMinersPlumbing interface {
+	ChainHeadKey() block.TipSetKey
+	ChainTipSet(key block.TipSetKey) (block.TipSet, error)
+	ClientDealStateView(baseKey block.TipSetKey) (ClientDealStateView, error)
+	NetworkPing(ctx context.Context, pid peer.ID) (<-chan ping.Result, error)
+	ListStorageProviders(ctx context.Context) ([]storagemarket.StorageProviderInfo, error)
+	GetStorageAsk(ctx context.Context, info storagemarket.StorageProviderInfo) (*storagemarket.StorageAsk, error)
+}
+
+// clientReplicatePlumbing is the subset of the storage API that proposes and
+// tracks storage deals.
+type clientReplicatePlumbing interface {
+	ProposeStorageDeal(
+		ctx context.Context,
+		addr address.Address,
+		info *storagemarket.StorageProviderInfo,
+		data *storagemarket.DataRef,
+		startEpoch abi.ChainEpoch,
+		endEpoch abi.ChainEpoch,
+		price abi.TokenAmount,
+		collateral abi.TokenAmount,
+		rt abi.RegisteredProof,
+	) (*storagemarket.ProposeStorageDealResult, error)
+	GetStorageDeal(ctx context.Context, c cid.Cid) (storagemarket.ClientDeal, error)
+}
+
+// StorageMinerOffer is a storage miner offering to store a piece, with what it
+// is ranked by.
+type StorageMinerOffer struct {
+	Info          storagemarket.StorageProviderInfo
+	SealProofType abi.RegisteredProof
+	// Price is the price per epoch of storing the piece.
+	Price abi.TokenAmount
+	// Power is the quality adjusted power the miner claims.
+	Power   abi.StoragePower
+	Faults  int
+	Latency time.Duration
+}
+
+// ClientFindMiners finds the storage miners whose asks accept a piece and who
+// answer pings, ranked from best to worst: by price, then by fewest faulty
+// sectors, then by most power and then by ping latency.
+func ClientFindMiners(ctx context.Context, plumbing clientFindMinersPlumbing, pieceSize abi.PaddedPieceSize) ([]StorageMinerOffer, error) {
+	head, err := plumbing.ChainTipSet(plumbing.ChainHeadKey())
+	if err != nil {
+		return nil, err
+	}
+	height, err := head.Height()
+	if err != nil {
+		return nil, err
+	}
+	view, err := plumbing.ClientDealStateView(head.Key())
+	if err != nil {
+		return nil, err
+	}
+	providers, err := plumbing.ListStorageProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var lk sync.Mutex
+	var wg sync.WaitGroup
+	offers := []StorageMinerOffer{}
+	for _, info := range providers {
+		if uint64(pieceSize) > info.SectorSize {
+			continue
+		}
+		wg.Add(1)
+		go func(info storagemarket.StorageProviderInfo) {
+			defer wg.Done()
+			offer, err := minerOffer(ctx, plumbing, view, info, pieceSize, height)
+			if err != nil {
+				// Miners that cannot be reached or do not accept the piece
+				// are left out.
+				return
+			}
+			lk.Lock()
+			offers = append(offers, *offer)
+			lk.Unlock()
+		}(info)
+	}
+	wg.Wait()
+
+	sort.Slice(offers, func(i, j int) bool {
+		a, b := offers[i], offers[j]
+		if !a.Price.Equals(b.Price) {
+			return a.Price.LessThan(b.Price)
+		}
+		if a.Faults != b.Faults {
+			return a.Faults < b.Faults
+		}
+		if !a.Power.Equals(b.Power) {
+			return a.Power.GreaterThan(b.Power)
+		}
+		return a.Latency < b.Latency
+	})
+	return offers, nil
+}
+
+// minerOffer queries the ask of a miner, pings it and looks up its power and
+// faults, failing if the miner does not offer to store the piece.
+func minerOffer(ctx context.Context, plumbing clientFindMinersPlumbing, view ClientDealStateView, info storagemarket.StorageProviderInfo, pieceSize abi.PaddedPieceSize, height abi.ChainEpoch) (*StorageMinerOffer, error) {
+	ask, err := plumbing.GetStorageAsk(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+	if ask.Expiry < height {
+		return nil, errors.New("ask expired")
+	}
+	if pieceSize < ask.MinPieceSize || (ask.MaxPieceSize > 0 && pieceSize > ask.MaxPieceSize) {
+		return nil, fmt.Errorf("ask accepts pieces of %d to %d bytes", ask.MinPieceSize, ask.MaxPieceSize)
+	}
+
+	latency, err := pingMiner(ctx, plumbing, info.PeerID)
+	if err != nil {
+		return nil, err
+	}
+
+	sectorCfg, err := view.MinerSectorConfiguration(ctx, info.Address)
+	if err != nil {
+		return nil, err
+	}
+	_, power, err := view.MinerClaimedPower(ctx, info.Address)
+	if err != nil {
+		return nil, err
+	}
+	faults, err := view.MinerFaults(ctx, info.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StorageMinerOffer{
+		Info:          info,
+		SealProofType: sectorCfg.SealProofType,
+		Price:         PiecePrice(ask.Price, pieceSize),
+		Power:         power,
+		Faults:        len(faults),
+		Latency:       latency,
+	}, nil
+}
+
+// PiecePrice is the price per epoch of storing a piece at an ask price per GiB
+// per epoch.
+func PiecePrice(askPrice abi.TokenAmount, pieceSize abi.PaddedPieceSize) abi.TokenAmount {
+	return big.Div(big.Mul(askPrice, big.NewIntUnsigned(uint64(pieceSize))), big.NewInt(1<<30))
+}
+
+func pingMiner(ctx context.Context, plumbing netPlumbing, pid peer.ID) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, minerPingTimeout)
+	defer cancel()
+
+	res, err := plumbing.NetworkPing(ctx, pid)
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case result, ok := <-res:
+		if !ok {
+			return 0, errors.New("ping channel closed")
+		}
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		return result.RTT, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// ReplicationParams describes the deals ClientReplicate makes.
+type ReplicationParams struct {
+	Client     address.Address
+	Data       *storagemarket.DataRef
+	StartEpoch abi.ChainEpoch
+	EndEpoch   abi.ChainEpoch
+	Replicas   int
+	// PollInterval is how often the states of the deals are checked.
+	PollInterval time.Duration
+}
+
+// DealReplica is the state of a deal made to replicate data.
+type DealReplica struct {
+	Miner       address.Address `json:"miner"`
+	ProposalCid cid.Cid         `json:"proposalCid"`
+	State       string          `json:"state"`
+	Message     string          `json:"message"`
+}
+
+// failedDealStates are the states of client deals that will not become active.
+var failedDealStates = map[storagemarket.StorageDealStatus]bool{
+	storagemarket.StorageDealProposalNotFound: true,
+	storagemarket.StorageDealProposalRejected: true,
+	storagemarket.StorageDealFailing:          true,
+	storagemarket.StorageDealNotFound:         true,
+	storagemarket.StorageDealError:            true,
+}
+
+// ClientReplicate proposes deals for data to the best ranked miners of offers
+// until the requested number of replicas are active, proposing to the next
+// miner in place of each miner the deal with fails. Every change in the state of
+// a deal is reported to progress. It returns the active deals, failing when
+// there are no miners left to propose to.
+func ClientReplicate(ctx context.Context, plumbing clientReplicatePlumbing, params ReplicationParams, offers []StorageMinerOffer, progress func(DealReplica)) ([]DealReplica, error) {
+	if params.Data.PieceCid == nil {
+		return nil, errors.New("replicated deals require a piece commitment")
+	}
+
+	var active []DealReplica
+	pending := map[cid.Cid]*DealReplica{}
+	propose := func() error {
+		for len(active)+len(pending) < params.Replicas {
+			if len(offers) == 0 {
+				return fmt.Errorf("%d of %d replicas are active or pending and no miners are left to propose deals to", len(active)+len(pending), params.Replicas)
+			}
+			offer := offers[0]
+			offers = offers[1:]
+
+			res, err := plumbing.ProposeStorageDeal(ctx, params.Client, &offer.Info, params.Data, params.StartEpoch, params.EndEpoch, offer.Price, big.Zero(), offer.SealProofType)
+			if err != nil {
+				progress(DealReplica{Miner: offer.Info.Address, State: "ProposalFailed", Message: err.Error()})
+				continue
+			}
+			replica := &DealReplica{Miner: offer.Info.Address, ProposalCid: res.ProposalCid, State: storagemarket.DealStates[storagemarket.StorageDealUnknown]}
+			pending[res.ProposalCid] = replica
+			progress(*replica)
+		}
+		return nil
+	}
+
+	ticker := time.NewTicker(params.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := propose(); err != nil && len(pending) == 0 {
+			return active, err
+		}
+		if len(active) == params.Replicas {
+			return active, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return active, ctx.Err()
+		}
+
+		for proposalCid, replica := range pending {
+			deal, err := plumbing.GetStorageDeal(ctx, proposalCid)
+			if err != nil {
+				return active, err
+			}
+			state := storagemarket.DealStates[deal.State]
+			if state == replica.State && deal.Message == replica.Message {
+				continue
+			}
+			replica.State, replica.Message = state, deal.Message
+			progress(*replica)
+
+			if deal.State == storagemarket.StorageDealActive {
+				active = append(active, *replica)
+				delete(pending, proposalCid)
+			} else if failedDealStates[deal.State] {
+				delete(pending, proposalCid)
+			}
+		}
+	}
+}
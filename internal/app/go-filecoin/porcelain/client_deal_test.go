@@ -0,0 +1,229 @@
+package porcelain_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/state"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+type fakeMiner struct {
+	info    storagemarket.StorageProviderInfo
+	ask     *storagemarket.StorageAsk
+	power   int64
+	faults  []uint64
+	latency time.Duration
+	// reachable is whether the miner answers pings.
+	reachable bool
+	// states are the states its deals pass through, one per poll.
+	states []storagemarket.StorageDealStatus
+}
+
+type clientDealPlumbing struct {
+	t      *testing.T
+	ts     block.TipSet
+	miners []*fakeMiner
+	deals  map[cid.Cid]*fakeMiner
+	polls  map[cid.Cid]int
+	cids   func() cid.Cid
+}
+
+func newClientDealPlumbing(t *testing.T, miners ...*fakeMiner) *clientDealPlumbing {
+	ts, err := block.NewTipSet(&block.Block{Height: 100})
+	require.NoError(t, err)
+	return &clientDealPlumbing{
+		t:      t,
+		ts:     ts,
+		miners: miners,
+		deals:  map[cid.Cid]*fakeMiner{},
+		polls:  map[cid.Cid]int{},
+		cids:   types.NewCidForTestGetter(),
+	}
+}
+
+func (p *clientDealPlumbing) miner(addr address.Address) *fakeMiner {
+	for _, m := range p.miners {
+		if m.info.Address == addr {
+			return m
+		}
+	}
+	p.t.Fatalf("unknown miner %s", addr)
+	return nil
+}
+
+func (p *clientDealPlumbing) ChainHeadKey() block.TipSetKey {
+	return p.ts.Key()
+}
+
+func (p *clientDealPlumbing) ChainTipSet(_ block.TipSetKey) (block.TipSet, error) {
+	return p.ts, nil
+}
+
+func (p *clientDealPlumbing) ClientDealStateView(_ block.TipSetKey) (ClientDealStateView, error) {
+	return p, nil
+}
+
+func (p *clientDealPlumbing) MinerSectorConfiguration(_ context.Context, maddr address.Address) (*state.MinerSectorConfiguration, error) {
+	return &state.MinerSectorConfiguration{SealProofType: abi.RegisteredProof_StackedDRG2KiBSeal}, nil
+}
+
+func (p *clientDealPlumbing) MinerClaimedPower(_ context.Context, maddr address.Address) (abi.StoragePower, abi.StoragePower, error) {
+	power := abi.NewStoragePower(p.miner(maddr).power)
+	return power, power, nil
+}
+
+func (p *clientDealPlumbing) MinerFaults(_ context.Context, maddr address.Address) ([]uint64, error) {
+	return p.miner(maddr).faults, nil
+}
+
+func (p *clientDealPlumbing) NetworkPing(_ context.Context, pid peer.ID) (<-chan ping.Result, error) {
+	for _, m := range p.miners {
+		if m.info.PeerID == pid && m.reachable {
+			res := make(chan ping.Result, 1)
+			res <- ping.Result{RTT: m.latency}
+			return res, nil
+		}
+	}
+	return nil, errors.New("unreachable")
+}
+
+func (p *clientDealPlumbing) ListStorageProviders(_ context.Context) ([]storagemarket.StorageProviderInfo, error) {
+	var infos []storagemarket.StorageProviderInfo
+	for _, m := range p.miners {
+		infos = append(infos, m.info)
+	}
+	return infos, nil
+}
+
+func (p *clientDealPlumbing) GetStorageAsk(_ context.Context, info storagemarket.StorageProviderInfo) (*storagemarket.StorageAsk, error) {
+	return p.miner(info.Address).ask, nil
+}
+
+func (p *clientDealPlumbing) ProposeStorageDeal(_ context.Context, _ address.Address, info *storagemarket.StorageProviderInfo, _ *storagemarket.DataRef, _, _ abi.ChainEpoch, _, _ abi.TokenAmount, _ abi.RegisteredProof) (*storagemarket.ProposeStorageDealResult, error) {
+	proposal := p.cids()
+	p.deals[proposal] = p.miner(info.Address)
+	return &storagemarket.ProposeStorageDealResult{ProposalCid: proposal}, nil
+}
+
+func (p *clientDealPlumbing) GetStorageDeal(_ context.Context, c cid.Cid) (storagemarket.ClientDeal, error) {
+	states := p.deals[c].states
+	i := p.polls[c]
+	if i >= len(states) {
+		i = len(states) - 1
+	}
+	p.polls[c]++
+	return storagemarket.ClientDeal{ProposalCid: c, State: states[i]}, nil
+}
+
+func newFakeMiner(t *testing.T, id int, price int64) *fakeMiner {
+	addr := vmaddr.RequireIDAddress(t, id)
+	return &fakeMiner{
+		info: storagemarket.StorageProviderInfo{
+			Address:    addr,
+			SectorSize: 2048,
+			PeerID:     peer.ID(addr.String()),
+		},
+		ask: &storagemarket.StorageAsk{
+			Price:        abi.NewTokenAmount(price),
+			MinPieceSize: 256,
+			MaxPieceSize: 2048,
+			Miner:        addr,
+			Expiry:       1000,
+		},
+		reachable: true,
+		states:    []storagemarket.StorageDealStatus{storagemarket.StorageDealProposalAccepted, storagemarket.StorageDealActive},
+	}
+}
+
+func TestClientFindMiners(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	cheap := newFakeMiner(t, 100, 1<<30)
+	faulty := newFakeMiner(t, 101, 2<<30)
+	faulty.faults = []uint64{1}
+	powerful := newFakeMiner(t, 102, 2<<30)
+	powerful.power = 10
+	slow := newFakeMiner(t, 103, 2<<30)
+	slow.latency = time.Second
+	unreachable := newFakeMiner(t, 104, 1)
+	unreachable.reachable = false
+	expired := newFakeMiner(t, 105, 1)
+	expired.ask.Expiry = 99
+	small := newFakeMiner(t, 106, 1)
+	small.ask.MaxPieceSize = 512
+	plumbing := newClientDealPlumbing(t, slow, faulty, unreachable, cheap, expired, powerful, small)
+
+	offers, err := ClientFindMiners(ctx, plumbing, 1024)
+	require.NoError(t, err)
+	var ranked []address.Address
+	for _, offer := range offers {
+		ranked = append(ranked, offer.Info.Address)
+	}
+	assert.Equal(t, []address.Address{cheap.info.Address, powerful.info.Address, slow.info.Address, faulty.info.Address}, ranked)
+	assert.Equal(t, abi.NewTokenAmount(1024), offers[0].Price)
+	assert.Equal(t, abi.RegisteredProof_StackedDRG2KiBSeal, offers[0].SealProofType)
+}
+
+func TestClientReplicate(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	rejecting := newFakeMiner(t, 100, 1)
+	rejecting.states = []storagemarket.StorageDealStatus{storagemarket.StorageDealProposalRejected}
+	rejecting.power = 10
+	first, second, third := newFakeMiner(t, 101, 1), newFakeMiner(t, 102, 1), newFakeMiner(t, 103, 1)
+	plumbing := newClientDealPlumbing(t, rejecting, first, second, third)
+
+	offers, err := ClientFindMiners(ctx, plumbing, 1024)
+	require.NoError(t, err)
+	require.Len(t, offers, 4)
+
+	pieceCid := types.CidFromString(t, "piece")
+	params := ReplicationParams{
+		Data:         &storagemarket.DataRef{Root: types.CidFromString(t, "data"), PieceCid: &pieceCid, PieceSize: 1016},
+		StartEpoch:   200,
+		EndEpoch:     1200,
+		Replicas:     2,
+		PollInterval: time.Millisecond,
+	}
+
+	t.Log("miners rejecting deals are replaced by the next miners")
+	var updates []DealReplica
+	active, err := ClientReplicate(ctx, plumbing, params, offers, func(r DealReplica) { updates = append(updates, r) })
+	require.NoError(t, err)
+	assert.Len(t, active, 2)
+	assert.Len(t, plumbing.deals, 3)
+	for _, replica := range active {
+		assert.NotEqual(t, rejecting.info.Address, replica.Miner)
+		assert.Equal(t, storagemarket.DealStates[storagemarket.StorageDealActive], replica.State)
+	}
+	rejected := false
+	for _, update := range updates {
+		if update.Miner == rejecting.info.Address && update.State == storagemarket.DealStates[storagemarket.StorageDealProposalRejected] {
+			rejected = true
+		}
+	}
+	assert.True(t, rejected)
+
+	t.Log("replication fails when too few miners accept deals")
+	params.Replicas = 4
+	_, err = ClientReplicate(ctx, plumbing, params, offers, func(DealReplica) {})
+	assert.Error(t, err)
+}
@@ -0,0 +1,150 @@
+package porcelain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/drand"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/net"
+)
+
+// Names of the checks making up a node's readiness.
+const (
+	HealthCheckSync  = "sync"
+	HealthCheckPeers = "peers"
+	HealthCheckDrand = "drand"
+)
+
+// drandHealthTimeout bounds the time spent fetching a drand entry when
+// checking that the drand network is reachable.
+const drandHealthTimeout = 5 * time.Second
+
+// NodeHealth reports whether a node is ready to serve, and why.
+type NodeHealth struct {
+	Ready  bool
+	Checks []HealthCheck
+}
+
+// HealthCheck is the outcome of a single readiness check.
+type HealthCheck struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+type nodeHealthPlumbing interface {
+	ConfigGet(dottedPath string) (interface{}, error)
+	ChainHeadKey() block.TipSetKey
+	ChainTipSet(key block.TipSetKey) (block.TipSet, error)
+	BlockTime() time.Duration
+	SyncerStatus() status.Status
+	NetworkPeers(ctx context.Context, verbose, latency, streams bool) (*net.SwarmConnInfos, error)
+	DrandReadEntry(ctx context.Context, round drand.Round) (*drand.Entry, error)
+	DrandStartTimeOfRound(round drand.Round) time.Time
+	DrandRoundsInInterval(startTime, endTime time.Time) []drand.Round
+}
+
+// CheckNodeHealth runs the node's readiness checks at time `now`. A node is
+// ready when its head is within the configured number of epochs both of the
+// chain it is syncing and of the wall clock, it has at least the bootstrap
+// minimum of peers, and the drand network serves recent entries.
+func CheckNodeHealth(ctx context.Context, plumbing nodeHealthPlumbing, now time.Time) (*NodeHealth, error) {
+	maxLag, err := plumbing.ConfigGet("api.readinessMaxEpochLag")
+	if err != nil {
+		return nil, err
+	}
+	maxLagEpochs, ok := maxLag.(uint64)
+	if !ok {
+		return nil, errors.New("failed to read readinessMaxEpochLag from config")
+	}
+	minPeers, err := plumbing.ConfigGet("bootstrap.minPeerThreshold")
+	if err != nil {
+		return nil, err
+	}
+	minPeerCount, ok := minPeers.(int)
+	if !ok {
+		return nil, errors.New("failed to read minPeerThreshold from config")
+	}
+
+	checks := []HealthCheck{
+		checkSync(plumbing, abi.ChainEpoch(maxLagEpochs), now),
+		checkPeers(ctx, plumbing, minPeerCount),
+		checkDrand(ctx, plumbing, now),
+	}
+	health := &NodeHealth{Ready: true, Checks: checks}
+	for _, c := range checks {
+		health.Ready = health.Ready && c.OK
+	}
+	return health, nil
+}
+
+// checkSync checks that the head is close to the chain being synced, and that
+// it is recent, so that a node syncing a stalled chain or no chain at all is
+// not ready.
+func checkSync(plumbing nodeHealthPlumbing, maxLag abi.ChainEpoch, now time.Time) HealthCheck {
+	check := HealthCheck{Name: HealthCheckSync}
+	head, err := plumbing.ChainTipSet(plumbing.ChainHeadKey())
+	if err != nil {
+		check.Detail = fmt.Sprintf("failed to load head: %s", err)
+		return check
+	}
+	headHeight, err := head.Height()
+	if err != nil {
+		check.Detail = fmt.Sprintf("failed to read head height: %s", err)
+		return check
+	}
+
+	lag := abi.ChainEpoch(0)
+	if st := plumbing.SyncerStatus(); st.SyncingHeight > headHeight {
+		lag = st.SyncingHeight - headHeight
+	}
+	// The head may be one epoch old at any time, and older still by the
+	// allowed lag.
+	age := now.Sub(time.Unix(int64(head.At(0).Timestamp), 0))
+	maxAge := time.Duration(maxLag+1) * plumbing.BlockTime()
+	check.OK = lag <= maxLag && age <= maxAge
+	check.Detail = fmt.Sprintf("head at height %d, %d epochs behind the syncing chain (max %d), %s old (max %s)",
+		headHeight, lag, maxLag, age.Truncate(time.Second), maxAge)
+	return check
+}
+
+func checkPeers(ctx context.Context, plumbing nodeHealthPlumbing, minPeers int) HealthCheck {
+	check := HealthCheck{Name: HealthCheckPeers}
+	peers, err := plumbing.NetworkPeers(ctx, false, false, false)
+	if err != nil {
+		check.Detail = fmt.Sprintf("failed to list peers: %s", err)
+		return check
+	}
+	check.OK = len(peers.Peers) >= minPeers
+	check.Detail = fmt.Sprintf("%d peers connected (min %d)", len(peers.Peers), minPeers)
+	return check
+}
+
+// checkDrand fetches the entry for the last round that completed before the
+// current one started, which every drand node should be able to serve.
+func checkDrand(ctx context.Context, plumbing nodeHealthPlumbing, now time.Time) HealthCheck {
+	check := HealthCheck{Name: HealthCheckDrand}
+	roundDuration := plumbing.DrandStartTimeOfRound(1).Sub(plumbing.DrandStartTimeOfRound(0))
+	rounds := plumbing.DrandRoundsInInterval(now.Add(-2*roundDuration), now.Add(-roundDuration))
+	if len(rounds) == 0 {
+		check.Detail = "no drand round has completed yet"
+		return check
+	}
+	round := rounds[len(rounds)-1]
+
+	ctx, cancel := context.WithTimeout(ctx, drandHealthTimeout)
+	defer cancel()
+	if _, err := plumbing.DrandReadEntry(ctx, round); err != nil {
+		check.Detail = fmt.Sprintf("failed to fetch round %d: %s", round, err)
+		return check
+	}
+	check.OK = true
+	check.Detail = fmt.Sprintf("fetched round %d", round)
+	return check
+}
@@ -0,0 +1,151 @@
+package porcelain_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/drand"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/net"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+type testNodeHealthPlumbing struct {
+	builder  *chain.Builder
+	head     block.TipSetKey
+	status   status.Status
+	peers    int
+	minPeers int
+	drand    *drand.Fake
+	drandErr error
+}
+
+func (p *testNodeHealthPlumbing) ConfigGet(dottedPath string) (interface{}, error) {
+	switch dottedPath {
+	case "api.readinessMaxEpochLag":
+		return uint64(5), nil
+	case "bootstrap.minPeerThreshold":
+		return p.minPeers, nil
+	}
+	return nil, errors.New("unexpected config path " + dottedPath)
+}
+
+func (p *testNodeHealthPlumbing) ChainHeadKey() block.TipSetKey {
+	return p.head
+}
+
+func (p *testNodeHealthPlumbing) ChainTipSet(key block.TipSetKey) (block.TipSet, error) {
+	return p.builder.GetTipSet(key)
+}
+
+func (p *testNodeHealthPlumbing) BlockTime() time.Duration {
+	return 30 * time.Second
+}
+
+func (p *testNodeHealthPlumbing) SyncerStatus() status.Status {
+	return p.status
+}
+
+func (p *testNodeHealthPlumbing) NetworkPeers(_ context.Context, _, _, _ bool) (*net.SwarmConnInfos, error) {
+	return &net.SwarmConnInfos{Peers: make([]net.SwarmConnInfo, p.peers)}, nil
+}
+
+func (p *testNodeHealthPlumbing) DrandReadEntry(ctx context.Context, round drand.Round) (*drand.Entry, error) {
+	if p.drandErr != nil {
+		return nil, p.drandErr
+	}
+	return p.drand.ReadEntry(ctx, round)
+}
+
+func (p *testNodeHealthPlumbing) DrandStartTimeOfRound(round drand.Round) time.Time {
+	return p.drand.StartTimeOfRound(round)
+}
+
+func (p *testNodeHealthPlumbing) DrandRoundsInInterval(startTime, endTime time.Time) []drand.Round {
+	return p.drand.RoundsInInterval(startTime, endTime)
+}
+
+func TestCheckNodeHealth(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	miner, err := address.NewIDAddress(100)
+	require.NoError(t, err)
+	genesisTime := time.Unix(1234567890, 0)
+	now := genesisTime.Add(time.Hour)
+	builder := chain.NewBuilder(t, miner)
+	head := builder.BuildOneOn(builder.AppendManyOn(9, builder.NewGenesis()), func(b *chain.BlockBuilder) {
+		b.SetTimestamp(uint64(now.Add(-time.Minute).Unix()))
+	})
+	healthy := func() *testNodeHealthPlumbing {
+		return &testNodeHealthPlumbing{
+			builder:  builder,
+			head:     head.Key(),
+			status:   status.Status{SyncingHeight: 12},
+			peers:    3,
+			minPeers: 2,
+			drand:    drand.NewFake(genesisTime),
+		}
+	}
+	failing := func(health *porcelain.NodeHealth) []string {
+		var names []string
+		for _, c := range health.Checks {
+			if !c.OK {
+				names = append(names, c.Name)
+			}
+		}
+		return names
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		health, err := porcelain.CheckNodeHealth(ctx, healthy(), now)
+		require.NoError(t, err)
+		assert.True(t, health.Ready)
+		assert.Len(t, health.Checks, 3)
+		assert.Empty(t, failing(health))
+	})
+
+	t.Run("behind the syncing chain", func(t *testing.T) {
+		plumbing := healthy()
+		plumbing.status.SyncingHeight = abi.ChainEpoch(20)
+		health, err := porcelain.CheckNodeHealth(ctx, plumbing, now)
+		require.NoError(t, err)
+		assert.False(t, health.Ready)
+		assert.Equal(t, []string{porcelain.HealthCheckSync}, failing(health))
+	})
+
+	t.Run("head older than the wall clock allows", func(t *testing.T) {
+		health, err := porcelain.CheckNodeHealth(ctx, healthy(), now.Add(time.Hour))
+		require.NoError(t, err)
+		assert.False(t, health.Ready)
+		assert.Contains(t, failing(health), porcelain.HealthCheckSync)
+	})
+
+	t.Run("too few peers", func(t *testing.T) {
+		plumbing := healthy()
+		plumbing.peers = 1
+		health, err := porcelain.CheckNodeHealth(ctx, plumbing, now)
+		require.NoError(t, err)
+		assert.False(t, health.Ready)
+		assert.Equal(t, []string{porcelain.HealthCheckPeers}, failing(health))
+	})
+
+	t.Run("drand unreachable", func(t *testing.T) {
+		plumbing := healthy()
+		plumbing.drandErr = errors.New("connection refused")
+		health, err := porcelain.CheckNodeHealth(ctx, plumbing, now)
+		require.NoError(t, err)
+		assert.False(t, health.Ready)
+		assert.Equal(t, []string{porcelain.HealthCheckDrand}, failing(health))
+	})
+}
@@ -2,15 +2,24 @@ package porcelain
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
 
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
 	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/util/moresync"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
 )
 
 type waitPlumbing interface {
@@ -32,3 +41,194 @@ func MessageWaitDone(ctx context.Context, plumbing waitPlumbing, msgCid cid.Cid)
 	l.Wait()
 	return ret, nil
 }
+
+// DefaultGasEstimateLookback is the number of tipsets searched for messages to estimate gas from.
+const DefaultGasEstimateLookback = 100
+
+// gasEstimateMargin is the percentage added to the largest recent gas usage to estimate a gas limit.
+const gasEstimateMargin = 25
+
+type gasEstimatePlumbing interface {
+	ActorGetAt(ctx context.Context, key block.TipSetKey, addr address.Address) (*actor.Actor, error)
+	ChainHeadKey() block.TipSetKey
+	ChainTipSet(key block.TipSetKey) (block.TipSet, error)
+	ChainGetMessages(ctx context.Context, metaCid cid.Cid) ([]*types.UnsignedMessage, []*types.SignedMessage, error)
+	ChainGetReceipts(ctx context.Context, id cid.Cid) ([]vm.MessageReceipt, error)
+}
+
+// MessageEstimateGas estimates the gas limit for invoking a method on an actor from the gas used
+// by successful messages invoking the same method on actors of the same type in the last
+// `lookback` executed tipsets. It returns the largest such usage plus a margin.
+func MessageEstimateGas(ctx context.Context, plumbing gasEstimatePlumbing, to address.Address, method abi.MethodNum, lookback int) (gas.Unit, error) {
+	head := plumbing.ChainHeadKey()
+	target, err := plumbing.ActorGetAt(ctx, head, to)
+	if err != nil {
+		return gas.Zero, errors.Wrapf(err, "no actor at address %s", to)
+	}
+
+	codes := map[address.Address]cid.Cid{}
+	codeOf := func(addr address.Address) (cid.Cid, error) {
+		if c, ok := codes[addr]; ok {
+			return c, nil
+		}
+		act, err := plumbing.ActorGetAt(ctx, head, addr)
+		if err == types.ErrNotFound {
+			codes[addr] = cid.Undef
+			return cid.Undef, nil
+		}
+		if err != nil {
+			return cid.Undef, err
+		}
+		codes[addr] = act.Code.Cid
+		return act.Code.Cid, nil
+	}
+
+	// Receipts of a tipset's messages are referenced by its child, so walk
+	// the chain from the head remembering the child of each tipset.
+	var maxUsed gas.Unit
+	found := false
+	child, err := plumbing.ChainTipSet(head)
+	if err != nil {
+		return gas.Zero, err
+	}
+	for i := 0; i < lookback; i++ {
+		parentKey, err := child.Parents()
+		if err != nil {
+			return gas.Zero, err
+		}
+		if parentKey.Empty() {
+			break
+		}
+		ts, err := plumbing.ChainTipSet(parentKey)
+		if err != nil {
+			return gas.Zero, err
+		}
+		msgs, err := executionOrder(ctx, plumbing, ts)
+		if err != nil {
+			return gas.Zero, err
+		}
+		receipts, err := plumbing.ChainGetReceipts(ctx, child.At(0).MessageReceipts.Cid)
+		if err != nil {
+			return gas.Zero, err
+		}
+		if len(receipts) != len(msgs) {
+			return gas.Zero, fmt.Errorf("tipset %s has %d messages but %d receipts", ts.Key(), len(msgs), len(receipts))
+		}
+		for j, m := range msgs {
+			if m.Method != method || receipts[j].ExitCode != exitcode.Ok {
+				continue
+			}
+			code, err := codeOf(m.To)
+			if err != nil {
+				return gas.Zero, err
+			}
+			if !code.Equals(target.Code.Cid) {
+				continue
+			}
+			found = true
+			if receipts[j].GasUsed > maxUsed {
+				maxUsed = receipts[j].GasUsed
+			}
+		}
+		child = ts
+	}
+	if !found {
+		return gas.Zero, fmt.Errorf("no message invoking method %d on a %s actor in the last %d tipsets to estimate gas from", method, builtin.ActorNameByCode(target.Code.Cid), lookback)
+	}
+	return maxUsed + maxUsed*gasEstimateMargin/100, nil
+}
+
+// executionOrder lists the distinct messages of a tipset in the order the VM applies them: block
+// by block, the BLS messages then the secp ones, skipping messages already seen.
+func executionOrder(ctx context.Context, plumbing gasEstimatePlumbing, ts block.TipSet) ([]*types.UnsignedMessage, error) {
+	var msgs []*types.UnsignedMessage
+	seen := map[cid.Cid]struct{}{}
+	add := func(m *types.UnsignedMessage) error {
+		c, err := m.Cid()
+		if err != nil {
+			return err
+		}
+		if _, ok := seen[c]; ok {
+			return nil
+		}
+		seen[c] = struct{}{}
+		msgs = append(msgs, m)
+		return nil
+	}
+	for i := 0; i < ts.Len(); i++ {
+		bls, secp, err := plumbing.ChainGetMessages(ctx, ts.At(i).Messages.Cid)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range bls {
+			if err := add(m); err != nil {
+				return nil, err
+			}
+		}
+		for _, sm := range secp {
+			if err := add(&sm.Message); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return msgs, nil
+}
+
+type messageCreatePlumbing interface {
+	gasEstimatePlumbing
+	MessageNextNonce(ctx context.Context, from address.Address) (uint64, error)
+}
+
+// MessageCreate builds an unsigned message for signing elsewhere, taking the nonce from the
+// sender's actor and queued messages. A zero gas limit is replaced by an estimate.
+func MessageCreate(
+	ctx context.Context,
+	plumbing messageCreatePlumbing,
+	from, to address.Address,
+	value types.AttoFIL,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+	method abi.MethodNum,
+	params []byte,
+) (*types.UnsignedMessage, error) {
+	nonce, err := plumbing.MessageNextNonce(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	if gasLimit == gas.Zero {
+		gasLimit, err = MessageEstimateGas(ctx, plumbing, to, method, DefaultGasEstimateLookback)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to estimate gas, set a gas limit")
+		}
+	}
+	if params == nil {
+		params = []byte{}
+	}
+	return types.NewMeteredMessage(from, to, nonce, value, method, params, gasPrice, gasLimit), nil
+}
+
+type signedMessageCheckPlumbing interface {
+	ActorGet(ctx context.Context, addr address.Address) (*actor.Actor, error)
+	SignedMessageValidate(ctx context.Context, smsg *types.SignedMessage) error
+}
+
+// SignedMessageCheck validates a signed message before it is sent: its syntax and signature, and
+// that at the head its nonce is not already used and its sender can pay its value and gas.
+func SignedMessageCheck(ctx context.Context, plumbing signedMessageCheckPlumbing, smsg *types.SignedMessage) error {
+	if err := plumbing.SignedMessageValidate(ctx, smsg); err != nil {
+		return err
+	}
+	msg := smsg.Message
+	from, err := plumbing.ActorGet(ctx, msg.From)
+	if err != nil {
+		return errors.Wrapf(err, "no actor at sender address %s", msg.From)
+	}
+	if msg.CallSeqNum < from.CallSeqNum {
+		return fmt.Errorf("nonce %d is lower than the sender's next nonce %d", msg.CallSeqNum, from.CallSeqNum)
+	}
+	cost := big.Add(msg.Value, big.Mul(msg.GasPrice, big.NewInt(int64(msg.GasLimit))))
+	if from.Balance.LessThan(cost) {
+		return fmt.Errorf("sender balance %s cannot cover the value and maximum gas cost %s", from.Balance, cost)
+	}
+	return nil
+}
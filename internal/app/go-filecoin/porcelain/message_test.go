@@ -0,0 +1,104 @@
+package porcelain_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+)
+
+type messageCheckPlumbing struct {
+	actor      *actor.Actor
+	invalidErr error
+	nonce      uint64
+}
+
+func (p *messageCheckPlumbing) ActorGet(_ context.Context, _ address.Address) (*actor.Actor, error) {
+	return p.actor, nil
+}
+
+func (p *messageCheckPlumbing) ActorGetAt(_ context.Context, _ block.TipSetKey, _ address.Address) (*actor.Actor, error) {
+	return p.actor, nil
+}
+
+func (p *messageCheckPlumbing) SignedMessageValidate(_ context.Context, _ *types.SignedMessage) error {
+	return p.invalidErr
+}
+
+func (p *messageCheckPlumbing) MessageNextNonce(_ context.Context, _ address.Address) (uint64, error) {
+	return p.nonce, nil
+}
+
+func (p *messageCheckPlumbing) ChainHeadKey() block.TipSetKey {
+	return block.NewTipSetKey()
+}
+
+func (p *messageCheckPlumbing) ChainTipSet(_ block.TipSetKey) (block.TipSet, error) {
+	return block.UndefTipSet, errors.New("no chain")
+}
+
+func (p *messageCheckPlumbing) ChainGetMessages(_ context.Context, _ cid.Cid) ([]*types.UnsignedMessage, []*types.SignedMessage, error) {
+	return nil, nil, nil
+}
+
+func (p *messageCheckPlumbing) ChainGetReceipts(_ context.Context, _ cid.Cid) ([]vm.MessageReceipt, error) {
+	return nil, nil
+}
+
+func TestMessageCreate(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	from := vmaddr.RequireIDAddress(t, 100)
+	to := vmaddr.RequireIDAddress(t, 101)
+	plumbing := &messageCheckPlumbing{actor: &actor.Actor{}, nonce: 7}
+
+	msg, err := MessageCreate(ctx, plumbing, from, to, types.NewAttoFILFromFIL(1), types.NewGasPrice(1), gas.NewGas(1000), builtin.MethodSend, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), msg.CallSeqNum)
+	assert.Equal(t, gas.NewGas(1000), msg.GasLimit)
+	assert.Equal(t, from, msg.From)
+	assert.Equal(t, to, msg.To)
+
+	// Without a gas limit or chain history to estimate it from, creation fails.
+	_, err = MessageCreate(ctx, plumbing, from, to, types.NewAttoFILFromFIL(1), types.NewGasPrice(1), gas.Zero, builtin.MethodSend, nil)
+	assert.Error(t, err)
+}
+
+func TestSignedMessageCheck(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	from := vmaddr.RequireIDAddress(t, 100)
+	to := vmaddr.RequireIDAddress(t, 101)
+
+	signed := func(nonce uint64, value int64) *types.SignedMessage {
+		msg := types.NewMeteredMessage(from, to, nonce, abi.NewTokenAmount(value), builtin.MethodSend, []byte{}, types.NewGasPrice(1), gas.NewGas(100))
+		return &types.SignedMessage{Message: *msg}
+	}
+	plumbing := &messageCheckPlumbing{actor: &actor.Actor{CallSeqNum: 5, Balance: abi.NewTokenAmount(1000)}}
+
+	assert.NoError(t, SignedMessageCheck(ctx, plumbing, signed(5, 900)))
+	assert.NoError(t, SignedMessageCheck(ctx, plumbing, signed(6, 0)))
+
+	// Nonce already used.
+	assert.Error(t, SignedMessageCheck(ctx, plumbing, signed(4, 0)))
+	// Value plus maximum gas cost exceeds the balance.
+	assert.Error(t, SignedMessageCheck(ctx, plumbing, signed(5, 901)))
+
+	plumbing.invalidErr = errors.New("bad signature")
+	assert.Error(t, SignedMessageCheck(ctx, plumbing, signed(5, 0)))
+}
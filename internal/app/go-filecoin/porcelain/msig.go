@@ -0,0 +1,238 @@
+package porcelain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	initActor "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/builtin/multisig"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/state"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+)
+
+// MultisigStateView is the subset of the state view the multisig porcelain reads.
+type MultisigStateView interface {
+	MultisigState(ctx context.Context, msigAddr address.Address) (*multisig.State, error)
+	MultisigPendingTransactions(ctx context.Context, msigAddr address.Address) ([]state.MultisigTransaction, error)
+}
+
+// msigSendPlumbing is the subset of the plumbing.API that sends messages to multisig actors.
+type msigSendPlumbing interface {
+	MessageSend(ctx context.Context, from, to address.Address, value types.AttoFIL, gasPrice types.AttoFIL, gasLimit gas.Unit, method abi.MethodNum, params interface{}) (cid.Cid, chan error, error)
+	MessageWait(ctx context.Context, msgCid cid.Cid, lookback uint64, cb func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error) error
+}
+
+// MultisigCtorExecParamsFor constructs parameters to send a message to the InitActor
+// to construct a multisig actor.
+func MultisigCtorExecParamsFor(signers []address.Address, threshold int64, unlockDuration abi.ChainEpoch) (initActor.ExecParams, error) {
+	ctorParams := multisig.ConstructorParams{
+		Signers:               signers,
+		NumApprovalsThreshold: threshold,
+		UnlockDuration:        unlockDuration,
+	}
+	marshaled, err := encoding.Encode(&ctorParams)
+	if err != nil {
+		return initActor.ExecParams{}, err
+	}
+
+	return initActor.ExecParams{
+		CodeCID:           builtin.MultisigActorCodeID,
+		ConstructorParams: marshaled,
+	}, nil
+}
+
+// MultisigCreate creates a multisig actor holding `value`, which vests linearly over unlockDuration
+// epochs, and waits for it to appear on chain. It returns the robust address of the new actor.
+func MultisigCreate(
+	ctx context.Context,
+	plumbing msigSendPlumbing,
+	from address.Address,
+	signers []address.Address,
+	threshold int64,
+	unlockDuration abi.ChainEpoch,
+	value types.AttoFIL,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+) (address.Address, error) {
+	if len(signers) == 0 {
+		return address.Undef, errors.New("a multisig needs at least one signer")
+	}
+	if threshold < 1 || threshold > int64(len(signers)) {
+		return address.Undef, fmt.Errorf("threshold %d must be between 1 and the number of signers, %d", threshold, len(signers))
+	}
+
+	execParams, err := MultisigCtorExecParamsFor(signers, threshold, unlockDuration)
+	if err != nil {
+		return address.Undef, err
+	}
+	var result initActor.ExecReturn
+	err = sendAndWait(ctx, plumbing, from, builtin.InitActorAddr, value, gasPrice, gasLimit, builtin.MethodsInit.Exec, &execParams, &result)
+	if err != nil {
+		return address.Undef, err
+	}
+	return result.RobustAddress, nil
+}
+
+// MultisigPropose proposes a transaction to a multisig actor, waits for the proposal to appear on
+// chain and returns the ID of the transaction. The transaction is executed at once if the
+// proposer alone meets the approval threshold. Params are the encoded parameters of the method.
+func MultisigPropose(
+	ctx context.Context,
+	plumbing msigSendPlumbing,
+	from address.Address,
+	msigAddr address.Address,
+	to address.Address,
+	value types.AttoFIL,
+	method abi.MethodNum,
+	params []byte,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+) (int64, error) {
+	proposal := multisig.ProposeParams{
+		To:     to,
+		Value:  value,
+		Method: method,
+		Params: params,
+	}
+	var txnID cbg.CborInt
+	err := sendAndWait(ctx, plumbing, from, msigAddr, types.ZeroAttoFIL, gasPrice, gasLimit, builtin.MethodsMultisig.Propose, &proposal, &txnID)
+	if err != nil {
+		return 0, err
+	}
+	return int64(txnID), nil
+}
+
+// MultisigApprove approves a pending transaction of a multisig actor and waits for the approval
+// to appear on chain. The transaction is executed once it gathers enough approvals.
+func MultisigApprove(ctx context.Context, plumbing msigSendPlumbing, from, msigAddr address.Address, txnID int64, gasPrice types.AttoFIL, gasLimit gas.Unit) error {
+	params := multisig.TxnIDParams{ID: multisig.TxnID(txnID)}
+	return sendAndWait(ctx, plumbing, from, msigAddr, types.ZeroAttoFIL, gasPrice, gasLimit, builtin.MethodsMultisig.Approve, &params, nil)
+}
+
+// MultisigCancel cancels a pending transaction of a multisig actor, which only its proposer may
+// do, and waits for the cancellation to appear on chain.
+func MultisigCancel(ctx context.Context, plumbing msigSendPlumbing, from, msigAddr address.Address, txnID int64, gasPrice types.AttoFIL, gasLimit gas.Unit) error {
+	params := multisig.TxnIDParams{ID: multisig.TxnID(txnID)}
+	return sendAndWait(ctx, plumbing, from, msigAddr, types.ZeroAttoFIL, gasPrice, gasLimit, builtin.MethodsMultisig.Cancel, &params, nil)
+}
+
+// sendAndWait sends a message, waits for it to appear on chain and decodes its return value into
+// ret unless ret is nil.
+func sendAndWait(
+	ctx context.Context,
+	plumbing msigSendPlumbing,
+	from, to address.Address,
+	value types.AttoFIL,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+	method abi.MethodNum,
+	params interface{},
+	ret interface{},
+) error {
+	msgCid, _, err := plumbing.MessageSend(ctx, from, to, value, gasPrice, gasLimit, method, params)
+	if err != nil {
+		return err
+	}
+	return plumbing.MessageWait(ctx, msgCid, msg.DefaultMessageWaitLookback, func(_ *block.Block, _ *types.SignedMessage, receipt *vm.MessageReceipt) error {
+		if receipt.ExitCode != exitcode.Ok {
+			return fmt.Errorf("message %s failed (exitcode: %d)", msgCid, receipt.ExitCode)
+		}
+		if ret == nil {
+			return nil
+		}
+		return encoding.Decode(receipt.ReturnValue, ret)
+	})
+}
+
+// MultisigVesting describes the linear vesting of a multisig actor's initial balance. Locked
+// funds may not be spent by any transaction.
+type MultisigVesting struct {
+	InitialBalance abi.TokenAmount
+	StartEpoch     abi.ChainEpoch
+	UnlockDuration abi.ChainEpoch
+	Locked         abi.TokenAmount
+}
+
+// MultisigInfo describes a multisig actor and its pending transactions.
+type MultisigInfo struct {
+	Address   address.Address
+	Balance   abi.TokenAmount
+	Spendable abi.TokenAmount
+	Signers   []address.Address
+	Threshold int64
+	NextTxnID int64
+	Vesting   MultisigVesting
+	Pending   []state.MultisigTransaction
+}
+
+// msigInspectPlumbing is the subset of the plumbing.API that MultisigInspect uses.
+type msigInspectPlumbing interface {
+	ActorGetAt(ctx context.Context, key block.TipSetKey, addr address.Address) (*actor.Actor, error)
+	ChainTipSet(key block.TipSetKey) (block.TipSet, error)
+	MultisigStateView(baseKey block.TipSetKey) (MultisigStateView, error)
+}
+
+// MultisigInspect reads the signers, vesting schedule and pending transactions of a multisig
+// actor at a tipset.
+func MultisigInspect(ctx context.Context, plumbing msigInspectPlumbing, msigAddr address.Address, key block.TipSetKey) (*MultisigInfo, error) {
+	ts, err := plumbing.ChainTipSet(key)
+	if err != nil {
+		return nil, err
+	}
+	height, err := ts.Height()
+	if err != nil {
+		return nil, err
+	}
+	view, err := plumbing.MultisigStateView(key)
+	if err != nil {
+		return nil, err
+	}
+	msigState, err := view.MultisigState(ctx, msigAddr)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := view.MultisigPendingTransactions(ctx, msigAddr)
+	if err != nil {
+		return nil, err
+	}
+	act, err := plumbing.ActorGetAt(ctx, key, msigAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	locked := msigState.AmountLocked(height - msigState.StartEpoch)
+	spendable := big.Sub(act.Balance, locked)
+	if spendable.LessThan(big.Zero()) {
+		spendable = big.Zero()
+	}
+	return &MultisigInfo{
+		Address:   msigAddr,
+		Balance:   act.Balance,
+		Spendable: spendable,
+		Signers:   msigState.Signers,
+		Threshold: msigState.NumApprovalsThreshold,
+		NextTxnID: int64(msigState.NextTxnID),
+		Vesting: MultisigVesting{
+			InitialBalance: msigState.InitialBalance,
+			StartEpoch:     msigState.StartEpoch,
+			UnlockDuration: msigState.UnlockDuration,
+			Locked:         locked,
+		},
+		Pending: pending,
+	}, nil
+}
@@ -0,0 +1,148 @@
+package porcelain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	initActor "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/builtin/multisig"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/state"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+)
+
+type msigSendPlumbing struct {
+	t        *testing.T
+	to       address.Address
+	method   abi.MethodNum
+	params   interface{}
+	exitCode exitcode.ExitCode
+	ret      []byte
+}
+
+func (p *msigSendPlumbing) MessageSend(_ context.Context, _, to address.Address, _ types.AttoFIL, _ types.AttoFIL, _ gas.Unit, method abi.MethodNum, params interface{}) (cid.Cid, chan error, error) {
+	p.to, p.method, p.params = to, method, params
+	return types.CidFromString(p.t, "msig"), nil, nil
+}
+
+func (p *msigSendPlumbing) MessageWait(_ context.Context, _ cid.Cid, _ uint64, cb func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error) error {
+	return cb(nil, nil, &vm.MessageReceipt{ExitCode: p.exitCode, ReturnValue: p.ret})
+}
+
+func TestMultisigCreate(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	signers := []address.Address{vmaddr.RequireIDAddress(t, 100), vmaddr.RequireIDAddress(t, 101)}
+	msigAddr := vmaddr.NewForTestGetter()()
+
+	ret, err := encoding.Encode(&initActor.ExecReturn{IDAddress: vmaddr.RequireIDAddress(t, 102), RobustAddress: msigAddr})
+	require.NoError(t, err)
+	plumbing := &msigSendPlumbing{t: t, ret: ret}
+
+	addr, err := MultisigCreate(ctx, plumbing, signers[0], signers, 2, 10, types.NewAttoFILFromFIL(5), types.NewGasPrice(1), gas.NewGas(100))
+	require.NoError(t, err)
+	assert.Equal(t, msigAddr, addr)
+	assert.Equal(t, builtin.InitActorAddr, plumbing.to)
+	assert.Equal(t, builtin.MethodsInit.Exec, plumbing.method)
+
+	execParams := plumbing.params.(*initActor.ExecParams)
+	assert.Equal(t, builtin.MultisigActorCodeID, execParams.CodeCID)
+	var ctorParams multisig.ConstructorParams
+	require.NoError(t, encoding.Decode(execParams.ConstructorParams, &ctorParams))
+	assert.Equal(t, signers, ctorParams.Signers)
+	assert.Equal(t, int64(2), ctorParams.NumApprovalsThreshold)
+	assert.Equal(t, abi.ChainEpoch(10), ctorParams.UnlockDuration)
+
+	_, err = MultisigCreate(ctx, plumbing, signers[0], signers, 3, 0, types.ZeroAttoFIL, types.NewGasPrice(1), gas.NewGas(100))
+	assert.Error(t, err)
+
+	plumbing.exitCode = exitcode.ErrForbidden
+	err = MultisigApprove(ctx, plumbing, signers[1], msigAddr, 4, types.NewGasPrice(1), gas.NewGas(100))
+	assert.Error(t, err)
+	assert.Equal(t, builtin.MethodsMultisig.Approve, plumbing.method)
+	assert.Equal(t, multisig.TxnID(4), plumbing.params.(*multisig.TxnIDParams).ID)
+}
+
+type msigInspectPlumbing struct {
+	ts      block.TipSet
+	balance abi.TokenAmount
+	state   *multisig.State
+	pending []state.MultisigTransaction
+}
+
+func (p *msigInspectPlumbing) ActorGetAt(_ context.Context, _ block.TipSetKey, _ address.Address) (*actor.Actor, error) {
+	return &actor.Actor{Balance: p.balance}, nil
+}
+
+func (p *msigInspectPlumbing) ChainTipSet(_ block.TipSetKey) (block.TipSet, error) {
+	return p.ts, nil
+}
+
+func (p *msigInspectPlumbing) MultisigStateView(_ block.TipSetKey) (MultisigStateView, error) {
+	return p, nil
+}
+
+func (p *msigInspectPlumbing) MultisigState(_ context.Context, _ address.Address) (*multisig.State, error) {
+	return p.state, nil
+}
+
+func (p *msigInspectPlumbing) MultisigPendingTransactions(_ context.Context, _ address.Address) ([]state.MultisigTransaction, error) {
+	return p.pending, nil
+}
+
+func TestMultisigInspect(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	msigAddr := vmaddr.RequireIDAddress(t, 200)
+
+	inspectAt := func(height abi.ChainEpoch) *MultisigInfo {
+		ts, err := block.NewTipSet(&block.Block{Height: height})
+		require.NoError(t, err)
+		plumbing := &msigInspectPlumbing{
+			ts:      ts,
+			balance: abi.NewTokenAmount(900),
+			state: &multisig.State{
+				Signers:               []address.Address{vmaddr.RequireIDAddress(t, 100)},
+				NumApprovalsThreshold: 1,
+				NextTxnID:             1,
+				InitialBalance:        abi.NewTokenAmount(1050),
+				StartEpoch:            10,
+				UnlockDuration:        100,
+			},
+			pending: []state.MultisigTransaction{{ID: 0}},
+		}
+		info, err := MultisigInspect(ctx, plumbing, msigAddr, ts.Key())
+		require.NoError(t, err)
+		return info
+	}
+
+	t.Log("the locked amount is rounded as the multisig actor rounds it")
+	info := inspectAt(35)
+	assert.Equal(t, "750", info.Vesting.Locked.String())
+	assert.Equal(t, "150", info.Spendable.String())
+	assert.Equal(t, int64(1), info.NextTxnID)
+	assert.Len(t, info.Pending, 1)
+
+	info = inspectAt(10)
+	assert.Equal(t, "1000", info.Vesting.Locked.String())
+	assert.Equal(t, "0", info.Spendable.String())
+
+	info = inspectAt(110)
+	assert.Equal(t, "0", info.Vesting.Locked.String())
+	assert.Equal(t, "900", info.Spendable.String())
+}
@@ -0,0 +1,303 @@
+package porcelain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paymentchannel"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+)
+
+// PaychStateView is the subset of the state view the payment channel porcelain reads.
+type PaychStateView interface {
+	PaychActorState(ctx context.Context, paychAddr address.Address) (*paych.State, error)
+	AccountSignerAddress(ctx context.Context, a address.Address) (address.Address, error)
+}
+
+// paychReadPlumbing is the subset of the plumbing.API that reads payment channels.
+type paychReadPlumbing interface {
+	ActorGetAt(ctx context.Context, key block.TipSetKey, addr address.Address) (*actor.Actor, error)
+	ChainHeadKey() block.TipSetKey
+	ChainTipSet(key block.TipSetKey) (block.TipSet, error)
+	PaychGet(paychAddr address.Address) (*paymentchannel.ChannelInfo, error)
+	PaychStateView(baseKey block.TipSetKey) (PaychStateView, error)
+}
+
+// paychSendPlumbing is the subset of the plumbing.API that sends messages to payment channels.
+type paychSendPlumbing interface {
+	msigSendPlumbing
+	ChainHeadKey() block.TipSetKey
+	PaychStateView(baseKey block.TipSetKey) (PaychStateView, error)
+	WalletAddresses() []address.Address
+}
+
+// paychVoucherPlumbing is the subset of the plumbing.API that creates vouchers.
+type paychVoucherPlumbing interface {
+	paychReadPlumbing
+	PaychAllocateLane(paychAddr address.Address) (uint64, error)
+	PaychAddVoucher(paychAddr address.Address, voucher *paych.SignedVoucher) error
+	WalletSignBytes(data []byte, addr address.Address) (crypto.Signature, error)
+}
+
+// PaychInfo describes the on-chain state of a payment channel together with the vouchers
+// the node holds for it.
+type PaychInfo struct {
+	Address         address.Address
+	From            address.Address
+	To              address.Address
+	Balance         abi.TokenAmount
+	ToSend          abi.TokenAmount
+	SettlingAt      abi.ChainEpoch
+	MinSettleHeight abi.ChainEpoch
+	Lanes           []*paych.LaneState
+	// Vouchers is the number of vouchers the node holds and Redeemable what the best of them
+	// would add to ToSend if submitted now.
+	Vouchers   int
+	Redeemable abi.TokenAmount
+}
+
+// PaychStatus reads the state of a payment channel at the head of the chain.
+func PaychStatus(ctx context.Context, plumbing paychReadPlumbing, paychAddr address.Address) (*PaychInfo, error) {
+	head := plumbing.ChainHeadKey()
+	st, bal, height, err := paychStateAt(ctx, plumbing, paychAddr, head)
+	if err != nil {
+		return nil, err
+	}
+	status := &PaychInfo{
+		Address:         paychAddr,
+		From:            st.From,
+		To:              st.To,
+		Balance:         bal,
+		ToSend:          st.ToSend,
+		SettlingAt:      st.SettlingAt,
+		MinSettleHeight: st.MinSettleHeight,
+		Lanes:           st.LaneStates,
+		Redeemable:      big.Zero(),
+	}
+
+	chinfo, err := plumbing.PaychGet(paychAddr)
+	if err != nil {
+		// The node has no record of channels it neither pays nor is paid through.
+		return status, nil
+	}
+	status.Vouchers = len(chinfo.Vouchers)
+	for _, vi := range paymentchannel.BestSpendable(chinfo, st, height+1) {
+		delta, err := paymentchannel.VoucherRedeemable(vi.Voucher, st, height+1)
+		if err != nil {
+			return nil, err
+		}
+		status.Redeemable = big.Add(status.Redeemable, delta)
+	}
+	return status, nil
+}
+
+// PaychSettle starts settling a payment channel, after which it may be collected once the
+// settle delay has passed, and waits for the message to appear on chain. Either party may
+// settle; the message is sent from the party whose key is in the wallet.
+func PaychSettle(ctx context.Context, plumbing paychSendPlumbing, paychAddr address.Address, gasPrice types.AttoFIL, gasLimit gas.Unit) error {
+	party, err := paychWalletParty(ctx, plumbing, paychAddr, false)
+	if err != nil {
+		return err
+	}
+	return sendAndWait(ctx, plumbing, party, paychAddr, types.ZeroAttoFIL, gasPrice, gasLimit, builtin.MethodsPaych.Settle, nil, nil)
+}
+
+// PaychCollect pays out a settled payment channel, sending what its vouchers redeemed to the
+// payee and the rest to the payer, and waits for the message to appear on chain.
+func PaychCollect(ctx context.Context, plumbing paychSendPlumbing, paychAddr address.Address, gasPrice types.AttoFIL, gasLimit gas.Unit) error {
+	party, err := paychWalletParty(ctx, plumbing, paychAddr, false)
+	if err != nil {
+		return err
+	}
+	return sendAndWait(ctx, plumbing, party, paychAddr, types.ZeroAttoFIL, gasPrice, gasLimit, builtin.MethodsPaych.Collect, nil, nil)
+}
+
+// PaychVoucherSubmit redeems a voucher, adding to what the channel pays out when collected,
+// and waits for the message to appear on chain. The message is sent by the payee.
+func PaychVoucherSubmit(ctx context.Context, plumbing paychSendPlumbing, paychAddr address.Address, voucher *paych.SignedVoucher, proof []byte, gasPrice types.AttoFIL, gasLimit gas.Unit) error {
+	payee, err := paychWalletParty(ctx, plumbing, paychAddr, true)
+	if err != nil {
+		return err
+	}
+	params := paych.UpdateChannelStateParams{Sv: *voucher, Proof: proof}
+	return sendAndWait(ctx, plumbing, payee, paychAddr, types.ZeroAttoFIL, gasPrice, gasLimit, builtin.MethodsPaych.UpdateChannelState, &params, nil)
+}
+
+// PaychVoucherCreate creates and records a voucher redeeming `amount` in total on a lane of a
+// payment channel the node pays through. A new lane is allocated if lane is nil.
+func PaychVoucherCreate(ctx context.Context, plumbing paychVoucherPlumbing, paychAddr address.Address, amount abi.TokenAmount, lane *uint64) (*paych.SignedVoucher, error) {
+	chinfo, err := plumbing.PaychGet(paychAddr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no payment channel %s from the node", paychAddr)
+	}
+	st, bal, height, err := paychStateAt(ctx, plumbing, paychAddr, plumbing.ChainHeadKey())
+	if err != nil {
+		return nil, err
+	}
+
+	voucher := &paych.SignedVoucher{
+		TimeLockMin:     height + 1,
+		Lane:            chinfo.NextLane,
+		Nonce:           chinfo.NextNonce,
+		Amount:          amount,
+		MinSettleHeight: height + 1,
+	}
+	if lane != nil {
+		if *lane >= chinfo.NextLane {
+			return nil, fmt.Errorf("lane %d of channel %s is not allocated", *lane, paychAddr)
+		}
+		voucher.Lane = *lane
+	}
+	delta, err := paymentchannel.VoucherRedeemable(voucher, st, height+1)
+	if err != nil {
+		return nil, err
+	}
+	if big.Add(st.ToSend, delta).GreaterThan(bal) {
+		return nil, fmt.Errorf("channel balance %s cannot cover the %s the voucher redeems", bal, delta)
+	}
+	if lane == nil {
+		if voucher.Lane, err = plumbing.PaychAllocateLane(paychAddr); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := voucher.SigningBytes()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := plumbing.WalletSignBytes(data, chinfo.From)
+	if err != nil {
+		return nil, err
+	}
+	voucher.Signature = &sig
+
+	if err := plumbing.PaychAddVoucher(paychAddr, voucher); err != nil {
+		return nil, err
+	}
+	return voucher, nil
+}
+
+// PaychVoucherCheck returns the amount a voucher would add to what a payment channel pays out
+// if submitted now, or an error saying why the channel would reject it.
+func PaychVoucherCheck(ctx context.Context, plumbing paychReadPlumbing, paychAddr address.Address, voucher *paych.SignedVoucher) (abi.TokenAmount, error) {
+	head := plumbing.ChainHeadKey()
+	st, bal, height, err := paychStateAt(ctx, plumbing, paychAddr, head)
+	if err != nil {
+		return big.Zero(), err
+	}
+	if st.SettlingAt != 0 && height >= st.SettlingAt {
+		return big.Zero(), fmt.Errorf("channel %s settled at epoch %d", paychAddr, st.SettlingAt)
+	}
+
+	if voucher.Signature == nil {
+		return big.Zero(), errors.New("voucher is not signed")
+	}
+	view, err := plumbing.PaychStateView(head)
+	if err != nil {
+		return big.Zero(), err
+	}
+	payer, err := view.AccountSignerAddress(ctx, st.From)
+	if err != nil {
+		return big.Zero(), err
+	}
+	data, err := voucher.SigningBytes()
+	if err != nil {
+		return big.Zero(), err
+	}
+	if err := crypto.ValidateSignature(data, payer, *voucher.Signature); err != nil {
+		return big.Zero(), errors.Wrapf(err, "voucher is not signed by the payer %s", payer)
+	}
+
+	// A message sent now applies at the next epoch at the earliest.
+	delta, err := paymentchannel.VoucherRedeemable(voucher, st, height+1)
+	if err != nil {
+		return big.Zero(), err
+	}
+	if big.Add(st.ToSend, delta).GreaterThan(bal) {
+		return big.Zero(), fmt.Errorf("channel balance %s cannot cover the %s the voucher redeems", bal, delta)
+	}
+	return delta, nil
+}
+
+// PaychBestSpendable returns the vouchers the node holds that redeem the most for each lane of
+// a payment channel if submitted now.
+func PaychBestSpendable(ctx context.Context, plumbing paychReadPlumbing, paychAddr address.Address) ([]*paymentchannel.VoucherInfo, error) {
+	chinfo, err := plumbing.PaychGet(paychAddr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no vouchers for payment channel %s", paychAddr)
+	}
+	st, _, height, err := paychStateAt(ctx, plumbing, paychAddr, plumbing.ChainHeadKey())
+	if err != nil {
+		return nil, err
+	}
+	return paymentchannel.BestSpendable(chinfo, st, height+1), nil
+}
+
+// paychStateAt reads the state and balance of a payment channel at a tipset and its height.
+func paychStateAt(ctx context.Context, plumbing paychReadPlumbing, paychAddr address.Address, key block.TipSetKey) (*paych.State, abi.TokenAmount, abi.ChainEpoch, error) {
+	ts, err := plumbing.ChainTipSet(key)
+	if err != nil {
+		return nil, big.Zero(), 0, err
+	}
+	height, err := ts.Height()
+	if err != nil {
+		return nil, big.Zero(), 0, err
+	}
+	view, err := plumbing.PaychStateView(key)
+	if err != nil {
+		return nil, big.Zero(), 0, err
+	}
+	st, err := view.PaychActorState(ctx, paychAddr)
+	if err != nil {
+		return nil, big.Zero(), 0, err
+	}
+	act, err := plumbing.ActorGetAt(ctx, key, paychAddr)
+	if err != nil {
+		return nil, big.Zero(), 0, err
+	}
+	return st, act.Balance, height, nil
+}
+
+// paychWalletParty returns the key address of the party to a payment channel whose key is in
+// the wallet, preferring the payer unless payeeOnly is set.
+func paychWalletParty(ctx context.Context, plumbing paychSendPlumbing, paychAddr address.Address, payeeOnly bool) (address.Address, error) {
+	view, err := plumbing.PaychStateView(plumbing.ChainHeadKey())
+	if err != nil {
+		return address.Undef, err
+	}
+	st, err := view.PaychActorState(ctx, paychAddr)
+	if err != nil {
+		return address.Undef, err
+	}
+
+	parties := []address.Address{st.From, st.To}
+	if payeeOnly {
+		parties = parties[1:]
+	}
+	for _, party := range parties {
+		signer, err := view.AccountSignerAddress(ctx, party)
+		if err != nil {
+			return address.Undef, err
+		}
+		for _, a := range plumbing.WalletAddresses() {
+			if a == signer {
+				return signer, nil
+			}
+		}
+	}
+	if payeeOnly {
+		return address.Undef, fmt.Errorf("the wallet does not hold the key of payee %s of channel %s", st.To, paychAddr)
+	}
+	return address.Undef, fmt.Errorf("the wallet holds the key of neither party to channel %s", paychAddr)
+}
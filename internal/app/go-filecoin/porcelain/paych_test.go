@@ -0,0 +1,161 @@
+package porcelain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/paymentchannel"
+	. "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+type paychPlumbing struct {
+	msigSendPlumbing
+	ts      block.TipSet
+	balance abi.TokenAmount
+	state   *paych.State
+	chinfo  *paymentchannel.ChannelInfo
+	signer  types.MockSigner
+	// signers maps the ID addresses of the parties to their key addresses.
+	signers map[address.Address]address.Address
+}
+
+func (p *paychPlumbing) ActorGetAt(_ context.Context, _ block.TipSetKey, _ address.Address) (*actor.Actor, error) {
+	return &actor.Actor{Balance: p.balance}, nil
+}
+
+func (p *paychPlumbing) ChainHeadKey() block.TipSetKey {
+	return p.ts.Key()
+}
+
+func (p *paychPlumbing) ChainTipSet(_ block.TipSetKey) (block.TipSet, error) {
+	return p.ts, nil
+}
+
+func (p *paychPlumbing) PaychGet(_ address.Address) (*paymentchannel.ChannelInfo, error) {
+	return p.chinfo, nil
+}
+
+func (p *paychPlumbing) PaychAllocateLane(_ address.Address) (uint64, error) {
+	lane := p.chinfo.NextLane
+	p.chinfo.NextLane++
+	p.chinfo.NextNonce++
+	return lane, nil
+}
+
+func (p *paychPlumbing) PaychAddVoucher(_ address.Address, voucher *paych.SignedVoucher) error {
+	p.chinfo.NextNonce++
+	p.chinfo.Vouchers = append(p.chinfo.Vouchers, &paymentchannel.VoucherInfo{Voucher: voucher})
+	return nil
+}
+
+func (p *paychPlumbing) PaychStateView(_ block.TipSetKey) (PaychStateView, error) {
+	return p, nil
+}
+
+func (p *paychPlumbing) PaychActorState(_ context.Context, _ address.Address) (*paych.State, error) {
+	return p.state, nil
+}
+
+func (p *paychPlumbing) AccountSignerAddress(_ context.Context, a address.Address) (address.Address, error) {
+	return p.signers[a], nil
+}
+
+func (p *paychPlumbing) WalletAddresses() []address.Address {
+	return []address.Address{p.signer.Addresses[0]}
+}
+
+func (p *paychPlumbing) WalletSignBytes(data []byte, addr address.Address) (crypto.Signature, error) {
+	return p.signer.SignBytes(context.Background(), data, addr)
+}
+
+func newPaychPlumbing(t *testing.T) *paychPlumbing {
+	ts, err := block.NewTipSet(&block.Block{Height: 20})
+	require.NoError(t, err)
+	signer := types.NewMockSigner(types.MustGenerateKeyInfo(1, 42))
+	other := types.NewMockSigner(types.MustGenerateKeyInfo(1, 43))
+	from, to := vmaddr.RequireIDAddress(t, 100), vmaddr.RequireIDAddress(t, 101)
+	return &paychPlumbing{
+		msigSendPlumbing: msigSendPlumbing{t: t},
+		ts:               ts,
+		balance:          abi.NewTokenAmount(100),
+		state:            &paych.State{From: from, To: to, ToSend: abi.NewTokenAmount(30)},
+		chinfo:           &paymentchannel.ChannelInfo{From: signer.Addresses[0], To: to},
+		signer:           signer,
+		signers:          map[address.Address]address.Address{from: signer.Addresses[0], to: other.Addresses[0]},
+	}
+}
+
+func TestPaychVoucherCreateAndCheck(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	paychAddr := vmaddr.RequireIDAddress(t, 200)
+	plumbing := newPaychPlumbing(t)
+
+	voucher, err := PaychVoucherCreate(ctx, plumbing, paychAddr, abi.NewTokenAmount(50), nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), voucher.Lane)
+	assert.Equal(t, abi.ChainEpoch(21), voucher.TimeLockMin)
+	redeemable, err := PaychVoucherCheck(ctx, plumbing, paychAddr, voucher)
+	require.NoError(t, err)
+	assert.Equal(t, abi.NewTokenAmount(50), redeemable)
+
+	t.Log("vouchers on the same lane redeem the difference to the amount already redeemed")
+	plumbing.state.LaneStates = []*paych.LaneState{{ID: 0, Redeemed: abi.NewTokenAmount(50), Nonce: voucher.Nonce}}
+	lane := uint64(0)
+	next, err := PaychVoucherCreate(ctx, plumbing, paychAddr, abi.NewTokenAmount(60), &lane)
+	require.NoError(t, err)
+	assert.True(t, next.Nonce > voucher.Nonce)
+	redeemable, err = PaychVoucherCheck(ctx, plumbing, paychAddr, next)
+	require.NoError(t, err)
+	assert.Equal(t, abi.NewTokenAmount(10), redeemable)
+
+	t.Log("vouchers exceeding the balance are rejected")
+	_, err = PaychVoucherCreate(ctx, plumbing, paychAddr, abi.NewTokenAmount(71), nil)
+	assert.Error(t, err)
+
+	t.Log("vouchers not signed by the payer are rejected")
+	forged := *next
+	forged.Amount = abi.NewTokenAmount(70)
+	_, err = PaychVoucherCheck(ctx, plumbing, paychAddr, &forged)
+	assert.Error(t, err)
+
+	t.Log("vouchers of settled channels are rejected")
+	plumbing.state.SettlingAt = 20
+	_, err = PaychVoucherCheck(ctx, plumbing, paychAddr, next)
+	assert.Error(t, err)
+}
+
+func TestPaychSettleAndSubmit(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	paychAddr := vmaddr.RequireIDAddress(t, 200)
+	plumbing := newPaychPlumbing(t)
+
+	require.NoError(t, PaychSettle(ctx, plumbing, paychAddr, types.ZeroAttoFIL, 0))
+	assert.Equal(t, paychAddr, plumbing.to)
+	assert.Equal(t, builtin.MethodsPaych.Settle, plumbing.method)
+
+	t.Log("only the payee submits vouchers")
+	voucher := &paych.SignedVoucher{Amount: big.NewInt(10)}
+	err := PaychVoucherSubmit(ctx, plumbing, paychAddr, voucher, nil, types.ZeroAttoFIL, 0)
+	assert.Error(t, err)
+
+	plumbing.signers[plumbing.state.To] = plumbing.signer.Addresses[0]
+	require.NoError(t, PaychVoucherSubmit(ctx, plumbing, paychAddr, voucher, []byte("proof"), types.ZeroAttoFIL, 0))
+	assert.Equal(t, builtin.MethodsPaych.UpdateChannelState, plumbing.method)
+	assert.Equal(t, &paych.UpdateChannelStateParams{Sv: *voucher, Proof: []byte("proof")}, plumbing.params)
+}
@@ -0,0 +1,136 @@
+package porcelain
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	fsm "github.com/filecoin-project/storage-fsm"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+)
+
+const (
+	// SectorPreCommitted is the chain state of sectors pre-committed and not
+	// yet proven.
+	SectorPreCommitted = "precommitted"
+	// SectorCommitted is the chain state of proven sectors.
+	SectorCommitted = "committed"
+)
+
+// retryEventPrefix prefixes the kinds of the sealing events retrying a failed
+// step of a sector.
+const retryEventPrefix = "event;sealing.SectorRetry"
+
+// SectorStateView is the subset of the state view the chain state of sectors
+// is read from.
+type SectorStateView interface {
+	MinerGetSector(ctx context.Context, maddr address.Address, sectorNum abi.SectorNumber) (*miner.SectorOnChainInfo, bool, error)
+	MinerGetPrecommittedSector(ctx context.Context, maddr address.Address, sectorNum abi.SectorNumber) (*miner.SectorPreCommitOnChainInfo, bool, error)
+	MinerFaults(ctx context.Context, maddr address.Address) ([]uint64, error)
+}
+
+type sectorStatusPlumbing interface {
+	ChainHeadKey() block.TipSetKey
+	SectorStateView(baseKey block.TipSetKey) (SectorStateView, error)
+}
+
+// SectorStatus is the sealing state of a sector together with its state on
+// chain.
+type SectorStatus struct {
+	SectorNumber       abi.SectorNumber
+	State              fsm.SectorState
+	Deals              []abi.DealID
+	PreCommitMessage   *cid.Cid
+	CommitMessage      *cid.Cid
+	FaultReportMessage *cid.Cid
+	LastError          string
+	// Retries counts the times sealing retried a failed step of the sector.
+	Retries int
+	// InvalidProofs counts the proofs computed for the sector which did not
+	// verify.
+	InvalidProofs uint64
+
+	// ChainState is SectorPreCommitted or SectorCommitted, or empty while the
+	// sector is not on chain.
+	ChainState      string
+	PreCommitEpoch  abi.ChainEpoch
+	ActivationEpoch abi.ChainEpoch
+	Expiration      abi.ChainEpoch
+	Faulty          bool
+
+	// Log holds the sealing events of the sector, when requested.
+	Log []fsm.Log `json:",omitempty"`
+}
+
+// SectorStatuses joins the sealing states of sectors of a miner with their
+// state on chain at the head, ordered by sector number.
+func SectorStatuses(ctx context.Context, plumbing sectorStatusPlumbing, maddr address.Address, sectors []fsm.SectorInfo) ([]SectorStatus, error) {
+	view, err := plumbing.SectorStateView(plumbing.ChainHeadKey())
+	if err != nil {
+		return nil, err
+	}
+	faults, err := view.MinerFaults(ctx, maddr)
+	if err != nil {
+		return nil, err
+	}
+	faulty := make(map[abi.SectorNumber]bool, len(faults))
+	for _, f := range faults {
+		faulty[abi.SectorNumber(f)] = true
+	}
+
+	statuses := make([]SectorStatus, 0, len(sectors))
+	for _, sector := range sectors {
+		status := SectorStatus{
+			SectorNumber:       sector.SectorNumber,
+			State:              sector.State,
+			Deals:              []abi.DealID{},
+			PreCommitMessage:   sector.PreCommitMessage,
+			CommitMessage:      sector.CommitMessage,
+			FaultReportMessage: sector.FaultReportMsg,
+			LastError:          sector.LastErr,
+			InvalidProofs:      sector.InvalidProofs,
+			Faulty:             faulty[sector.SectorNumber],
+		}
+		for _, piece := range sector.Pieces {
+			if piece.DealInfo != nil {
+				status.Deals = append(status.Deals, piece.DealInfo.DealID)
+			}
+		}
+		for _, l := range sector.Log {
+			if strings.HasPrefix(l.Kind, retryEventPrefix) {
+				status.Retries++
+			}
+		}
+
+		onChain, found, err := view.MinerGetSector(ctx, maddr, sector.SectorNumber)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			status.ChainState = SectorCommitted
+			status.ActivationEpoch = onChain.ActivationEpoch
+			status.Expiration = onChain.Info.Expiration
+		} else {
+			preCommit, found, err := view.MinerGetPrecommittedSector(ctx, maddr, sector.SectorNumber)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				status.ChainState = SectorPreCommitted
+				status.PreCommitEpoch = preCommit.PreCommitEpoch
+				status.Expiration = preCommit.Info.Expiration
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].SectorNumber < statuses[j].SectorNumber
+	})
+	return statuses, nil
+}
@@ -0,0 +1,112 @@
+package porcelain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	fsm "github.com/filecoin-project/storage-fsm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+type sectorStatusPlumbing struct {
+	committed    map[abi.SectorNumber]*miner.SectorOnChainInfo
+	precommitted map[abi.SectorNumber]*miner.SectorPreCommitOnChainInfo
+	faults       []uint64
+}
+
+func (p *sectorStatusPlumbing) ChainHeadKey() block.TipSetKey {
+	return block.NewTipSetKey()
+}
+
+func (p *sectorStatusPlumbing) SectorStateView(_ block.TipSetKey) (SectorStateView, error) {
+	return p, nil
+}
+
+func (p *sectorStatusPlumbing) MinerGetSector(_ context.Context, _ address.Address, num abi.SectorNumber) (*miner.SectorOnChainInfo, bool, error) {
+	info, ok := p.committed[num]
+	return info, ok, nil
+}
+
+func (p *sectorStatusPlumbing) MinerGetPrecommittedSector(_ context.Context, _ address.Address, num abi.SectorNumber) (*miner.SectorPreCommitOnChainInfo, bool, error) {
+	info, ok := p.precommitted[num]
+	return info, ok, nil
+}
+
+func (p *sectorStatusPlumbing) MinerFaults(context.Context, address.Address) ([]uint64, error) {
+	return p.faults, nil
+}
+
+func TestSectorStatuses(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	maddr := vmaddr.RequireIDAddress(t, 1000)
+	preCommitMsg, commitMsg := types.CidFromString(t, "precommit"), types.CidFromString(t, "commit")
+
+	plumbing := &sectorStatusPlumbing{
+		committed: map[abi.SectorNumber]*miner.SectorOnChainInfo{
+			2: {Info: miner.SectorPreCommitInfo{Expiration: 1000}, ActivationEpoch: 50},
+		},
+		precommitted: map[abi.SectorNumber]*miner.SectorPreCommitOnChainInfo{
+			3: {Info: miner.SectorPreCommitInfo{Expiration: 2000}, PreCommitEpoch: 80},
+		},
+		faults: []uint64{2},
+	}
+	sectors := []fsm.SectorInfo{
+		{
+			SectorNumber:     3,
+			State:            fsm.CommitFailed,
+			PreCommitMessage: &preCommitMsg,
+			InvalidProofs:    1,
+			LastErr:          "proof invalid",
+			Log: []fsm.Log{
+				{Kind: "event;sealing.SectorSealPreCommitFailed"},
+				{Kind: "event;sealing.SectorRetrySeal"},
+				{Kind: "event;sealing.SectorRetryComputeProof"},
+			},
+		},
+		{
+			SectorNumber:     2,
+			State:            fsm.Proving,
+			PreCommitMessage: &preCommitMsg,
+			CommitMessage:    &commitMsg,
+			Pieces: []fsm.Piece{
+				{DealInfo: &fsm.DealInfo{DealID: 7}},
+				{},
+			},
+		},
+		{SectorNumber: 1, State: fsm.PreCommit1},
+	}
+
+	statuses, err := SectorStatuses(ctx, plumbing, maddr, sectors)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+
+	assert.Equal(t, abi.SectorNumber(1), statuses[0].SectorNumber)
+	assert.Equal(t, "", statuses[0].ChainState)
+	assert.Empty(t, statuses[0].Deals)
+
+	assert.Equal(t, SectorCommitted, statuses[1].ChainState)
+	assert.Equal(t, []abi.DealID{7}, statuses[1].Deals)
+	assert.Equal(t, abi.ChainEpoch(50), statuses[1].ActivationEpoch)
+	assert.Equal(t, abi.ChainEpoch(1000), statuses[1].Expiration)
+	assert.Equal(t, &commitMsg, statuses[1].CommitMessage)
+	assert.True(t, statuses[1].Faulty)
+
+	assert.Equal(t, SectorPreCommitted, statuses[2].ChainState)
+	assert.Equal(t, abi.ChainEpoch(80), statuses[2].PreCommitEpoch)
+	assert.Equal(t, fsm.CommitFailed, statuses[2].State)
+	assert.Equal(t, "proof invalid", statuses[2].LastError)
+	assert.Equal(t, 2, statuses[2].Retries)
+	assert.Equal(t, uint64(1), statuses[2].InvalidProofs)
+	assert.False(t, statuses[2].Faulty)
+}
@@ -9,8 +9,10 @@ import (
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/pkg/errors"
 
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet/hd"
 )
 
 // ErrNoDefaultFromAddress is returned when a default wallet address couldn't be determined (eg, there are zero addresses in the wallet).
@@ -62,3 +64,128 @@ func WalletDefaultAddress(plumbing wdaPlumbing) (address.Address, error) {
 
 	return address.Undef, ErrNoDefaultFromAddress
 }
+
+// AddressStateView is the subset of the state view that resolves addresses.
+type AddressStateView interface {
+	InitResolveAddress(ctx context.Context, a address.Address) (address.Address, error)
+}
+
+// WalletAddressInfo describes an address of the wallet.
+type WalletAddressInfo struct {
+	Address address.Address
+	Label   string `json:",omitempty"`
+	// ID is the ID address of the address's actor, undefined until the actor exists.
+	ID      address.Address
+	Balance abi.TokenAmount
+}
+
+type waiPlumbing interface {
+	ActorGet(ctx context.Context, addr address.Address) (*actor.Actor, error)
+	AddressBookLabel(addr address.Address) (string, bool)
+	AddressStateView(baseKey block.TipSetKey) (AddressStateView, error)
+	ChainHeadKey() block.TipSetKey
+	WalletAddresses() []address.Address
+}
+
+// WalletAddressInfos describes the addresses of the wallet with their labels,
+// ID addresses and balances at the head.
+func WalletAddressInfos(ctx context.Context, plumbing waiPlumbing) ([]WalletAddressInfo, error) {
+	view, err := plumbing.AddressStateView(plumbing.ChainHeadKey())
+	if err != nil {
+		return nil, err
+	}
+	addrs := plumbing.WalletAddresses()
+	infos := make([]WalletAddressInfo, len(addrs))
+	for i, addr := range addrs {
+		infos[i].Address = addr
+		infos[i].Label, _ = plumbing.AddressBookLabel(addr)
+		infos[i].ID, err = view.InitResolveAddress(ctx, addr)
+		if err == initact.ErrAddressNotFound {
+			infos[i].ID = address.Undef
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve %s", addr)
+		}
+		infos[i].Balance, err = WalletBalance(ctx, plumbing, addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
+
+// DefaultRecoveryGap is the number of consecutive addresses without an actor
+// on chain after which recovery stops searching a derivation path.
+const DefaultRecoveryGap = 20
+
+type wisPlumbing interface {
+	WalletSetSeed(seed []byte) error
+}
+
+// WalletInitSeed generates a mnemonic and makes the wallet derive new addresses
+// from it. The mnemonic, with the passphrase if any, is the backup of every
+// address derived afterwards.
+func WalletInitSeed(plumbing wisPlumbing, passphrase string) (string, error) {
+	mnemonic, err := hd.NewMnemonic()
+	if err != nil {
+		return "", err
+	}
+	seed, err := hd.SeedFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return "", err
+	}
+	if err := plumbing.WalletSetSeed(seed); err != nil {
+		return "", err
+	}
+	return mnemonic, nil
+}
+
+type wrPlumbing interface {
+	ActorGet(ctx context.Context, addr address.Address) (*actor.Actor, error)
+	WalletDeriveAddress(protocol address.Protocol, index uint32) (address.Address, error)
+	WalletImportDerived(protocol address.Protocol, index uint32) (address.Address, error)
+	WalletSetSeed(seed []byte) error
+}
+
+// WalletRecover restores the addresses derived from a mnemonic. It searches
+// the secp256k1 and BLS derivation paths for addresses with an actor on chain,
+// until `gap` consecutive addresses have none, and adds every address up to
+// the last one found. The first secp256k1 address is always added.
+func WalletRecover(ctx context.Context, plumbing wrPlumbing, mnemonic, passphrase string, gap uint32) ([]address.Address, error) {
+	seed, err := hd.SeedFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if err := plumbing.WalletSetSeed(seed); err != nil {
+		return nil, err
+	}
+
+	var recovered []address.Address
+	for _, protocol := range []address.Protocol{address.SECP256K1, address.BLS} {
+		used := 0
+		if protocol == address.SECP256K1 {
+			used = 1
+		}
+		for i := uint32(0); i < uint32(used)+gap; i++ {
+			addr, err := plumbing.WalletDeriveAddress(protocol, i)
+			if err != nil {
+				return nil, err
+			}
+			_, err = plumbing.ActorGet(ctx, addr)
+			if err == types.ErrNotFound || err == initact.ErrAddressNotFound {
+				continue
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to look up %s", addr)
+			}
+			used = int(i) + 1
+		}
+		for i := 0; i < used; i++ {
+			addr, err := plumbing.WalletImportDerived(protocol, uint32(i))
+			if err != nil {
+				return nil, err
+			}
+			recovered = append(recovered, addr)
+		}
+	}
+	return recovered, nil
+}
@@ -6,10 +6,12 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	initact "github.com/filecoin-project/specs-actors/actors/builtin/init"
 	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cfg"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
@@ -113,6 +115,142 @@ func TestWalletDefaultAddress(t *testing.T) {
 	})
 }
 
+type waiTestPlumbing struct {
+	wbTestPlumbing
+	book    *wallet.AddressBook
+	addrs   []address.Address
+	onChain map[address.Address]address.Address
+}
+
+func (p *waiTestPlumbing) AddressBookLabel(addr address.Address) (string, bool) {
+	return p.book.Label(addr)
+}
+
+func (p *waiTestPlumbing) AddressStateView(_ block.TipSetKey) (porcelain.AddressStateView, error) {
+	return p, nil
+}
+
+func (p *waiTestPlumbing) InitResolveAddress(_ context.Context, a address.Address) (address.Address, error) {
+	if id, ok := p.onChain[a]; ok {
+		return id, nil
+	}
+	return address.Undef, initact.ErrAddressNotFound
+}
+
+func (p *waiTestPlumbing) ChainHeadKey() block.TipSetKey {
+	return block.NewTipSetKey()
+}
+
+func (p *waiTestPlumbing) WalletAddresses() []address.Address {
+	return p.addrs
+}
+
+func TestWalletAddressInfos(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	book, err := wallet.NewAddressBook(repo.NewInMemoryRepo().Datastore())
+	require.NoError(t, err)
+	labelled, err := address.NewSecp256k1Address([]byte("labelled"))
+	require.NoError(t, err)
+	fresh, err := address.NewSecp256k1Address([]byte("fresh"))
+	require.NoError(t, err)
+	id, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	require.NoError(t, book.Set("hot", labelled))
+
+	plumbing := &waiTestPlumbing{
+		wbTestPlumbing: wbTestPlumbing{balance: types.NewAttoFILFromFIL(3)},
+		book:           book,
+		addrs:          []address.Address{labelled, fresh},
+		onChain:        map[address.Address]address.Address{labelled: id},
+	}
+	infos, err := porcelain.WalletAddressInfos(ctx, plumbing)
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+
+	assert.Equal(t, "hot", infos[0].Label)
+	assert.Equal(t, id, infos[0].ID)
+	assert.Equal(t, types.NewAttoTokenFromToken(3), infos[0].Balance)
+
+	assert.Equal(t, "", infos[1].Label)
+	assert.Equal(t, address.Undef, infos[1].ID)
+}
+
+type wrTestPlumbing struct {
+	wallet  *wallet.Wallet
+	onChain map[address.Address]bool
+}
+
+func (p *wrTestPlumbing) ActorGet(_ context.Context, addr address.Address) (*actor.Actor, error) {
+	if p.onChain[addr] {
+		return &actor.Actor{}, nil
+	}
+	return nil, types.ErrNotFound
+}
+
+func (p *wrTestPlumbing) WalletDeriveAddress(protocol address.Protocol, index uint32) (address.Address, error) {
+	return wallet.DeriveAddress(p.wallet, protocol, index)
+}
+
+func (p *wrTestPlumbing) WalletImportDerived(protocol address.Protocol, index uint32) (address.Address, error) {
+	return wallet.ImportDerived(p.wallet, protocol, index)
+}
+
+func (p *wrTestPlumbing) WalletSetSeed(seed []byte) error {
+	return wallet.SetSeed(p.wallet, seed)
+}
+
+func newWrTestPlumbing(t *testing.T) *wrTestPlumbing {
+	r := repo.NewInMemoryRepo()
+	backend, err := wallet.NewDSBackendWithHD(r.WalletDatastore(), r.Datastore())
+	require.NoError(t, err)
+	return &wrTestPlumbing{wallet: wallet.New(backend), onChain: map[address.Address]bool{}}
+}
+
+func TestWalletRecover(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	original := newWrTestPlumbing(t)
+	mnemonic, err := porcelain.WalletInitSeed(original, "secret")
+	require.NoError(t, err)
+	var used []address.Address
+	for i := 0; i < 4; i++ {
+		addr, err := wallet.NewAddress(original.wallet, address.SECP256K1)
+		require.NoError(t, err)
+		used = append(used, addr)
+	}
+	addr, err := wallet.NewAddress(original.wallet, address.BLS)
+	require.NoError(t, err)
+	used = append(used, addr)
+
+	// Only the last secp256k1 address and the BLS one have actors on chain.
+	restored := newWrTestPlumbing(t)
+	restored.onChain[used[3]] = true
+	restored.onChain[used[4]] = true
+
+	recovered, err := porcelain.WalletRecover(ctx, restored, mnemonic, "secret", 5)
+	require.NoError(t, err)
+	assert.Equal(t, used, recovered)
+	for _, addr := range used {
+		assert.True(t, restored.wallet.HasAddress(addr))
+	}
+
+	t.Log("a gap larger than the search stops recovery")
+	restored = newWrTestPlumbing(t)
+	restored.onChain[used[3]] = true
+	recovered, err = porcelain.WalletRecover(ctx, restored, mnemonic, "secret", 2)
+	require.NoError(t, err)
+	assert.Equal(t, used[:1], recovered)
+
+	t.Log("the passphrase is part of the seed")
+	restored = newWrTestPlumbing(t)
+	recovered, err = porcelain.WalletRecover(ctx, restored, mnemonic, "", 5)
+	require.NoError(t, err)
+	assert.NotEqual(t, used[0], recovered[0])
+}
+
 func isInList(needle address.Address, haystack []address.Address) bool {
 	for _, a := range haystack {
 		if a == needle {
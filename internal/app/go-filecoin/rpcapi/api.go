@@ -0,0 +1,125 @@
+// Package rpcapi defines the node's JSON-RPC API, serves it from a node's
+// porcelain and storage APIs, and provides a typed client for it.
+package rpcapi
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+)
+
+// Namespace prefixes the name of every method of the API, e.g.
+// "Filecoin.ChainHead".
+const Namespace = "Filecoin"
+
+// Path is the path of the JSON-RPC endpoint on the daemon's API server.
+const Path = "/rpc/v0"
+
+// FullNode is the API served over JSON-RPC. Methods taking a tipset key read
+// the state at that tipset, and an empty key selects the current head.
+type FullNode interface {
+	ChainHead(ctx context.Context) (*TipSet, error)
+	ChainGetTipSet(ctx context.Context, key block.TipSetKey) (*TipSet, error)
+	ChainGetBlock(ctx context.Context, id cid.Cid) (*block.Block, error)
+	ChainGetMessages(ctx context.Context, metaCid cid.Cid) (*BlockMessages, error)
+	ChainGetReceipts(ctx context.Context, id cid.Cid) ([]vm.MessageReceipt, error)
+	// ChainNotify is a subscription receiving each new head.
+	ChainNotify(ctx context.Context) (<-chan *TipSet, error)
+	SyncStatus(ctx context.Context) (*status.Status, error)
+
+	StateGetActor(ctx context.Context, addr address.Address, key block.TipSetKey) (*actor.Actor, error)
+	StateNetworkName(ctx context.Context, key block.TipSetKey) (string, error)
+	StateMinerStatus(ctx context.Context, addr address.Address, key block.TipSetKey) (*porcelain.MinerStatus, error)
+	StateMarketDeal(ctx context.Context, dealID abi.DealID, key block.TipSetKey) (*MarketDeal, error)
+
+	MpoolPending(ctx context.Context) ([]*types.SignedMessage, error)
+	// MpoolPush adds a signed message to the pool and publishes it.
+	MpoolPush(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error)
+
+	WalletAddresses(ctx context.Context) ([]address.Address, error)
+	WalletDefaultAddress(ctx context.Context) (address.Address, error)
+	WalletNewAddress(ctx context.Context, protocol address.Protocol) (address.Address, error)
+	WalletBalance(ctx context.Context, addr address.Address) (abi.TokenAmount, error)
+
+	// MessageSend signs a message from a wallet address and publishes it.
+	MessageSend(ctx context.Context, msg *MessageSendParams) (cid.Cid, error)
+	// MessageWait waits for a message to appear on chain, looking for it in
+	// up to `lookback` tipsets below the head first.
+	MessageWait(ctx context.Context, msgCid cid.Cid, lookback uint64) (*MessageLookup, error)
+
+	ClientListDeals(ctx context.Context) ([]storagemarket.ClientDeal, error)
+	ClientGetDeal(ctx context.Context, proposalCid cid.Cid) (*storagemarket.ClientDeal, error)
+	ProviderListDeals(ctx context.Context) ([]storagemarket.MinerDeal, error)
+	// ProviderListAsks lists the asks this node's storage provider offers for
+	// a miner.
+	ProviderListAsks(ctx context.Context, miner address.Address) ([]*storagemarket.SignedStorageAsk, error)
+
+	// JournalSubscribe is a subscription receiving journal entries selected
+	// by the filter as they are recorded.
+	JournalSubscribe(ctx context.Context, filter journal.Filter) (<-chan journal.Entry, error)
+	NodeHealth(ctx context.Context) (*porcelain.NodeHealth, error)
+}
+
+// TipSet is the JSON representation of a tipset.
+type TipSet struct {
+	Key    block.TipSetKey
+	Height abi.ChainEpoch
+	Blocks []*block.Block
+}
+
+func newTipSet(ts block.TipSet) (*TipSet, error) {
+	height, err := ts.Height()
+	if err != nil {
+		return nil, err
+	}
+	return &TipSet{
+		Key:    ts.Key(),
+		Height: height,
+		Blocks: ts.ToSlice(),
+	}, nil
+}
+
+// BlockMessages holds the messages included in a block.
+type BlockMessages struct {
+	BLSMessages  []*types.UnsignedMessage
+	SecpMessages []*types.SignedMessage
+}
+
+// MarketDeal is a storage deal published to the market actor. State is nil
+// until the deal is activated.
+type MarketDeal struct {
+	Proposal market.DealProposal
+	State    *market.DealState
+}
+
+// MessageSendParams describes a message to send. Params holds the method
+// parameters already encoded.
+type MessageSendParams struct {
+	From     address.Address
+	To       address.Address
+	Value    types.AttoFIL
+	GasPrice types.AttoFIL
+	GasLimit gas.Unit
+	Method   abi.MethodNum
+	Params   []byte
+}
+
+// MessageLookup locates a message on chain along with its receipt.
+type MessageLookup struct {
+	Block   cid.Cid
+	Height  abi.ChainEpoch
+	Receipt *vm.MessageReceipt
+}
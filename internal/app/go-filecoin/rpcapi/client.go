@@ -0,0 +1,300 @@
+package rpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/jsonrpc"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+)
+
+// Client is a typed client of the FullNode API.
+type Client struct {
+	rpc *jsonrpc.Client
+}
+
+var _ FullNode = (*Client)(nil)
+
+// NewClient connects to the JSON-RPC endpoint of a daemon, such as
+// ws://127.0.0.1:3453/rpc/v0.
+func NewClient(ctx context.Context, url string, header http.Header) (*Client, error) {
+	c, err := jsonrpc.Dial(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{rpc: c}, nil
+}
+
+// Close closes the connection to the daemon.
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+// Schema fetches the description of the methods served by the daemon.
+func (c *Client) Schema(ctx context.Context) (*jsonrpc.Schema, error) {
+	var schema jsonrpc.Schema
+	if err := c.rpc.Call(ctx, jsonrpc.RPCDiscover, &schema); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func (c *Client) call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	return c.rpc.Call(ctx, Namespace+"."+method, result, params...)
+}
+
+// ChainHead returns the current head.
+func (c *Client) ChainHead(ctx context.Context) (*TipSet, error) {
+	var ts TipSet
+	if err := c.call(ctx, "ChainHead", &ts); err != nil {
+		return nil, err
+	}
+	return &ts, nil
+}
+
+// ChainGetTipSet returns the tipset with the given key.
+func (c *Client) ChainGetTipSet(ctx context.Context, key block.TipSetKey) (*TipSet, error) {
+	var ts TipSet
+	if err := c.call(ctx, "ChainGetTipSet", &ts, key); err != nil {
+		return nil, err
+	}
+	return &ts, nil
+}
+
+// ChainGetBlock returns the block with the given cid.
+func (c *Client) ChainGetBlock(ctx context.Context, id cid.Cid) (*block.Block, error) {
+	var blk block.Block
+	if err := c.call(ctx, "ChainGetBlock", &blk, id); err != nil {
+		return nil, err
+	}
+	return &blk, nil
+}
+
+// ChainGetMessages returns the messages referenced by a block's message collection.
+func (c *Client) ChainGetMessages(ctx context.Context, metaCid cid.Cid) (*BlockMessages, error) {
+	var msgs BlockMessages
+	if err := c.call(ctx, "ChainGetMessages", &msgs, metaCid); err != nil {
+		return nil, err
+	}
+	return &msgs, nil
+}
+
+// ChainGetReceipts returns the receipts referenced by a block's receipt collection.
+func (c *Client) ChainGetReceipts(ctx context.Context, id cid.Cid) ([]vm.MessageReceipt, error) {
+	var receipts []vm.MessageReceipt
+	if err := c.call(ctx, "ChainGetReceipts", &receipts, id); err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
+
+// ChainNotify returns a channel receiving each new head until ctx is done.
+func (c *Client) ChainNotify(ctx context.Context) (<-chan *TipSet, error) {
+	values, err := c.rpc.Subscribe(ctx, Namespace+".ChainNotify")
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan *TipSet)
+	go func() {
+		defer close(out)
+		for raw := range values {
+			var ts TipSet
+			if err := json.Unmarshal(raw, &ts); err != nil {
+				continue
+			}
+			select {
+			case out <- &ts:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// SyncStatus returns the status of the chain syncer.
+func (c *Client) SyncStatus(ctx context.Context) (*status.Status, error) {
+	var st status.Status
+	if err := c.call(ctx, "SyncStatus", &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// StateGetActor returns an actor from the state at a tipset.
+func (c *Client) StateGetActor(ctx context.Context, addr address.Address, key block.TipSetKey) (*actor.Actor, error) {
+	var act actor.Actor
+	if err := c.call(ctx, "StateGetActor", &act, addr, key); err != nil {
+		return nil, err
+	}
+	return &act, nil
+}
+
+// StateNetworkName returns the name of the network.
+func (c *Client) StateNetworkName(ctx context.Context, key block.TipSetKey) (string, error) {
+	var name string
+	err := c.call(ctx, "StateNetworkName", &name, key)
+	return name, err
+}
+
+// StateMinerStatus returns a miner's addresses, sectors and power at a tipset.
+func (c *Client) StateMinerStatus(ctx context.Context, addr address.Address, key block.TipSetKey) (*porcelain.MinerStatus, error) {
+	var st porcelain.MinerStatus
+	if err := c.call(ctx, "StateMinerStatus", &st, addr, key); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// StateMarketDeal returns a published storage deal at a tipset.
+func (c *Client) StateMarketDeal(ctx context.Context, dealID abi.DealID, key block.TipSetKey) (*MarketDeal, error) {
+	var deal MarketDeal
+	if err := c.call(ctx, "StateMarketDeal", &deal, dealID, key); err != nil {
+		return nil, err
+	}
+	return &deal, nil
+}
+
+// MpoolPending returns the messages in the message pool.
+func (c *Client) MpoolPending(ctx context.Context) ([]*types.SignedMessage, error) {
+	var msgs []*types.SignedMessage
+	if err := c.call(ctx, "MpoolPending", &msgs); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// MpoolPush adds a signed message to the pool and publishes it.
+func (c *Client) MpoolPush(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error) {
+	var id cid.Cid
+	err := c.call(ctx, "MpoolPush", &id, smsg)
+	return id, err
+}
+
+// WalletAddresses returns the addresses held by the daemon's wallet.
+func (c *Client) WalletAddresses(ctx context.Context) ([]address.Address, error) {
+	var addrs []address.Address
+	if err := c.call(ctx, "WalletAddresses", &addrs); err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// WalletDefaultAddress returns the daemon's default wallet address.
+func (c *Client) WalletDefaultAddress(ctx context.Context) (address.Address, error) {
+	var addr address.Address
+	err := c.call(ctx, "WalletDefaultAddress", &addr)
+	return addr, err
+}
+
+// WalletNewAddress creates a new address in the daemon's wallet.
+func (c *Client) WalletNewAddress(ctx context.Context, protocol address.Protocol) (address.Address, error) {
+	var addr address.Address
+	err := c.call(ctx, "WalletNewAddress", &addr, protocol)
+	return addr, err
+}
+
+// WalletBalance returns the balance of an address.
+func (c *Client) WalletBalance(ctx context.Context, addr address.Address) (abi.TokenAmount, error) {
+	balance := abi.NewTokenAmount(0)
+	err := c.call(ctx, "WalletBalance", &balance, addr)
+	return balance, err
+}
+
+// MessageSend signs a message from a wallet address and publishes it.
+func (c *Client) MessageSend(ctx context.Context, msg *MessageSendParams) (cid.Cid, error) {
+	var id cid.Cid
+	err := c.call(ctx, "MessageSend", &id, msg)
+	return id, err
+}
+
+// MessageWait waits for a message to appear on chain.
+func (c *Client) MessageWait(ctx context.Context, msgCid cid.Cid, lookback uint64) (*MessageLookup, error) {
+	var lookup MessageLookup
+	if err := c.call(ctx, "MessageWait", &lookup, msgCid, lookback); err != nil {
+		return nil, err
+	}
+	return &lookup, nil
+}
+
+// ClientListDeals lists the storage deals made by the daemon as a client.
+func (c *Client) ClientListDeals(ctx context.Context) ([]storagemarket.ClientDeal, error) {
+	var deals []storagemarket.ClientDeal
+	if err := c.call(ctx, "ClientListDeals", &deals); err != nil {
+		return nil, err
+	}
+	return deals, nil
+}
+
+// ClientGetDeal returns a storage deal made by the daemon as a client.
+func (c *Client) ClientGetDeal(ctx context.Context, proposalCid cid.Cid) (*storagemarket.ClientDeal, error) {
+	var deal storagemarket.ClientDeal
+	if err := c.call(ctx, "ClientGetDeal", &deal, proposalCid); err != nil {
+		return nil, err
+	}
+	return &deal, nil
+}
+
+// ProviderListDeals lists the storage deals made with the daemon's miner.
+func (c *Client) ProviderListDeals(ctx context.Context) ([]storagemarket.MinerDeal, error) {
+	var deals []storagemarket.MinerDeal
+	if err := c.call(ctx, "ProviderListDeals", &deals); err != nil {
+		return nil, err
+	}
+	return deals, nil
+}
+
+// ProviderListAsks lists the asks the daemon's storage provider offers for a miner.
+func (c *Client) ProviderListAsks(ctx context.Context, miner address.Address) ([]*storagemarket.SignedStorageAsk, error) {
+	var asks []*storagemarket.SignedStorageAsk
+	if err := c.call(ctx, "ProviderListAsks", &asks, miner); err != nil {
+		return nil, err
+	}
+	return asks, nil
+}
+
+// JournalSubscribe returns a channel receiving journal entries selected by
+// the filter as they are recorded, until ctx is done.
+func (c *Client) JournalSubscribe(ctx context.Context, filter journal.Filter) (<-chan journal.Entry, error) {
+	values, err := c.rpc.Subscribe(ctx, Namespace+".JournalSubscribe", filter)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan journal.Entry)
+	go func() {
+		defer close(out)
+		for raw := range values {
+			var e journal.Entry
+			if err := json.Unmarshal(raw, &e); err != nil {
+				continue
+			}
+			select {
+			case out <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// NodeHealth runs the daemon's readiness checks.
+func (c *Client) NodeHealth(ctx context.Context) (*porcelain.NodeHealth, error) {
+	var health porcelain.NodeHealth
+	if err := c.call(ctx, "NodeHealth", &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
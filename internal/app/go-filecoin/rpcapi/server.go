@@ -0,0 +1,224 @@
+package rpcapi
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/jsonrpc"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+)
+
+// NewServer returns a JSON-RPC server for the FullNode API, accepting browser
+// requests from the same origins as the go-ipfs-cmds HTTP API. Requests
+// without an origin are accepted only by a local server.
+func NewServer(allowedOrigins []string, local bool, api *porcelain.API, storageAPI *storage.API) (*jsonrpc.Server, error) {
+	s := jsonrpc.NewServer(allowedOrigins, local)
+	if err := s.Register(Namespace, &fullNode{porcelain: api, storage: storageAPI}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// fullNode implements FullNode. Its exported methods are exactly those of the
+// API, as every one of them is served.
+type fullNode struct {
+	porcelain *porcelain.API
+	storage   *storage.API
+}
+
+var _ FullNode = (*fullNode)(nil)
+
+func (n *fullNode) ChainHead(ctx context.Context) (*TipSet, error) {
+	return n.ChainGetTipSet(ctx, n.porcelain.ChainHeadKey())
+}
+
+func (n *fullNode) ChainGetTipSet(_ context.Context, key block.TipSetKey) (*TipSet, error) {
+	ts, err := n.porcelain.ChainTipSet(key)
+	if err != nil {
+		return nil, err
+	}
+	return newTipSet(ts)
+}
+
+func (n *fullNode) ChainGetBlock(ctx context.Context, id cid.Cid) (*block.Block, error) {
+	return n.porcelain.ChainGetBlock(ctx, id)
+}
+
+func (n *fullNode) ChainGetMessages(ctx context.Context, metaCid cid.Cid) (*BlockMessages, error) {
+	bls, secp, err := n.porcelain.ChainGetMessages(ctx, metaCid)
+	if err != nil {
+		return nil, err
+	}
+	return &BlockMessages{BLSMessages: bls, SecpMessages: secp}, nil
+}
+
+func (n *fullNode) ChainGetReceipts(ctx context.Context, id cid.Cid) ([]vm.MessageReceipt, error) {
+	return n.porcelain.ChainGetReceipts(ctx, id)
+}
+
+func (n *fullNode) ChainNotify(ctx context.Context) (<-chan *TipSet, error) {
+	heads := n.porcelain.ChainNotify(ctx)
+	out := make(chan *TipSet)
+	go func() {
+		defer close(out)
+		for ts := range heads {
+			rts, err := newTipSet(ts)
+			if err != nil {
+				continue
+			}
+			select {
+			case out <- rts:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (n *fullNode) SyncStatus(_ context.Context) (*status.Status, error) {
+	st := n.porcelain.SyncerStatus()
+	return &st, nil
+}
+
+func (n *fullNode) StateGetActor(ctx context.Context, addr address.Address, key block.TipSetKey) (*actor.Actor, error) {
+	return n.porcelain.ActorGetAt(ctx, n.resolve(key), addr)
+}
+
+func (n *fullNode) StateNetworkName(ctx context.Context, key block.TipSetKey) (string, error) {
+	view, err := n.porcelain.StateView(n.resolve(key))
+	if err != nil {
+		return "", err
+	}
+	return view.InitNetworkName(ctx)
+}
+
+func (n *fullNode) StateMinerStatus(ctx context.Context, addr address.Address, key block.TipSetKey) (*porcelain.MinerStatus, error) {
+	st, err := n.porcelain.MinerGetStatus(ctx, addr, n.resolve(key))
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (n *fullNode) StateMarketDeal(ctx context.Context, dealID abi.DealID, key block.TipSetKey) (*MarketDeal, error) {
+	view, err := n.porcelain.StateView(n.resolve(key))
+	if err != nil {
+		return nil, err
+	}
+	proposal, err := view.MarketDealProposal(ctx, dealID)
+	if err != nil {
+		return nil, err
+	}
+	state, found, err := view.MarketDealState(ctx, dealID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		state = nil
+	}
+	return &MarketDeal{Proposal: proposal, State: state}, nil
+}
+
+func (n *fullNode) MpoolPending(_ context.Context) ([]*types.SignedMessage, error) {
+	return n.porcelain.MessagePoolPending(), nil
+}
+
+func (n *fullNode) MpoolPush(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error) {
+	if smsg == nil {
+		return cid.Undef, errors.New("missing message")
+	}
+	c, _, err := n.porcelain.SignedMessageSend(ctx, smsg)
+	return c, err
+}
+
+func (n *fullNode) WalletAddresses(_ context.Context) ([]address.Address, error) {
+	return n.porcelain.WalletAddresses(), nil
+}
+
+func (n *fullNode) WalletDefaultAddress(_ context.Context) (address.Address, error) {
+	return n.porcelain.WalletDefaultAddress()
+}
+
+func (n *fullNode) WalletNewAddress(_ context.Context, protocol address.Protocol) (address.Address, error) {
+	return n.porcelain.WalletNewAddress(protocol)
+}
+
+func (n *fullNode) WalletBalance(ctx context.Context, addr address.Address) (abi.TokenAmount, error) {
+	return n.porcelain.WalletBalance(ctx, addr)
+}
+
+func (n *fullNode) MessageSend(ctx context.Context, msg *MessageSendParams) (cid.Cid, error) {
+	if msg == nil {
+		return cid.Undef, errors.New("missing message")
+	}
+	c, _, err := n.porcelain.MessageSendEncoded(ctx, msg.From, msg.To, msg.Value, msg.GasPrice, msg.GasLimit, msg.Method, msg.Params)
+	return c, err
+}
+
+func (n *fullNode) MessageWait(ctx context.Context, msgCid cid.Cid, lookback uint64) (*MessageLookup, error) {
+	var lookup *MessageLookup
+	err := n.porcelain.MessageWait(ctx, msgCid, lookback, func(blk *block.Block, _ *types.SignedMessage, receipt *vm.MessageReceipt) error {
+		lookup = &MessageLookup{
+			Block:   blk.Cid(),
+			Height:  blk.Height,
+			Receipt: receipt,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if lookup == nil {
+		return nil, errors.Errorf("message %s was not found", msgCid)
+	}
+	return lookup, nil
+}
+
+func (n *fullNode) ClientListDeals(ctx context.Context) ([]storagemarket.ClientDeal, error) {
+	return n.storage.GetClientDeals(ctx)
+}
+
+func (n *fullNode) ClientGetDeal(ctx context.Context, proposalCid cid.Cid) (*storagemarket.ClientDeal, error) {
+	deal, err := n.storage.GetStorageDeal(ctx, proposalCid)
+	if err != nil {
+		return nil, err
+	}
+	return &deal, nil
+}
+
+func (n *fullNode) ProviderListDeals(ctx context.Context) ([]storagemarket.MinerDeal, error) {
+	return n.storage.GetProviderDeals(ctx)
+}
+
+func (n *fullNode) ProviderListAsks(_ context.Context, miner address.Address) ([]*storagemarket.SignedStorageAsk, error) {
+	return n.storage.ListAsks(miner)
+}
+
+func (n *fullNode) JournalSubscribe(ctx context.Context, filter journal.Filter) (<-chan journal.Entry, error) {
+	return n.porcelain.JournalSubscribe(ctx, filter)
+}
+
+func (n *fullNode) NodeHealth(ctx context.Context) (*porcelain.NodeHealth, error) {
+	return n.porcelain.NodeHealth(ctx)
+}
+
+// resolve selects the head for an empty tipset key.
+func (n *fullNode) resolve(key block.TipSetKey) block.TipSetKey {
+	if key.Empty() {
+		return n.porcelain.ChainHeadKey()
+	}
+	return key
+}
@@ -0,0 +1,61 @@
+package rpcapi
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/jsonrpc"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestServerServesFullNode(t *testing.T) {
+	tf.UnitTest(t)
+
+	s, err := NewServer(nil, true, nil, nil)
+	require.NoError(t, err)
+
+	var served []string
+	subscriptions := make(map[string]bool)
+	for _, m := range s.Schema().Methods {
+		served = append(served, m.Name)
+		subscriptions[m.Name] = m.Subscription
+	}
+
+	api := reflect.TypeOf((*FullNode)(nil)).Elem()
+	var expected []string
+	for i := 0; i < api.NumMethod(); i++ {
+		expected = append(expected, Namespace+"."+api.Method(i).Name)
+	}
+	assert.Equal(t, expected, served)
+	assert.True(t, subscriptions[Namespace+".ChainNotify"])
+	assert.True(t, subscriptions[Namespace+".JournalSubscribe"])
+	assert.False(t, subscriptions[Namespace+".ChainHead"])
+}
+
+func TestServerRejectsNullMessages(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	s, err := NewServer(nil, true, nil, nil)
+	require.NoError(t, err)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	c, err := jsonrpc.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	for _, method := range []string{"MpoolPush", "MessageSend"} {
+		var msgCid cid.Cid
+		err := c.Call(ctx, Namespace+"."+method, &msgCid, nil)
+		require.Error(t, err, method)
+		assert.Equal(t, "missing message", err.(*jsonrpc.Error).Message, method)
+	}
+}
@@ -328,6 +328,13 @@ func (bb *BlockBuilder) AddMessages(secpmsgs []*types.SignedMessage, blsMsgs []*
 	bb.block.Messages = e.NewCid(meta)
 }
 
+// SetReceipts sets the receipts, carried by the block, of the messages of its parent tipset.
+func (bb *BlockBuilder) SetReceipts(receipts []vm.MessageReceipt) {
+	c, err := bb.messages.StoreReceipts(context.Background(), receipts)
+	require.NoError(bb.t, err)
+	bb.block.MessageReceipts = e.NewCid(c)
+}
+
 // SetStateRoot sets the block's state root.
 func (bb *BlockBuilder) SetStateRoot(root cid.Cid) {
 	bb.block.StateRoot = e.NewCid(root)
@@ -0,0 +1,294 @@
+package chainreplay
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/consensus"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
+)
+
+var log = logging.Logger("chainreplay")
+
+// chainReader provides stored tipsets and the state and receipt roots
+// recorded for them when they were first validated.
+type chainReader interface {
+	GetHead() block.TipSetKey
+	GetTipSet(block.TipSetKey) (block.TipSet, error)
+	GetTipSetStateRoot(block.TipSetKey) (cid.Cid, error)
+	GetTipSetReceiptsRoot(block.TipSetKey) (cid.Cid, error)
+}
+
+type messageStore interface {
+	LoadMessages(context.Context, cid.Cid) ([]*types.SignedMessage, []*types.UnsignedMessage, error)
+	StoreReceipts(context.Context, []vm.MessageReceipt) (cid.Cid, error)
+}
+
+// stateTransitioner validates a tipset and computes the state resulting from
+// applying its messages.
+type stateTransitioner interface {
+	RunStateTransition(ctx context.Context, ts block.TipSet, blsMessages [][]*types.UnsignedMessage, secpMessages [][]*types.SignedMessage, parentWeight fbig.Int, stateID cid.Cid, receiptRoot cid.Cid) (cid.Cid, []vm.MessageReceipt, error)
+}
+
+type weigher interface {
+	Weight(ctx context.Context, ts block.TipSet, stRoot cid.Cid) (fbig.Int, error)
+}
+
+// Replayer re-runs the state transitions of tipsets already in the chain
+// store and checks the results against the stored state and receipt roots.
+type Replayer struct {
+	chain      chainReader
+	messages   messageStore
+	validator  stateTransitioner
+	weigher    weigher
+	stateStore cbor.IpldStore
+}
+
+// NewReplayer creates a new Replayer.
+func NewReplayer(chn chainReader, messages messageStore, validator stateTransitioner, weigher weigher, stateStore cbor.IpldStore) *Replayer {
+	return &Replayer{
+		chain:      chn,
+		messages:   messages,
+		validator:  validator,
+		weigher:    weigher,
+		stateStore: stateStore,
+	}
+}
+
+// Result summarizes the replay of a range of the chain.
+type Result struct {
+	From abi.ChainEpoch
+	To   abi.ChainEpoch
+	// Validated is the number of tipsets replayed without divergence.
+	Validated int
+	// Divergence describes the first tipset whose replay did not match the
+	// store, or is nil if the whole range matched.
+	Divergence *Divergence
+}
+
+// Divergence describes a tipset whose replayed state transition does not
+// match the stored one.
+type Divergence struct {
+	TipSet block.TipSetKey
+	Height abi.ChainEpoch
+	// Error is set if the state transition failed outright.
+	Error string `json:",omitempty"`
+
+	ExpectedStateRoot    cid.Cid
+	ComputedStateRoot    cid.Cid
+	ExpectedReceiptsRoot cid.Cid
+	ComputedReceiptsRoot cid.Cid
+
+	// StateDiff lists the actors whose state differs between the expected
+	// and computed state trees.
+	StateDiff []ActorDiff `json:",omitempty"`
+}
+
+// ActorDiff describes an actor that differs between two state trees. A nil
+// actor means the actor is absent from that tree.
+type ActorDiff struct {
+	Address  address.Address
+	Expected *actor.Actor
+	Computed *actor.Actor
+}
+
+// Replay re-validates every tipset with height in [from, to] on the chain
+// ending at the current head, in increasing height order. It stops at the
+// first divergence. The genesis tipset has no parent state and is skipped.
+func (r *Replayer) Replay(ctx context.Context, from, to abi.ChainEpoch) (*Result, error) {
+	if from > to {
+		return nil, fmt.Errorf("invalid range: from %d is after to %d", from, to)
+	}
+	if from < 1 {
+		from = 1
+	}
+
+	head, err := r.chain.GetTipSet(r.chain.GetHead())
+	if err != nil {
+		return nil, err
+	}
+	start, err := chain.FindTipsetAtEpoch(ctx, head, to, r.chain)
+	if err != nil {
+		return nil, err
+	}
+	tipsets, err := chain.CollectTipSetsOfHeightAtLeast(ctx, chain.IterAncestors(ctx, r.chain, start), from)
+	if err != nil {
+		return nil, err
+	}
+	chain.Reverse(tipsets)
+
+	result := &Result{From: from, To: to}
+	for _, ts := range tipsets {
+		div, err := r.replayOne(ctx, ts)
+		if err != nil {
+			return nil, err
+		}
+		if div != nil {
+			result.Divergence = div
+			return result, nil
+		}
+		result.Validated++
+	}
+	return result, nil
+}
+
+// replayOne re-runs the state transition for a single tipset, returning a
+// non-nil divergence if it does not reproduce the stored roots.
+func (r *Replayer) replayOne(ctx context.Context, ts block.TipSet) (*Divergence, error) {
+	height, err := ts.Height()
+	if err != nil {
+		return nil, err
+	}
+	parentKey, err := ts.Parents()
+	if err != nil {
+		return nil, err
+	}
+	parent, err := r.chain.GetTipSet(parentKey)
+	if err != nil {
+		return nil, err
+	}
+	parentStateRoot, err := r.chain.GetTipSetStateRoot(parentKey)
+	if err != nil {
+		return nil, err
+	}
+	parentReceiptRoot, err := r.chain.GetTipSetReceiptsRoot(parentKey)
+	if err != nil {
+		return nil, err
+	}
+	parentWeight, err := r.parentWeight(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+
+	var secpMessages [][]*types.SignedMessage
+	var blsMessages [][]*types.UnsignedMessage
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		secpMsgs, blsMsgs, err := r.messages.LoadMessages(ctx, blk.Messages.Cid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed loading message list %s for block %s", blk.Messages, blk.Cid())
+		}
+		secpMessages = append(secpMessages, secpMsgs)
+		blsMessages = append(blsMessages, blsMsgs)
+	}
+
+	div := &Divergence{
+		TipSet: ts.Key(),
+		Height: height,
+	}
+	div.ExpectedStateRoot, err = r.chain.GetTipSetStateRoot(ts.Key())
+	if err != nil {
+		return nil, err
+	}
+	div.ExpectedReceiptsRoot, err = r.chain.GetTipSetReceiptsRoot(ts.Key())
+	if err != nil {
+		return nil, err
+	}
+
+	// The tipset was validated when first synced; replaying it again must not
+	// count towards the metrics of blocks validated or rejected.
+	root, receipts, err := r.validator.RunStateTransition(consensus.WithoutValidationMetrics(ctx), ts, blsMessages, secpMessages, parentWeight, parentStateRoot, parentReceiptRoot)
+	if err != nil {
+		div.Error = err.Error()
+		return div, nil
+	}
+	div.ComputedStateRoot = root
+	div.ComputedReceiptsRoot, err = r.messages.StoreReceipts(ctx, receipts)
+	if err != nil {
+		return nil, err
+	}
+
+	if div.ComputedStateRoot.Equals(div.ExpectedStateRoot) && div.ComputedReceiptsRoot.Equals(div.ExpectedReceiptsRoot) {
+		log.Debugf("replayed tipset %s at height %d", ts.Key(), height)
+		return nil, nil
+	}
+
+	if !div.ComputedStateRoot.Equals(div.ExpectedStateRoot) {
+		div.StateDiff, err = r.diffStates(ctx, div.ExpectedStateRoot, div.ComputedStateRoot)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to diff state trees")
+		}
+	}
+	return div, nil
+}
+
+// parentWeight computes the weight of a tipset's parent in the same way as
+// the syncer does when validating it.
+func (r *Replayer) parentWeight(ctx context.Context, parent block.TipSet) (fbig.Int, error) {
+	grandParentKey, err := parent.Parents()
+	if err != nil {
+		return fbig.Zero(), err
+	}
+	var baseStateRoot cid.Cid
+	if grandParentKey.Empty() {
+		// use genesis state as parent of genesis block
+		baseStateRoot, err = r.chain.GetTipSetStateRoot(parent.Key())
+	} else {
+		baseStateRoot, err = r.chain.GetTipSetStateRoot(grandParentKey)
+	}
+	if err != nil {
+		return fbig.Zero(), err
+	}
+	return r.weigher.Weight(ctx, parent, baseStateRoot)
+}
+
+// diffStates lists the actors that differ between two state trees.
+func (r *Replayer) diffStates(ctx context.Context, expectedRoot, computedRoot cid.Cid) ([]ActorDiff, error) {
+	expected, err := r.loadActors(ctx, expectedRoot)
+	if err != nil {
+		return nil, err
+	}
+	computed, err := r.loadActors(ctx, computedRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []ActorDiff
+	for addr, exp := range expected {
+		comp, ok := computed[addr]
+		if !ok || !actorsEqual(exp, comp) {
+			diffs = append(diffs, ActorDiff{Address: addr, Expected: exp, Computed: comp})
+		}
+	}
+	for addr, comp := range computed {
+		if _, ok := expected[addr]; !ok {
+			diffs = append(diffs, ActorDiff{Address: addr, Computed: comp})
+		}
+	}
+	return diffs, nil
+}
+
+func (r *Replayer) loadActors(ctx context.Context, root cid.Cid) (map[address.Address]*actor.Actor, error) {
+	tree, err := state.LoadState(ctx, r.stateStore, root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load state tree %s", root)
+	}
+	actors := make(map[address.Address]*actor.Actor)
+	for res := range tree.GetAllActors(ctx) {
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		actors[res.Key] = res.Actor
+	}
+	return actors, nil
+}
+
+func actorsEqual(a, b *actor.Actor) bool {
+	return a.Code.Equals(b.Code.Cid) &&
+		a.Head.Equals(b.Head.Cid) &&
+		a.CallSeqNum == b.CallSeqNum &&
+		a.Balance.Equals(b.Balance)
+}
@@ -0,0 +1,100 @@
+package chainreplay_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chainreplay"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+)
+
+func TestReplayMatchingRange(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	head := builder.AppendManyOn(5, block.UndefTipSet)
+	chn := newFakeChain(t, builder, head)
+
+	replayer := chainreplay.NewReplayer(chn, builder, &fakeTransitioner{builder: builder}, &fakeWeigher{}, nil)
+	res, err := replayer.Replay(ctx, 0, 3)
+	require.NoError(t, err)
+	assert.Equal(t, abi.ChainEpoch(1), res.From)
+	assert.Equal(t, abi.ChainEpoch(3), res.To)
+	assert.Equal(t, 3, res.Validated)
+	assert.Nil(t, res.Divergence)
+
+	_, err = replayer.Replay(ctx, 3, 2)
+	assert.Error(t, err)
+}
+
+func TestReplayStopsAtFirstDivergence(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	bad := builder.AppendManyOn(3, block.UndefTipSet)
+	head := builder.AppendManyOn(2, bad)
+	chn := newFakeChain(t, builder, head)
+
+	transitioner := &fakeTransitioner{builder: builder, fail: bad.Key()}
+	replayer := chainreplay.NewReplayer(chn, builder, transitioner, &fakeWeigher{}, nil)
+	res, err := replayer.Replay(ctx, 1, 4)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Validated)
+	require.NotNil(t, res.Divergence)
+	assert.Equal(t, bad.Key(), res.Divergence.TipSet)
+	assert.Equal(t, abi.ChainEpoch(2), res.Divergence.Height)
+	assert.Equal(t, "boom", res.Divergence.Error)
+}
+
+// fakeChain adds a head and receipt roots to a chain builder. All tipsets
+// share the receipt root of an empty receipt list.
+type fakeChain struct {
+	*chain.Builder
+	head     block.TipSetKey
+	receipts cid.Cid
+}
+
+func newFakeChain(t *testing.T, builder *chain.Builder, head block.TipSet) *fakeChain {
+	receipts, err := builder.StoreReceipts(context.Background(), []vm.MessageReceipt{})
+	require.NoError(t, err)
+	return &fakeChain{Builder: builder, head: head.Key(), receipts: receipts}
+}
+
+func (f *fakeChain) GetHead() block.TipSetKey {
+	return f.head
+}
+
+func (f *fakeChain) GetTipSetReceiptsRoot(block.TipSetKey) (cid.Cid, error) {
+	return f.receipts, nil
+}
+
+// fakeTransitioner reproduces the builder's state, failing for one tipset.
+type fakeTransitioner struct {
+	builder *chain.Builder
+	fail    block.TipSetKey
+}
+
+func (f *fakeTransitioner) RunStateTransition(_ context.Context, ts block.TipSet, _ [][]*types.UnsignedMessage, _ [][]*types.SignedMessage, _ fbig.Int, _ cid.Cid, _ cid.Cid) (cid.Cid, []vm.MessageReceipt, error) {
+	if ts.Key().Equals(f.fail) {
+		return cid.Undef, nil, errors.New("boom")
+	}
+	return f.builder.StateForKey(ts.Key()), []vm.MessageReceipt{}, nil
+}
+
+type fakeWeigher struct{}
+
+func (fakeWeigher) Weight(context.Context, block.TipSet, cid.Cid) (fbig.Int, error) {
+	return fbig.Zero(), nil
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/internal/syncer"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/forkmon"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/slashing"
 )
 
@@ -25,11 +27,12 @@ type Manager struct {
 	syncer       *syncer.Syncer
 	dispatcher   *dispatcher.Dispatcher
 	transitionCh chan bool
+	forks        *forkmon.Monitor
 }
 
 // NewManager creates a new chain sync manager.
-func NewManager(fv syncer.FullBlockValidator, hv syncer.BlockValidator, cs syncer.ChainSelector, s syncer.ChainReaderWriter, m *chain.MessageStore, f syncer.Fetcher, c clock.Clock, detector *slashing.ConsensusFaultDetector) (Manager, error) {
-	syncer, err := syncer.NewSyncer(fv, hv, cs, s, m, f, status.NewReporter(), c, detector)
+func NewManager(fv syncer.FullBlockValidator, hv syncer.BlockValidator, cs syncer.ChainSelector, s syncer.ChainReaderWriter, m *chain.MessageStore, f syncer.Fetcher, c clock.Clock, detector *slashing.ConsensusFaultDetector, forks *forkmon.Monitor, jw journal.Writer) (Manager, error) {
+	syncer, err := syncer.NewSyncer(fv, hv, cs, s, m, f, status.NewReporter(), c, detector, forks, jw)
 	if err != nil {
 		return Manager{}, err
 	}
@@ -39,6 +42,7 @@ func NewManager(fv syncer.FullBlockValidator, hv syncer.BlockValidator, cs synce
 		syncer:       syncer,
 		dispatcher:   dispatcher,
 		transitionCh: gapTransitioner.TransitionChannel(),
+		forks:        forks,
 	}, nil
 }
 
@@ -62,3 +66,8 @@ func (m *Manager) TransitionChannel() chan bool {
 func (m *Manager) Status() status.Status {
 	return m.syncer.Status()
 }
+
+// Reorgs returns up to `limit` of the most recent reorgs observed while syncing.
+func (m *Manager) Reorgs(limit int) []*forkmon.Reorg {
+	return m.forks.Reorgs(limit)
+}
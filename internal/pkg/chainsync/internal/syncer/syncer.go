@@ -2,6 +2,7 @@ package syncer
 
 import (
 	"context"
+	"sync/atomic"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
@@ -17,6 +18,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chainsync/status"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics/tracing"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
@@ -63,6 +65,16 @@ type Syncer struct {
 
 	// Reporter is used by the syncer to update the current status of the chain.
 	reporter status.Reporter
+
+	// reorgs is notified whenever the staged head switches branches.
+	reorgs ReorgObserver
+
+	// journal records head changes and rejected chains.
+	journal journal.Writer
+
+	// bestKnownHeight is the highest head height announced by a peer. It is
+	// accessed atomically as tipsets may be handled concurrently.
+	bestKnownHeight int64
 }
 
 // Fetcher defines an interface that may be used to fetch data from the network.
@@ -121,6 +133,13 @@ type FullBlockValidator interface {
 	RunStateTransition(ctx context.Context, ts block.TipSet, blsMessages [][]*types.UnsignedMessage, secpMessages [][]*types.SignedMessage, parentWeight fbig.Int, stateID cid.Cid, receiptRoot cid.Cid) (cid.Cid, []vm.MessageReceipt, error)
 }
 
+// ReorgObserver is notified of reorgs of the staged head.
+type ReorgObserver interface {
+	// HandleReorg is called when the staged head switches from oldHead to
+	// newHead, which share commonAncestor as their latest common tipset.
+	HandleReorg(ctx context.Context, oldHead, newHead, commonAncestor block.TipSet) error
+}
+
 // faultDetector tracks data for detecting consensus faults and emits faults
 // upon detection.
 type faultDetector interface {
@@ -142,17 +161,25 @@ var (
 	ErrUnexpectedStoreState = errors.New("the chain store is in an unexpected state")
 )
 
-var syncOneTimer *metrics.Float64Timer
+var (
+	syncOneTimer   *metrics.Float64Timer
+	syncLagGauge   *metrics.Int64Gauge
+	syncedCnt      *metrics.Int64Counter
+	fetchFailedCnt *metrics.Int64Counter
+)
 
 func init() {
 	syncOneTimer = metrics.NewTimerMs("syncer/sync_one", "Duration of single tipset validation in milliseconds")
+	syncLagGauge = metrics.NewInt64Gauge("syncer/lag", "Number of epochs the staged head is behind the highest head announced by a peer")
+	syncedCnt = metrics.NewInt64Counter("syncer/tipsets_synced", "Number of tipsets validated and added to the chain store")
+	fetchFailedCnt = metrics.NewInt64Counter("syncer/fetch_failure", "Number of failures fetching a chain from a peer", metrics.PeerKey)
 }
 
 var logSyncer = logging.Logger("chainsync.syncer")
 
 // NewSyncer constructs a Syncer ready for use.  The chain reader must have a
 // head tipset to initialize the staging field.
-func NewSyncer(fv FullBlockValidator, hv BlockValidator, cs ChainSelector, s ChainReaderWriter, m messageStore, f Fetcher, sr status.Reporter, c clock.Clock, fd faultDetector) (*Syncer, error) {
+func NewSyncer(fv FullBlockValidator, hv BlockValidator, cs ChainSelector, s ChainReaderWriter, m messageStore, f Fetcher, sr status.Reporter, c clock.Clock, fd faultDetector, ro ReorgObserver, jw journal.Writer) (*Syncer, error) {
 	return &Syncer{
 		fetcher: f,
 		badTipSets: &BadTipSetCache{
@@ -166,6 +193,8 @@ func NewSyncer(fv FullBlockValidator, hv BlockValidator, cs ChainSelector, s Cha
 		clock:           c,
 		faultDetector:   fd,
 		reporter:        sr,
+		reorgs:          ro,
+		journal:         jw,
 	}, nil
 }
 
@@ -182,7 +211,15 @@ func (syncer *Syncer) InitStaged() error {
 
 // SetStagedHead sets the syncer's internal staged tipset to the chain's head.
 func (syncer *Syncer) SetStagedHead(ctx context.Context) error {
-	return syncer.chainStore.SetHead(ctx, syncer.staged)
+	changed := !syncer.chainStore.GetHead().Equals(syncer.staged.Key())
+	if err := syncer.chainStore.SetHead(ctx, syncer.staged); err != nil {
+		return err
+	}
+	if changed {
+		height, _ := syncer.staged.Height()
+		syncer.journal.Write("head-change", "head", syncer.staged.Key().String(), "height", height)
+	}
+	return nil
 }
 
 // fetchAndValidateHeaders fetches headers and runs semantic block validation
@@ -212,6 +249,7 @@ func (syncer *Syncer) fetchAndValidateHeaders(ctx context.Context, ci *block.Cha
 		return syncer.chainStore.HasTipSetAndState(ctx, parents), nil
 	})
 	if err != nil {
+		fetchFailedCnt.Inc(metrics.WithTag(ctx, metrics.PeerKey, ci.Sender.String()), 1)
 		return nil, err
 	}
 	// Fetcher returns chain in Traversal order, reverse it to height order
@@ -312,6 +350,7 @@ func (syncer *Syncer) syncOne(ctx context.Context, grandParent, parent, next blo
 	if err != nil {
 		return err
 	}
+	syncedCnt.Inc(ctx, 1)
 	logSyncer.Debugf("Successfully updated store with %s", next.String())
 	return nil
 }
@@ -385,6 +424,9 @@ func (syncer *Syncer) logReorg(ctx context.Context, curHead, newHead block.TipSe
 			).Infof("reorg")
 			logSyncer.Errorw("unexpected error from ReorgDiff during log", "error", err)
 		}
+		if err := syncer.reorgs.HandleReorg(ctx, curHead, newHead, commonAncestor); err != nil {
+			logSyncer.Errorw("failed to record reorg", "error", err)
+		}
 	}
 }
 
@@ -453,6 +495,9 @@ func (syncer *Syncer) widen(ctx context.Context, ts block.TipSet) (block.TipSet,
 // HandleNewTipSet validates and syncs the chain rooted at the provided tipset
 // to a chain store.  Iff catchup is false then the syncer will set the head.
 func (syncer *Syncer) HandleNewTipSet(ctx context.Context, ci *block.ChainInfo, catchup bool) error {
+	syncer.observeHeight(ci.Height)
+	defer syncer.reportLag(ctx)
+
 	err := syncer.handleNewTipSet(ctx, ci)
 	if err != nil {
 		return err
@@ -463,6 +508,33 @@ func (syncer *Syncer) HandleNewTipSet(ctx context.Context, ci *block.ChainInfo,
 	return syncer.SetStagedHead(ctx)
 }
 
+// observeHeight raises the best known height to a height announced by a peer.
+func (syncer *Syncer) observeHeight(height abi.ChainEpoch) {
+	for {
+		best := atomic.LoadInt64(&syncer.bestKnownHeight)
+		if int64(height) <= best || atomic.CompareAndSwapInt64(&syncer.bestKnownHeight, best, int64(height)) {
+			return
+		}
+	}
+}
+
+// reportLag records how far the staged head is behind the highest head
+// announced by a peer.
+func (syncer *Syncer) reportLag(ctx context.Context) {
+	if !syncer.staged.Defined() {
+		return
+	}
+	height, err := syncer.staged.Height()
+	if err != nil {
+		return
+	}
+	lag := abi.ChainEpoch(atomic.LoadInt64(&syncer.bestKnownHeight)) - height
+	if lag < 0 {
+		lag = 0
+	}
+	syncLagGauge.Set(ctx, int64(lag))
+}
+
 func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo) (err error) {
 	// handleNewTipSet extends the Syncer's chain store with the given tipset if
 	// the chain is a valid extension.  It stages new heaviest tipsets for later
@@ -510,6 +582,7 @@ func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo)
 		return syncer.chainStore.HasTipSetAndState(ctx, parentsKey), nil
 	})
 	if err != nil {
+		fetchFailedCnt.Inc(metrics.WithTag(ctx, metrics.PeerKey, ci.Sender.String()), 1)
 		return errors.Wrapf(err, "failure fetching full blocks")
 	}
 
@@ -558,6 +631,7 @@ func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo)
 				// there is no assumption that the running node's data is valid at all,
 				// so we don't really lose anything with this simplification.
 				syncer.badTipSets.AddChain(tipsets[i:])
+				syncer.journal.Write("bad-chain", "peer", ci.Sender.String(), "head", ci.Head.String(), "tipset", ts.Key().String(), "error", err)
 				return errors.Wrapf(err, "failed to sync tipset %s, number %d of %d in chain", ts.Key(), i, len(tipsets))
 			}
 		}
@@ -37,6 +37,9 @@ type APIConfig struct {
 	AccessControlAllowOrigin      []string `json:"accessControlAllowOrigin"`
 	AccessControlAllowCredentials bool     `json:"accessControlAllowCredentials"`
 	AccessControlAllowMethods     []string `json:"accessControlAllowMethods"`
+	// ReadinessMaxEpochLag is the number of epochs the node's head may trail
+	// the chain being synced while the node still reports itself ready.
+	ReadinessMaxEpochLag uint64 `json:"readinessMaxEpochLag"`
 }
 
 func newDefaultAPIConfig() *APIConfig {
@@ -49,6 +52,7 @@ func newDefaultAPIConfig() *APIConfig {
 			"https://127.0.0.1:8080",
 		},
 		AccessControlAllowMethods: []string{"GET", "POST", "PUT"},
+		ReadinessMaxEpochLag:      5,
 	}
 }
 
@@ -105,9 +109,10 @@ func newDefaultBootstrapConfig() *BootstrapConfig {
 
 // MiningConfig holds all configuration options related to mining.
 type MiningConfig struct {
-	MinerAddress            address.Address `json:"minerAddress"`
-	AutoSealIntervalSeconds uint            `json:"autoSealIntervalSeconds"`
-	StoragePrice            types.AttoFIL   `json:"storagePrice"`
+	MinerAddress            address.Address   `json:"minerAddress"`
+	AutoSealIntervalSeconds uint              `json:"autoSealIntervalSeconds"`
+	StoragePrice            types.AttoFIL     `json:"storagePrice"`
+	DealPolicy              *DealPolicyConfig `json:"dealPolicy"`
 }
 
 func newDefaultMiningConfig() *MiningConfig {
@@ -115,6 +120,49 @@ func newDefaultMiningConfig() *MiningConfig {
 		MinerAddress:            address.Undef,
 		AutoSealIntervalSeconds: 120,
 		StoragePrice:            types.ZeroAttoFIL,
+		DealPolicy:              newDefaultDealPolicyConfig(),
+	}
+}
+
+// DealPolicyConfig holds the rules a storage miner decides whether to accept a
+// storage deal matching its ask with. Zero values leave a rule out.
+type DealPolicyConfig struct {
+	// AllowedClients, if not empty, are the only clients deals are accepted from.
+	AllowedClients []address.Address `json:"allowedClients"`
+	// DeniedClients are clients deals are never accepted from.
+	DeniedClients []address.Address `json:"deniedClients"`
+	// MinPieceSize and MaxPieceSize bound the padded size in bytes of the
+	// pieces of accepted deals.
+	MinPieceSize uint64 `json:"minPieceSize"`
+	MaxPieceSize uint64 `json:"maxPieceSize"`
+	// MinDuration and MaxDuration bound the duration in epochs of accepted deals.
+	MinDuration uint64 `json:"minDuration"`
+	MaxDuration uint64 `json:"maxDuration"`
+	// MinStartDelay is the least number of epochs after the chain head an
+	// accepted deal may start, leaving time to transfer and seal its data.
+	MinStartDelay uint64 `json:"minStartDelay"`
+	// RejectVerified and RejectUnverified reject deals by whether they are made
+	// by verified clients.
+	RejectVerified   bool `json:"rejectVerified"`
+	RejectUnverified bool `json:"rejectUnverified"`
+	// MaxSealingDeals is the most deals accepted and not yet sealed at once.
+	MaxSealingDeals uint64 `json:"maxSealingDeals"`
+	// DecisionCommand is a command deals passing the other rules are given to
+	// as JSON on its standard input. The deal is accepted if the command exits
+	// successfully and rejected with its output as reason otherwise.
+	DecisionCommand string `json:"decisionCommand"`
+	// DecisionURL is an HTTP endpoint deals passing the other rules are posted
+	// to as JSON. It answers with a JSON object with the fields "accept" and
+	// "reason".
+	DecisionURL string `json:"decisionURL"`
+	// DecisionTimeout bounds how long the decision command or endpoint may take
+	// to decide, e.g. "30s".
+	DecisionTimeout string `json:"decisionTimeout"`
+}
+
+func newDefaultDealPolicyConfig() *DealPolicyConfig {
+	return &DealPolicyConfig{
+		DecisionTimeout: "30s",
 	}
 }
 
@@ -173,14 +221,18 @@ type HeartbeatConfig struct {
 
 // ObservabilityConfig is a container for configuration related to observables.
 type ObservabilityConfig struct {
-	Metrics *MetricsConfig `json:"metrics"`
-	Tracing *TraceConfig   `json:"tracing"`
+	Metrics     *MetricsConfig     `json:"metrics"`
+	Tracing     *TraceConfig       `json:"tracing"`
+	ForkMonitor *ForkMonitorConfig `json:"forkMonitor"`
+	Journal     *JournalConfig     `json:"journal"`
 }
 
 func newDefaultObservabilityConfig() *ObservabilityConfig {
 	return &ObservabilityConfig{
-		Metrics: newDefaultMetricsConfig(),
-		Tracing: newDefaultTraceConfig(),
+		Metrics:     newDefaultMetricsConfig(),
+		Tracing:     newDefaultTraceConfig(),
+		ForkMonitor: newDefaultForkMonitorConfig(),
+		Journal:     newDefaultJournalConfig(),
 	}
 }
 
@@ -221,6 +273,37 @@ func newDefaultTraceConfig() *TraceConfig {
 	}
 }
 
+// ForkMonitorConfig holds all configuration options related to recording chain reorgs.
+type ForkMonitorConfig struct {
+	// AlertDepth is the reorg depth in epochs at or above which a reorg is
+	// reported as an alert, logged and journaled. Zero disables alerts.
+	AlertDepth uint64 `json:"alertDepth"`
+	// HistorySize is the number of recent reorgs kept for querying.
+	HistorySize int `json:"historySize"`
+}
+
+func newDefaultForkMonitorConfig() *ForkMonitorConfig {
+	return &ForkMonitorConfig{
+		AlertDepth:  5,
+		HistorySize: 64,
+	}
+}
+
+// JournalConfig holds all configuration options related to the on-disk event journal.
+type JournalConfig struct {
+	// MaxFileSize is the size in bytes at which the journal file is rotated.
+	MaxFileSize int64 `json:"maxFileSize"`
+	// MaxFiles is the number of journal files kept, including the current one.
+	MaxFiles int `json:"maxFiles"`
+}
+
+func newDefaultJournalConfig() *JournalConfig {
+	return &JournalConfig{
+		MaxFileSize: 16 << 20,
+		MaxFiles:    8,
+	}
+}
+
 // MessagePoolConfig holds all configuration options related to nodes message pool (mpool).
 type MessagePoolConfig struct {
 	// MaxPoolSize is the maximum number of pending messages will will allow in the message pool at any time
@@ -264,12 +347,34 @@ type SectorBaseConfig struct {
 	// pre-sealed sector files and corresponding metadata JSON.
 	// If empty, it is assumed that no pre-sealed sectors exist.
 	PreSealedSectorsDirPath string `json:"preSealedSectorsDir"`
+
+	// StoragePaths are further directories holding sector data, such as
+	// directories on other disks. New sectors are placed in the directory with
+	// the most free space, weighted, among those allowed to hold them.
+	StoragePaths []*StoragePathConfig `json:"storagePaths"`
+}
+
+// StoragePathConfig holds the configuration of a directory holding sector data.
+type StoragePathConfig struct {
+	// Path is the absolute path to the directory.
+	Path string `json:"path"`
+	// Weight scales the free space of the directory when choosing where to
+	// place new sectors.
+	Weight uint64 `json:"weight"`
+	// MaxStorage, if not zero, is the most bytes of sector data placed in the
+	// directory.
+	MaxStorage uint64 `json:"maxStorage"`
+	// CanSeal allows sectors to be sealed in the directory.
+	CanSeal bool `json:"canSeal"`
+	// CanStore allows sealed sectors to be stored in the directory.
+	CanStore bool `json:"canStore"`
 }
 
 func newDefaultSectorbaseConfig() *SectorBaseConfig {
 	return &SectorBaseConfig{
 		RootDirPath:             "",
 		PreSealedSectorsDirPath: "",
+		StoragePaths:            []*StoragePathConfig{},
 	}
 }
 
@@ -114,13 +114,15 @@ func (dv *DefaultBlockValidator) TimeMatchesEpoch(b *block.Block) error {
 // ValidateHeaderSemantic checks validation conditions on a header that can be
 // checked given only the parent header.
 func (dv *DefaultBlockValidator) ValidateHeaderSemantic(ctx context.Context, child *block.Block, parents block.TipSet) error {
+	defer timeStage(ctx, "header")()
+
 	ph, err := parents.Height()
 	if err != nil {
 		return err
 	}
 
 	if child.Height <= ph {
-		return fmt.Errorf("block %s has invalid height %d", child.Cid().String(), child.Height)
+		return rejectBlock(ctx, "height", fmt.Errorf("block %s has invalid height %d", child.Cid().String(), child.Height))
 	}
 
 	return nil
@@ -128,6 +130,8 @@ func (dv *DefaultBlockValidator) ValidateHeaderSemantic(ctx context.Context, chi
 
 // ValidateFullSemantic checks validation conditions on a block's messages that don't require message execution.
 func (dv *DefaultBlockValidator) ValidateMessagesSemantic(ctx context.Context, child *block.Block, parents block.TipSetKey) error {
+	defer timeStage(ctx, "message_semantics")()
+
 	// validate call sequence numbers
 	secpMsgs, blsMsgs, err := dv.ms.LoadMessages(ctx, child.Messages.Cid)
 	if err != nil {
@@ -143,12 +147,12 @@ func (dv *DefaultBlockValidator) ValidateMessagesSemantic(ctx context.Context, c
 
 		from, err := dv.getAndValidateFromActor(ctx, msg, parents)
 		if err != nil {
-			return errors.Wrapf(err, "from actor %s for message %s of block %s invalid", msg.From, msgCid, child.Cid())
+			return rejectBlock(ctx, "message_sender", errors.Wrapf(err, "from actor %s for message %s of block %s invalid", msg.From, msgCid, child.Cid()))
 		}
 
 		err = dv.validateMessage(msg, expectedCallSeqNum, from)
 		if err != nil {
-			return errors.Wrapf(err, "message %s of block %s invalid", msgCid, child.Cid())
+			return rejectBlock(ctx, "message_nonce", errors.Wrapf(err, "message %s of block %s invalid", msgCid, child.Cid()))
 		}
 	}
 
@@ -160,12 +164,12 @@ func (dv *DefaultBlockValidator) ValidateMessagesSemantic(ctx context.Context, c
 
 		from, err := dv.getAndValidateFromActor(ctx, &msg.Message, parents)
 		if err != nil {
-			return errors.Wrapf(err, "from actor %s for message %s of block %s invalid", msg.Message.From, msgCid, child.Cid())
+			return rejectBlock(ctx, "message_sender", errors.Wrapf(err, "from actor %s for message %s of block %s invalid", msg.Message.From, msgCid, child.Cid()))
 		}
 
 		err = dv.validateMessage(&msg.Message, expectedCallSeqNum, from)
 		if err != nil {
-			return errors.Wrapf(err, "message %s of block %s invalid", msgCid, child.Cid())
+			return rejectBlock(ctx, "message_nonce", errors.Wrapf(err, "message %s of block %s invalid", msgCid, child.Cid()))
 		}
 	}
 
@@ -208,25 +212,27 @@ func (dv *DefaultBlockValidator) ValidateSyntax(ctx context.Context, blk *block.
 	if blk.Height == 0 {
 		return nil
 	}
+	defer timeStage(ctx, "syntax")()
+
 	err := dv.NotFutureBlock(blk)
 	if err != nil {
-		return err
+		return rejectBlock(ctx, "future_block", err)
 	}
 	err = dv.TimeMatchesEpoch(blk)
 	if err != nil {
-		return err
+		return rejectBlock(ctx, "epoch_time", err)
 	}
 	if !blk.StateRoot.Defined() {
-		return fmt.Errorf("block %s has nil StateRoot", blk.Cid())
+		return rejectBlock(ctx, "syntax", fmt.Errorf("block %s has nil StateRoot", blk.Cid()))
 	}
 	if blk.Miner.Empty() {
-		return fmt.Errorf("block %s has nil miner address", blk.Cid())
+		return rejectBlock(ctx, "syntax", fmt.Errorf("block %s has nil miner address", blk.Cid()))
 	}
 	if len(blk.Ticket.VRFProof) == 0 {
-		return fmt.Errorf("block %s has nil ticket", blk.Cid())
+		return rejectBlock(ctx, "syntax", fmt.Errorf("block %s has nil ticket", blk.Cid()))
 	}
 	if blk.BlockSig == nil {
-		return fmt.Errorf("block %s has nil signature", blk.Cid())
+		return rejectBlock(ctx, "syntax", fmt.Errorf("block %s has nil signature", blk.Cid()))
 	}
 
 	//TODO: validate all the messages syntax
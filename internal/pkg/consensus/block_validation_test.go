@@ -11,6 +11,7 @@ import (
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
@@ -50,6 +51,29 @@ func TestBlockValidHeaderSemantic(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "invalid height")
 	})
+
+	t.Run("blocks rejected without validation metrics are not counted", func(t *testing.T) {
+		c := &block.Block{Height: 2, Timestamp: uint64(ts.Add(blockTime).Unix())}
+		parents := consensus.RequireNewTipSet(require.New(t), &block.Block{Height: 2, Timestamp: uint64(ts.Unix())})
+
+		require.Error(t, validator.ValidateHeaderSemantic(ctx, c, parents))
+		rejected := rejectedBlocks(t)
+		require.Error(t, validator.ValidateHeaderSemantic(consensus.WithoutValidationMetrics(ctx), c, parents))
+		assert.Equal(t, rejected, rejectedBlocks(t))
+		require.Error(t, validator.ValidateHeaderSemantic(ctx, c, parents))
+		assert.Equal(t, rejected+1, rejectedBlocks(t))
+	})
+}
+
+// rejectedBlocks reads the number of blocks rejected by validation so far.
+func rejectedBlocks(t *testing.T) int64 {
+	rows, err := view.RetrieveData("consensus/rejected_block")
+	require.NoError(t, err)
+	var count int64
+	for _, row := range rows {
+		count += row.Data.(*view.CountData).Value
+	}
+	return count
 }
 
 func TestBlockValidMessageSemantic(t *testing.T) {
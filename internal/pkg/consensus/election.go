@@ -30,6 +30,12 @@ type EPoStVerifier interface {
 	GenerateWinningPoStSectorChallenge(ctx context.Context, proofType abi.RegisteredProof, minerID abi.ActorID, randomness abi.PoStRandomness, eligibleSectorCount uint64) ([]uint64, error)
 }
 
+// winningPoStChallenger generates the indexes of the sectors challenged by a
+// Winning PoSt. PoSt generators without one use the challenges of ffi.
+type winningPoStChallenger interface {
+	GenerateWinningPoStSectorChallenge(ctx context.Context, proofType abi.RegisteredProof, minerID abi.ActorID, randomness abi.PoStRandomness, eligibleSectorCount uint64) ([]uint64, error)
+}
+
 type SectorsStateView interface {
 	MinerSectorConfiguration(ctx context.Context, maddr address.Address) (*state.MinerSectorConfiguration, error)
 	MinerSectorStates(ctx context.Context, maddr address.Address) (*state.MinerSectorStates, error)
@@ -77,7 +83,11 @@ func (em ElectionMachine) GenerateWinningPoSt(ctx context.Context, entry *drand.
 	}
 	minerID := abi.ActorID(minerIDuint64)
 
-	challengedSectorInfos, err := computeWinningPoStSectorChallenges(ctx, sectors, maddr, poStRandomness)
+	var challenger winningPoStChallenger = ffiwrapper.ProofVerifier
+	if c, ok := ep.(winningPoStChallenger); ok {
+		challenger = c
+	}
+	challengedSectorInfos, err := computeWinningPoStSectorChallenges(ctx, challenger, sectors, maddr, poStRandomness)
 	if err != nil {
 		return nil, err
 	}
@@ -138,7 +148,7 @@ func (em ElectionMachine) VerifyWinningPoSt(ctx context.Context, ep EPoStVerifie
 	}
 	minerID := abi.ActorID(minerIDuint64)
 
-	challengedSectorInfos, err := computeWinningPoStSectorChallenges(ctx, sectors, mIDAddr, poStRandomness)
+	challengedSectorInfos, err := computeWinningPoStSectorChallenges(ctx, ep, sectors, mIDAddr, poStRandomness)
 	if err != nil {
 		return false, err
 	}
@@ -161,7 +171,7 @@ func (em ElectionMachine) VerifyWinningPoSt(ctx context.Context, ep EPoStVerifie
 }
 
 // Loads infos for sectors challenged by a Winning PoSt.
-func computeWinningPoStSectorChallenges(ctx context.Context, sectors SectorsStateView, mIDAddr address.Address, poStRandomness abi.PoStRandomness) ([]abi.SectorInfo, error) {
+func computeWinningPoStSectorChallenges(ctx context.Context, challenger winningPoStChallenger, sectors SectorsStateView, mIDAddr address.Address, poStRandomness abi.PoStRandomness) ([]abi.SectorInfo, error) {
 	provingSet, err := computeProvingSet(ctx, sectors, mIDAddr)
 	if err != nil {
 		return nil, err
@@ -186,7 +196,7 @@ func computeWinningPoStSectorChallenges(ctx context.Context, sectors SectorsStat
 	}
 	minerID := abi.ActorID(minerIDuint64)
 
-	challengeIndexes, err := ffiwrapper.ProofVerifier.GenerateWinningPoStSectorChallenge(ctx, rp, minerID, poStRandomness, sectorCount)
+	challengeIndexes, err := challenger.GenerateWinningPoStSectorChallenge(ctx, rp, minerID, poStRandomness, sectorCount)
 	if err != nil {
 		return nil, err
 	}
@@ -19,6 +19,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/drand"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics/tracing"
 	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
@@ -35,6 +36,45 @@ var (
 	ErrReceiptRootMismatch = errors.New("blocks receipt root does not match parent tip set")
 )
 
+var (
+	validationTimer  = metrics.NewTimerMs("consensus/validation", "Duration of a block validation stage in milliseconds", metrics.StageKey)
+	rejectedBlockCnt = metrics.NewInt64Counter("consensus/rejected_block", "Number of blocks rejected by validation", metrics.ReasonKey)
+)
+
+type noMetricsKey struct{}
+
+// WithoutValidationMetrics returns a copy of ctx under which validation records
+// no metrics, for re-validating blocks the node has already accepted, such as
+// when replaying the stored chain.
+func WithoutValidationMetrics(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noMetricsKey{}, true)
+}
+
+func recordsMetrics(ctx context.Context) bool {
+	return ctx.Value(noMetricsKey{}) == nil
+}
+
+// timeStage starts timing a validation stage. Calling the returned function
+// records the stage's duration.
+func timeStage(ctx context.Context, stage string) func() {
+	if !recordsMetrics(ctx) {
+		return func() {}
+	}
+	ctx = metrics.WithTag(ctx, metrics.StageKey, stage)
+	sw := validationTimer.Start(ctx)
+	return func() { sw.Stop(ctx) }
+}
+
+// rejectBlock counts a block rejected by validation for `reason` and returns
+// the validation error.
+func rejectBlock(ctx context.Context, reason string, err error) error {
+	if !recordsMetrics(ctx) {
+		return err
+	}
+	rejectedBlockCnt.Inc(metrics.WithTag(ctx, metrics.ReasonKey, reason), 1)
+	return err
+}
+
 // challengeBits is the number of bits in the challenge ticket's domain
 const challengeBits = 256
 
@@ -151,7 +191,10 @@ func (c *Expected) RunStateTransition(ctx context.Context, ts block.TipSet, blsM
 	span.AddAttributes(trace.StringAttribute("tipset", ts.String()))
 	defer tracing.AddErrorEndSpan(ctx, span, &err)
 
-	if err := c.validateMining(ctx, ts, parentStateRoot, blsMessages, secpMessages, parentWeight, parentReceiptRoot); err != nil {
+	stopMiningStage := timeStage(ctx, "mining")
+	err = c.validateMining(ctx, ts, parentStateRoot, blsMessages, secpMessages, parentWeight, parentReceiptRoot)
+	stopMiningStage()
+	if err != nil {
 		return cid.Undef, []vm.MessageReceipt{}, err
 	}
 
@@ -161,9 +204,11 @@ func (c *Expected) RunStateTransition(ctx context.Context, ts block.TipSet, blsM
 	}
 	vms := vm.NewStorage(c.bstore)
 	var newState state.Tree
+	stopMessagesStage := timeStage(ctx, "messages")
 	newState, receipts, err = c.runMessages(ctx, priorState, vms, ts, blsMessages, secpMessages)
+	stopMessagesStage()
 	if err != nil {
-		return cid.Undef, []vm.MessageReceipt{}, err
+		return cid.Undef, []vm.MessageReceipt{}, rejectBlock(ctx, "messages", err)
 	}
 	err = vms.Flush()
 	if err != nil {
@@ -223,20 +268,20 @@ func (c *Expected) validateMining(ctx context.Context,
 
 		// confirm block state root matches parent state root
 		if !parentStateRoot.Equals(blk.StateRoot.Cid) {
-			return ErrStateRootMismatch
+			return rejectBlock(ctx, "state_root", ErrStateRootMismatch)
 		}
 
 		// confirm block receipts match parent receipts
 		if !parentReceiptRoot.Equals(blk.MessageReceipts.Cid) {
-			return ErrReceiptRootMismatch
+			return rejectBlock(ctx, "receipt_root", ErrReceiptRootMismatch)
 		}
 
 		if !parentWeight.Equals(blk.ParentWeight) {
-			return errors.Errorf("block %s has invalid parent weight %d expected %d", blk.Cid().String(), blk.ParentWeight, parentWeight)
+			return rejectBlock(ctx, "parent_weight", errors.Errorf("block %s has invalid parent weight %d expected %d", blk.Cid().String(), blk.ParentWeight, parentWeight))
 		}
 		workerAddr, err := keyPowerTable.WorkerAddr(ctx, blk.Miner)
 		if err != nil {
-			return errors.Wrap(err, "failed to read worker address of block miner")
+			return rejectBlock(ctx, "miner", errors.Wrap(err, "failed to read worker address of block miner"))
 		}
 		workerSignerAddr, err := keyPowerTable.SignerAddress(ctx, workerAddr)
 		if err != nil {
@@ -244,27 +289,27 @@ func (c *Expected) validateMining(ctx context.Context,
 		}
 		// Validate block signature
 		if blk.BlockSig == nil {
-			return errors.Errorf("invalid nil block signature")
+			return rejectBlock(ctx, "block_signature", errors.Errorf("invalid nil block signature"))
 		}
 		if err := crypto.ValidateSignature(blk.SignatureData(), workerSignerAddr, *blk.BlockSig); err != nil {
-			return errors.Wrap(err, "block signature invalid")
+			return rejectBlock(ctx, "block_signature", errors.Wrap(err, "block signature invalid"))
 		}
 
 		// Verify that the BLS signature aggregate is correct
 		if err := sigValidator.ValidateBLSMessageAggregate(ctx, blsMsgs[i], blk.BLSAggregateSig); err != nil {
-			return errors.Wrapf(err, "bls message verification failed for block %s", blk.Cid())
+			return rejectBlock(ctx, "message_signature", errors.Wrapf(err, "bls message verification failed for block %s", blk.Cid()))
 		}
 
 		// Verify that all secp message signatures are correct
 		for i, msg := range secpMsgs[i] {
 			if err := sigValidator.ValidateMessageSignature(ctx, msg); err != nil {
-				return errors.Wrapf(err, "invalid signature for secp message %d in block %s", i, blk.Cid())
+				return rejectBlock(ctx, "message_signature", errors.Wrapf(err, "invalid signature for secp message %d in block %s", i, blk.Cid()))
 			}
 		}
 
 		err = c.validateDRANDEntries(ctx, blk)
 		if err != nil {
-			return errors.Wrapf(err, "invalid DRAND entries")
+			return rejectBlock(ctx, "beacon", errors.Wrapf(err, "invalid DRAND entries"))
 		}
 
 		electionEntry, err := c.electionEntry(ctx, blk)
@@ -273,7 +318,7 @@ func (c *Expected) validateMining(ctx context.Context,
 		}
 		err = c.VerifyElectionProof(ctx, electionEntry, blk.Height, blk.Miner, workerSignerAddr, blk.ElectionProof.VRFProof)
 		if err != nil {
-			return errors.Wrapf(err, "failed to verify election proof")
+			return rejectBlock(ctx, "election_proof", errors.Wrapf(err, "failed to verify election proof"))
 		}
 		// TODO this is not using nominal power, which must take into account undeclared faults
 		// TODO the nominal power must be tested against the minimum (power.minerNominalPowerMeetsConsensusMinimum)
@@ -289,22 +334,22 @@ func (c *Expected) validateMining(ctx context.Context,
 		electionVRFDigest := blk.ElectionProof.VRFProof.Digest()
 		wins := c.IsWinner(electionVRFDigest[:], minerPower, networkPower)
 		if !wins {
-			return errors.Errorf("Block did not win election")
+			return rejectBlock(ctx, "election", errors.Errorf("Block did not win election"))
 		}
 
 		valid, err := c.VerifyWinningPoSt(ctx, c.postVerifier, electionEntry, blk.Height, blk.PoStProofs, blk.Miner, sectorSetStateView)
 		if err != nil {
-			return errors.Wrapf(err, "failed verifying winning post")
+			return rejectBlock(ctx, "winning_post", errors.Wrapf(err, "failed verifying winning post"))
 		}
 		if !valid {
-			return errors.Errorf("Invalid winning post")
+			return rejectBlock(ctx, "winning_post", errors.Errorf("Invalid winning post"))
 		}
 
 		// Ticket was correctly generated by miner
 		sampleEpoch := blk.Height - miner.ElectionLookback
 		newPeriod := len(blk.BeaconEntries) > 0
 		if err := c.IsValidTicket(ctx, blk.Parents, electionEntry, newPeriod, sampleEpoch, blk.Miner, workerSignerAddr, blk.Ticket); err != nil {
-			return errors.Wrapf(err, "invalid ticket: %s in block %s", blk.Ticket.String(), blk.Cid())
+			return rejectBlock(ctx, "ticket", errors.Wrapf(err, "invalid ticket: %s in block %s", blk.Ticket.String(), blk.Cid()))
 		}
 	}
 	return nil
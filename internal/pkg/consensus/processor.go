@@ -2,17 +2,29 @@ package consensus
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/crypto"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
 	"go.opencensus.io/trace"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics/tracing"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/state"
 )
 
+var (
+	applyTipSetTimer = metrics.NewTimerMs("vm/apply_tipset", "Duration of applying a tipset's messages in milliseconds")
+	tipSetGasDist    = metrics.NewInt64Distribution("vm/tipset_gas_used", "Gas used by the messages of a tipset", tipSetGasBounds)
+	msgFailedCnt     = metrics.NewInt64Counter("vm/message_failure", "Number of messages applied with a non-zero exit code", metrics.ExitCodeKey)
+)
+
+// tipSetGasBounds bucket gas used per tipset on a log scale.
+var tipSetGasBounds = []float64{1e5, 1e6, 1e7, 1e8, 1e9, 1e10}
+
 // ApplicationResult contains the result of successfully applying one message.
 // ExecutionError might be set and the message can still be applied successfully.
 // See ApplyMessage() for details.
@@ -79,7 +91,22 @@ func (p *DefaultProcessor) ProcessTipSet(ctx context.Context, st state.Tree, vms
 	}
 	v := vm.NewVM(st, &vms, p.syscalls)
 
-	return v.ApplyTipSetMessages(msgs, parent, epoch, &rnd)
+	stopwatch := applyTipSetTimer.Start(ctx)
+	results, err = v.ApplyTipSetMessages(msgs, parent, epoch, &rnd)
+	stopwatch.Stop(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var gasUsed int64
+	for _, r := range results {
+		gasUsed += int64(r.GasUsed)
+		if r.ExitCode != exitcode.Ok {
+			msgFailedCnt.Inc(metrics.WithTag(ctx, metrics.ExitCodeKey, strconv.FormatInt(int64(r.ExitCode), 10)), 1)
+		}
+	}
+	tipSetGasDist.Record(ctx, gasUsed)
+	return results, nil
 }
 
 // A chain randomness source with a fixed head tipset key.
@@ -19,6 +19,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/cborutil"
 	e "github.com/filecoin-project/go-filecoin/internal/pkg/enccid"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/encoding"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 )
 
@@ -66,6 +67,8 @@ type HelloProtocolHandler struct {
 	getHeaviestTipSet getTipSetFunc
 
 	networkName string
+
+	journal journal.Writer
 }
 
 type peerDiscoveredCallback func(ci *block.ChainInfo)
@@ -73,12 +76,13 @@ type peerDiscoveredCallback func(ci *block.ChainInfo)
 type getTipSetFunc func() (block.TipSet, error)
 
 // NewHelloProtocolHandler creates a new instance of the hello protocol `Handler` and registers it to
-// the given `host.Host`.
-func NewHelloProtocolHandler(h host.Host, gen cid.Cid, networkName string) *HelloProtocolHandler {
+// the given `host.Host`. Peers disconnected for a bad genesis are recorded in the journal.
+func NewHelloProtocolHandler(h host.Host, gen cid.Cid, networkName string, jw journal.Writer) *HelloProtocolHandler {
 	return &HelloProtocolHandler{
 		host:        h,
 		genesis:     gen,
 		networkName: networkName,
+		journal:     jw,
 	}
 }
 
@@ -121,6 +125,7 @@ func (h *HelloProtocolHandler) handleNewStream(s net.Stream) {
 	case err == ErrBadGenesis:
 		log.Debugf("peer genesis cid: %s does not match ours: %s, disconnecting from peer: %s", &hello.GenesisHash, h.genesis, from)
 		genesisErrCt.Inc(context.Background(), 1)
+		h.journal.Write("peer-ban", "peer", from.String(), "reason", "bad genesis", "genesis", hello.GenesisHash.String())
 		_ = s.Conn().Close()
 		return
 	default:
@@ -2,6 +2,7 @@ package discovery_test
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/discovery"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	th "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers"
 	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
 )
@@ -36,6 +38,24 @@ func (mhg *mockHeaviestGetter) getHeaviestTipSet() (block.TipSet, error) {
 	return mhg.heaviest, nil
 }
 
+// recordingWriter records the events written to it.
+type recordingWriter struct {
+	lk     sync.Mutex
+	events []string
+}
+
+func (w *recordingWriter) Write(event string, _ ...interface{}) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	w.events = append(w.events, event)
+}
+
+func (w *recordingWriter) recorded() []string {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	return append([]string{}, w.events...)
+}
+
 func TestHelloHandshake(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -56,8 +76,8 @@ func TestHelloHandshake(t *testing.T) {
 	msc1, msc2 := new(mockHelloCallback), new(mockHelloCallback)
 	hg1, hg2 := &mockHeaviestGetter{heavy1}, &mockHeaviestGetter{heavy2}
 
-	discovery.NewHelloProtocolHandler(a, genesisA.Cid(), "").Register(msc1.HelloCallback, hg1.getHeaviestTipSet)
-	discovery.NewHelloProtocolHandler(b, genesisA.Cid(), "").Register(msc2.HelloCallback, hg2.getHeaviestTipSet)
+	discovery.NewHelloProtocolHandler(a, genesisA.Cid(), "", journal.NewNoopJournal().Topic("discovery")).Register(msc1.HelloCallback, hg1.getHeaviestTipSet)
+	discovery.NewHelloProtocolHandler(b, genesisA.Cid(), "", journal.NewNoopJournal().Topic("discovery")).Register(msc2.HelloCallback, hg2.getHeaviestTipSet)
 
 	msc1.On("HelloCallback", b.ID(), heavy2.Key(), abi.ChainEpoch(3)).Return()
 	msc2.On("HelloCallback", a.ID(), heavy1.Key(), abi.ChainEpoch(2)).Return()
@@ -112,8 +132,9 @@ func TestHelloBadGenesis(t *testing.T) {
 	msc1, msc2 := new(mockHelloCallback), new(mockHelloCallback)
 	hg1, hg2 := &mockHeaviestGetter{heavy1}, &mockHeaviestGetter{heavy2}
 
-	discovery.NewHelloProtocolHandler(a, genesisA.Cid(), "").Register(msc1.HelloCallback, hg1.getHeaviestTipSet)
-	discovery.NewHelloProtocolHandler(b, genesisB.Cid(), "").Register(msc2.HelloCallback, hg2.getHeaviestTipSet)
+	jw := &recordingWriter{}
+	discovery.NewHelloProtocolHandler(a, genesisA.Cid(), "", jw).Register(msc1.HelloCallback, hg1.getHeaviestTipSet)
+	discovery.NewHelloProtocolHandler(b, genesisB.Cid(), "", jw).Register(msc2.HelloCallback, hg2.getHeaviestTipSet)
 
 	msc1.On("HelloCallback", mock.Anything, mock.Anything, mock.Anything).Return()
 	msc2.On("HelloCallback", mock.Anything, mock.Anything, mock.Anything).Return()
@@ -125,6 +146,7 @@ func TestHelloBadGenesis(t *testing.T) {
 
 	msc1.AssertNumberOfCalls(t, "HelloCallback", 0)
 	msc2.AssertNumberOfCalls(t, "HelloCallback", 0)
+	assert.Contains(t, jw.recorded(), "peer-ban")
 }
 
 func TestHelloMultiBlock(t *testing.T) {
@@ -151,8 +173,8 @@ func TestHelloMultiBlock(t *testing.T) {
 	msc1, msc2 := new(mockHelloCallback), new(mockHelloCallback)
 	hg1, hg2 := &mockHeaviestGetter{heavy1}, &mockHeaviestGetter{heavy2}
 
-	discovery.NewHelloProtocolHandler(a, genesisTipset.At(0).Cid(), "").Register(msc1.HelloCallback, hg1.getHeaviestTipSet)
-	discovery.NewHelloProtocolHandler(b, genesisTipset.At(0).Cid(), "").Register(msc2.HelloCallback, hg2.getHeaviestTipSet)
+	discovery.NewHelloProtocolHandler(a, genesisTipset.At(0).Cid(), "", journal.NewNoopJournal().Topic("discovery")).Register(msc1.HelloCallback, hg1.getHeaviestTipSet)
+	discovery.NewHelloProtocolHandler(b, genesisTipset.At(0).Cid(), "", journal.NewNoopJournal().Topic("discovery")).Register(msc2.HelloCallback, hg2.getHeaviestTipSet)
 
 	msc1.On("HelloCallback", b.ID(), heavy2.Key(), abi.ChainEpoch(3)).Return()
 	msc2.On("HelloCallback", a.ID(), heavy1.Key(), abi.ChainEpoch(2)).Return()
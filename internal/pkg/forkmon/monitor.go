@@ -0,0 +1,260 @@
+package forkmon
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+)
+
+var log = logging.Logger("forkmon")
+
+var (
+	reorgDepthGauge *metrics.Int64Gauge
+	deepReorgCnt    *metrics.Int64Counter
+	orphanedCnt     *metrics.Int64Counter
+	revertedMsgCnt  *metrics.Int64Counter
+)
+
+func init() {
+	reorgDepthGauge = metrics.NewInt64Gauge("chain/reorg_depth", "The depth of the most recent reorg.")
+	deepReorgCnt = metrics.NewInt64Counter("chain/deep_reorg_count", "The number of reorgs at or above the alert depth.")
+	orphanedCnt = metrics.NewInt64SumCounter("chain/orphaned_tipset_count", "The number of tipsets dropped from the head chain by reorgs.")
+	revertedMsgCnt = metrics.NewInt64SumCounter("chain/reverted_message_count", "The number of messages reverted by reorgs and not re-included.")
+}
+
+// DefaultHistorySize is the number of reorgs retained when no size is configured.
+const DefaultHistorySize = 64
+
+// Reorg records a single switch of the head from one branch to another.
+type Reorg struct {
+	// Time is when the reorg was observed.
+	Time time.Time
+	// OldHead and NewHead are the heads before and after the switch.
+	OldHead block.TipSetKey
+	NewHead block.TipSetKey
+	// CommonAncestor is the latest tipset shared by both branches.
+	CommonAncestor block.TipSetKey
+	// Depth is the number of epochs rolled back from the old head.
+	Depth abi.ChainEpoch
+	// Dropped and Added are the tipsets removed from and added to the head
+	// chain, ordered by decreasing height.
+	Dropped []block.TipSetKey
+	Added   []block.TipSetKey
+	// RevertedMessages are the messages included in the dropped tipsets that
+	// are not included in the added ones.
+	RevertedMessages []cid.Cid
+	// AffectedMiners are the miners of blocks in the dropped tipsets.
+	AffectedMiners []address.Address
+	// Alert is true when the depth met the configured alert depth.
+	Alert bool
+}
+
+type chainReader interface {
+	GetTipSet(block.TipSetKey) (block.TipSet, error)
+}
+
+type messageLoader interface {
+	LoadMessages(context.Context, cid.Cid) ([]*types.SignedMessage, []*types.UnsignedMessage, error)
+}
+
+// Monitor records reorgs observed by the syncer, keeping a bounded history
+// of recent ones and raising alerts for reorgs above a configured depth.
+// Alerts are logged, counted and journaled; other reorgs are only kept in the
+// history.
+type Monitor struct {
+	chain      chainReader
+	messages   messageLoader
+	journal    journal.Writer
+	clock      clock.Clock
+	alertDepth abi.ChainEpoch
+
+	lk      sync.Mutex
+	history []*Reorg
+	next    int
+	full    bool
+}
+
+// NewMonitor creates a new fork monitor retaining up to `historySize` reorgs.
+// An alert depth of zero disables alerts, and so the journaling of reorgs.
+func NewMonitor(chn chainReader, messages messageLoader, jw journal.Writer, clk clock.Clock, alertDepth abi.ChainEpoch, historySize int) *Monitor {
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+	return &Monitor{
+		chain:      chn,
+		messages:   messages,
+		journal:    jw,
+		clock:      clk,
+		alertDepth: alertDepth,
+		history:    make([]*Reorg, historySize),
+	}
+}
+
+// HandleReorg records the reorg switching the head from oldHead to newHead.
+func (m *Monitor) HandleReorg(ctx context.Context, oldHead, newHead, commonAncestor block.TipSet) error {
+	ancestorHeight, err := commonAncestor.Height()
+	if err != nil {
+		return err
+	}
+	oldHeight, err := oldHead.Height()
+	if err != nil {
+		return err
+	}
+
+	dropped, err := chain.CollectTipSetsOfHeightAtLeast(ctx, chain.IterAncestors(ctx, m.chain, oldHead), ancestorHeight+1)
+	if err != nil {
+		return errors.Wrap(err, "failed to collect dropped tipsets")
+	}
+	added, err := chain.CollectTipSetsOfHeightAtLeast(ctx, chain.IterAncestors(ctx, m.chain, newHead), ancestorHeight+1)
+	if err != nil {
+		return errors.Wrap(err, "failed to collect added tipsets")
+	}
+
+	droppedMsgs, err := m.collectMessages(ctx, dropped)
+	if err != nil {
+		return err
+	}
+	addedMsgs, err := m.collectMessages(ctx, added)
+	if err != nil {
+		return err
+	}
+
+	reorg := &Reorg{
+		Time:           m.clock.Now(),
+		OldHead:        oldHead.Key(),
+		NewHead:        newHead.Key(),
+		CommonAncestor: commonAncestor.Key(),
+		Depth:          oldHeight - ancestorHeight,
+	}
+	reorg.Alert = m.alertDepth > 0 && reorg.Depth >= m.alertDepth
+
+	miners := make(map[address.Address]struct{})
+	for _, ts := range dropped {
+		reorg.Dropped = append(reorg.Dropped, ts.Key())
+		for i := 0; i < ts.Len(); i++ {
+			miner := ts.At(i).Miner
+			if _, seen := miners[miner]; !seen {
+				miners[miner] = struct{}{}
+				reorg.AffectedMiners = append(reorg.AffectedMiners, miner)
+			}
+		}
+	}
+	for _, ts := range added {
+		reorg.Added = append(reorg.Added, ts.Key())
+	}
+	included := make(map[cid.Cid]struct{}, len(addedMsgs))
+	for _, c := range addedMsgs {
+		included[c] = struct{}{}
+	}
+	for _, c := range droppedMsgs {
+		if _, ok := included[c]; !ok {
+			reorg.RevertedMessages = append(reorg.RevertedMessages, c)
+		}
+	}
+
+	m.record(reorg)
+
+	reorgDepthGauge.Set(ctx, int64(reorg.Depth))
+	orphanedCnt.Inc(ctx, int64(len(reorg.Dropped)))
+	revertedMsgCnt.Inc(ctx, int64(len(reorg.RevertedMessages)))
+	// Only deep reorgs are journaled: shallow ones are routine, and the
+	// journal is written on the sync path.
+	if reorg.Alert {
+		deepReorgCnt.Inc(ctx, 1)
+		log.Warnw("deep reorg", "depth", reorg.Depth, "oldHead", reorg.OldHead, "newHead", reorg.NewHead, "reverted", len(reorg.RevertedMessages))
+		m.journal.Write("reorg",
+			"depth", reorg.Depth,
+			"oldHead", reorg.OldHead,
+			"newHead", reorg.NewHead,
+			"commonAncestor", reorg.CommonAncestor,
+			"dropped", reorg.Dropped,
+			"added", reorg.Added,
+			"revertedMessages", reorg.RevertedMessages,
+			"affectedMiners", reorg.AffectedMiners,
+		)
+	}
+	return nil
+}
+
+// Reorgs returns up to `limit` of the most recently recorded reorgs, most
+// recent first. A non-positive limit returns the whole history.
+func (m *Monitor) Reorgs(limit int) []*Reorg {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	count := m.next
+	if m.full {
+		count = len(m.history)
+	}
+	if limit > 0 && limit < count {
+		count = limit
+	}
+
+	out := make([]*Reorg, 0, count)
+	for i := 1; i <= count; i++ {
+		idx := (m.next - i + len(m.history)) % len(m.history)
+		out = append(out, m.history[idx])
+	}
+	return out
+}
+
+func (m *Monitor) record(reorg *Reorg) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	m.history[m.next] = reorg
+	m.next = (m.next + 1) % len(m.history)
+	if m.next == 0 {
+		m.full = true
+	}
+}
+
+// collectMessages returns the cids of messages included in the tipsets,
+// de-duplicated and in order of first appearance.
+func (m *Monitor) collectMessages(ctx context.Context, tipsets []block.TipSet) ([]cid.Cid, error) {
+	var out []cid.Cid
+	seen := make(map[cid.Cid]struct{})
+	add := func(c cid.Cid) {
+		if _, ok := seen[c]; !ok {
+			seen[c] = struct{}{}
+			out = append(out, c)
+		}
+	}
+	for _, ts := range tipsets {
+		for i := 0; i < ts.Len(); i++ {
+			blk := ts.At(i)
+			secpMsgs, blsMsgs, err := m.messages.LoadMessages(ctx, blk.Messages.Cid)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to load messages for block %s", blk.Cid())
+			}
+			for _, msg := range secpMsgs {
+				c, err := msg.Cid()
+				if err != nil {
+					return nil, err
+				}
+				add(c)
+			}
+			for _, msg := range blsMsgs {
+				c, err := msg.Cid()
+				if err != nil {
+					return nil, err
+				}
+				add(c)
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,109 @@
+package forkmon_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/forkmon"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+// recordingWriter records the events written to it.
+type recordingWriter struct {
+	events []string
+}
+
+func (w *recordingWriter) Write(event string, _ ...interface{}) {
+	w.events = append(w.events, event)
+}
+
+func TestMonitorRecordsReorg(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	miner := vmaddr.RequireIDAddress(t, 1000)
+	builder := chain.NewBuilder(t, miner)
+
+	mm := vm.NewMessageMaker(t, types.MustGenerateKeyInfo(1, 42))
+	alice := mm.Addresses()[0]
+	kept := mm.NewSignedMessage(alice, 0)
+	reverted := mm.NewSignedMessage(alice, 1)
+
+	common := builder.AppendManyOn(2, block.UndefTipSet)
+	oldHead := builder.BuildOneOn(common, func(bb *chain.BlockBuilder) {
+		bb.AddMessages([]*types.SignedMessage{kept, reverted}, []*types.UnsignedMessage{})
+	})
+	oldHead = builder.AppendOn(oldHead, 1)
+	newHead := builder.BuildOneOn(common, func(bb *chain.BlockBuilder) {
+		bb.AddMessages([]*types.SignedMessage{kept}, []*types.UnsignedMessage{})
+	})
+	newHead = builder.AppendManyOn(2, newHead)
+
+	clk := clock.NewFake(time.Unix(1234567890, 0))
+	jw := &recordingWriter{}
+	mon := forkmon.NewMonitor(builder, builder, jw, clk, 2, 4)
+	require.NoError(t, mon.HandleReorg(ctx, oldHead, newHead, common))
+	assert.Equal(t, []string{"reorg"}, jw.events)
+
+	reorgs := mon.Reorgs(0)
+	require.Len(t, reorgs, 1)
+	reorg := reorgs[0]
+	assert.Equal(t, oldHead.Key(), reorg.OldHead)
+	assert.Equal(t, newHead.Key(), reorg.NewHead)
+	assert.Equal(t, common.Key(), reorg.CommonAncestor)
+	assert.Equal(t, abi.ChainEpoch(2), reorg.Depth)
+	assert.Len(t, reorg.Dropped, 2)
+	assert.Len(t, reorg.Added, 3)
+	assert.True(t, reorg.Alert)
+	assert.Equal(t, []address.Address{miner}, reorg.AffectedMiners)
+
+	revertedCid, err := reverted.Cid()
+	require.NoError(t, err)
+	assert.Equal(t, []cid.Cid{revertedCid}, reorg.RevertedMessages)
+}
+
+func TestMonitorHistoryIsBounded(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	clk := clock.NewFake(time.Unix(1234567890, 0))
+	jw := &recordingWriter{}
+	mon := forkmon.NewMonitor(builder, builder, jw, clk, 0, 3)
+
+	common := builder.AppendOn(block.UndefTipSet, 1)
+	var heads []block.TipSetKey
+	for i := 0; i < 5; i++ {
+		oldHead := builder.AppendOn(common, 1)
+		newHead := builder.AppendManyOn(2, common)
+		require.NoError(t, mon.HandleReorg(ctx, oldHead, newHead, common))
+		heads = append(heads, newHead.Key())
+	}
+
+	reorgs := mon.Reorgs(0)
+	require.Len(t, reorgs, 3)
+	// Most recent first.
+	assert.Equal(t, heads[4], reorgs[0].NewHead)
+	assert.Equal(t, heads[3], reorgs[1].NewHead)
+	assert.Equal(t, heads[2], reorgs[2].NewHead)
+	for _, r := range reorgs {
+		assert.False(t, r.Alert)
+	}
+	t.Log("reorgs below the alert depth are not journaled")
+	assert.Empty(t, jw.events)
+
+	limited := mon.Reorgs(2)
+	require.Len(t, limited, 2)
+	assert.Equal(t, heads[4], limited[0].NewHead)
+}
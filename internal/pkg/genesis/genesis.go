@@ -25,6 +25,16 @@ var Ticket = block.Ticket{
 	},
 }
 
+// MockProofsSignal is set in the ForkSignaling field of the genesis block of a
+// network that uses mock proofs. Since it is part of the genesis block, nodes
+// using real proofs and nodes using mock proofs cannot share a network.
+const MockProofsSignal uint64 = 1 << 63
+
+// UsesMockProofs returns true if a genesis block is for a network using mock proofs.
+func UsesMockProofs(genesis *block.Block) bool {
+	return genesis.ForkSignaling&MockProofsSignal != 0
+}
+
 // VM is the view into the VM used during genesis block creation.
 type VM interface {
 	ApplyGenesisMessage(from address.Address, to address.Address, method abi.MethodNum, value abi.TokenAmount, params interface{}, rnd crypto.RandomnessSource) (interface{}, error)
@@ -0,0 +1,102 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Keys reserved for an entry's metadata when it is encoded. They match the
+// keys written by the ZapJournal.
+const (
+	timeKey  = "ts"
+	topicKey = "_topic"
+	eventKey = "_event"
+)
+
+// Entry is a single recorded journal event.
+type Entry struct {
+	Time   time.Time
+	Topic  string
+	Event  string
+	Fields map[string]interface{}
+}
+
+// MarshalJSON encodes an entry as a flat JSON object holding the entry's
+// fields alongside its time, topic and event.
+func (e Entry) MarshalJSON() ([]byte, error) {
+	obj := make(map[string]interface{}, len(e.Fields)+3)
+	for k, v := range e.Fields {
+		obj[k] = v
+	}
+	obj[timeKey] = e.Time.UTC().Format(time.RFC3339Nano)
+	obj[topicKey] = e.Topic
+	obj[eventKey] = e.Event
+	return json.Marshal(obj)
+}
+
+// UnmarshalJSON decodes an entry encoded by MarshalJSON.
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	ts, _ := obj[timeKey].(string)
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return fmt.Errorf("invalid journal entry time %q", ts)
+	}
+	e.Time = t
+	e.Topic, _ = obj[topicKey].(string)
+	e.Event, _ = obj[eventKey].(string)
+	delete(obj, timeKey)
+	delete(obj, topicKey)
+	delete(obj, eventKey)
+	e.Fields = obj
+	return nil
+}
+
+// newEntry builds an entry from the variadic key-value pairs passed to a
+// Writer. Values that cannot be encoded as JSON are recorded in their string
+// form.
+func newEntry(t time.Time, topic, event string, kvs []interface{}) Entry {
+	fields := make(map[string]interface{}, len(kvs)/2)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		key, ok := kvs[i].(string)
+		if !ok {
+			key = fmt.Sprint(kvs[i])
+		}
+		val := kvs[i+1]
+		if err, ok := val.(error); ok {
+			val = err.Error()
+		} else if _, err := json.Marshal(val); err != nil {
+			val = fmt.Sprint(val)
+		}
+		fields[key] = val
+	}
+	return Entry{
+		Time:   t,
+		Topic:  topic,
+		Event:  event,
+		Fields: fields,
+	}
+}
+
+// Filter selects journal entries. Zero-valued fields match every entry.
+type Filter struct {
+	Topic string
+	Event string
+	// Since excludes entries recorded before it.
+	Since time.Time
+}
+
+// Matches returns true if the entry is selected by the filter.
+func (f Filter) Matches(e Entry) bool {
+	if f.Topic != "" && f.Topic != e.Topic {
+		return false
+	}
+	if f.Event != "" && f.Event != e.Event {
+		return false
+	}
+	return f.Since.IsZero() || !e.Time.Before(f.Since)
+}
@@ -0,0 +1,266 @@
+package journal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	logging "github.com/ipfs/go-log/v2"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+)
+
+var log = logging.Logger("journal")
+
+// subscriberBuffer is the number of entries buffered for each subscriber.
+// Entries are dropped for subscribers that fall further behind.
+const subscriberBuffer = 64
+
+// maxEntrySize bounds the size of a single encoded entry read back from disk.
+const maxEntrySize = 1 << 20
+
+// NewFileJournal returns a journal writing entries as ndjson to the file at
+// `path`. When writing an entry would grow the file beyond maxFileSize bytes
+// it is rotated to `path`.1, shifting older files up, and at most maxFiles
+// files are kept.
+func NewFileJournal(path string, maxFileSize int64, maxFiles int, clk clock.Clock) (*FileJournal, error) {
+	if maxFiles < 1 {
+		return nil, fmt.Errorf("journal must keep at least one file, got %d", maxFiles)
+	}
+	fj := &FileJournal{
+		path:        path,
+		maxFileSize: maxFileSize,
+		maxFiles:    maxFiles,
+		clock:       clk,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+	if err := fj.open(); err != nil {
+		return nil, err
+	}
+	return fj, nil
+}
+
+// FileJournal implements the Journal and Reader interfaces with a bounded set
+// of rotated files.
+type FileJournal struct {
+	path        string
+	maxFileSize int64
+	maxFiles    int
+	clock       clock.Clock
+
+	lk   sync.Mutex
+	file *os.File
+	size int64
+
+	subLk       sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	filter Filter
+	ch     chan Entry
+}
+
+// Topic returns a Writer that records events for a topic.
+func (fj *FileJournal) Topic(topic string) Writer {
+	return &FileWriter{
+		journal: fj,
+		topic:   topic,
+	}
+}
+
+// Query returns the entries selected by the filter from all retained files,
+// oldest first.
+func (fj *FileJournal) Query(filter Filter) ([]Entry, error) {
+	files, err := fj.openFiles()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+
+	var entries []Entry
+	for _, f := range files {
+		found, err := readEntries(f, filter)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, found...)
+	}
+	return entries, nil
+}
+
+// openFiles opens the retained files, oldest first. They are opened under the
+// lock so that a concurrent rotation cannot shift them while they are listed;
+// open files are read as they were even if they are rotated afterwards.
+func (fj *FileJournal) openFiles() ([]*os.File, error) {
+	fj.lk.Lock()
+	defer fj.lk.Unlock()
+
+	var files []*os.File
+	for i := fj.maxFiles - 1; i >= 0; i-- {
+		f, err := os.Open(fj.filePath(i))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			for _, f := range files {
+				_ = f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// Subscribe returns a channel receiving entries selected by the filter as they
+// are recorded. The channel is closed when the context is done.
+func (fj *FileJournal) Subscribe(ctx context.Context, filter Filter) <-chan Entry {
+	sub := &subscriber{
+		filter: filter,
+		ch:     make(chan Entry, subscriberBuffer),
+	}
+	fj.subLk.Lock()
+	fj.subscribers[sub] = struct{}{}
+	fj.subLk.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		fj.subLk.Lock()
+		delete(fj.subscribers, sub)
+		close(sub.ch)
+		fj.subLk.Unlock()
+	}()
+	return sub.ch
+}
+
+// Close closes the current journal file.
+func (fj *FileJournal) Close() error {
+	fj.lk.Lock()
+	defer fj.lk.Unlock()
+	return fj.file.Close()
+}
+
+func (fj *FileJournal) write(e Entry) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		log.Errorf("failed to encode journal entry %s/%s: %s", e.Topic, e.Event, err)
+		return
+	}
+	line = append(line, '\n')
+
+	fj.lk.Lock()
+	if fj.size > 0 && fj.size+int64(len(line)) > fj.maxFileSize {
+		if err := fj.rotate(); err != nil {
+			log.Errorf("failed to rotate journal: %s", err)
+		}
+	}
+	n, err := fj.file.Write(line)
+	fj.size += int64(n)
+	fj.lk.Unlock()
+	if err != nil {
+		log.Errorf("failed to write journal entry %s/%s: %s", e.Topic, e.Event, err)
+	}
+
+	fj.subLk.Lock()
+	defer fj.subLk.Unlock()
+	for sub := range fj.subscribers {
+		if !sub.filter.Matches(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+		}
+	}
+}
+
+// rotate shifts each retained file up by one, dropping the oldest, and
+// starts a new current file. If the files cannot be shifted the current file
+// is reopened, so that entries keep being written to it beyond the size limit
+// rather than to a closed file. The caller must hold the lock.
+func (fj *FileJournal) rotate() error {
+	err := fj.file.Close()
+	if err == nil {
+		err = fj.shift()
+	}
+	if openErr := fj.open(); openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+// shift renames each retained file to the next older one, dropping the
+// oldest.
+func (fj *FileJournal) shift() error {
+	if err := os.Remove(fj.filePath(fj.maxFiles - 1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := fj.maxFiles - 2; i >= 0; i-- {
+		if err := os.Rename(fj.filePath(i), fj.filePath(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fj *FileJournal) open() error {
+	f, err := os.OpenFile(fj.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	fj.file = f
+	fj.size = info.Size()
+	return nil
+}
+
+// filePath returns the path of the i-th most recent file, the current file
+// being the 0th.
+func (fj *FileJournal) filePath(i int) string {
+	if i == 0 {
+		return fj.path
+	}
+	return fmt.Sprintf("%s.%d", fj.path, i)
+}
+
+// readEntries reads the entries selected by the filter from a journal file.
+// Lines that fail to decode, such as one truncated by a crash, are skipped.
+func readEntries(f *os.File, filter Filter) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxEntrySize)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			log.Warnf("skipping malformed journal entry in %s: %s", f.Name(), err)
+			continue
+		}
+		if filter.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// FileWriter implements the Writer interface for a topic of a FileJournal.
+type FileWriter struct {
+	journal *FileJournal
+	topic   string
+}
+
+// Write records an operation and its metadata to a Journal accepting variadic key-value
+// pairs.
+func (fw *FileWriter) Write(event string, kvs ...interface{}) {
+	fw.journal.write(newEntry(fw.journal.clock.Now(), fw.topic, event, kvs))
+}
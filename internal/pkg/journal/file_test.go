@@ -0,0 +1,171 @@
+package journal
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/clock"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestFileJournalQuery(t *testing.T) {
+	tf.UnitTest(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	clk := clock.NewFake(time.Unix(1234567890, 0))
+	fj, err := NewFileJournal(filepath.Join(dir, "journal.json"), 1<<20, 2, clk)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, fj.Close()) }()
+
+	fj.Topic("chainsync").Write("head-change", "height", 1)
+	clk.Advance(time.Minute)
+	fj.Topic("mining").Write("mined-block", "height", 2)
+	clk.Advance(time.Minute)
+	fj.Topic("chainsync").Write("head-change", "height", 2)
+
+	all, err := fj.Query(Filter{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "chainsync", all[0].Topic)
+	assert.Equal(t, "head-change", all[0].Event)
+	assert.Equal(t, float64(1), all[0].Fields["height"])
+	assert.True(t, time.Unix(1234567890, 0).Equal(all[0].Time))
+
+	heads, err := fj.Query(Filter{Topic: "chainsync", Since: time.Unix(1234567890, 0).Add(time.Second)})
+	require.NoError(t, err)
+	require.Len(t, heads, 1)
+	assert.Equal(t, float64(2), heads[0].Fields["height"])
+
+	mined, err := fj.Query(Filter{Event: "mined-block"})
+	require.NoError(t, err)
+	require.Len(t, mined, 1)
+	assert.Equal(t, "mining", mined[0].Topic)
+}
+
+func TestFileJournalRotation(t *testing.T) {
+	tf.UnitTest(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	// Every entry is larger than half the maximum size, so each write after
+	// the first rotates the journal.
+	path := filepath.Join(dir, "journal.json")
+	fj, err := NewFileJournal(path, 100, 3, clock.NewFake(time.Unix(1234567890, 0)))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, fj.Close()) }()
+
+	w := fj.Topic("testing")
+	for i := 0; i < 5; i++ {
+		w.Write("event", "n", i)
+	}
+
+	entries, err := fj.Query(Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for i, e := range entries {
+		assert.Equal(t, float64(i+2), e.Fields["n"])
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFileJournalKeepsWritingWhenRotationFails(t *testing.T) {
+	tf.UnitTest(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	// The oldest file cannot be removed, so rotation fails.
+	path := filepath.Join(dir, "journal.json")
+	require.NoError(t, os.MkdirAll(filepath.Join(path+".2", "stuck"), 0755))
+	fj, err := NewFileJournal(path, 100, 3, clock.NewFake(time.Unix(1234567890, 0)))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, fj.Close()) }()
+
+	w := fj.Topic("testing")
+	for i := 0; i < 3; i++ {
+		w.Write("event", "n", i)
+	}
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	entries, err := readEntries(f, Filter{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func TestFileJournalQueryDuringRotation(t *testing.T) {
+	tf.UnitTest(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	fj, err := NewFileJournal(filepath.Join(dir, "journal.json"), 300, 3, clock.NewFake(time.Unix(1234567890, 0)))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, fj.Close()) }()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w := fj.Topic("testing")
+		for i := 0; i < 500; i++ {
+			w.Write("event", "n", i)
+		}
+	}()
+
+	// The retained entries are always consecutive, however queries and
+	// rotations interleave.
+	for {
+		entries, err := fj.Query(Filter{})
+		require.NoError(t, err)
+		for i := 1; i < len(entries); i++ {
+			require.Equal(t, entries[i-1].Fields["n"].(float64)+1, entries[i].Fields["n"])
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+
+func TestFileJournalSubscribe(t *testing.T) {
+	tf.UnitTest(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	fj, err := NewFileJournal(filepath.Join(dir, "journal.json"), 1<<20, 1, clock.NewFake(time.Unix(1234567890, 0)))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, fj.Close()) }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entries := fj.Subscribe(ctx, Filter{Topic: "outbox"})
+
+	fj.Topic("chainsync").Write("head-change")
+	fj.Topic("outbox").Write("SendEncoded", "to", "t01")
+
+	e := <-entries
+	assert.Equal(t, "SendEncoded", e.Event)
+	assert.Equal(t, "t01", e.Fields["to"])
+
+	cancel()
+	_, ok := <-entries
+	assert.False(t, ok)
+}
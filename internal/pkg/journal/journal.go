@@ -1,5 +1,7 @@
 package journal
 
+import "context"
+
 // Writer defines an interface for recording events and their metadata
 type Writer interface {
 	// Write records an operation and its metadata to a Journal accepting variadic key-value
@@ -12,3 +14,12 @@ type Journal interface {
 	// Topic returns a Writer that records events for a topic.
 	Topic(topic string) Writer
 }
+
+// Reader reads back the events recorded in a Journal.
+type Reader interface {
+	// Query returns the recorded entries selected by the filter, oldest first.
+	Query(filter Filter) ([]Entry, error)
+	// Subscribe returns a channel receiving entries selected by the filter as
+	// they are recorded, until the context is done.
+	Subscribe(ctx context.Context, filter Filter) <-chan Entry
+}
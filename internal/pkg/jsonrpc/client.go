@@ -0,0 +1,267 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// subscriptionBuffer is the number of values buffered for each subscription.
+// A subscription whose consumer falls further behind is closed.
+const subscriptionBuffer = 128
+
+// ErrClosed is returned by calls made on, or pending when closing, a client.
+var ErrClosed = errors.New("jsonrpc client closed")
+
+// Client calls methods on a server over a websocket connection.
+type Client struct {
+	ws      *websocket.Conn
+	writeLk sync.Mutex
+
+	lk      sync.Mutex
+	nextID  uint64
+	pending map[uint64]*pendingCall
+	subs    map[string]chan json.RawMessage
+	err     error
+	done    chan struct{}
+}
+
+type pendingCall struct {
+	resp chan *response
+	// sub receives the subscription's values if the call is a subscription.
+	sub chan json.RawMessage
+}
+
+// Dial connects to the websocket endpoint of a server, such as
+// ws://127.0.0.1:3453/rpc/v0.
+func Dial(ctx context.Context, url string, header http.Header) (*Client, error) {
+	ws, _, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		return nil, err
+	}
+	ws.SetReadLimit(maxRequestSize)
+	c := &Client{
+		ws:      ws,
+		pending: make(map[uint64]*pendingCall),
+		subs:    make(map[string]chan json.RawMessage),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// Close closes the connection, failing pending calls and ending subscriptions.
+func (c *Client) Close() error {
+	c.lk.Lock()
+	if c.err == nil {
+		c.err = ErrClosed
+	}
+	c.lk.Unlock()
+	err := c.ws.Close()
+	<-c.done
+	return err
+}
+
+// Call invokes a method and decodes its result into result, which may be nil
+// to discard it.
+func (c *Client) Call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	resp, err := c.roundTrip(ctx, method, params, nil)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+// Subscribe invokes a subscription method and returns a channel receiving the
+// encoded values sent by the server. The channel is closed when the server
+// ends the subscription, when ctx is done or when the client is closed.
+func (c *Client) Subscribe(ctx context.Context, method string, params ...interface{}) (<-chan json.RawMessage, error) {
+	sub := make(chan json.RawMessage, subscriptionBuffer)
+	resp, err := c.roundTrip(ctx, method, params, sub)
+	if err != nil {
+		return nil, err
+	}
+	var id string
+	if err := json.Unmarshal(resp.Result, &id); err != nil {
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-c.done:
+			return
+		}
+		if c.closeSub(id) {
+			// Let the server release the subscription. The reply does not
+			// matter as the channel is already closed.
+			_ = c.send(&request{JSONRPC: Version, Method: RPCUnsubscribe, Params: mustMarshal([]string{id})})
+		}
+	}()
+	return sub, nil
+}
+
+// roundTrip sends a request and waits for its response.
+func (c *Client) roundTrip(ctx context.Context, method string, params []interface{}, sub chan json.RawMessage) (*response, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	encodedParams, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	call := &pendingCall{resp: make(chan *response, 1), sub: sub}
+	c.lk.Lock()
+	if c.err != nil {
+		c.lk.Unlock()
+		return nil, c.err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = call
+	c.lk.Unlock()
+	defer func() {
+		c.lk.Lock()
+		delete(c.pending, id)
+		c.lk.Unlock()
+	}()
+
+	req := &request{
+		JSONRPC: Version,
+		ID:      json.RawMessage(strconv.FormatUint(id, 10)),
+		Method:  method,
+		Params:  encodedParams,
+	}
+	if err := c.send(req); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-call.resp:
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp, nil
+	case <-c.done:
+		return nil, c.closedErr()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Client) send(req *request) error {
+	c.writeLk.Lock()
+	defer c.writeLk.Unlock()
+	return c.ws.WriteJSON(req)
+}
+
+func (c *Client) readLoop() {
+	defer close(c.done)
+	for {
+		var f frame
+		if err := c.ws.ReadJSON(&f); err != nil {
+			c.shutdown(err)
+			return
+		}
+		switch f.Method {
+		case "":
+			c.deliverResponse(&f)
+		case RPCNotify, RPCClose:
+			var n notification
+			if err := json.Unmarshal(f.Params, &n); err != nil {
+				log.Warnf("malformed %s notification: %s", f.Method, err)
+				continue
+			}
+			if f.Method == RPCClose {
+				c.closeSub(n.Subscription)
+			} else {
+				c.deliverNotification(&n)
+			}
+		}
+	}
+}
+
+// deliverResponse hands a response to its caller. A subscription is
+// registered here, before any further message is read, so that values sent
+// right after the response are not missed.
+func (c *Client) deliverResponse(f *frame) {
+	id, err := strconv.ParseUint(string(f.ID), 10, 64)
+	if err != nil {
+		return
+	}
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	call, ok := c.pending[id]
+	if !ok {
+		return
+	}
+	if call.sub != nil && f.Error == nil {
+		var subID string
+		if err := json.Unmarshal(f.Result, &subID); err == nil {
+			c.subs[subID] = call.sub
+		}
+	}
+	call.resp <- &response{JSONRPC: f.JSONRPC, ID: f.ID, Result: f.Result, Error: f.Error}
+}
+
+func (c *Client) deliverNotification(n *notification) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	sub, ok := c.subs[n.Subscription]
+	if !ok {
+		return
+	}
+	select {
+	case sub <- n.Result:
+		return
+	default:
+	}
+	log.Warnf("closing subscription %s: consumer is not keeping up", n.Subscription)
+	close(sub)
+	delete(c.subs, n.Subscription)
+	go func() {
+		_ = c.send(&request{JSONRPC: Version, Method: RPCUnsubscribe, Params: mustMarshal([]string{n.Subscription})})
+	}()
+}
+
+// closeSub closes a subscription's channel, returning false if it was
+// already closed.
+func (c *Client) closeSub(id string) bool {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	sub, ok := c.subs[id]
+	if ok {
+		close(sub)
+		delete(c.subs, id)
+	}
+	return ok
+}
+
+func (c *Client) shutdown(err error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+	for id, sub := range c.subs {
+		close(sub)
+		delete(c.subs, id)
+	}
+}
+
+func (c *Client) closedErr() error {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if c.err == nil {
+		return ErrClosed
+	}
+	return c.err
+}
@@ -0,0 +1,85 @@
+// Package jsonrpc serves the exported methods of Go values over JSON-RPC 2.0,
+// on plain HTTP and on websockets, and provides a client for calling them.
+//
+// A method is exposed as "<namespace>.<Name>". Its parameters are passed
+// positionally, after an optional leading context.Context, and it returns an
+// error, optionally preceded by a result. A method whose result is a receive
+// channel is a subscription: it can only be called over a websocket, its
+// result is a subscription ID, and each value received from the channel is
+// sent to the caller in an RPCNotify notification. RPCClose notifies the
+// caller that the channel was closed, and RPCUnsubscribe cancels the context
+// passed to the method.
+package jsonrpc
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Version is the JSON-RPC protocol version spoken by the server and client.
+const Version = "2.0"
+
+// Methods reserved by the protocol.
+const (
+	// RPCDiscover returns the server's Schema.
+	RPCDiscover = "rpc.discover"
+	// RPCUnsubscribe cancels a subscription. Its only parameter is the
+	// subscription ID.
+	RPCUnsubscribe = "rpc.unsubscribe"
+	// RPCNotify is sent by the server with a value for a subscription.
+	RPCNotify = "rpc.notify"
+	// RPCClose is sent by the server when a subscription ends.
+	RPCClose = "rpc.close"
+)
+
+// Error codes defined by the JSON-RPC 2.0 specification.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	// CodeServerError is returned when a method returns an error.
+	CodeServerError = -32000
+)
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// notification carries the params of RPCNotify and RPCClose.
+type notification struct {
+	Subscription string          `json:"subscription"`
+	Result       json.RawMessage `json:"result,omitempty"`
+}
+
+// frame is any message read from a connection: a request, a response or a
+// notification.
+type frame struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
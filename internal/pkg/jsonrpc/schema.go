@@ -0,0 +1,198 @@
+package jsonrpc
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Schema is a machine-readable description of a server's methods, derived
+// from their Go signatures. Type descriptions follow JSON Schema.
+type Schema struct {
+	Methods []*MethodSchema `json:"methods"`
+	// Definitions describes the named struct types referred to by methods.
+	Definitions map[string]*TypeSchema `json:"definitions"`
+}
+
+// MethodSchema describes a method's positional params and its result.
+type MethodSchema struct {
+	Name   string        `json:"name"`
+	Params []*TypeSchema `json:"params"`
+	// Result is nil for methods returning no value. For subscriptions it
+	// describes the values sent in notifications.
+	Result       *TypeSchema `json:"result,omitempty"`
+	Subscription bool        `json:"subscription,omitempty"`
+}
+
+// TypeSchema describes the JSON encoding of a Go type.
+type TypeSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	GoType               string                 `json:"goType,omitempty"`
+	Items                *TypeSchema            `json:"items,omitempty"`
+	Properties           map[string]*TypeSchema `json:"properties,omitempty"`
+	AdditionalProperties *TypeSchema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+type schemaBuilder struct {
+	schema *Schema
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{schema: &Schema{Definitions: make(map[string]*TypeSchema)}}
+}
+
+func (b *schemaBuilder) addMethod(m *method) {
+	ms := &MethodSchema{
+		Name:         m.name,
+		Params:       make([]*TypeSchema, len(m.params)),
+		Subscription: m.subscription,
+	}
+	for i, pt := range m.params {
+		ms.Params[i] = b.describe(pt)
+	}
+	if m.subscription {
+		ms.Result = b.describe(m.result.Elem())
+	} else if m.result != nil {
+		ms.Result = b.describe(m.result)
+	}
+	b.schema.Methods = append(b.schema.Methods, ms)
+}
+
+// describe returns the schema of a type. Named structs are described once in
+// the definitions and referred to, which also terminates recursive types.
+func (b *schemaBuilder) describe(t reflect.Type) *TypeSchema {
+	if custom := describeCustom(t); custom != nil {
+		return custom
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return b.describe(t.Elem())
+	case reflect.Bool:
+		return &TypeSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &TypeSchema{Type: "integer", GoType: namedType(t)}
+	case reflect.Float32, reflect.Float64:
+		return &TypeSchema{Type: "number", GoType: namedType(t)}
+	case reflect.String:
+		return &TypeSchema{Type: "string", GoType: namedType(t)}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are encoded in base64.
+			return &TypeSchema{Type: "string", GoType: t.String()}
+		}
+		return &TypeSchema{Type: "array", Items: b.describe(t.Elem())}
+	case reflect.Map:
+		return &TypeSchema{Type: "object", AdditionalProperties: b.describe(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.describeStruct(t)
+		}
+		name := t.String()
+		if _, ok := b.schema.Definitions[name]; !ok {
+			// Reserve the name before describing fields that may refer back
+			// to this type.
+			b.schema.Definitions[name] = &TypeSchema{}
+			b.schema.Definitions[name] = b.describeStruct(t)
+		}
+		return &TypeSchema{Ref: "#/definitions/" + name}
+	default:
+		// Interfaces may hold values of any type.
+		return &TypeSchema{GoType: t.String()}
+	}
+}
+
+func (b *schemaBuilder) describeStruct(t reflect.Type) *TypeSchema {
+	s := &TypeSchema{Type: "object", GoType: namedType(t), Properties: make(map[string]*TypeSchema)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		} else if f.Anonymous {
+			// Fields of untagged embedded structs are promoted.
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && describeCustom(ft) == nil {
+				for k, v := range b.describeStruct(ft).Properties {
+					s.Properties[k] = v
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		s.Properties[name] = b.describe(f.Type)
+	}
+	return s
+}
+
+// describeCustom describes types that encode themselves. Their JSON type is
+// found by encoding a zero value, which is the best available without
+// knowledge of the type.
+func describeCustom(t reflect.Type) *TypeSchema {
+	if t.Kind() == reflect.Interface {
+		return nil
+	}
+	pt := reflect.PtrTo(t)
+	if !t.Implements(jsonMarshalerType) && !pt.Implements(jsonMarshalerType) {
+		if t.Implements(textMarshalerType) || pt.Implements(textMarshalerType) {
+			return &TypeSchema{Type: "string", GoType: t.String()}
+		}
+		return nil
+	}
+	return &TypeSchema{Type: zeroValueJSONType(t), GoType: t.String()}
+}
+
+func zeroValueJSONType(t reflect.Type) (jsonType string) {
+	defer func() {
+		// Some types cannot encode their zero value.
+		if recover() != nil {
+			jsonType = ""
+		}
+	}()
+	encoded, err := json.Marshal(reflect.New(t).Interface())
+	if err != nil || len(encoded) == 0 {
+		return ""
+	}
+	switch c := encoded[0]; {
+	case c == '"':
+		return "string"
+	case c == '{':
+		return "object"
+	case c == '[':
+		return "array"
+	case c == 't' || c == 'f':
+		return "boolean"
+	case c == '-' || (c >= '0' && c <= '9'):
+		return "number"
+	}
+	return ""
+}
+
+// namedType returns the name of a defined type, or "" for builtin types.
+func namedType(t reflect.Type) string {
+	if t.PkgPath() == "" {
+		return ""
+	}
+	return t.String()
+}
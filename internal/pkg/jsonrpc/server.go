@@ -0,0 +1,460 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("jsonrpc")
+
+// maxRequestSize bounds the size of a request body or websocket message.
+const maxRequestSize = 16 << 20
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Server dispatches JSON-RPC requests to registered methods.
+type Server struct {
+	methods  map[string]*method
+	origins  map[string]struct{}
+	anyOrig  bool
+	local    bool
+	upgrader websocket.Upgrader
+}
+
+// NewServer returns a server accepting browser requests only from the given
+// origins, as the go-ipfs-cmds HTTP API does. "*" allows every origin.
+// Requests without an Origin header, such as those of non-browser clients,
+// are accepted only by a local server, one listening on a loopback address.
+// The server does not authenticate requests, so a server reachable from other
+// hosts serves only requests from an allowed origin.
+func NewServer(allowedOrigins []string, local bool) *Server {
+	s := &Server{
+		methods: make(map[string]*method),
+		origins: make(map[string]struct{}),
+		local:   local,
+	}
+	for _, o := range allowedOrigins {
+		if o == "*" {
+			s.anyOrig = true
+		}
+		s.origins[o] = struct{}{}
+	}
+	s.upgrader = websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin:     s.originAllowed,
+	}
+	return s
+}
+
+// method is a registered method bound to its receiver.
+type method struct {
+	name   string
+	fn     reflect.Value
+	hasCtx bool
+	params []reflect.Type
+	// result is nil for methods returning only an error.
+	result reflect.Type
+	// subscription is true for methods returning a receive channel.
+	subscription bool
+}
+
+// Register exposes every exported method of handler as
+// "<namespace>.<Method>". It fails if any method has an unsupported
+// signature.
+func (s *Server) Register(namespace string, handler interface{}) error {
+	v := reflect.ValueOf(handler)
+	t := v.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		m, err := newMethod(namespace+"."+t.Method(i).Name, v.Method(i))
+		if err != nil {
+			return err
+		}
+		if _, ok := s.methods[m.name]; ok {
+			return fmt.Errorf("method %s registered twice", m.name)
+		}
+		s.methods[m.name] = m
+	}
+	return nil
+}
+
+func newMethod(name string, fn reflect.Value) (*method, error) {
+	ft := fn.Type()
+	m := &method{name: name, fn: fn}
+
+	for i := 0; i < ft.NumIn(); i++ {
+		in := ft.In(i)
+		if i == 0 && in == contextType {
+			m.hasCtx = true
+			continue
+		}
+		if ft.IsVariadic() && i == ft.NumIn()-1 {
+			return nil, fmt.Errorf("method %s: variadic parameters are not supported", name)
+		}
+		m.params = append(m.params, in)
+	}
+
+	switch ft.NumOut() {
+	case 1:
+	case 2:
+		m.result = ft.Out(0)
+		m.subscription = m.result.Kind() == reflect.Chan && m.result.ChanDir()&reflect.RecvDir != 0
+		if m.subscription && !m.hasCtx {
+			return nil, fmt.Errorf("method %s: subscriptions must take a context", name)
+		}
+	default:
+		return nil, fmt.Errorf("method %s: must return an error, optionally preceded by a result", name)
+	}
+	if ft.Out(ft.NumOut()-1) != errorType {
+		return nil, fmt.Errorf("method %s: last result must be an error", name)
+	}
+	return m, nil
+}
+
+// call decodes the params and invokes the method. A panicking method fails
+// the request with an internal error rather than bringing the process down.
+func (m *method) call(ctx context.Context, rawParams json.RawMessage) (result reflect.Value, rpcErr *Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("method %s panicked: %v\n%s", m.name, r, debug.Stack())
+			result, rpcErr = reflect.Value{}, &Error{Code: CodeInternalError, Message: fmt.Sprintf("method %s failed", m.name)}
+		}
+	}()
+
+	var params []json.RawMessage
+	if len(bytes.TrimSpace(rawParams)) > 0 && !bytes.Equal(bytes.TrimSpace(rawParams), []byte("null")) {
+		if err := json.Unmarshal(rawParams, &params); err != nil {
+			return reflect.Value{}, &Error{Code: CodeInvalidParams, Message: "params must be an array"}
+		}
+	}
+	if len(params) != len(m.params) {
+		return reflect.Value{}, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("%s takes %d params, got %d", m.name, len(m.params), len(params))}
+	}
+
+	args := make([]reflect.Value, 0, len(m.params)+1)
+	if m.hasCtx {
+		args = append(args, reflect.ValueOf(ctx))
+	}
+	for i, pt := range m.params {
+		arg := reflect.New(pt)
+		if err := json.Unmarshal(params[i], arg.Interface()); err != nil {
+			return reflect.Value{}, &Error{Code: CodeInvalidParams, Message: fmt.Sprintf("param %d: %s", i, err)}
+		}
+		args = append(args, arg.Elem())
+	}
+
+	out := m.fn.Call(args)
+	if err, _ := out[len(out)-1].Interface().(error); err != nil {
+		return reflect.Value{}, &Error{Code: CodeServerError, Message: err.Error()}
+	}
+	if m.result == nil {
+		return reflect.Value{}, nil
+	}
+	return out[0], nil
+}
+
+// ServeHTTP serves single and batched requests POSTed over HTTP, and upgrades
+// websocket requests to a connection on which subscriptions are available.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWebsocket(w, r)
+		return
+	}
+	if !s.originAllowed(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	var out interface{}
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			out = parseErrorResponse()
+		} else {
+			var resps []*response
+			for _, raw := range batch {
+				if resp, _ := s.handle(r.Context(), raw, nil); resp != nil {
+					resps = append(resps, resp)
+				}
+			}
+			out = resps
+		}
+	} else {
+		resp, _ := s.handle(r.Context(), body, nil)
+		if resp == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		out = resp
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		log.Warnf("failed to write response: %s", err)
+	}
+}
+
+// handle serves a single request and returns its response, or nil if the
+// request is a notification. Subscriptions are only available when conn is
+// not nil; the returned start function, if any, must be called once the
+// response has been written to begin sending the subscription's values.
+func (s *Server) handle(ctx context.Context, raw json.RawMessage, conn *serverConn) (*response, func()) {
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return parseErrorResponse(), nil
+	}
+	resp := &response{JSONRPC: Version, ID: req.ID}
+	if resp.ID == nil {
+		resp.ID = json.RawMessage("null")
+	}
+	if req.JSONRPC != Version || req.Method == "" {
+		resp.Error = &Error{Code: CodeInvalidRequest, Message: "invalid JSON-RPC 2.0 request"}
+		return resp, nil
+	}
+	// A subscription answers with the ID its values are sent under, so it
+	// cannot be a notification.
+	if m, ok := s.methods[req.Method]; ok && m.subscription && req.ID == nil {
+		resp.Error = &Error{Code: CodeInvalidRequest, Message: fmt.Sprintf("subscription %s requires a request id", req.Method)}
+		return resp, nil
+	}
+
+	result, start, rpcErr := s.dispatch(ctx, &req, conn)
+	if req.ID == nil {
+		return nil, start
+	}
+	if rpcErr != nil {
+		resp.Error = rpcErr
+		return resp, nil
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		resp.Error = &Error{Code: CodeInternalError, Message: fmt.Sprintf("failed to encode result: %s", err)}
+		return resp, start
+	}
+	resp.Result = encoded
+	return resp, start
+}
+
+func (s *Server) dispatch(ctx context.Context, req *request, conn *serverConn) (interface{}, func(), *Error) {
+	switch req.Method {
+	case RPCDiscover:
+		return s.Schema(), nil, nil
+	case RPCUnsubscribe:
+		if conn == nil {
+			return nil, nil, &Error{Code: CodeInvalidRequest, Message: "subscriptions require a websocket connection"}
+		}
+		var params []string
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params) != 1 {
+			return nil, nil, &Error{Code: CodeInvalidParams, Message: "expected a subscription ID"}
+		}
+		return conn.unsubscribe(params[0]), nil, nil
+	}
+
+	m, ok := s.methods[req.Method]
+	if !ok {
+		return nil, nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("method %s not found", req.Method)}
+	}
+	if !m.subscription {
+		result, rpcErr := m.call(ctx, req.Params)
+		if rpcErr != nil || m.result == nil {
+			return nil, nil, rpcErr
+		}
+		return result.Interface(), nil, nil
+	}
+
+	if conn == nil {
+		return nil, nil, &Error{Code: CodeInvalidRequest, Message: "subscriptions require a websocket connection"}
+	}
+	subCtx, cancel := context.WithCancel(conn.ctx)
+	ch, rpcErr := m.call(subCtx, req.Params)
+	if rpcErr != nil {
+		cancel()
+		return nil, nil, rpcErr
+	}
+	id, start := conn.subscribe(subCtx, ch, cancel)
+	return id, start, nil
+}
+
+// Schema describes the registered methods, ordered by name.
+func (s *Server) Schema() *Schema {
+	names := make([]string, 0, len(s.methods))
+	for name := range s.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := newSchemaBuilder()
+	for _, name := range names {
+		b.addMethod(s.methods[name])
+	}
+	return b.schema
+}
+
+// originAllowed accepts requests from allowed origins, and requests without
+// an Origin header when the server is local.
+func (s *Server) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return s.local
+	}
+	if s.anyOrig {
+		return true
+	}
+	_, ok := s.origins[origin]
+	return ok
+}
+
+func parseErrorResponse() *response {
+	return &response{
+		JSONRPC: Version,
+		ID:      json.RawMessage("null"),
+		Error:   &Error{Code: CodeParseError, Message: "invalid JSON"},
+	}
+}
+
+func (s *Server) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Warnf("failed to upgrade websocket connection: %s", err)
+		return
+	}
+	ws.SetReadLimit(maxRequestSize)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	conn := &serverConn{
+		ctx:  ctx,
+		ws:   ws,
+		subs: make(map[string]context.CancelFunc),
+	}
+	defer func() {
+		cancel()
+		_ = ws.Close()
+	}()
+
+	var wg sync.WaitGroup
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Debugf("websocket connection closed: %s", err)
+			}
+			break
+		}
+		// Requests are served concurrently so that a long call, such as
+		// waiting for a message, does not hold up the connection.
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, start := s.handle(ctx, msg, conn)
+			if resp != nil {
+				conn.write(resp)
+			}
+			if start != nil {
+				start()
+			}
+		}()
+	}
+	cancel()
+	wg.Wait()
+}
+
+// serverConn is the server side of a websocket connection.
+type serverConn struct {
+	ctx context.Context
+
+	writeLk sync.Mutex
+	ws      *websocket.Conn
+
+	lk     sync.Mutex
+	nextID uint64
+	subs   map[string]context.CancelFunc
+}
+
+func (c *serverConn) write(msg interface{}) {
+	c.writeLk.Lock()
+	defer c.writeLk.Unlock()
+	if err := c.ws.WriteJSON(msg); err != nil {
+		log.Debugf("failed to write to websocket: %s", err)
+	}
+}
+
+// subscribe registers a subscription and returns its ID along with a function
+// starting to forward the values received on ch to the client.
+func (c *serverConn) subscribe(ctx context.Context, ch reflect.Value, cancel context.CancelFunc) (string, func()) {
+	c.lk.Lock()
+	c.nextID++
+	id := strconv.FormatUint(c.nextID, 10)
+	c.subs[id] = cancel
+	c.lk.Unlock()
+
+	start := func() {
+		go func() {
+			defer c.unsubscribe(id)
+			cases := []reflect.SelectCase{
+				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+				{Dir: reflect.SelectRecv, Chan: ch},
+			}
+			for {
+				chosen, v, ok := reflect.Select(cases)
+				if chosen == 0 {
+					return
+				}
+				if !ok {
+					c.write(&request{JSONRPC: Version, Method: RPCClose, Params: mustMarshal(&notification{Subscription: id})})
+					return
+				}
+				result, err := json.Marshal(v.Interface())
+				if err != nil {
+					log.Warnf("failed to encode value for subscription %s: %s", id, err)
+					continue
+				}
+				c.write(&request{JSONRPC: Version, Method: RPCNotify, Params: mustMarshal(&notification{Subscription: id, Result: result})})
+			}
+		}()
+	}
+	return id, start
+}
+
+// unsubscribe cancels a subscription, returning false if it does not exist.
+func (c *serverConn) unsubscribe(id string) bool {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	cancel, ok := c.subs[id]
+	if ok {
+		cancel()
+		delete(c.subs, id)
+	}
+	return ok
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,230 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+type testPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type testHandler struct{}
+
+func (testHandler) Add(a, b int) (int, error) {
+	return a + b, nil
+}
+
+func (testHandler) Scale(_ context.Context, p testPoint, k int) (*testPoint, error) {
+	return &testPoint{X: p.X * k, Y: p.Y * k}, nil
+}
+
+func (testHandler) Fail() error {
+	return errors.New("boom")
+}
+
+func (testHandler) Count(ctx context.Context, n int) (<-chan int, error) {
+	out := make(chan int)
+	go func() {
+		defer close(out)
+		for i := 0; i < n; i++ {
+			select {
+			case out <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	s := NewServer([]string{"http://localhost:8080"}, true)
+	require.NoError(t, s.Register("Test", testHandler{}))
+	return httptest.NewServer(s)
+}
+
+func TestServerHTTP(t *testing.T) {
+	tf.UnitTest(t)
+
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	post := func(body string) map[string]interface{} {
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		var out map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return out
+	}
+
+	out := post(`{"jsonrpc":"2.0","id":1,"method":"Test.Add","params":[2,3]}`)
+	assert.Equal(t, float64(5), out["result"])
+	assert.Equal(t, float64(1), out["id"])
+
+	out = post(`{"jsonrpc":"2.0","id":2,"method":"Test.Fail"}`)
+	assert.Equal(t, float64(CodeServerError), out["error"].(map[string]interface{})["code"])
+
+	out = post(`{"jsonrpc":"2.0","id":3,"method":"Test.Missing"}`)
+	assert.Equal(t, float64(CodeMethodNotFound), out["error"].(map[string]interface{})["code"])
+
+	out = post(`{"jsonrpc":"2.0","id":4,"method":"Test.Count","params":[2]}`)
+	assert.Equal(t, float64(CodeInvalidRequest), out["error"].(map[string]interface{})["code"])
+
+	out = post(`{"jsonrpc":"2.0","method":"Test.Count","params":[2]}`)
+	assert.Equal(t, float64(CodeInvalidRequest), out["error"].(map[string]interface{})["code"])
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL, bytes.NewReader([]byte(`{"jsonrpc":"2.0","id":5,"method":"Test.Fail"}`)))
+	require.NoError(t, err)
+	req.Header.Set("Origin", "http://evil.example")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestServerRequiresOriginWhenNotLocal(t *testing.T) {
+	tf.UnitTest(t)
+
+	s := NewServer([]string{"http://localhost:8080"}, false)
+	require.NoError(t, s.Register("Test", testHandler{}))
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	post := func(origin string) int {
+		req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"Test.Add","params":[2,3]}`))
+		require.NoError(t, err)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusForbidden, post(""))
+	assert.Equal(t, http.StatusOK, post("http://localhost:8080"))
+}
+
+func TestClientCallAndSubscribe(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	c, err := Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	var scaled testPoint
+	require.NoError(t, c.Call(ctx, "Test.Scale", &scaled, testPoint{X: 1, Y: 2}, 3))
+	assert.Equal(t, testPoint{X: 3, Y: 6}, scaled)
+
+	err = c.Call(ctx, "Test.Fail", nil)
+	require.Error(t, err)
+	assert.Equal(t, "boom", err.(*Error).Message)
+
+	values, err := c.Subscribe(ctx, "Test.Count", 3)
+	require.NoError(t, err)
+	var got []int
+	for raw := range values {
+		var v int
+		require.NoError(t, json.Unmarshal(raw, &v))
+		got = append(got, v)
+	}
+	assert.Equal(t, []int{0, 1, 2}, got)
+
+	subCtx, cancel := context.WithCancel(ctx)
+	values, err = c.Subscribe(subCtx, "Test.Count", 1000000)
+	require.NoError(t, err)
+	<-values
+	cancel()
+	for range values {
+	}
+}
+
+func TestSchema(t *testing.T) {
+	tf.UnitTest(t)
+
+	s := NewServer(nil, true)
+	require.NoError(t, s.Register("Test", testHandler{}))
+	schema := s.Schema()
+
+	names := make([]string, len(schema.Methods))
+	for i, m := range schema.Methods {
+		names[i] = m.Name
+	}
+	assert.Equal(t, []string{"Test.Add", "Test.Count", "Test.Fail", "Test.Scale"}, names)
+
+	count := schema.Methods[1]
+	assert.True(t, count.Subscription)
+	assert.Equal(t, "integer", count.Result.Type)
+
+	fail := schema.Methods[2]
+	assert.Empty(t, fail.Params)
+	assert.Nil(t, fail.Result)
+
+	scale := schema.Methods[3]
+	require.Len(t, scale.Params, 2)
+	assert.Equal(t, "#/definitions/jsonrpc.testPoint", scale.Params[0].Ref)
+	assert.Equal(t, scale.Params[0], scale.Result)
+	point := schema.Definitions["jsonrpc.testPoint"]
+	assert.Equal(t, "integer", point.Properties["x"].Type)
+	assert.Equal(t, "integer", point.Properties["y"].Type)
+}
+
+func TestRegisterRejectsUnsupportedSignatures(t *testing.T) {
+	tf.UnitTest(t)
+
+	s := NewServer(nil, true)
+	assert.Error(t, s.Register("Bad", badHandler{}))
+}
+
+type badHandler struct{}
+
+func (badHandler) NoError() int {
+	return 0
+}
+
+type derefHandler struct{}
+
+func (derefHandler) Sum(p *testPoint) (int, error) {
+	return p.X + p.Y, nil
+}
+
+func TestServerRecoversFromPanickingMethods(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	s := NewServer(nil, true)
+	require.NoError(t, s.Register("Test", testHandler{}))
+	require.NoError(t, s.Register("Deref", derefHandler{}))
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	c, err := Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	var sum int
+	err = c.Call(ctx, "Deref.Sum", &sum, nil)
+	require.Error(t, err)
+	assert.Equal(t, CodeInternalError, err.(*Error).Code)
+
+	require.NoError(t, c.Call(ctx, "Deref.Sum", &sum, testPoint{X: 1, Y: 2}))
+	assert.Equal(t, 3, sum)
+}
@@ -146,6 +146,19 @@ func (ob *Outbox) SendEncoded(ctx context.Context, from, to address.Address, val
 	return sendSignedMsg(ctx, ob, signed, bcast)
 }
 
+// NextNonce returns the nonce the next message sent from an address should carry, accounting for
+// the messages from it still waiting in the queue.
+func (ob *Outbox) NextNonce(ctx context.Context, from address.Address) (uint64, error) {
+	ob.nonceLock.Lock()
+	defer ob.nonceLock.Unlock()
+
+	fromActor, err := ob.actors.GetActorAt(ctx, ob.chains.GetHead(), from)
+	if err != nil {
+		return 0, errors.Wrapf(err, "no actor at address %s", from)
+	}
+	return nextNonce(fromActor, ob.queue, from)
+}
+
 // SignedSend send a signed message, retaining it in the outbound message queue.
 // If bcast is true, the publisher broadcasts the message to the network at the current block height.
 func (ob *Outbox) SignedSend(ctx context.Context, signed *types.SignedMessage, bcast bool) (out cid.Cid, pubErrCh chan error, err error) {
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestSumCounterSumsTaggedIncrements(t *testing.T) {
+	tf.BadUnitTestWithSideEffects(t)
+
+	ctx := context.Background()
+
+	testCounter := NewInt64SumCounter("testCounter", "testDesc", ReasonKey)
+	defer view.Unregister(testCounter.view)
+
+	testCounter.Inc(WithTag(ctx, ReasonKey, "a"), 2)
+	testCounter.Inc(WithTag(ctx, ReasonKey, "a"), 3)
+	testCounter.Inc(WithTag(ctx, ReasonKey, "b"), 1)
+
+	rows, err := view.RetrieveData("testCounter")
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	sums := make(map[string]float64)
+	for _, row := range rows {
+		require.Len(t, row.Tags, 1)
+		assert.Equal(t, ReasonKey, row.Tags[0].Key)
+		sums[row.Tags[0].Value] = row.Data.(*view.SumData).Value
+	}
+	assert.Equal(t, map[string]float64{"a": 5, "b": 1}, sums)
+}
+
+func TestCounterCountsIncrements(t *testing.T) {
+	tf.BadUnitTestWithSideEffects(t)
+
+	ctx := context.Background()
+
+	testCounter := NewInt64Counter("testCountCounter", "testDesc")
+	defer view.Unregister(testCounter.view)
+
+	testCounter.Inc(ctx, 2)
+	testCounter.Inc(ctx, 3)
+
+	rows, err := view.RetrieveData("testCountCounter")
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, int64(2), rows[0].Data.(*view.CountData).Value)
+}
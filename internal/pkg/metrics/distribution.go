@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// Int64Distribution wraps an opencensus int64 measure whose values are
+// aggregated into buckets.
+type Int64Distribution struct {
+	measure *stats.Int64Measure
+	view    *view.View
+}
+
+// NewInt64Distribution creates a new Int64Distribution with demensionless
+// units and buckets delimited by `bounds`.
+func NewInt64Distribution(name, desc string, bounds []float64, keys ...tag.Key) *Int64Distribution {
+	log.Infof("registering int64 distribution: %s - %s", name, desc)
+	iMeasure := stats.Int64(name, desc, stats.UnitDimensionless)
+	iView := &view.View{
+		Name:        name,
+		Measure:     iMeasure,
+		Description: desc,
+		Aggregation: view.Distribution(bounds...),
+		TagKeys:     keys,
+	}
+	if err := view.Register(iView); err != nil {
+		// a panic here indicates a developer error when creating a view.
+		// Since this method is called in init() methods, this panic when hit
+		// will cause running the program to fail immediately.
+		panic(err)
+	}
+
+	return &Int64Distribution{
+		measure: iMeasure,
+		view:    iView,
+	}
+}
+
+// Record records the value `v` in the distribution.
+func (d *Int64Distribution) Record(ctx context.Context, v int64) {
+	stats.Record(ctx, d.measure.M(v))
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"context"
+
+	"go.opencensus.io/tag"
+)
+
+// Tag keys shared by metrics across subsystems, so that measurements of the
+// same dimension can be joined and filtered consistently.
+var (
+	// PeerKey identifies the remote peer involved in an operation.
+	PeerKey = tag.MustNewKey("peer")
+	// StageKey identifies a step of a multi-step operation.
+	StageKey = tag.MustNewKey("stage")
+	// ReasonKey classifies why an operation failed or an object was rejected.
+	ReasonKey = tag.MustNewKey("reason")
+	// ExitCodeKey is the exit code of a message execution.
+	ExitCodeKey = tag.MustNewKey("exit_code")
+	// RoleKey is the market role of the node in a deal, client or provider.
+	RoleKey = tag.MustNewKey("role")
+	// StateKey is the state of a deal or other state machine.
+	StateKey = tag.MustNewKey("state")
+	// SigTypeKey is the signature type used to sign, bls or secp256k1.
+	SigTypeKey = tag.MustNewKey("sig_type")
+)
+
+// WithTag returns a copy of ctx whose measurements are recorded with the tag
+// `key` set to `value`.
+func WithTag(ctx context.Context, key tag.Key, value string) context.Context {
+	tagged, err := tag.New(ctx, tag.Upsert(key, value))
+	if err != nil {
+		log.Warnf("failed to tag %s with %q: %s", key.Name(), value, err)
+		return ctx
+	}
+	return tagged
+}
@@ -3,24 +3,51 @@ package piecemanager
 import (
 	"context"
 	"io"
+	"os"
+	"time"
 
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-statestore"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	fsm "github.com/filecoin-project/storage-fsm"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 )
 
 var _ PieceManager = new(FiniteStateMachineBackEnd)
 
+// removePollInterval is how often the state of a sector being removed is
+// checked until the state machine has stopped acting on it.
+const removePollInterval = 100 * time.Millisecond
+
+// removeTimeout bounds the wait for the state machine to stop acting on a
+// sector being removed.
+const removeTimeout = 30 * time.Second
+
+// errNoSectorStorage is returned when sector files are not kept in storage
+// paths, as with mock proofs.
+var errNoSectorStorage = errors.New("sector storage paths are not available")
+
 type FiniteStateMachineBackEnd struct {
-	idc fsm.SectorIDCounter
-	fsm *fsm.Sealing
+	idc     fsm.SectorIDCounter
+	fsm     *fsm.Sealing
+	sectors *statestore.StateStore
+	storage SectorStorage
 }
 
-func NewFiniteStateMachineBackEnd(fsm *fsm.Sealing, idc fsm.SectorIDCounter) FiniteStateMachineBackEnd {
+// NewFiniteStateMachineBackEnd creates a piece manager sealing with a state
+// machine. The sector storage may be nil when sector files are not kept on
+// disk.
+func NewFiniteStateMachineBackEnd(sealing *fsm.Sealing, idc fsm.SectorIDCounter, ds datastore.Batching, storage SectorStorage) FiniteStateMachineBackEnd {
 	return FiniteStateMachineBackEnd{
-		idc: idc,
-		fsm: fsm,
+		idc:     idc,
+		fsm:     sealing,
+		sectors: statestore.New(namespace.Wrap(ds, datastore.NewKey(fsm.SectorStorePrefix))),
+		storage: storage,
 	}
 }
 
@@ -44,7 +71,18 @@ func (f *FiniteStateMachineBackEnd) PledgeSector(ctx context.Context) error {
 }
 
 func (f *FiniteStateMachineBackEnd) UnsealSector(ctx context.Context, sectorID uint64) (io.ReadCloser, error) {
-	panic("implement me")
+	if f.storage == nil {
+		return nil, errNoSectorStorage
+	}
+	sid, err := f.sectorID(abi.SectorNumber(sectorID))
+	if err != nil {
+		return nil, err
+	}
+	path, err := f.storage.UnsealedSectorPath(ctx, sid)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
 }
 
 func (f *FiniteStateMachineBackEnd) LocatePieceForDealWithinSector(ctx context.Context, dealID uint64) (sectorID uint64, offset uint64, length uint64, err error) {
@@ -74,3 +112,95 @@ func (f *FiniteStateMachineBackEnd) LocatePieceForDealWithinSector(ctx context.C
 
 	return 0, 0, 0, errors.Errorf("no encoded piece could be found corresponding to deal id: %d", dealID)
 }
+
+func (f *FiniteStateMachineBackEnd) ListSectors(ctx context.Context) ([]fsm.SectorInfo, error) {
+	return f.fsm.ListSectors()
+}
+
+func (f *FiniteStateMachineBackEnd) GetSectorInfo(ctx context.Context, sectorNum abi.SectorNumber) (fsm.SectorInfo, error) {
+	info, err := f.fsm.GetSectorInfo(sectorNum)
+	if err != nil {
+		return fsm.SectorInfo{}, errors.Wrapf(err, "failed to get sector %d", sectorNum)
+	}
+	return info, nil
+}
+
+func (f *FiniteStateMachineBackEnd) ForceSectorState(ctx context.Context, sectorNum abi.SectorNumber, state fsm.SectorState) error {
+	if _, err := f.GetSectorInfo(ctx, sectorNum); err != nil {
+		return err
+	}
+	return f.fsm.ForceSectorState(ctx, sectorNum, state)
+}
+
+// RemoveSector forgets a sector once the state machine has stopped acting on
+// it. The state group of the state machine cannot drop a single machine, so
+// the idle machine of the sector stays in memory until the node restarts.
+// Events must not be sent to it as it has no state left to update, which
+// ForceSectorState ensures by refusing unknown sectors, and sector numbers
+// are never reused.
+func (f *FiniteStateMachineBackEnd) RemoveSector(ctx context.Context, sectorNum abi.SectorNumber) error {
+	// The sector is moved to a state the state machine does not act on and
+	// which it has recorded before the sector is forgotten, so that no pending
+	// update writes the sector back.
+	if err := f.ForceSectorState(ctx, sectorNum, fsm.FailedUnrecoverable); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, removeTimeout)
+	defer cancel()
+	for {
+		info, err := f.GetSectorInfo(ctx, sectorNum)
+		if err != nil {
+			return err
+		}
+		if info.State == fsm.FailedUnrecoverable {
+			break
+		}
+		select {
+		case <-time.After(removePollInterval):
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "sector %d is still %s, sealing stops acting on it once its current step completes, remove it again then", sectorNum, info.State)
+		}
+	}
+	return f.sectors.Get(uint64(sectorNum)).End()
+}
+
+func (f *FiniteStateMachineBackEnd) StoragePaths(ctx context.Context) ([]StoragePath, error) {
+	if f.storage == nil {
+		return nil, errNoSectorStorage
+	}
+	return f.storage.StoragePaths(ctx)
+}
+
+func (f *FiniteStateMachineBackEnd) AttachStoragePath(ctx context.Context, cfg config.StoragePathConfig) error {
+	if f.storage == nil {
+		return errNoSectorStorage
+	}
+	return f.storage.AttachStoragePath(ctx, cfg)
+}
+
+func (f *FiniteStateMachineBackEnd) MoveSector(ctx context.Context, sectorNum abi.SectorNumber, storageID string) error {
+	if f.storage == nil {
+		return errNoSectorStorage
+	}
+	info, err := f.GetSectorInfo(ctx, sectorNum)
+	if err != nil {
+		return err
+	}
+	// Sectors are only moved once sealing no longer writes to their files.
+	if info.State != fsm.Proving {
+		return errors.Errorf("sector %d is %s, only proving sectors can be moved", sectorNum, info.State)
+	}
+	sid, err := f.sectorID(sectorNum)
+	if err != nil {
+		return err
+	}
+	return f.storage.MoveSector(ctx, sid, storageID)
+}
+
+func (f *FiniteStateMachineBackEnd) sectorID(sectorNum abi.SectorNumber) (abi.SectorID, error) {
+	minerID, err := address.IDFromAddress(f.fsm.Address())
+	if err != nil {
+		return abi.SectorID{}, err
+	}
+	return abi.SectorID{Miner: abi.ActorID(minerID), Number: sectorNum}, nil
+}
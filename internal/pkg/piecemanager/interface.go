@@ -5,6 +5,9 @@ import (
 	"io"
 
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	fsm "github.com/filecoin-project/storage-fsm"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 )
 
 // PieceManager is responsible for sealing pieces into sectors and progressing
@@ -34,4 +37,31 @@ type PieceManager interface {
 	// a deal's piece within a sealed sector, or an error if that piece does not
 	// exist within any sealed sectors.
 	LocatePieceForDealWithinSector(ctx context.Context, dealID uint64) (sectorID uint64, offset uint64, length uint64, err error)
+
+	// ListSectors produces the sectors known to the sealing state machine, in
+	// every state of their lifecycle.
+	ListSectors(ctx context.Context) ([]fsm.SectorInfo, error)
+
+	// GetSectorInfo produces the sealing state of a sector, or an error if the
+	// sector is unknown.
+	GetSectorInfo(ctx context.Context, sectorNum abi.SectorNumber) (fsm.SectorInfo, error)
+
+	// ForceSectorState moves a sector to the provided state, from which the
+	// sealing state machine carries on. It is used to retry failed states.
+	ForceSectorState(ctx context.Context, sectorNum abi.SectorNumber, state fsm.SectorState) error
+
+	// RemoveSector stops sealing a sector and forgets it. Sealed data already
+	// written to storage is left in place. It fails if sealing does not stop
+	// acting on the sector in time.
+	RemoveSector(ctx context.Context, sectorNum abi.SectorNumber) error
+
+	// StoragePaths produces the directories holding sector data.
+	StoragePaths(ctx context.Context) ([]StoragePath, error)
+
+	// AttachStoragePath registers a directory to hold sector data.
+	AttachStoragePath(ctx context.Context, cfg config.StoragePathConfig) error
+
+	// MoveSector moves the sealed and cache files of a sealed sector to the
+	// directory with the provided storage ID.
+	MoveSector(ctx context.Context, sectorNum abi.SectorNumber, storageID string) error
 }
@@ -0,0 +1,45 @@
+package piecemanager
+
+import (
+	"context"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
+)
+
+// StoragePath is a directory holding sector data, with how much of it is used.
+type StoragePath struct {
+	ID         string
+	Path       string
+	Weight     uint64
+	MaxStorage uint64
+	CanSeal    bool
+	CanStore   bool
+
+	// Capacity and Available are the size and free space of the file system
+	// holding the directory.
+	Capacity  uint64
+	Available uint64
+	// Used is the space reserved for the sector files in the directory.
+	Used    uint64
+	Sectors []abi.SectorNumber
+}
+
+// SectorStorage keeps track of the directories sector files are placed in and
+// of the directory each sector file lives in.
+type SectorStorage interface {
+	// StoragePaths produces the directories holding sector data.
+	StoragePaths(ctx context.Context) ([]StoragePath, error)
+
+	// AttachStoragePath registers a directory to hold sector data.
+	AttachStoragePath(ctx context.Context, cfg config.StoragePathConfig) error
+
+	// MoveSector moves the sealed and cache files of a sector to the directory
+	// with the provided storage ID.
+	MoveSector(ctx context.Context, sector abi.SectorID, storageID string) error
+
+	// UnsealedSectorPath produces the path of the unsealed file of a sector,
+	// in whichever directory it lives.
+	UnsealedSectorPath(ctx context.Context, sector abi.SectorID) (string, error)
+}
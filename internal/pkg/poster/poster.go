@@ -18,6 +18,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/cst"
 	"github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/message"
 	appstate "github.com/filecoin-project/go-filecoin/internal/pkg/state"
 	"github.com/filecoin-project/specs-actors/actors/abi"
@@ -39,6 +40,10 @@ type Poster struct {
 	chain       *cst.ChainStateReadWriter
 	stateViewer *appstate.Viewer
 	waiter      *msg.Waiter
+	journal     journal.Writer
+	// proving is held while generating PoSts so that sector files do not move
+	// under them.
+	proving sync.Locker
 }
 
 // NewPoster creates a Poster struct
@@ -46,17 +51,21 @@ func NewPoster(
 	minerAddr address.Address,
 	outbox *message.Outbox,
 	mgr sectorstorage.SectorManager,
+	proving sync.Locker,
 	chain *cst.ChainStateReadWriter,
 	stateViewer *appstate.Viewer,
-	waiter *msg.Waiter) *Poster {
+	waiter *msg.Waiter,
+	jw journal.Writer) *Poster {
 
 	return &Poster{
 		minerAddr:   minerAddr,
 		outbox:      outbox,
 		mgr:         mgr,
+		proving:     proving,
 		chain:       chain,
 		stateViewer: stateViewer,
 		waiter:      waiter,
+		journal:     jw,
 		challenge:   abi.Randomness{},
 	}
 }
@@ -158,7 +167,9 @@ func (p *Poster) doPoSt(ctx context.Context, stateView *appstate.View, deadlineI
 		return
 	}
 
+	p.proving.Lock()
 	proofs, err := p.mgr.GenerateWindowPoSt(ctx, abi.ActorID(minerID), sectors, abi.PoStRandomness(p.challenge))
+	p.proving.Unlock()
 	if err != nil {
 		log.Errorf("error generating window PoSt: %s", err)
 		return
@@ -203,6 +214,7 @@ func (p *Poster) sendPoSt(ctx context.Context, workerAddr address.Address, index
 	if err := <-errCh; err != nil {
 		return err
 	}
+	p.journal.Write("post-submitted", "miner", p.minerAddr.String(), "deadline", index, "partitions", partitions, "message", mcid.String())
 
 	// wait until we see the post on chain at least once
 	err = p.waiter.Wait(ctx, mcid, msg.DefaultMessageWaitLookback, func(_ *block.Block, _ *types.SignedMessage, recp *vm.MessageReceipt) error {
@@ -211,6 +223,7 @@ func (p *Poster) sendPoSt(ctx context.Context, workerAddr address.Address, index
 	if err != nil {
 		return err
 	}
+	p.journal.Write("post-landed", "miner", p.minerAddr.String(), "deadline", index, "message", mcid.String())
 
 	return nil
 }
@@ -0,0 +1,106 @@
+package proofs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+
+	sectorstorage "github.com/filecoin-project/sector-storage"
+	"github.com/filecoin-project/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/sector-storage/mock"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/minio/blake2b-simd"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/constants"
+)
+
+// MockProver seals sectors with the mock sector manager of sector-storage and
+// generates mock PoSts for them, which MockVerifier accepts. A mock PoSt is a
+// hash of the prover and the PoSt randomness, so it is deterministic and cheap
+// but proves nothing.
+type MockProver struct {
+	*mock.SectorMgr
+}
+
+var _ sectorstorage.SectorManager = (*MockProver)(nil)
+
+// NewMockProver creates a mock prover sealing sectors of a size.
+func NewMockProver(sectorSize abi.SectorSize) *MockProver {
+	return &MockProver{SectorMgr: mock.NewMockSectorMgr(sectorSize)}
+}
+
+// GenerateWinningPoSt generates a mock winning PoSt.
+func (p *MockProver) GenerateWinningPoSt(_ context.Context, minerID abi.ActorID, sectorInfo []abi.SectorInfo, randomness abi.PoStRandomness) ([]abi.PoStProof, error) {
+	proofType := constants.DevRegisteredWinningPoStProof
+	if len(sectorInfo) > 0 {
+		var err error
+		if proofType, err = sectorInfo[0].RegisteredProof.RegisteredWinningPoStProof(); err != nil {
+			return nil, err
+		}
+	}
+	return []abi.PoStProof{{RegisteredProof: proofType, ProofBytes: mockPoSt(minerID, randomness)}}, nil
+}
+
+// GenerateWindowPoSt generates a mock window PoSt.
+func (p *MockProver) GenerateWindowPoSt(_ context.Context, minerID abi.ActorID, sectorInfo []abi.SectorInfo, randomness abi.PoStRandomness) ([]abi.PoStProof, error) {
+	proofType := constants.DevRegisteredWindowPoStProof
+	if len(sectorInfo) > 0 {
+		var err error
+		if proofType, err = sectorInfo[0].RegisteredProof.RegisteredWindowPoStProof(); err != nil {
+			return nil, err
+		}
+	}
+	return []abi.PoStProof{{RegisteredProof: proofType, ProofBytes: mockPoSt(minerID, randomness)}}, nil
+}
+
+// GenerateWinningPoStSectorChallenge challenges the sectors MockVerifier
+// expects a winning PoSt over.
+func (p *MockProver) GenerateWinningPoStSectorChallenge(ctx context.Context, proofType abi.RegisteredProof, minerID abi.ActorID, randomness abi.PoStRandomness, eligibleSectorCount uint64) ([]uint64, error) {
+	return MockVerifier{}.GenerateWinningPoStSectorChallenge(ctx, proofType, minerID, randomness, eligibleSectorCount)
+}
+
+// MockVerifier verifies the mock seals of the sector-storage mock sector
+// manager and the mock PoSts of MockProver.
+type MockVerifier struct{}
+
+var _ ffiwrapper.Verifier = MockVerifier{}
+
+// VerifySeal verifies a mock seal.
+func (MockVerifier) VerifySeal(info abi.SealVerifyInfo) (bool, error) {
+	return mock.MockVerifier.VerifySeal(info)
+}
+
+// VerifyWinningPoSt verifies a mock winning PoSt.
+func (MockVerifier) VerifyWinningPoSt(_ context.Context, info abi.WinningPoStVerifyInfo) (bool, error) {
+	return verifyMockPoSt(info.Prover, info.Randomness, info.Proofs), nil
+}
+
+// VerifyWindowPoSt verifies a mock window PoSt.
+func (MockVerifier) VerifyWindowPoSt(_ context.Context, info abi.WindowPoStVerifyInfo) (bool, error) {
+	return verifyMockPoSt(info.Prover, info.Randomness, info.Proofs), nil
+}
+
+// GenerateWinningPoStSectorChallenge challenges the first sector.
+func (MockVerifier) GenerateWinningPoStSectorChallenge(ctx context.Context, proofType abi.RegisteredProof, minerID abi.ActorID, randomness abi.PoStRandomness, eligibleSectorCount uint64) ([]uint64, error) {
+	return mock.MockVerifier.GenerateWinningPoStSectorChallenge(ctx, proofType, minerID, randomness, eligibleSectorCount)
+}
+
+func mockPoSt(minerID abi.ActorID, randomness abi.PoStRandomness) []byte {
+	buf := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(randomness))
+	buf = append(buf[:binary.PutUvarint(buf, uint64(minerID))], randomness...)
+	sum := blake2b.Sum256(buf)
+	return sum[:]
+}
+
+func verifyMockPoSt(minerID abi.ActorID, randomness abi.PoStRandomness, proofs []abi.PoStProof) bool {
+	if len(proofs) == 0 {
+		return false
+	}
+	expected := mockPoSt(minerID, randomness)
+	for _, proof := range proofs {
+		if !bytes.Equal(proof.ProofBytes, expected) {
+			return false
+		}
+	}
+	return true
+}
@@ -8,4 +8,5 @@ import (
 type API interface {
 	Client() iface.RetrievalClient
 	Provider() iface.RetrievalProvider
+	Retriever() *Retriever
 }
@@ -0,0 +1,385 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	bserv "github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-merkledag"
+	uio "github.com/ipfs/go-unixfs/io"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+)
+
+// Progress reports the state of a retrieval.
+type Progress struct {
+	DealID retrievalmarket.DealID
+	Status string
+	// Received is the number of bytes received out of Size, the size of the
+	// piece holding the payload.
+	Received uint64
+	Size     uint64
+	// FundsSpent is the amount paid to the miner through vouchers so far, out
+	// of TotalFunds.
+	FundsSpent     abi.TokenAmount
+	TotalFunds     abi.TokenAmount
+	PaymentChannel address.Address
+	Message        string
+	// Local is set when the payload was already stored locally, so that no
+	// deal was made.
+	Local bool
+}
+
+// StatusLocal is the status reported for payloads retrieved from local storage.
+const StatusLocal = "Local"
+
+type retrievalClient interface {
+	Query(ctx context.Context, p retrievalmarket.RetrievalPeer, payloadCID cid.Cid, params retrievalmarket.QueryParams) (retrievalmarket.QueryResponse, error)
+	Retrieve(ctx context.Context, payloadCID cid.Cid, params retrievalmarket.Params, totalFunds abi.TokenAmount, miner peer.ID, clientWallet address.Address, minerWallet address.Address) (retrievalmarket.DealID, error)
+	SubscribeToEvents(subscriber retrievalmarket.ClientSubscriber) retrievalmarket.Unsubscribe
+}
+
+type dealKey struct {
+	payload cid.Cid
+	miner   peer.ID
+}
+
+// trackedDeal is the latest state of a deal. Changed is closed and replaced on
+// each update. Claimed is set once the retrieval that proposed the deal knows
+// its id.
+type trackedDeal struct {
+	id      retrievalmarket.DealID
+	size    uint64
+	state   retrievalmarket.ClientDealState
+	seen    bool
+	claimed bool
+	changed chan struct{}
+}
+
+// startingDeal reserves a payload and miner while a deal for them is being
+// proposed. Done is closed once deal or err is set.
+type startingDeal struct {
+	done chan struct{}
+	deal *trackedDeal
+	err  error
+}
+
+// Retriever retrieves payloads from miners into the local blockstore, paying
+// them incrementally through a payment channel from the client to the miner,
+// which is created on the first retrieval and topped up on the next ones.
+//
+// Deals continue when the caller waiting on them goes away, and a later
+// retrieval of the same payload from the same miner resumes waiting on the
+// deal in progress rather than paying for another. Deals do not survive a
+// restart of the node: the markets client retrieves whole payloads only, so a
+// deal interrupted by a restart cannot be resumed and a later retrieval pays
+// for the whole payload again. The blocks received remain in the blockstore,
+// and a payload whose blocks are all there is not retrieved again.
+type Retriever struct {
+	client retrievalClient
+	dag    ipld.DAGService
+
+	lk sync.Mutex
+	// deals holds the deals in progress, including those whose events arrive
+	// before Retrieve returns their id. Deals are dropped once they end.
+	deals    map[retrievalmarket.DealID]*trackedDeal
+	inflight map[dealKey]*trackedDeal
+	// starting holds the deals being proposed, so that concurrent retrievals
+	// of the same payload from the same miner propose a single deal.
+	starting map[dealKey]*startingDeal
+}
+
+// NewRetriever creates a retriever making deals with a retrieval market client
+// that stores blocks in a blockstore.
+func NewRetriever(client retrievalClient, bs blockstore.Blockstore) *Retriever {
+	r := &Retriever{
+		client:   client,
+		dag:      merkledag.NewDAGService(bserv.New(bs, offline.Exchange(bs))),
+		deals:    make(map[retrievalmarket.DealID]*trackedDeal),
+		inflight: make(map[dealKey]*trackedDeal),
+		starting: make(map[dealKey]*startingDeal),
+	}
+	client.SubscribeToEvents(r.handleEvent)
+	return r
+}
+
+// Retrieve retrieves a payload from a miner, calling progress with each change
+// of the deal, and returns when the payload is stored locally. The client
+// pays from `clientAddr`.
+func (r *Retriever) Retrieve(ctx context.Context, miner retrievalmarket.RetrievalPeer, payload cid.Cid, clientAddr address.Address, progress func(Progress)) error {
+	rt, err := r.Open(ctx, miner, payload, clientAddr)
+	if err != nil {
+		return err
+	}
+	return rt.Wait(ctx, progress)
+}
+
+// Open starts retrieving a payload from a miner, or attaches to the deal in
+// progress retrieving it, unless the payload is stored locally. The client
+// pays from `clientAddr`.
+func (r *Retriever) Open(ctx context.Context, miner retrievalmarket.RetrievalPeer, payload cid.Cid, clientAddr address.Address) (*Retrieval, error) {
+	local, err := r.HasPayload(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	if local {
+		return &Retrieval{r: r, payload: payload}, nil
+	}
+	deal, err := r.dealFor(ctx, miner, payload, clientAddr)
+	if err != nil {
+		return nil, err
+	}
+	return &Retrieval{r: r, payload: payload, deal: deal}, nil
+}
+
+// Retrieval is a payload being retrieved by a deal, or stored locally.
+type Retrieval struct {
+	r       *Retriever
+	payload cid.Cid
+	// deal is nil when the payload is stored locally.
+	deal *trackedDeal
+}
+
+// Wait calls progress with each change of the deal and returns when the
+// payload is stored locally.
+func (rt *Retrieval) Wait(ctx context.Context, progress func(Progress)) error {
+	if rt.deal == nil {
+		progress(Progress{Status: StatusLocal, FundsSpent: big.Zero(), TotalFunds: big.Zero(), Local: true})
+		return nil
+	}
+
+	for {
+		state, seen, size, changed := rt.snapshot()
+		if seen {
+			progress(progressOf(state, size))
+			if retrievalmarket.IsTerminalSuccess(state.Status) {
+				return nil
+			}
+			if terminal(state.Status) {
+				return rt.failure(state)
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Read returns a reader of the unixfs payload that reads its blocks as the
+// deal receives them, so that the payload is read while the deal pays for the
+// rest of it.
+func (rt *Retrieval) Read(ctx context.Context) (io.Reader, error) {
+	root, err := rt.Get(ctx, rt.payload)
+	if err != nil {
+		return nil, err
+	}
+	return uio.NewDagReader(ctx, root, rt)
+}
+
+// Get returns a node of the payload, waiting for the deal to receive it.
+func (rt *Retrieval) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	for {
+		// The deal is read before the blockstore, so that a block received in
+		// between wakes the wait below.
+		var state retrievalmarket.ClientDealState
+		var seen bool
+		var changed chan struct{}
+		if rt.deal != nil {
+			state, seen, _, changed = rt.snapshot()
+		}
+
+		nd, err := rt.r.dag.Get(ctx, c)
+		if err == nil || !isNotFound(err) {
+			return nd, err
+		}
+		if rt.deal == nil {
+			return nil, err
+		}
+		if seen && terminal(state.Status) {
+			return nil, errors.Wrapf(rt.failure(state), "block %s was not received", c)
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// GetMany returns the nodes of the payload in order, as the deal receives them.
+func (rt *Retrieval) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption, len(cids))
+	go func() {
+		defer close(out)
+		for _, c := range cids {
+			nd, err := rt.Get(ctx, c)
+			out <- &ipld.NodeOption{Node: nd, Err: err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (rt *Retrieval) snapshot() (retrievalmarket.ClientDealState, bool, uint64, chan struct{}) {
+	rt.r.lk.Lock()
+	defer rt.r.lk.Unlock()
+	return rt.deal.state, rt.deal.seen, rt.deal.size, rt.deal.changed
+}
+
+func (rt *Retrieval) failure(state retrievalmarket.ClientDealState) error {
+	if retrievalmarket.IsTerminalSuccess(state.Status) {
+		return fmt.Errorf("retrieval deal %d completed", rt.deal.id)
+	}
+	return fmt.Errorf("retrieval deal %d %s: %s", rt.deal.id, retrievalmarket.DealStatuses[state.Status], state.Message)
+}
+
+// HasPayload returns whether all the blocks of a payload are stored locally.
+func (r *Retriever) HasPayload(ctx context.Context, payload cid.Cid) (bool, error) {
+	err := merkledag.Walk(ctx, merkledag.GetLinksDirect(r.dag), payload, cid.NewSet().Visit)
+	if err == nil {
+		return true, nil
+	}
+	if isNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+func isNotFound(err error) bool {
+	return err == ipld.ErrNotFound || errors.Cause(err) == blockstore.ErrNotFound
+}
+
+// dealFor returns the deal in progress retrieving a payload from a miner, or
+// starts one. A caller finding a deal being started waits for it, and starts
+// one itself if that fails.
+func (r *Retriever) dealFor(ctx context.Context, miner retrievalmarket.RetrievalPeer, payload cid.Cid, clientAddr address.Address) (*trackedDeal, error) {
+	key := dealKey{payload: payload, miner: miner.ID}
+	for {
+		r.lk.Lock()
+		if deal, ok := r.inflight[key]; ok {
+			r.lk.Unlock()
+			return deal, nil
+		}
+		start, ok := r.starting[key]
+		if !ok {
+			start = &startingDeal{done: make(chan struct{})}
+			r.starting[key] = start
+			r.lk.Unlock()
+
+			start.deal, start.err = r.startDeal(ctx, miner, payload, clientAddr)
+			r.lk.Lock()
+			delete(r.starting, key)
+			r.lk.Unlock()
+			close(start.done)
+			return start.deal, start.err
+		}
+		r.lk.Unlock()
+
+		select {
+		case <-start.done:
+			if start.err == nil {
+				return start.deal, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// startDeal queries the miner for its terms and proposes a deal for the whole
+// piece holding the payload.
+func (r *Retriever) startDeal(ctx context.Context, miner retrievalmarket.RetrievalPeer, payload cid.Cid, clientAddr address.Address) (*trackedDeal, error) {
+	resp, err := r.client.Query(ctx, miner, payload, retrievalmarket.QueryParams{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query miner %s", miner.Address)
+	}
+	if resp.Status != retrievalmarket.QueryResponseAvailable {
+		return nil, fmt.Errorf("miner %s cannot serve %s: %s", miner.Address, payload, resp.Message)
+	}
+
+	params := retrievalmarket.NewParamsV0(resp.MinPricePerByte, resp.MaxPaymentInterval, resp.MaxPaymentIntervalIncrease)
+
+	id, err := r.client.Retrieve(ctx, payload, params, resp.PieceRetrievalPrice(), miner.ID, clientAddr, resp.PaymentAddress)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to start retrieval deal with miner %s", miner.Address)
+	}
+
+	// Events of the deal may have been handled before it is tracked here.
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	deal := r.dealLocked(id)
+	deal.size = resp.Size
+	deal.claimed = true
+	if deal.seen && terminal(deal.state.Status) {
+		delete(r.deals, id)
+	} else {
+		r.inflight[dealKey{payload: payload, miner: miner.ID}] = deal
+	}
+	return deal, nil
+}
+
+func (r *Retriever) handleEvent(_ retrievalmarket.ClientEvent, state retrievalmarket.ClientDealState) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	deal := r.dealLocked(state.ID)
+	deal.state = state
+	deal.seen = true
+	close(deal.changed)
+	deal.changed = make(chan struct{})
+
+	if terminal(state.Status) {
+		delete(r.inflight, dealKey{payload: state.PayloadCID, miner: state.Sender})
+		// A deal ending before its retrieval knows its id is dropped once
+		// claimed.
+		if deal.claimed {
+			delete(r.deals, state.ID)
+		}
+	}
+}
+
+func (r *Retriever) dealLocked(id retrievalmarket.DealID) *trackedDeal {
+	deal, ok := r.deals[id]
+	if !ok {
+		deal = &trackedDeal{id: id, changed: make(chan struct{})}
+		r.deals[id] = deal
+	}
+	return deal
+}
+
+// terminal returns whether a deal has ended, well or not.
+func terminal(status retrievalmarket.DealStatus) bool {
+	return retrievalmarket.IsTerminalStatus(status) || status == retrievalmarket.DealStatusErrored
+}
+
+func progressOf(state retrievalmarket.ClientDealState, size uint64) Progress {
+	p := Progress{
+		DealID:     state.ID,
+		Status:     retrievalmarket.DealStatuses[state.Status],
+		Received:   state.TotalReceived,
+		Size:       size,
+		FundsSpent: state.FundsSpent,
+		TotalFunds: state.TotalFunds,
+		Message:    state.Message,
+	}
+	if state.PaymentInfo != nil {
+		p.PaymentChannel = state.PaymentInfo.PayCh
+	}
+	return p
+}
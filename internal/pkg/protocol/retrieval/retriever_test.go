@@ -0,0 +1,228 @@
+package retrieval_test
+
+import (
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/retrievalmarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipfs/go-merkledag"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/retrieval"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+// fakeClient proposes deals with increasing ids and reports them on `proposed`.
+type fakeClient struct {
+	lk         sync.Mutex
+	subscriber retrievalmarket.ClientSubscriber
+	nextID     retrievalmarket.DealID
+	proposed   chan retrievalmarket.ClientDealState
+}
+
+func (c *fakeClient) Query(context.Context, retrievalmarket.RetrievalPeer, cid.Cid, retrievalmarket.QueryParams) (retrievalmarket.QueryResponse, error) {
+	return retrievalmarket.QueryResponse{
+		Status:                     retrievalmarket.QueryResponseAvailable,
+		Size:                       100,
+		MinPricePerByte:            abi.NewTokenAmount(2),
+		MaxPaymentInterval:         10,
+		MaxPaymentIntervalIncrease: 10,
+	}, nil
+}
+
+func (c *fakeClient) Retrieve(_ context.Context, payload cid.Cid, params retrievalmarket.Params, totalFunds abi.TokenAmount, miner peer.ID, _ address.Address, _ address.Address) (retrievalmarket.DealID, error) {
+	c.lk.Lock()
+	c.nextID++
+	id := c.nextID
+	c.lk.Unlock()
+	state := retrievalmarket.ClientDealState{
+		DealProposal: retrievalmarket.DealProposal{PayloadCID: payload, ID: id, Params: params},
+		TotalFunds:   totalFunds,
+		Sender:       miner,
+		FundsSpent:   abi.NewTokenAmount(0),
+	}
+	go func() { c.proposed <- state }()
+	return id, nil
+}
+
+func (c *fakeClient) SubscribeToEvents(subscriber retrievalmarket.ClientSubscriber) retrievalmarket.Unsubscribe {
+	c.subscriber = subscriber
+	return func() {}
+}
+
+func (c *fakeClient) update(state retrievalmarket.ClientDealState, status retrievalmarket.DealStatus, received uint64) {
+	state.Status = status
+	state.TotalReceived = received
+	c.subscriber(retrievalmarket.ClientEventBlocksReceived, state)
+}
+
+func TestRetriever(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	client := &fakeClient{proposed: make(chan retrievalmarket.ClientDealState)}
+	retriever := retrieval.NewRetriever(client, bs)
+	miner := retrievalmarket.RetrievalPeer{Address: vmaddr.RequireIDAddress(t, 100), ID: peer.ID("miner")}
+	clientAddr := vmaddr.NewForTestGetter()()
+
+	t.Log("payloads stored locally are not retrieved")
+	local := merkledag.NewRawNode([]byte("local"))
+	require.NoError(t, bs.Put(local))
+	var progress []retrieval.Progress
+	record := func(p retrieval.Progress) { progress = append(progress, p) }
+	require.NoError(t, retriever.Retrieve(ctx, miner, local.Cid(), clientAddr, record))
+	require.Len(t, progress, 1)
+	assert.True(t, progress[0].Local)
+
+	t.Log("a retrieval started again while in progress waits for the same deal")
+	remote := merkledag.NewRawNode([]byte("remote")).Cid()
+	cancelled, cancel := context.WithCancel(ctx)
+	interrupted := make(chan error)
+	go func() {
+		interrupted <- retriever.Retrieve(cancelled, miner, remote, clientAddr, func(retrieval.Progress) {})
+	}()
+	state := <-client.proposed
+	assert.Equal(t, abi.NewTokenAmount(200), state.TotalFunds)
+	client.update(state, retrievalmarket.DealStatusOngoing, 50)
+	cancel()
+	assert.Equal(t, context.Canceled, <-interrupted)
+
+	progress = nil
+	attached := make(chan struct{}, 1)
+	resumed := make(chan error)
+	go func() {
+		resumed <- retriever.Retrieve(ctx, miner, remote, clientAddr, func(p retrieval.Progress) {
+			record(p)
+			select {
+			case attached <- struct{}{}:
+			default:
+			}
+		})
+	}()
+	<-attached
+	client.update(state, retrievalmarket.DealStatusCompleted, 100)
+	require.NoError(t, <-resumed)
+	require.NotEmpty(t, progress)
+	last := progress[len(progress)-1]
+	assert.Equal(t, state.ID, last.DealID)
+	assert.Equal(t, uint64(100), last.Received)
+	assert.Equal(t, uint64(100), last.Size)
+	assert.Equal(t, "DealStatusCompleted", last.Status)
+
+	t.Log("failed deals return an error and are not resumed")
+	failed := make(chan error)
+	go func() { failed <- retriever.Retrieve(ctx, miner, remote, clientAddr, func(retrieval.Progress) {}) }()
+	state = <-client.proposed
+	assert.Equal(t, retrievalmarket.DealID(2), state.ID)
+	client.update(state, retrievalmarket.DealStatusFailed, 0)
+	assert.Error(t, <-failed)
+
+	go func() { failed <- retriever.Retrieve(ctx, miner, remote, clientAddr, func(retrieval.Progress) {}) }()
+	state = <-client.proposed
+	assert.Equal(t, retrievalmarket.DealID(3), state.ID)
+	client.update(state, retrievalmarket.DealStatusCompleted, 100)
+	assert.NoError(t, <-failed)
+}
+
+func TestRetrieverStartsOneDealForConcurrentRetrievals(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	client := &fakeClient{proposed: make(chan retrievalmarket.ClientDealState)}
+	retriever := retrieval.NewRetriever(client, bs)
+	miner := retrievalmarket.RetrievalPeer{Address: vmaddr.RequireIDAddress(t, 100), ID: peer.ID("miner")}
+	clientAddr := vmaddr.NewForTestGetter()()
+	remote := merkledag.NewRawNode([]byte("remote")).Cid()
+
+	attached := make(chan retrievalmarket.DealID, 2)
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			once := false
+			done <- retriever.Retrieve(ctx, miner, remote, clientAddr, func(p retrieval.Progress) {
+				if !once {
+					once = true
+					attached <- p.DealID
+				}
+			})
+		}()
+	}
+
+	state := <-client.proposed
+	client.update(state, retrievalmarket.DealStatusOngoing, 50)
+	assert.Equal(t, state.ID, <-attached)
+	assert.Equal(t, state.ID, <-attached)
+	client.update(state, retrievalmarket.DealStatusCompleted, 100)
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+
+	client.lk.Lock()
+	defer client.lk.Unlock()
+	assert.Equal(t, retrievalmarket.DealID(1), client.nextID)
+}
+
+func TestRetrieverReadsPayloadAsItArrives(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	client := &fakeClient{proposed: make(chan retrievalmarket.ClientDealState)}
+	retriever := retrieval.NewRetriever(client, bs)
+	miner := retrievalmarket.RetrievalPeer{Address: vmaddr.RequireIDAddress(t, 100), ID: peer.ID("miner")}
+	clientAddr := vmaddr.NewForTestGetter()()
+
+	t.Log("the payload is read once the deal receives it")
+	remote := merkledag.NewRawNode([]byte("remote"))
+	opened := make(chan *retrieval.Retrieval)
+	go func() {
+		rt, err := retriever.Open(ctx, miner, remote.Cid(), clientAddr)
+		assert.NoError(t, err)
+		opened <- rt
+	}()
+	state := <-client.proposed
+	rt := <-opened
+	read := make(chan []byte)
+	go func() {
+		r, err := rt.Read(ctx)
+		assert.NoError(t, err)
+		data, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		read <- data
+	}()
+	client.update(state, retrievalmarket.DealStatusOngoing, 0)
+	require.NoError(t, bs.Put(remote))
+	client.update(state, retrievalmarket.DealStatusOngoing, 6)
+	assert.Equal(t, []byte("remote"), <-read)
+	client.update(state, retrievalmarket.DealStatusCompleted, 6)
+	require.NoError(t, rt.Wait(ctx, func(retrieval.Progress) {}))
+
+	t.Log("reading fails when the deal fails before receiving the payload")
+	missing := merkledag.NewRawNode([]byte("missing")).Cid()
+	go func() {
+		rt, err := retriever.Open(ctx, miner, missing, clientAddr)
+		assert.NoError(t, err)
+		opened <- rt
+	}()
+	state = <-client.proposed
+	rt = <-opened
+	failed := make(chan error)
+	go func() {
+		_, err := rt.Read(ctx)
+		failed <- err
+	}()
+	client.update(state, retrievalmarket.DealStatusFailed, 0)
+	assert.Error(t, <-failed)
+}
@@ -2,19 +2,35 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/config"
 	"github.com/filecoin-project/go-filecoin/internal/pkg/piecemanager"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage/asks"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage/tracker"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	fsm "github.com/filecoin-project/storage-fsm"
 )
 
+// storageAskTimeout bounds how long storage miners have to answer a query for
+// their ask when asks are listed.
+const storageAskTimeout = 10 * time.Second
+
 type storage interface {
 	Client() storagemarket.StorageClient
 	Provider() (storagemarket.StorageProvider, error)
 	PieceManager() (piecemanager.PieceManager, error)
+	DealTracker() *tracker.Tracker
+	AskManager() (*asks.Manager, error)
+	VerifiedPrice(ctx context.Context, info storagemarket.StorageProviderInfo) (abi.TokenAmount, bool, error)
 }
 
 // API is the storage API for the test environment
@@ -37,14 +53,86 @@ func (api *API) PledgeSector(ctx context.Context) error {
 	return pm.PledgeSector(ctx)
 }
 
-// AddAsk stores a new price for storage
+// ListSectors lists the sectors of the miner known to the sealing state machine.
+func (api *API) ListSectors(ctx context.Context) ([]fsm.SectorInfo, error) {
+	pm, err := api.storage.PieceManager()
+	if err != nil {
+		return nil, err
+	}
+
+	return pm.ListSectors(ctx)
+}
+
+// GetSector returns the sealing state of a sector of the miner.
+func (api *API) GetSector(ctx context.Context, sectorNum abi.SectorNumber) (fsm.SectorInfo, error) {
+	pm, err := api.storage.PieceManager()
+	if err != nil {
+		return fsm.SectorInfo{}, err
+	}
+
+	return pm.GetSectorInfo(ctx, sectorNum)
+}
+
+// UpdateSectorState forces a sector of the miner into a sealing state, from
+// which sealing carries on.
+func (api *API) UpdateSectorState(ctx context.Context, sectorNum abi.SectorNumber, state fsm.SectorState) error {
+	pm, err := api.storage.PieceManager()
+	if err != nil {
+		return err
+	}
+
+	return pm.ForceSectorState(ctx, sectorNum, state)
+}
+
+// RemoveSector stops sealing a sector of the miner and forgets it.
+func (api *API) RemoveSector(ctx context.Context, sectorNum abi.SectorNumber) error {
+	pm, err := api.storage.PieceManager()
+	if err != nil {
+		return err
+	}
+
+	return pm.RemoveSector(ctx, sectorNum)
+}
+
+// StoragePaths lists the directories holding sector data of the miner.
+func (api *API) StoragePaths(ctx context.Context) ([]piecemanager.StoragePath, error) {
+	pm, err := api.storage.PieceManager()
+	if err != nil {
+		return nil, err
+	}
+
+	return pm.StoragePaths(ctx)
+}
+
+// AttachStoragePath adds a directory to hold sector data of the miner.
+func (api *API) AttachStoragePath(ctx context.Context, cfg config.StoragePathConfig) error {
+	pm, err := api.storage.PieceManager()
+	if err != nil {
+		return err
+	}
+
+	return pm.AttachStoragePath(ctx, cfg)
+}
+
+// MoveSector moves a sealed sector of the miner to another storage path.
+func (api *API) MoveSector(ctx context.Context, sectorNum abi.SectorNumber, storageID string) error {
+	pm, err := api.storage.PieceManager()
+	if err != nil {
+		return err
+	}
+
+	return pm.MoveSector(ctx, sectorNum, storageID)
+}
+
+// AddAsk signs a new ask at a price and duration, keeping the other terms of
+// the miner's ask.
 func (api *API) AddAsk(price abi.TokenAmount, duration abi.ChainEpoch) error {
-	provider, err := api.storage.Provider()
+	askManager, err := api.storage.AskManager()
 	if err != nil {
 		return err
 	}
 
-	return provider.AddAsk(price, duration)
+	return askManager.SetPrice(price, duration)
 }
 
 // ListAsks lists all asks for the miner
@@ -57,6 +145,138 @@ func (api *API) ListAsks(maddr address.Address) ([]*storagemarket.SignedStorageA
 	return provider.ListAsks(maddr), nil
 }
 
+// AskStatus returns the ask of the miner with its terms and the changes of
+// terms scheduled.
+func (api *API) AskStatus() (asks.Status, error) {
+	askManager, err := api.storage.AskManager()
+	if err != nil {
+		return asks.Status{}, err
+	}
+
+	return askManager.Status()
+}
+
+// SetAskTerms signs a new ask of the miner with the terms.
+func (api *API) SetAskTerms(terms asks.Terms) error {
+	askManager, err := api.storage.AskManager()
+	if err != nil {
+		return err
+	}
+
+	return askManager.SetTerms(terms)
+}
+
+// ScheduleAsk schedules the terms of the miner's ask to change at an epoch.
+func (api *API) ScheduleAsk(epoch abi.ChainEpoch, terms asks.Terms) error {
+	askManager, err := api.storage.AskManager()
+	if err != nil {
+		return err
+	}
+
+	return askManager.Schedule(epoch, terms)
+}
+
+// UnscheduleAsk cancels the change of the miner's ask scheduled at an epoch.
+func (api *API) UnscheduleAsk(epoch abi.ChainEpoch) error {
+	askManager, err := api.storage.AskManager()
+	if err != nil {
+		return err
+	}
+
+	return askManager.Unschedule(epoch)
+}
+
+// StorageAsk is the signed ask of a storage miner along with the price
+// verified deals must pay at least, which the signed ask has no room for.
+type StorageAsk struct {
+	*storagemarket.SignedStorageAsk
+	// VerifiedPrice is nil when the miner does not tell it.
+	VerifiedPrice *abi.TokenAmount `json:",omitempty"`
+}
+
+// ListStorageAsks queries the current asks of the storage miners with power
+// claims on chain, and their verified deal prices, ordered by price. Miners
+// which cannot be reached are left out. The ask of the node's own miner, if
+// any, is read locally.
+func (api *API) ListStorageAsks(ctx context.Context, self address.Address) ([]StorageAsk, error) {
+	providers, err := api.ListStorageProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := []StorageAsk{}
+	if provider, err := api.storage.Provider(); err == nil && self != address.Undef {
+		var verifiedPrice *abi.TokenAmount
+		if askManager, err := api.storage.AskManager(); err == nil {
+			if price, ok := askManager.VerifiedPrice(); ok {
+				verifiedPrice = &price
+			}
+		}
+		for _, signed := range provider.ListAsks(self) {
+			listed = append(listed, StorageAsk{SignedStorageAsk: signed, VerifiedPrice: verifiedPrice})
+		}
+	}
+
+	var lk sync.Mutex
+	var wg sync.WaitGroup
+	for _, info := range providers {
+		if info.Address == self {
+			continue
+		}
+		wg.Add(1)
+		go func(info storagemarket.StorageProviderInfo) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, storageAskTimeout)
+			defer cancel()
+			signed, err := api.storage.Client().GetAsk(ctx, info)
+			if err != nil || signed == nil || signed.Ask == nil {
+				return
+			}
+			ask := StorageAsk{SignedStorageAsk: signed}
+			// Miners running other implementations do not tell their
+			// verified deal price.
+			if price, ok, err := api.storage.VerifiedPrice(ctx, info); err == nil && ok {
+				ask.VerifiedPrice = &price
+			}
+			lk.Lock()
+			listed = append(listed, ask)
+			lk.Unlock()
+		}(info)
+	}
+	wg.Wait()
+
+	sort.Slice(listed, func(i, j int) bool {
+		a, b := listed[i].Ask, listed[j].Ask
+		if !a.Price.Equals(b.Price) {
+			return a.Price.LessThan(b.Price)
+		}
+		return a.Miner.String() < b.Miner.String()
+	})
+	return listed, ctx.Err()
+}
+
+// ListStorageProviders lists the storage miners with power claims on chain.
+func (api *API) ListStorageProviders(ctx context.Context) ([]storagemarket.StorageProviderInfo, error) {
+	providers, err := api.storage.Client().ListProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var infos []storagemarket.StorageProviderInfo
+	for info := range providers {
+		infos = append(infos, info)
+	}
+	return infos, ctx.Err()
+}
+
+// GetStorageAsk queries a storage miner for its current ask.
+func (api *API) GetStorageAsk(ctx context.Context, info storagemarket.StorageProviderInfo) (*storagemarket.StorageAsk, error) {
+	ask, err := api.storage.Client().GetAsk(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+	return ask.Ask, nil
+}
+
 // ProposeStorageDeal proposes a storage deal
 func (api *API) ProposeStorageDeal(
 	ctx context.Context,
@@ -90,3 +310,44 @@ func (api *API) GetProviderDeals(ctx context.Context) ([]storagemarket.MinerDeal
 	}
 	return provider.ListLocalDeals()
 }
+
+// DealChainStatuses lists the statuses on chain of the published deals made by
+// or with the node.
+func (api *API) DealChainStatuses() []tracker.DealStatus {
+	return api.storage.DealTracker().Statuses()
+}
+
+// DealChainStatus returns the status on chain of a published deal in which the
+// node plays a role.
+func (api *API) DealChainStatus(role tracker.Role, proposalCid cid.Cid) (tracker.DealStatus, bool) {
+	return api.storage.DealTracker().Status(role, proposalCid)
+}
+
+// ImportDataForDeal imports the piece of a deal proposed with a manual
+// transfer, once it has been accepted and is waiting for data. The provider
+// verifies the piece commitment of the data against the proposal before handing
+// the piece off to be sealed.
+func (api *API) ImportDataForDeal(ctx context.Context, proposalCid cid.Cid, data io.Reader) error {
+	provider, err := api.storage.Provider()
+	if err != nil {
+		return err
+	}
+
+	deals, err := provider.ListLocalDeals()
+	if err != nil {
+		return err
+	}
+	for _, deal := range deals {
+		if !deal.ProposalCid.Equals(proposalCid) {
+			continue
+		}
+		if deal.Ref == nil || deal.Ref.TransferType != storagemarket.TTManual {
+			return fmt.Errorf("deal %s does not use a manual transfer", proposalCid)
+		}
+		if deal.State != storagemarket.StorageDealWaitingForData {
+			return fmt.Errorf("deal %s is not waiting for data, its state is %s", proposalCid, storagemarket.DealStates[deal.State])
+		}
+		return provider.ImportDataForDeal(ctx, proposalCid, data)
+	}
+	return fmt.Errorf("no deal with proposal %s", proposalCid)
+}
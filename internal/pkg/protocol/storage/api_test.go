@@ -0,0 +1,172 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/piecemanager"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage/asks"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage/tracker"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+// fakeProvider lists a fixed set of deals and records the data imported for them.
+type fakeProvider struct {
+	storagemarket.StorageProvider
+	deals    []storagemarket.MinerDeal
+	imported map[cid.Cid][]byte
+	ask      *storagemarket.SignedStorageAsk
+}
+
+func (p *fakeProvider) ListLocalDeals() ([]storagemarket.MinerDeal, error) {
+	return p.deals, nil
+}
+
+func (p *fakeProvider) ImportDataForDeal(_ context.Context, propCid cid.Cid, data io.Reader) error {
+	b, err := ioutil.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	p.imported[propCid] = b
+	return nil
+}
+
+func (p *fakeProvider) ListAsks(addr address.Address) []*storagemarket.SignedStorageAsk {
+	if p.ask == nil || p.ask.Ask.Miner != addr {
+		return nil
+	}
+	return []*storagemarket.SignedStorageAsk{p.ask}
+}
+
+// fakeClient answers ask queries for the asks of reachable miners.
+type fakeClient struct {
+	storagemarket.StorageClient
+	providers []storagemarket.StorageProviderInfo
+	asks      map[address.Address]*storagemarket.SignedStorageAsk
+}
+
+func (c *fakeClient) ListProviders(context.Context) (<-chan storagemarket.StorageProviderInfo, error) {
+	out := make(chan storagemarket.StorageProviderInfo, len(c.providers))
+	for _, info := range c.providers {
+		out <- info
+	}
+	close(out)
+	return out, nil
+}
+
+func (c *fakeClient) GetAsk(_ context.Context, info storagemarket.StorageProviderInfo) (*storagemarket.SignedStorageAsk, error) {
+	ask, ok := c.asks[info.Address]
+	if !ok {
+		return nil, errors.New("miner unreachable")
+	}
+	return ask, nil
+}
+
+type fakeStorage struct {
+	client         *fakeClient
+	provider       *fakeProvider
+	verifiedPrices map[address.Address]abi.TokenAmount
+}
+
+func (s *fakeStorage) Client() storagemarket.StorageClient {
+	return s.client
+}
+
+func (s *fakeStorage) Provider() (storagemarket.StorageProvider, error) {
+	return s.provider, nil
+}
+
+func (s *fakeStorage) PieceManager() (piecemanager.PieceManager, error) {
+	return nil, nil
+}
+
+func (s *fakeStorage) DealTracker() *tracker.Tracker {
+	return nil
+}
+
+func (s *fakeStorage) AskManager() (*asks.Manager, error) {
+	return nil, errors.New("not mining")
+}
+
+func (s *fakeStorage) VerifiedPrice(_ context.Context, info storagemarket.StorageProviderInfo) (abi.TokenAmount, bool, error) {
+	price, ok := s.verifiedPrices[info.Address]
+	return price, ok, nil
+}
+
+func signedAsk(miner address.Address, price int64) *storagemarket.SignedStorageAsk {
+	return &storagemarket.SignedStorageAsk{Ask: &storagemarket.StorageAsk{Miner: miner, Price: abi.NewTokenAmount(price)}}
+}
+
+func TestListStorageAsks(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	self, cheap, dear, down := vmaddr.RequireIDAddress(t, 1000), vmaddr.RequireIDAddress(t, 1001), vmaddr.RequireIDAddress(t, 1002), vmaddr.RequireIDAddress(t, 1003)
+
+	client := &fakeClient{
+		providers: []storagemarket.StorageProviderInfo{{Address: self}, {Address: cheap}, {Address: dear}, {Address: down}},
+		asks: map[address.Address]*storagemarket.SignedStorageAsk{
+			cheap: signedAsk(cheap, 10),
+			dear:  signedAsk(dear, 30),
+		},
+	}
+	provider := &fakeProvider{ask: signedAsk(self, 20)}
+	verifiedPrices := map[address.Address]abi.TokenAmount{dear: abi.NewTokenAmount(50)}
+	api := storage.NewAPI(&fakeStorage{client: client, provider: provider, verifiedPrices: verifiedPrices})
+
+	signed, err := api.ListStorageAsks(ctx, self)
+	require.NoError(t, err)
+	require.Len(t, signed, 3)
+	assert.Equal(t, cheap, signed[0].Ask.Miner)
+	assert.Equal(t, self, signed[1].Ask.Miner)
+	assert.Equal(t, dear, signed[2].Ask.Miner)
+
+	t.Log("asks carry the verified deal price of the miners which tell it")
+	assert.Nil(t, signed[0].VerifiedPrice)
+	require.NotNil(t, signed[2].VerifiedPrice)
+	assert.Equal(t, abi.NewTokenAmount(50), *signed[2].VerifiedPrice)
+
+	t.Log("nodes which do not mine list the asks of the other miners")
+	signed, err = api.ListStorageAsks(ctx, address.Undef)
+	require.NoError(t, err)
+	require.Len(t, signed, 2)
+}
+
+func TestImportDataForDeal(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	cids := types.NewCidForTestGetter()
+	waiting, graphsync, sealing, unknown := cids(), cids(), cids(), cids()
+
+	provider := &fakeProvider{
+		deals: []storagemarket.MinerDeal{
+			{ProposalCid: waiting, State: storagemarket.StorageDealWaitingForData, Ref: &storagemarket.DataRef{TransferType: storagemarket.TTManual}},
+			{ProposalCid: graphsync, State: storagemarket.StorageDealWaitingForData, Ref: &storagemarket.DataRef{TransferType: storagemarket.TTGraphsync}},
+			{ProposalCid: sealing, State: storagemarket.StorageDealSealing, Ref: &storagemarket.DataRef{TransferType: storagemarket.TTManual}},
+		},
+		imported: map[cid.Cid][]byte{},
+	}
+	api := storage.NewAPI(&fakeStorage{provider: provider})
+
+	require.NoError(t, api.ImportDataForDeal(ctx, waiting, bytes.NewReader([]byte("car"))))
+	assert.Equal(t, []byte("car"), provider.imported[waiting])
+
+	t.Log("only accepted deals with a manual transfer import data")
+	assert.Error(t, api.ImportDataForDeal(ctx, graphsync, bytes.NewReader(nil)))
+	assert.Error(t, api.ImportDataForDeal(ctx, sealing, bytes.NewReader(nil)))
+	assert.Error(t, api.ImportDataForDeal(ctx, unknown, bytes.NewReader(nil)))
+	assert.Len(t, provider.imported, 1)
+}
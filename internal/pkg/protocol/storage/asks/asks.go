@@ -0,0 +1,379 @@
+// Package asks manages the storage ask of the node's miner: the terms it
+// offers storage at, changes of the terms scheduled for later epochs, and the
+// renewal of the signed ask before it expires.
+package asks
+
+import (
+	"encoding/json"
+	"math/bits"
+	"sort"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	ds "github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
+)
+
+var log = logging.Logger("storage-asks")
+
+// asksKey is the key under which the terms and scheduled asks are kept in the
+// repo datastore.
+const asksKey = "/deals/asks"
+
+// Terms are the terms the miner offers storage at.
+type Terms struct {
+	// Price is the price per GiB per epoch of all deals.
+	Price abi.TokenAmount
+	// VerifiedPrice is the price per GiB per epoch verified deals must pay at
+	// least. The market checks every deal against Price, so verified deals pay
+	// the higher of both.
+	VerifiedPrice abi.TokenAmount
+	// MinPieceSize and MaxPieceSize bound the size of the pieces accepted, the
+	// market defaults applying when zero.
+	MinPieceSize abi.PaddedPieceSize
+	MaxPieceSize abi.PaddedPieceSize
+	// Duration is the number of epochs each signed ask is valid for.
+	Duration abi.ChainEpoch
+}
+
+// ScheduledAsk is a change of the terms taking effect at an epoch.
+type ScheduledAsk struct {
+	Epoch abi.ChainEpoch
+	Terms Terms
+}
+
+// Status is the ask of the miner with the terms it was signed with and the
+// changes scheduled.
+type Status struct {
+	// Terms are nil until an ask is set.
+	Terms     *Terms
+	Ask       *storagemarket.SignedStorageAsk
+	Scheduled []ScheduledAsk
+}
+
+// markets gives access to the storage provider signing the asks.
+type markets interface {
+	Provider() (storagemarket.StorageProvider, error)
+}
+
+// record is what is kept in the repo datastore.
+type record struct {
+	Terms     *Terms
+	Scheduled []ScheduledAsk
+}
+
+// Manager signs the asks of the miner, renewing them before they expire and
+// applying scheduled changes of terms as the chain reaches their epochs.
+type Manager struct {
+	markets   markets
+	minerAddr address.Address
+	ds        ds.Datastore
+	journal   journal.Writer
+
+	lk        sync.Mutex
+	terms     *Terms
+	scheduled []ScheduledAsk
+}
+
+// New creates an ask manager for a miner, keeping its terms in the repo
+// datastore.
+func New(markets markets, minerAddr address.Address, repoDs ds.Datastore, jw journal.Writer) (*Manager, error) {
+	m := &Manager{
+		markets:   markets,
+		minerAddr: minerAddr,
+		ds:        repoDs,
+		journal:   jw,
+	}
+
+	val, err := m.ds.Get(ds.NewKey(asksKey))
+	if err == ds.ErrNotFound {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec record
+	if err := json.Unmarshal(val, &rec); err != nil {
+		return nil, err
+	}
+	m.terms, m.scheduled = rec.Terms, rec.Scheduled
+	return m, nil
+}
+
+// Status returns the current ask of the miner and the changes scheduled.
+func (m *Manager) Status() (Status, error) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	signed, err := m.signedAsk()
+	if err != nil {
+		return Status{}, err
+	}
+	if err := m.adopt(signed); err != nil {
+		return Status{}, err
+	}
+
+	status := Status{Ask: signed, Scheduled: append([]ScheduledAsk{}, m.scheduled...)}
+	if m.terms != nil {
+		terms := *m.terms
+		status.Terms = &terms
+	}
+	return status, nil
+}
+
+// Terms returns the current terms of the miner, if an ask was set.
+func (m *Manager) Terms() (Terms, bool) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	if m.terms == nil {
+		return Terms{}, false
+	}
+	return *m.terms, true
+}
+
+// SetTerms signs a new ask with the terms right away.
+func (m *Manager) SetTerms(terms Terms) error {
+	if err := validate(&terms); err != nil {
+		return err
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	if err := m.sign(terms, "set"); err != nil {
+		return err
+	}
+	return m.persist()
+}
+
+// SetPrice signs a new ask at a price and duration, keeping the other terms.
+func (m *Manager) SetPrice(price abi.TokenAmount, duration abi.ChainEpoch) error {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	signed, err := m.signedAsk()
+	if err != nil {
+		return err
+	}
+	if err := m.adopt(signed); err != nil {
+		return err
+	}
+
+	terms := Terms{}
+	if m.terms != nil {
+		terms = *m.terms
+	}
+	terms.Price, terms.Duration = price, duration
+	if err := validate(&terms); err != nil {
+		return err
+	}
+	if err := m.sign(terms, "set"); err != nil {
+		return err
+	}
+	return m.persist()
+}
+
+// Schedule schedules the terms to take effect at an epoch, replacing the terms
+// scheduled at that epoch if any.
+func (m *Manager) Schedule(epoch abi.ChainEpoch, terms Terms) error {
+	if err := validate(&terms); err != nil {
+		return err
+	}
+
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	scheduled := []ScheduledAsk{{Epoch: epoch, Terms: terms}}
+	for _, s := range m.scheduled {
+		if s.Epoch != epoch {
+			scheduled = append(scheduled, s)
+		}
+	}
+	sort.Slice(scheduled, func(i, j int) bool { return scheduled[i].Epoch < scheduled[j].Epoch })
+	m.scheduled = scheduled
+	return m.persist()
+}
+
+// Unschedule cancels the terms scheduled at an epoch.
+func (m *Manager) Unschedule(epoch abi.ChainEpoch) error {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	for i, s := range m.scheduled {
+		if s.Epoch == epoch {
+			m.scheduled = append(m.scheduled[:i:i], m.scheduled[i+1:]...)
+			return m.persist()
+		}
+	}
+	return errors.Errorf("no ask scheduled at epoch %d", epoch)
+}
+
+// VerifiedPrice returns the price per GiB per epoch verified deals must pay at
+// least, if an ask was set.
+func (m *Manager) VerifiedPrice() (abi.TokenAmount, bool) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	if m.terms == nil {
+		return abi.TokenAmount{}, false
+	}
+	return m.terms.VerifiedPrice, true
+}
+
+// HandleNewHead applies the latest terms scheduled at or before the height of
+// a new head, or renews the ask when less than a quarter of its duration is
+// left before it expires.
+func (m *Manager) HandleNewHead(height abi.ChainEpoch) error {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	due := -1
+	for i, s := range m.scheduled {
+		if s.Epoch <= height {
+			due = i
+		}
+	}
+	if due >= 0 {
+		if err := m.sign(m.scheduled[due].Terms, "scheduled"); err != nil {
+			return err
+		}
+		m.scheduled = m.scheduled[due+1:]
+		return m.persist()
+	}
+
+	signed, err := m.signedAsk()
+	if err != nil {
+		return err
+	}
+	if err := m.adopt(signed); err != nil {
+		return err
+	}
+	if m.terms == nil {
+		return nil
+	}
+	if signed != nil && signed.Ask.Expiry-height > renewalMargin(m.terms.Duration) {
+		return nil
+	}
+	if err := m.sign(*m.terms, "renewed"); err != nil {
+		return err
+	}
+	return m.persist()
+}
+
+// renewalMargin is how many epochs before it expires an ask is renewed.
+func renewalMargin(duration abi.ChainEpoch) abi.ChainEpoch {
+	if margin := duration / 4; margin > 0 {
+		return margin
+	}
+	return 1
+}
+
+// sign signs a new ask with the terms and records them as the current terms,
+// leaving them to be persisted by the caller.
+func (m *Manager) sign(terms Terms, reason string) error {
+	provider, err := m.markets.Provider()
+	if err != nil {
+		return err
+	}
+
+	var options []storagemarket.StorageAskOption
+	if terms.MinPieceSize > 0 {
+		options = append(options, storagemarket.MinPieceSize(terms.MinPieceSize))
+	}
+	if terms.MaxPieceSize > 0 {
+		options = append(options, storagemarket.MaxPieceSize(terms.MaxPieceSize))
+	}
+	if err := provider.AddAsk(terms.Price, terms.Duration, options...); err != nil {
+		return err
+	}
+
+	signed, err := m.signedAsk()
+	if err != nil {
+		return err
+	}
+	if signed == nil {
+		return errors.New("signed ask not found")
+	}
+	terms.MinPieceSize, terms.MaxPieceSize = signed.Ask.MinPieceSize, signed.Ask.MaxPieceSize
+	m.terms = &terms
+
+	m.journal.Write("storage-ask",
+		"reason", reason,
+		"price", terms.Price.String(),
+		"verifiedPrice", terms.VerifiedPrice.String(),
+		"minPieceSize", uint64(terms.MinPieceSize),
+		"maxPieceSize", uint64(terms.MaxPieceSize),
+		"expiry", int64(signed.Ask.Expiry),
+		"seqNo", signed.Ask.SeqNo,
+	)
+	log.Infof("%s ask of miner %s at %s per GiB per epoch, expiring at epoch %d", reason, m.minerAddr, terms.Price, signed.Ask.Expiry)
+	return nil
+}
+
+// adopt takes the terms of an ask signed before the manager kept terms, so
+// that it is renewed.
+func (m *Manager) adopt(signed *storagemarket.SignedStorageAsk) error {
+	if m.terms != nil || signed == nil {
+		return nil
+	}
+	m.terms = &Terms{
+		Price:         signed.Ask.Price,
+		VerifiedPrice: big.Zero(),
+		MinPieceSize:  signed.Ask.MinPieceSize,
+		MaxPieceSize:  signed.Ask.MaxPieceSize,
+		Duration:      signed.Ask.Expiry - signed.Ask.Timestamp,
+	}
+	return m.persist()
+}
+
+// signedAsk returns the ask of the miner last signed, if any.
+func (m *Manager) signedAsk() (*storagemarket.SignedStorageAsk, error) {
+	provider, err := m.markets.Provider()
+	if err != nil {
+		return nil, err
+	}
+	asks := provider.ListAsks(m.minerAddr)
+	if len(asks) == 0 {
+		return nil, nil
+	}
+	return asks[0], nil
+}
+
+func (m *Manager) persist() error {
+	val, err := json.Marshal(&record{Terms: m.terms, Scheduled: m.scheduled})
+	if err != nil {
+		return err
+	}
+	return m.ds.Put(ds.NewKey(asksKey), val)
+}
+
+// validate checks terms, defaulting the verified price to zero.
+func validate(terms *Terms) error {
+	if terms.Price.Int == nil || terms.Price.Sign() < 0 {
+		return errors.New("ask price must not be negative")
+	}
+	if terms.VerifiedPrice.Int == nil {
+		terms.VerifiedPrice = big.Zero()
+	}
+	if terms.VerifiedPrice.Sign() < 0 {
+		return errors.New("verified deal price must not be negative")
+	}
+	if terms.Duration <= 0 {
+		return errors.New("ask duration must be positive")
+	}
+	if terms.MaxPieceSize > 0 && terms.MinPieceSize > terms.MaxPieceSize {
+		return errors.Errorf("minimum piece size %d is above the maximum piece size %d", terms.MinPieceSize, terms.MaxPieceSize)
+	}
+	for _, size := range []abi.PaddedPieceSize{terms.MinPieceSize, terms.MaxPieceSize} {
+		if size > 0 && bits.OnesCount64(uint64(size)) != 1 {
+			return errors.Errorf("piece size %d is not a power of two", size)
+		}
+	}
+	return nil
+}
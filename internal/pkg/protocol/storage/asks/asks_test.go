@@ -0,0 +1,229 @@
+package asks_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage/asks"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+// fakeProvider signs asks at the height of its chain head, as the stored ask
+// of the market does.
+type fakeProvider struct {
+	storagemarket.StorageProvider
+	miner  address.Address
+	height abi.ChainEpoch
+	ask    *storagemarket.SignedStorageAsk
+}
+
+func (p *fakeProvider) AddAsk(price abi.TokenAmount, duration abi.ChainEpoch, options ...storagemarket.StorageAskOption) error {
+	ask := &storagemarket.StorageAsk{
+		Price:        price,
+		MinPieceSize: 256,
+		MaxPieceSize: 1 << 20,
+		Miner:        p.miner,
+		Timestamp:    p.height,
+		Expiry:       p.height + duration,
+	}
+	if p.ask != nil {
+		ask.SeqNo = p.ask.Ask.SeqNo + 1
+	}
+	for _, option := range options {
+		option(ask)
+	}
+	p.ask = &storagemarket.SignedStorageAsk{Ask: ask}
+	return nil
+}
+
+func (p *fakeProvider) ListAsks(addr address.Address) []*storagemarket.SignedStorageAsk {
+	if p.ask == nil || addr != p.miner {
+		return nil
+	}
+	return []*storagemarket.SignedStorageAsk{p.ask}
+}
+
+type fakeMarkets struct {
+	provider *fakeProvider
+}
+
+func (m *fakeMarkets) Provider() (storagemarket.StorageProvider, error) {
+	return m.provider, nil
+}
+
+type recordingJournal struct {
+	events []string
+}
+
+func (j *recordingJournal) Write(event string, _ ...interface{}) {
+	j.events = append(j.events, event)
+}
+
+func TestManagerSetsAndRenewsAsks(t *testing.T) {
+	tf.UnitTest(t)
+	miner := vmaddr.RequireIDAddress(t, 1000)
+	provider := &fakeProvider{miner: miner, height: 10}
+	journal := &recordingJournal{}
+	repoDs := dssync.MutexWrap(ds.NewMapDatastore())
+
+	m, err := New(&fakeMarkets{provider}, miner, repoDs, journal)
+	require.NoError(t, err)
+
+	status, err := m.Status()
+	require.NoError(t, err)
+	assert.Nil(t, status.Terms)
+	assert.Nil(t, status.Ask)
+	require.NoError(t, m.HandleNewHead(10))
+	assert.Nil(t, provider.ask)
+
+	require.NoError(t, m.SetTerms(Terms{
+		Price:         abi.NewTokenAmount(100),
+		VerifiedPrice: abi.NewTokenAmount(150),
+		MaxPieceSize:  4096,
+		Duration:      100,
+	}))
+	require.NotNil(t, provider.ask)
+	assert.Equal(t, abi.NewTokenAmount(100), provider.ask.Ask.Price)
+	assert.Equal(t, abi.PaddedPieceSize(4096), provider.ask.Ask.MaxPieceSize)
+	assert.Equal(t, abi.ChainEpoch(110), provider.ask.Ask.Expiry)
+
+	status, err = m.Status()
+	require.NoError(t, err)
+	require.NotNil(t, status.Terms)
+	assert.Equal(t, abi.PaddedPieceSize(256), status.Terms.MinPieceSize)
+	verified, ok := m.VerifiedPrice()
+	require.True(t, ok)
+	assert.Equal(t, abi.NewTokenAmount(150), verified)
+
+	t.Log("the ask is renewed once a quarter of its duration is left")
+	provider.height = 84
+	require.NoError(t, m.HandleNewHead(84))
+	assert.Equal(t, uint64(0), provider.ask.Ask.SeqNo)
+	provider.height = 85
+	require.NoError(t, m.HandleNewHead(85))
+	assert.Equal(t, uint64(1), provider.ask.Ask.SeqNo)
+	assert.Equal(t, abi.ChainEpoch(185), provider.ask.Ask.Expiry)
+	assert.Equal(t, abi.PaddedPieceSize(4096), provider.ask.Ask.MaxPieceSize)
+
+	t.Log("setting the price keeps the other terms")
+	require.NoError(t, m.SetPrice(abi.NewTokenAmount(200), 50))
+	terms, ok := m.Terms()
+	require.True(t, ok)
+	assert.Equal(t, abi.NewTokenAmount(200), terms.Price)
+	assert.Equal(t, abi.NewTokenAmount(150), terms.VerifiedPrice)
+	assert.Equal(t, abi.ChainEpoch(50), terms.Duration)
+	assert.Equal(t, abi.PaddedPieceSize(4096), provider.ask.Ask.MaxPieceSize)
+
+	reloaded, err := New(&fakeMarkets{provider}, miner, repoDs, journal)
+	require.NoError(t, err)
+	reloadedTerms, ok := reloaded.Terms()
+	require.True(t, ok)
+	assert.Equal(t, terms, reloadedTerms)
+	assert.Equal(t, []string{"storage-ask", "storage-ask", "storage-ask"}, journal.events)
+
+	assert.Error(t, m.SetTerms(Terms{Price: abi.NewTokenAmount(1), Duration: 0}))
+	assert.Error(t, m.SetTerms(Terms{Price: abi.NewTokenAmount(1), Duration: 10, MinPieceSize: 4096, MaxPieceSize: 2048}))
+	assert.Error(t, m.SetTerms(Terms{Price: abi.NewTokenAmount(1), Duration: 10, MaxPieceSize: 3000}))
+}
+
+func TestManagerAppliesScheduledAsks(t *testing.T) {
+	tf.UnitTest(t)
+	miner := vmaddr.RequireIDAddress(t, 1000)
+	provider := &fakeProvider{miner: miner, height: 10}
+	repoDs := dssync.MutexWrap(ds.NewMapDatastore())
+
+	m, err := New(&fakeMarkets{provider}, miner, repoDs, &recordingJournal{})
+	require.NoError(t, err)
+	require.NoError(t, m.SetTerms(Terms{Price: abi.NewTokenAmount(100), Duration: 1000}))
+
+	require.NoError(t, m.Schedule(30, Terms{Price: abi.NewTokenAmount(300), Duration: 1000}))
+	require.NoError(t, m.Schedule(20, Terms{Price: abi.NewTokenAmount(200), Duration: 1000}))
+	require.NoError(t, m.Schedule(40, Terms{Price: abi.NewTokenAmount(400), Duration: 1000}))
+	require.NoError(t, m.Unschedule(40))
+	assert.Error(t, m.Unschedule(50))
+
+	status, err := m.Status()
+	require.NoError(t, err)
+	require.Len(t, status.Scheduled, 2)
+	assert.Equal(t, abi.ChainEpoch(20), status.Scheduled[0].Epoch)
+	assert.Equal(t, big.Zero(), status.Scheduled[0].Terms.VerifiedPrice)
+
+	provider.height = 19
+	require.NoError(t, m.HandleNewHead(19))
+	assert.Equal(t, abi.NewTokenAmount(100), provider.ask.Ask.Price)
+
+	// Reaching both epochs at once applies the latest terms only.
+	provider.height = 35
+	require.NoError(t, m.HandleNewHead(35))
+	assert.Equal(t, abi.NewTokenAmount(300), provider.ask.Ask.Price)
+	assert.Equal(t, abi.ChainEpoch(1035), provider.ask.Ask.Expiry)
+
+	reloaded, err := New(&fakeMarkets{provider}, miner, repoDs, &recordingJournal{})
+	require.NoError(t, err)
+	status, err = reloaded.Status()
+	require.NoError(t, err)
+	assert.Empty(t, status.Scheduled)
+	assert.Equal(t, abi.NewTokenAmount(300), status.Terms.Price)
+}
+
+func TestManagerAdoptsExistingAsk(t *testing.T) {
+	tf.UnitTest(t)
+	miner := vmaddr.RequireIDAddress(t, 1000)
+	provider := &fakeProvider{miner: miner, height: 10}
+	require.NoError(t, provider.AddAsk(abi.NewTokenAmount(100), 40))
+
+	m, err := New(&fakeMarkets{provider}, miner, dssync.MutexWrap(ds.NewMapDatastore()), &recordingJournal{})
+	require.NoError(t, err)
+
+	provider.height = 45
+	require.NoError(t, m.HandleNewHead(45))
+	assert.Equal(t, uint64(1), provider.ask.Ask.SeqNo)
+	assert.Equal(t, abi.NewTokenAmount(100), provider.ask.Ask.Price)
+	assert.Equal(t, abi.ChainEpoch(85), provider.ask.Ask.Expiry)
+}
+
+func TestVerifiedPriceQuery(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	miner, other := vmaddr.RequireIDAddress(t, 1000), vmaddr.RequireIDAddress(t, 1001)
+
+	mn, err := mocknet.FullMeshLinked(ctx, 2)
+	require.NoError(t, err)
+	minerHost, clientHost := mn.Hosts()[0], mn.Hosts()[1]
+
+	m, err := New(&fakeMarkets{&fakeProvider{miner: miner, height: 10}}, miner, dssync.MutexWrap(ds.NewMapDatastore()), &recordingJournal{})
+	require.NoError(t, err)
+	minerHost.SetStreamHandler(VerifiedPriceProtocolID, m.HandleVerifiedPriceStream)
+	info := storagemarket.StorageProviderInfo{Address: miner, PeerID: minerHost.ID()}
+
+	t.Log("miners without terms have no verified deal price")
+	_, ok, err := QueryVerifiedPrice(ctx, clientHost, info)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, m.SetTerms(Terms{Price: abi.NewTokenAmount(100), VerifiedPrice: abi.NewTokenAmount(150), Duration: 100}))
+	price, ok, err := QueryVerifiedPrice(ctx, clientHost, info)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, abi.NewTokenAmount(150), price)
+
+	t.Log("peers only answer for their own miner")
+	_, ok, err = QueryVerifiedPrice(ctx, clientHost, storagemarket.StorageProviderInfo{Address: other, PeerID: minerHost.ID()})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	t.Log("peers which do not speak the protocol cannot be queried")
+	_, _, err = QueryVerifiedPrice(ctx, minerHost, storagemarket.StorageProviderInfo{Address: miner, PeerID: clientHost.ID()})
+	assert.Error(t, err)
+}
@@ -0,0 +1,83 @@
+package asks
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/protocol"
+	"github.com/pkg/errors"
+)
+
+// VerifiedPriceProtocolID is the protocol on which miners answer queries for
+// the verified deal price of their ask, which the signed ask has no room for.
+const VerifiedPriceProtocolID = protocol.ID("/fil/storage/verifiedprice/1.0.0")
+
+// verifiedPriceTimeout bounds how long a miner has to answer a query.
+const verifiedPriceTimeout = 10 * time.Second
+
+// VerifiedPriceRequest queries a miner for its verified deal price.
+type VerifiedPriceRequest struct {
+	Miner address.Address
+}
+
+// VerifiedPriceResponse is the answer of a miner to a verified price query.
+type VerifiedPriceResponse struct {
+	// Price is the price per GiB per epoch verified deals must pay at least,
+	// nil when the peer has no ask for the miner queried.
+	Price *abi.TokenAmount
+}
+
+// HandleVerifiedPriceStream answers a verified price query.
+func (m *Manager) HandleVerifiedPriceStream(s network.Stream) {
+	defer func() { _ = s.Close() }()
+	_ = s.SetDeadline(time.Now().Add(verifiedPriceTimeout))
+
+	var req VerifiedPriceRequest
+	if err := json.NewDecoder(s).Decode(&req); err != nil {
+		log.Debugf("failed to read verified price query from %s: %s", s.Conn().RemotePeer(), err)
+		return
+	}
+	var resp VerifiedPriceResponse
+	if req.Miner == m.minerAddr {
+		if price, ok := m.VerifiedPrice(); ok {
+			resp.Price = &price
+		}
+	}
+	if err := json.NewEncoder(s).Encode(&resp); err != nil {
+		log.Debugf("failed to answer verified price query from %s: %s", s.Conn().RemotePeer(), err)
+	}
+}
+
+// QueryVerifiedPrice asks a miner for the price verified deals must pay at
+// least. The answer is trusted as coming from the peer the miner's on-chain
+// info names. It returns false when the miner has no ask.
+func QueryVerifiedPrice(ctx context.Context, h host.Host, info storagemarket.StorageProviderInfo) (abi.TokenAmount, bool, error) {
+	s, err := h.NewStream(ctx, info.PeerID, VerifiedPriceProtocolID)
+	if err != nil {
+		return abi.TokenAmount{}, false, errors.Wrapf(err, "failed to query miner %s for its verified deal price", info.Address)
+	}
+	defer func() { _ = s.Close() }()
+	deadline := time.Now().Add(verifiedPriceTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = s.SetDeadline(deadline)
+
+	if err := json.NewEncoder(s).Encode(&VerifiedPriceRequest{Miner: info.Address}); err != nil {
+		return abi.TokenAmount{}, false, errors.Wrapf(err, "failed to query miner %s for its verified deal price", info.Address)
+	}
+	var resp VerifiedPriceResponse
+	if err := json.NewDecoder(s).Decode(&resp); err != nil {
+		return abi.TokenAmount{}, false, errors.Wrapf(err, "failed to read the verified deal price of miner %s", info.Address)
+	}
+	if resp.Price == nil {
+		return abi.TokenAmount{}, false, nil
+	}
+	return *resp.Price, true, nil
+}
@@ -0,0 +1,409 @@
+// Package tracker follows the storage deals made by or with the node on chain
+// once they are published, reconciling the local state of each deal with the
+// state of the deal in the market and of the sector hosting it as the chain
+// head moves.
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	dsq "github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log/v2"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/journal"
+)
+
+var log = logging.Logger("deal-tracker")
+
+// trackerPrefix is the key prefix under which the statuses of deals are kept
+// in the repo datastore.
+const trackerPrefix = "/deals/tracker"
+
+// Status is the state of a deal on chain.
+type Status string
+
+const (
+	// Published deals are on chain and wait for their sector to be proven.
+	Published Status = "published"
+	// Active deals are stored in a proven sector.
+	Active Status = "active"
+	// Slashed deals were terminated early because their sector was lost.
+	Slashed Status = "slashed"
+	// Expired deals reached their end epoch.
+	Expired Status = "expired"
+	// Terminated deals left the market before their end epoch, having never
+	// been activated or having been slashed.
+	Terminated Status = "terminated"
+)
+
+// Role is the part the node plays in a deal.
+type Role string
+
+const (
+	// Client deals are deals the node pays for storage with.
+	Client Role = "client"
+	// Provider deals are deals the node's miner stores data for.
+	Provider Role = "provider"
+)
+
+// DealStatus is the state on chain of a deal made by or with the node.
+type DealStatus struct {
+	ProposalCid cid.Cid
+	DealID      abi.DealID
+	Role        Role
+	Client      address.Address
+	Provider    address.Address
+	Status      Status
+	// ActivationEpoch, LastUpdatedEpoch and SlashEpoch are the epochs the
+	// market recorded for the deal, -1 until they happen.
+	ActivationEpoch  abi.ChainEpoch
+	LastUpdatedEpoch abi.ChainEpoch
+	SlashEpoch       abi.ChainEpoch
+	// Sector is the sector hosting an active deal, if found.
+	Sector *abi.SectorNumber
+	// SectorFaulty reports whether the sector hosting the deal is faulty. The
+	// deal survives if the sector recovers.
+	SectorFaulty bool
+	// NeedsRestore reports that the data of the deal is no longer stored by the
+	// deal and must be stored again elsewhere.
+	NeedsRestore bool
+	// Height is the height of the head the status was reconciled at. Statuses
+	// are persisted only when they change, so reloaded statuses may be older.
+	Height abi.ChainEpoch
+}
+
+// StateView is the subset of the state view deals are reconciled with.
+type StateView interface {
+	MarketDealState(ctx context.Context, dealID abi.DealID) (*market.DealState, bool, error)
+	MarketDealExists(ctx context.Context, dealID abi.DealID) (bool, error)
+	MinerSectorsForEach(ctx context.Context, maddr address.Address, f func(abi.SectorNumber, cid.Cid, abi.RegisteredProof, []abi.DealID) error) error
+	MinerFaults(ctx context.Context, maddr address.Address) ([]uint64, error)
+}
+
+// markets gives access to the deals made by or with the node.
+type markets interface {
+	Client() storagemarket.StorageClient
+	Provider() (storagemarket.StorageProvider, error)
+}
+
+// publishedStates are the local states of deals which have been published.
+var publishedStates = map[storagemarket.StorageDealStatus]bool{
+	storagemarket.StorageDealStaged:    true,
+	storagemarket.StorageDealSealing:   true,
+	storagemarket.StorageDealActive:    true,
+	storagemarket.StorageDealCompleted: true,
+}
+
+// Tracker reconciles the published deals made by or with the node with the
+// chain, recording the transitions of their statuses in the journal.
+type Tracker struct {
+	markets markets
+	ds      ds.Datastore
+	journal journal.Writer
+
+	lk       sync.Mutex
+	statuses map[dealKey]*DealStatus
+}
+
+// dealKey identifies a deal from one side, as the node may be both the client
+// and the provider of a deal.
+type dealKey struct {
+	role        Role
+	proposalCid cid.Cid
+}
+
+// New creates a tracker keeping the statuses of deals in the repo datastore.
+func New(markets markets, repoDs ds.Datastore, jw journal.Writer) (*Tracker, error) {
+	t := &Tracker{
+		markets:  markets,
+		ds:       namespace.Wrap(repoDs, ds.NewKey(trackerPrefix)),
+		journal:  jw,
+		statuses: make(map[dealKey]*DealStatus),
+	}
+
+	res, err := t.ds.Query(dsq.Query{})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Close() }()
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var status DealStatus
+		if err := json.Unmarshal(r.Value, &status); err != nil {
+			return nil, err
+		}
+		t.statuses[dealKey{status.Role, status.ProposalCid}] = &status
+	}
+	return t, nil
+}
+
+// Statuses returns the statuses of the tracked deals, ordered by deal id.
+func (t *Tracker) Statuses() []DealStatus {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	statuses := make([]DealStatus, 0, len(t.statuses))
+	for _, status := range t.statuses {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].DealID != statuses[j].DealID {
+			return statuses[i].DealID < statuses[j].DealID
+		}
+		return statuses[i].Role < statuses[j].Role
+	})
+	return statuses
+}
+
+// Status returns the status of a deal tracked in a role.
+func (t *Tracker) Status(role Role, proposalCid cid.Cid) (DealStatus, bool) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	status, ok := t.statuses[dealKey{role, proposalCid}]
+	if !ok {
+		return DealStatus{}, false
+	}
+	return *status, true
+}
+
+// trackedDeal is a published deal made by or with the node.
+type trackedDeal struct {
+	proposalCid cid.Cid
+	dealID      abi.DealID
+	role        Role
+	proposal    market.DealProposal
+}
+
+// HandleNewHead reconciles the published deals with the state of a new head.
+func (t *Tracker) HandleNewHead(ctx context.Context, height abi.ChainEpoch, view StateView) error {
+	deals, err := t.publishedDeals(ctx)
+	if err != nil {
+		return err
+	}
+
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	sectors := newSectorIndex(view)
+	for _, deal := range deals {
+		key := dealKey{deal.role, deal.proposalCid}
+		prev := t.statuses[key]
+		if prev != nil && (prev.Status == Expired || prev.Status == Terminated) {
+			continue
+		}
+
+		status, err := reconcile(ctx, view, sectors, deal, prev, height)
+		if err != nil {
+			return err
+		}
+		t.statuses[key] = status
+		if prev != nil && !transitioned(prev, status) {
+			continue
+		}
+
+		if err := t.persist(status); err != nil {
+			return err
+		}
+		t.recordTransition(prev, status)
+	}
+	return nil
+}
+
+// publishedDeals lists the published client deals and, if the node mines,
+// provider deals.
+func (t *Tracker) publishedDeals(ctx context.Context) ([]trackedDeal, error) {
+	var deals []trackedDeal
+	clientDeals, err := t.markets.Client().ListLocalDeals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, deal := range clientDeals {
+		if publishedStates[deal.State] {
+			deals = append(deals, trackedDeal{deal.ProposalCid, deal.DealID, Client, deal.Proposal})
+		}
+	}
+
+	provider, err := t.markets.Provider()
+	if err != nil {
+		// The node does not mine.
+		return deals, nil
+	}
+	providerDeals, err := provider.ListLocalDeals()
+	if err != nil {
+		return nil, err
+	}
+	for _, deal := range providerDeals {
+		if publishedStates[deal.State] {
+			deals = append(deals, trackedDeal{deal.ProposalCid, deal.DealID, Provider, deal.Proposal})
+		}
+	}
+	return deals, nil
+}
+
+// reconcile computes the status of a deal from the state of the market and of
+// the miner storing it.
+func reconcile(ctx context.Context, view StateView, sectors *sectorIndex, deal trackedDeal, prev *DealStatus, height abi.ChainEpoch) (*DealStatus, error) {
+	status := &DealStatus{
+		ProposalCid:      deal.proposalCid,
+		DealID:           deal.dealID,
+		Role:             deal.role,
+		Client:           deal.proposal.Client,
+		Provider:         deal.proposal.Provider,
+		ActivationEpoch:  -1,
+		LastUpdatedEpoch: -1,
+		SlashEpoch:       -1,
+		Height:           height,
+	}
+
+	state, found, err := view.MarketDealState(ctx, deal.dealID)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		status.ActivationEpoch = state.SectorStartEpoch
+		status.LastUpdatedEpoch = state.LastUpdatedEpoch
+		status.SlashEpoch = state.SlashEpoch
+		switch {
+		case state.SlashEpoch != -1:
+			status.Status = Slashed
+		case state.SectorStartEpoch != -1:
+			status.Status = Active
+			sector, faulty, err := sectors.lookup(ctx, deal.proposal.Provider, deal.dealID)
+			if err != nil {
+				return nil, err
+			}
+			status.Sector, status.SectorFaulty = sector, faulty
+		default:
+			status.Status = Published
+		}
+	} else {
+		exists, err := view.MarketDealExists(ctx, deal.dealID)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case exists:
+			status.Status = Published
+		case height >= deal.proposal.EndEpoch:
+			status.Status = Expired
+		default:
+			status.Status = Terminated
+		}
+		if !exists && prev != nil {
+			// The market forgets deals once they end, so what was last known
+			// of them is kept.
+			status.ActivationEpoch = prev.ActivationEpoch
+			status.LastUpdatedEpoch = prev.LastUpdatedEpoch
+			status.SlashEpoch = prev.SlashEpoch
+		}
+	}
+
+	status.NeedsRestore = status.Status == Slashed || status.Status == Terminated
+	return status, nil
+}
+
+// transitioned reports whether a deal's status changed in more than the height
+// it was reconciled at and the epoch the market last updated it at.
+func transitioned(prev, next *DealStatus) bool {
+	a, b := *prev, *next
+	a.Height, b.Height = 0, 0
+	a.LastUpdatedEpoch, b.LastUpdatedEpoch = 0, 0
+	if (a.Sector == nil) != (b.Sector == nil) || (a.Sector != nil && *a.Sector != *b.Sector) {
+		return true
+	}
+	a.Sector, b.Sector = nil, nil
+	return a != b
+}
+
+func (t *Tracker) persist(status *DealStatus) error {
+	val, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return t.ds.Put(ds.NewKey(string(status.Role)).ChildString(status.ProposalCid.String()), val)
+}
+
+// recordTransition records the transition of a deal to a new status in the
+// journal, warning when the data of the deal must be stored again.
+func (t *Tracker) recordTransition(prev, next *DealStatus) {
+	from := Status("")
+	if prev != nil {
+		from = prev.Status
+	}
+	sector := ""
+	if next.Sector != nil {
+		sector = next.Sector.String()
+	}
+	t.journal.Write("deal-chain-state",
+		"proposal", next.ProposalCid.String(),
+		"dealID", uint64(next.DealID),
+		"role", string(next.Role),
+		"from", string(from),
+		"to", string(next.Status),
+		"sector", sector,
+		"sectorFaulty", next.SectorFaulty,
+		"needsRestore", next.NeedsRestore,
+	)
+	if next.NeedsRestore && (prev == nil || !prev.NeedsRestore) {
+		log.Warnf("%s deal %d (proposal %s) is %s, its data must be stored again", next.Role, next.DealID, next.ProposalCid, next.Status)
+	}
+}
+
+// sectorIndex finds the sectors hosting deals and whether they are faulty,
+// reading the sectors of each miner once.
+type sectorIndex struct {
+	view   StateView
+	miners map[address.Address]*minerSectors
+}
+
+type minerSectors struct {
+	byDeal map[abi.DealID]abi.SectorNumber
+	faults map[abi.SectorNumber]bool
+}
+
+func newSectorIndex(view StateView) *sectorIndex {
+	return &sectorIndex{view: view, miners: make(map[address.Address]*minerSectors)}
+}
+
+func (idx *sectorIndex) lookup(ctx context.Context, maddr address.Address, dealID abi.DealID) (*abi.SectorNumber, bool, error) {
+	m, ok := idx.miners[maddr]
+	if !ok {
+		m = &minerSectors{byDeal: make(map[abi.DealID]abi.SectorNumber), faults: make(map[abi.SectorNumber]bool)}
+		err := idx.view.MinerSectorsForEach(ctx, maddr, func(num abi.SectorNumber, _ cid.Cid, _ abi.RegisteredProof, dealIDs []abi.DealID) error {
+			for _, id := range dealIDs {
+				m.byDeal[id] = num
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		faults, err := idx.view.MinerFaults(ctx, maddr)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, f := range faults {
+			m.faults[abi.SectorNumber(f)] = true
+		}
+		idx.miners[maddr] = m
+	}
+
+	num, ok := m.byDeal[dealID]
+	if !ok {
+		return nil, false, nil
+	}
+	return &num, m.faults[num], nil
+}
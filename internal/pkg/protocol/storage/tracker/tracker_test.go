@@ -0,0 +1,215 @@
+package tracker_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/filecoin-project/go-filecoin/internal/pkg/protocol/storage/tracker"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+)
+
+type fakeClient struct {
+	storagemarket.StorageClient
+	deals []storagemarket.ClientDeal
+}
+
+func (c *fakeClient) ListLocalDeals(context.Context) ([]storagemarket.ClientDeal, error) {
+	return c.deals, nil
+}
+
+type fakeProvider struct {
+	storagemarket.StorageProvider
+	deals []storagemarket.MinerDeal
+}
+
+func (p *fakeProvider) ListLocalDeals() ([]storagemarket.MinerDeal, error) {
+	return p.deals, nil
+}
+
+type fakeMarkets struct {
+	client   *fakeClient
+	provider *fakeProvider
+}
+
+func (m *fakeMarkets) Client() storagemarket.StorageClient {
+	return m.client
+}
+
+func (m *fakeMarkets) Provider() (storagemarket.StorageProvider, error) {
+	if m.provider == nil {
+		return nil, errors.New("not mining")
+	}
+	return m.provider, nil
+}
+
+// fakeView holds the market and miner state of a single miner.
+type fakeView struct {
+	states    map[abi.DealID]*market.DealState
+	proposals map[abi.DealID]bool
+	sectors   map[abi.SectorNumber][]abi.DealID
+	faults    []uint64
+}
+
+func (v *fakeView) MarketDealState(_ context.Context, dealID abi.DealID) (*market.DealState, bool, error) {
+	state, ok := v.states[dealID]
+	return state, ok, nil
+}
+
+func (v *fakeView) MarketDealExists(_ context.Context, dealID abi.DealID) (bool, error) {
+	return v.proposals[dealID], nil
+}
+
+func (v *fakeView) MinerSectorsForEach(_ context.Context, _ address.Address, f func(abi.SectorNumber, cid.Cid, abi.RegisteredProof, []abi.DealID) error) error {
+	for num, deals := range v.sectors {
+		if err := f(num, cid.Undef, abi.RegisteredProof_StackedDRG2KiBSeal, deals); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *fakeView) MinerFaults(context.Context, address.Address) ([]uint64, error) {
+	return v.faults, nil
+}
+
+type journalEntry struct {
+	event string
+	kvs   []interface{}
+}
+
+type recordingJournal struct {
+	entries []journalEntry
+}
+
+func (j *recordingJournal) Write(event string, kvs ...interface{}) {
+	j.entries = append(j.entries, journalEntry{event, kvs})
+}
+
+func clientDeal(t *testing.T, name string, dealID abi.DealID, state storagemarket.StorageDealStatus) storagemarket.ClientDeal {
+	return storagemarket.ClientDeal{
+		ClientDealProposal: market.ClientDealProposal{
+			Proposal: market.DealProposal{
+				Client:   vmaddr.RequireIDAddress(t, 100),
+				Provider: vmaddr.RequireIDAddress(t, 1000),
+				EndEpoch: 500,
+			},
+		},
+		ProposalCid: types.CidFromString(t, name),
+		DealID:      dealID,
+		State:       state,
+	}
+}
+
+func TestTrackerReconcilesDeals(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	active := clientDeal(t, "active", 1, storagemarket.StorageDealActive)
+	slashed := clientDeal(t, "slashed", 2, storagemarket.StorageDealActive)
+	expiring := clientDeal(t, "expiring", 3, storagemarket.StorageDealActive)
+	unpublished := clientDeal(t, "unpublished", 4, storagemarket.StorageDealProposalAccepted)
+	markets := &fakeMarkets{client: &fakeClient{deals: []storagemarket.ClientDeal{active, slashed, expiring, unpublished}}}
+
+	view := &fakeView{
+		states: map[abi.DealID]*market.DealState{
+			1: {SectorStartEpoch: -1, LastUpdatedEpoch: -1, SlashEpoch: -1},
+			2: {SectorStartEpoch: 10, LastUpdatedEpoch: 20, SlashEpoch: -1},
+		},
+		proposals: map[abi.DealID]bool{1: true, 2: true, 3: true},
+		sectors:   map[abi.SectorNumber][]abi.DealID{7: {2}},
+	}
+	journal := &recordingJournal{}
+	repoDs := dssync.MutexWrap(ds.NewMapDatastore())
+	tracker, err := New(markets, repoDs, journal)
+	require.NoError(t, err)
+
+	require.NoError(t, tracker.HandleNewHead(ctx, 100, view))
+	statuses := tracker.Statuses()
+	require.Len(t, statuses, 3)
+	assert.Equal(t, Published, statuses[0].Status)
+	assert.Equal(t, Active, statuses[1].Status)
+	assert.Equal(t, abi.ChainEpoch(10), statuses[1].ActivationEpoch)
+	require.NotNil(t, statuses[1].Sector)
+	assert.Equal(t, abi.SectorNumber(7), *statuses[1].Sector)
+	assert.False(t, statuses[1].SectorFaulty)
+	assert.Equal(t, Published, statuses[2].Status)
+	assert.Len(t, journal.entries, 3)
+
+	t.Log("transitions are recorded once")
+	require.NoError(t, tracker.HandleNewHead(ctx, 101, view))
+	assert.Len(t, journal.entries, 3)
+
+	t.Log("faulty sectors and slashed deals are reported")
+	view.faults = []uint64{7}
+	view.states[1] = &market.DealState{SectorStartEpoch: 110, LastUpdatedEpoch: 110, SlashEpoch: 150}
+	require.NoError(t, tracker.HandleNewHead(ctx, 150, view))
+	status, ok := tracker.Status(Client, active.ProposalCid)
+	require.True(t, ok)
+	assert.Equal(t, Slashed, status.Status)
+	assert.True(t, status.NeedsRestore)
+	status, ok = tracker.Status(Client, slashed.ProposalCid)
+	require.True(t, ok)
+	assert.True(t, status.SectorFaulty)
+	assert.False(t, status.NeedsRestore)
+	assert.Len(t, journal.entries, 5)
+
+	t.Log("deals leaving the market expire at their end epoch or are terminated before it")
+	delete(view.states, 1)
+	delete(view.proposals, 1)
+	delete(view.proposals, 3)
+	require.NoError(t, tracker.HandleNewHead(ctx, 200, view))
+	status, _ = tracker.Status(Client, active.ProposalCid)
+	assert.Equal(t, Terminated, status.Status)
+	assert.Equal(t, abi.ChainEpoch(150), status.SlashEpoch)
+	assert.True(t, status.NeedsRestore)
+	status, _ = tracker.Status(Client, expiring.ProposalCid)
+	assert.Equal(t, Terminated, status.Status)
+
+	t.Log("statuses are reloaded from the datastore")
+	reloaded, err := New(markets, repoDs, journal)
+	require.NoError(t, err)
+	require.Len(t, reloaded.Statuses(), 3)
+	for i, status := range reloaded.Statuses() {
+		assert.Equal(t, tracker.Statuses()[i].Status, status.Status)
+		assert.Equal(t, tracker.Statuses()[i].NeedsRestore, status.NeedsRestore)
+	}
+}
+
+func TestTrackerExpiresDeals(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	deal := clientDeal(t, "deal", 1, storagemarket.StorageDealActive)
+	markets := &fakeMarkets{
+		client: &fakeClient{},
+		provider: &fakeProvider{deals: []storagemarket.MinerDeal{{
+			ClientDealProposal: deal.ClientDealProposal,
+			ProposalCid:        deal.ProposalCid,
+			DealID:             deal.DealID,
+			State:              storagemarket.StorageDealCompleted,
+		}}},
+	}
+	tracker, err := New(markets, dssync.MutexWrap(ds.NewMapDatastore()), &recordingJournal{})
+	require.NoError(t, err)
+
+	require.NoError(t, tracker.HandleNewHead(ctx, 500, &fakeView{}))
+	status, ok := tracker.Status(Provider, deal.ProposalCid)
+	require.True(t, ok)
+	assert.Equal(t, Expired, status.Status)
+	assert.False(t, status.NeedsRestore)
+	_, ok = tracker.Status(Client, deal.ProposalCid)
+	assert.False(t, ok)
+}
@@ -0,0 +1,311 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	addr "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/account"
+	"github.com/filecoin-project/specs-actors/actors/builtin/cron"
+	notinit "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/builtin/multisig"
+	paychActor "github.com/filecoin-project/specs-actors/actors/builtin/paych"
+	"github.com/filecoin-project/specs-actors/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/actors/builtin/reward"
+	"github.com/filecoin-project/specs-actors/actors/builtin/system"
+	"github.com/filecoin-project/specs-actors/actors/builtin/verifreg"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	cbg "github.com/whyrusleeping/cbor-gen"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/actor"
+)
+
+// ActorState is the decoded state of an actor. State holds the actor type's
+// on-chain state structure; the HAMTs and AMTs it references are listed in
+// Collections and may be read with View.ActorCollection.
+type ActorState struct {
+	Address     addr.Address
+	Name        string
+	Actor       *actor.Actor
+	State       interface{}
+	Collections []string
+}
+
+// CollectionPage is a range of the entries of an actor state collection, in
+// the collection's iteration order. Total counts all entries.
+type CollectionPage struct {
+	Name    string
+	Offset  int
+	Total   int
+	Entries []CollectionEntry
+}
+
+// CollectionEntry is a single entry of a collection. Keys are rendered as
+// addresses or integers depending on the collection.
+type CollectionEntry struct {
+	Key   string
+	Value json.RawMessage
+}
+
+type collectionKind int
+
+const (
+	hamtCollection collectionKind = iota
+	amtCollection
+)
+
+// collection describes an ADT referenced from an actor's state.
+type collection struct {
+	kind collectionKind
+	root func(state interface{}) cid.Cid
+	// value returns a fresh value to decode entries into.
+	value func() cbg.CBORUnmarshaler
+	// key renders a HAMT key. AMT keys are always indices.
+	key func(string) (string, error)
+}
+
+// actorStateType describes how to decode the state of a builtin actor type.
+type actorStateType struct {
+	state       func() cbg.CBORUnmarshaler
+	collections map[string]collection
+}
+
+var actorStateTypes = map[cid.Cid]actorStateType{
+	builtin.SystemActorCodeID: {
+		state: func() cbg.CBORUnmarshaler { return &system.State{} },
+	},
+	builtin.InitActorCodeID: {
+		state: func() cbg.CBORUnmarshaler { return &notinit.State{} },
+		collections: map[string]collection{
+			"addresses": {
+				kind:  hamtCollection,
+				root:  func(s interface{}) cid.Cid { return s.(*notinit.State).AddressMap },
+				value: func() cbg.CBORUnmarshaler { return new(cbg.CborInt) },
+				key:   addressKey,
+			},
+		},
+	},
+	builtin.CronActorCodeID: {
+		state: func() cbg.CBORUnmarshaler { return &cron.State{} },
+	},
+	builtin.AccountActorCodeID: {
+		state: func() cbg.CBORUnmarshaler { return &account.State{} },
+	},
+	builtin.RewardActorCodeID: {
+		state: func() cbg.CBORUnmarshaler { return &reward.State{} },
+	},
+	builtin.PaymentChannelActorCodeID: {
+		state: func() cbg.CBORUnmarshaler { return &paychActor.State{} },
+	},
+	builtin.MultisigActorCodeID: {
+		state: func() cbg.CBORUnmarshaler { return &multisig.State{} },
+		collections: map[string]collection{
+			"pending": {
+				kind:  hamtCollection,
+				root:  func(s interface{}) cid.Cid { return s.(*multisig.State).PendingTxns },
+				value: func() cbg.CBORUnmarshaler { return &multisig.Transaction{} },
+				key:   intKey,
+			},
+		},
+	},
+	builtin.StorageMinerActorCodeID: {
+		state: func() cbg.CBORUnmarshaler { return &miner.State{} },
+		collections: map[string]collection{
+			"precommits": {
+				kind:  hamtCollection,
+				root:  func(s interface{}) cid.Cid { return s.(*miner.State).PreCommittedSectors },
+				value: func() cbg.CBORUnmarshaler { return &miner.SectorPreCommitOnChainInfo{} },
+				key:   uintKey,
+			},
+			"sectors": {
+				kind:  amtCollection,
+				root:  func(s interface{}) cid.Cid { return s.(*miner.State).Sectors },
+				value: func() cbg.CBORUnmarshaler { return &miner.SectorOnChainInfo{} },
+			},
+		},
+	},
+	builtin.StorageMarketActorCodeID: {
+		state: func() cbg.CBORUnmarshaler { return &market.State{} },
+		collections: map[string]collection{
+			"proposals": {
+				kind:  amtCollection,
+				root:  func(s interface{}) cid.Cid { return s.(*market.State).Proposals },
+				value: func() cbg.CBORUnmarshaler { return &market.DealProposal{} },
+			},
+			"states": {
+				kind:  amtCollection,
+				root:  func(s interface{}) cid.Cid { return s.(*market.State).States },
+				value: func() cbg.CBORUnmarshaler { return &market.DealState{} },
+			},
+			"escrow": {
+				kind:  hamtCollection,
+				root:  func(s interface{}) cid.Cid { return s.(*market.State).EscrowTable },
+				value: func() cbg.CBORUnmarshaler { return &abi.TokenAmount{} },
+				key:   addressKey,
+			},
+			"locked": {
+				kind:  hamtCollection,
+				root:  func(s interface{}) cid.Cid { return s.(*market.State).LockedTable },
+				value: func() cbg.CBORUnmarshaler { return &abi.TokenAmount{} },
+				key:   addressKey,
+			},
+		},
+	},
+	builtin.StoragePowerActorCodeID: {
+		state: func() cbg.CBORUnmarshaler { return &power.State{} },
+		collections: map[string]collection{
+			"claims": {
+				kind:  hamtCollection,
+				root:  func(s interface{}) cid.Cid { return s.(*power.State).Claims },
+				value: func() cbg.CBORUnmarshaler { return &power.Claim{} },
+				key:   addressKey,
+			},
+		},
+	},
+	builtin.VerifiedRegistryActorCodeID: {
+		state: func() cbg.CBORUnmarshaler { return &verifreg.State{} },
+		collections: map[string]collection{
+			"verifiers": {
+				kind:  hamtCollection,
+				root:  func(s interface{}) cid.Cid { return s.(*verifreg.State).Verifiers },
+				value: func() cbg.CBORUnmarshaler { return &verifreg.DataCap{} },
+				key:   addressKey,
+			},
+			"clients": {
+				kind:  hamtCollection,
+				root:  func(s interface{}) cid.Cid { return s.(*verifreg.State).VerifiedClients },
+				value: func() cbg.CBORUnmarshaler { return &verifreg.DataCap{} },
+				key:   addressKey,
+			},
+		},
+	},
+}
+
+// ActorState loads an actor and decodes its state according to its code.
+func (v *View) ActorState(ctx context.Context, a addr.Address) (*ActorState, error) {
+	resolved, err := v.InitResolveAddress(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	actr, err := v.loadActor(ctx, resolved)
+	if err != nil {
+		return nil, err
+	}
+	typ, ok := actorStateTypes[actr.Code.Cid]
+	if !ok {
+		return nil, fmt.Errorf("actor %s has unknown code %s", a, actr.Code.Cid)
+	}
+	st := typ.state()
+	if err := v.ipldStore.Get(ctx, actr.Head.Cid, st); err != nil {
+		return nil, errors.Wrapf(err, "failed to load state of actor %s", a)
+	}
+
+	names := make([]string, 0, len(typ.collections))
+	for name := range typ.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &ActorState{
+		Address:     resolved,
+		Name:        builtin.ActorNameByCode(actr.Code.Cid),
+		Actor:       actr,
+		State:       st,
+		Collections: names,
+	}, nil
+}
+
+// ActorCollection reads up to limit entries, from offset on, of a collection
+// referenced by an actor's state. A limit of zero reads all remaining entries.
+func (v *View) ActorCollection(ctx context.Context, a addr.Address, name string, offset, limit int) (*CollectionPage, error) {
+	if offset < 0 || limit < 0 {
+		return nil, errors.New("offset and limit must not be negative")
+	}
+	st, err := v.ActorState(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	coll, ok := actorStateTypes[st.Actor.Code.Cid].collections[name]
+	if !ok {
+		return nil, fmt.Errorf("%s actor has no collection %q, expected one of %v", st.Name, name, st.Collections)
+	}
+
+	page := &CollectionPage{Name: name, Offset: offset, Entries: []CollectionEntry{}}
+	value := coll.value()
+	// Every entry is counted so the page reports the size of the whole collection.
+	add := func(key func() (string, error)) error {
+		page.Total++
+		if page.Total <= offset || (limit > 0 && len(page.Entries) >= limit) {
+			return nil
+		}
+		k, err := key()
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		page.Entries = append(page.Entries, CollectionEntry{Key: k, Value: encoded})
+		return nil
+	}
+
+	root := coll.root(st.State)
+	switch coll.kind {
+	case amtCollection:
+		arr, err := v.asArray(ctx, root)
+		if err != nil {
+			return nil, err
+		}
+		err = arr.ForEach(value, func(i int64) error {
+			return add(func() (string, error) { return strconv.FormatInt(i, 10), nil })
+		})
+		if err != nil {
+			return nil, err
+		}
+	case hamtCollection:
+		m, err := v.asMap(ctx, root)
+		if err != nil {
+			return nil, err
+		}
+		err = m.ForEach(value, func(k string) error {
+			return add(func() (string, error) { return coll.key(k) })
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func addressKey(k string) (string, error) {
+	a, err := addr.NewFromBytes([]byte(k))
+	if err != nil {
+		return "", err
+	}
+	return a.String(), nil
+}
+
+func intKey(k string) (string, error) {
+	i, err := adt.ParseIntKey(k)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(i, 10), nil
+}
+
+func uintKey(k string) (string, error) {
+	i, err := adt.ParseUIntKey(k)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(i, 10), nil
+}
@@ -0,0 +1,69 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestActorStateTypesCoverBuiltinActors(t *testing.T) {
+	tf.UnitTest(t)
+
+	codes := []struct {
+		name        string
+		collections []string
+	}{
+		{builtin.ActorNameByCode(builtin.SystemActorCodeID), nil},
+		{builtin.ActorNameByCode(builtin.InitActorCodeID), []string{"addresses"}},
+		{builtin.ActorNameByCode(builtin.CronActorCodeID), nil},
+		{builtin.ActorNameByCode(builtin.AccountActorCodeID), nil},
+		{builtin.ActorNameByCode(builtin.RewardActorCodeID), nil},
+		{builtin.ActorNameByCode(builtin.PaymentChannelActorCodeID), nil},
+		{builtin.ActorNameByCode(builtin.MultisigActorCodeID), []string{"pending"}},
+		{builtin.ActorNameByCode(builtin.StorageMinerActorCodeID), []string{"precommits", "sectors"}},
+		{builtin.ActorNameByCode(builtin.StorageMarketActorCodeID), []string{"escrow", "locked", "proposals", "states"}},
+		{builtin.ActorNameByCode(builtin.StoragePowerActorCodeID), []string{"claims"}},
+		{builtin.ActorNameByCode(builtin.VerifiedRegistryActorCodeID), []string{"clients", "verifiers"}},
+	}
+	byName := map[string]actorStateType{}
+	for code, typ := range actorStateTypes {
+		byName[builtin.ActorNameByCode(code)] = typ
+	}
+	require.Len(t, byName, len(codes))
+
+	for _, c := range codes {
+		typ, ok := byName[c.name]
+		require.True(t, ok, c.name)
+		assert.NotNil(t, typ.state(), c.name)
+		assert.Len(t, typ.collections, len(c.collections), c.name)
+		for _, name := range c.collections {
+			coll, ok := typ.collections[name]
+			require.True(t, ok, "%s %s", c.name, name)
+			assert.Equal(t, coll.kind == hamtCollection, coll.key != nil, "%s %s", c.name, name)
+		}
+	}
+}
+
+func TestCollectionKeys(t *testing.T) {
+	tf.UnitTest(t)
+
+	a, err := address.NewIDAddress(1234)
+	require.NoError(t, err)
+	k, err := addressKey(adt.AddrKey(a).Key())
+	require.NoError(t, err)
+	assert.Equal(t, a.String(), k)
+
+	k, err = intKey(adt.IntKey(-7).Key())
+	require.NoError(t, err)
+	assert.Equal(t, "-7", k)
+
+	k, err = uintKey(adt.UIntKey(300).Key())
+	require.NoError(t, err)
+	assert.Equal(t, "300", k)
+}
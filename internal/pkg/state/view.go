@@ -3,6 +3,7 @@ package state
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"github.com/filecoin-project/go-bitfield"
 	"github.com/filecoin-project/sector-storage/ffiwrapper"
@@ -15,8 +16,10 @@ import (
 	notinit "github.com/filecoin-project/specs-actors/actors/builtin/init"
 	"github.com/filecoin-project/specs-actors/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/builtin/multisig"
 	paychActor "github.com/filecoin-project/specs-actors/actors/builtin/paych"
 	"github.com/filecoin-project/specs-actors/actors/builtin/power"
+	"github.com/filecoin-project/specs-actors/actors/builtin/reward"
 	"github.com/filecoin-project/specs-actors/actors/util/adt"
 	"github.com/ipfs/go-cid"
 	cbor "github.com/ipfs/go-ipld-cbor"
@@ -478,6 +481,23 @@ func (v *View) MarketDealProposal(ctx context.Context, dealID abi.DealID) (marke
 	return proposal, nil
 }
 
+// MarketDealExists returns whether the market holds the proposal of a deal, as
+// it does from the deal's publication until it expires or is terminated.
+func (v *View) MarketDealExists(ctx context.Context, dealID abi.DealID) (bool, error) {
+	marketState, err := v.loadMarketActor(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	deals, err := v.asArray(ctx, marketState.Proposals)
+	if err != nil {
+		return false, err
+	}
+
+	var proposal market.DealProposal
+	return deals.Get(uint64(dealID), &proposal)
+}
+
 // NOTE: exposes on-chain structures directly for storage FSM and market module interfaces.
 func (v *View) MarketDealState(ctx context.Context, dealID abi.DealID) (*market.DealState, bool, error) {
 	marketState, err := v.loadMarketActor(ctx)
@@ -549,6 +569,16 @@ func (v *View) MinerClaimedPower(ctx context.Context, miner addr.Address) (raw,
 	return claim.RawBytePower, claim.QualityAdjPower, nil
 }
 
+// RewardBlockReward returns the reward, before gas fees and penalties, paid
+// for each block mined on this state.
+func (v *View) RewardBlockReward(ctx context.Context) (abi.TokenAmount, error) {
+	st, err := v.loadRewardActor(ctx)
+	if err != nil {
+		return big.Zero(), err
+	}
+	return big.Div(st.LastPerEpochReward, big.NewInt(builtin.ExpectedLeadersPerEpoch)), nil
+}
+
 // PaychActorParties returns the From and To addresses for the given payment channel
 func (v *View) PaychActorParties(ctx context.Context, paychAddr addr.Address) (from, to addr.Address, err error) {
 	a, err := v.loadActor(ctx, paychAddr)
@@ -563,6 +593,83 @@ func (v *View) PaychActorParties(ctx context.Context, paychAddr addr.Address) (f
 	return state.From, state.To, nil
 }
 
+// PaychActorState returns the state of a payment channel actor.
+// NOTE: exposes on-chain structures directly for the payment channel porcelain.
+func (v *View) PaychActorState(ctx context.Context, paychAddr addr.Address) (*paychActor.State, error) {
+	resolvedAddr, err := v.InitResolveAddress(ctx, paychAddr)
+	if err != nil {
+		return nil, err
+	}
+	a, err := v.loadActor(ctx, resolvedAddr)
+	if err != nil {
+		return nil, err
+	}
+	if !a.Code.Cid.Equals(builtin.PaymentChannelActorCodeID) {
+		return nil, fmt.Errorf("actor %s is not a payment channel actor", paychAddr)
+	}
+	var state paychActor.State
+	err = v.ipldStore.Get(ctx, a.Head.Cid, &state)
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// NOTE: exposes on-chain structures directly for the multisig porcelain.
+func (v *View) MultisigState(ctx context.Context, msigAddr addr.Address) (*multisig.State, error) {
+	resolvedAddr, err := v.InitResolveAddress(ctx, msigAddr)
+	if err != nil {
+		return nil, err
+	}
+	a, err := v.loadActor(ctx, resolvedAddr)
+	if err != nil {
+		return nil, err
+	}
+	if !a.Code.Cid.Equals(builtin.MultisigActorCodeID) {
+		return nil, fmt.Errorf("actor %s is not a multisig actor", msigAddr)
+	}
+	var state multisig.State
+	err = v.ipldStore.Get(ctx, a.Head.Cid, &state)
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// MultisigTransaction is a transaction proposed to a multisig actor and awaiting approval.
+type MultisigTransaction struct {
+	ID int64
+	multisig.Transaction
+}
+
+// MultisigPendingTransactions returns the transactions of a multisig actor awaiting approval, ordered by ID.
+func (v *View) MultisigPendingTransactions(ctx context.Context, msigAddr addr.Address) ([]MultisigTransaction, error) {
+	msigState, err := v.MultisigState(ctx, msigAddr)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := v.asMap(ctx, msigState.PendingTxns)
+	if err != nil {
+		return nil, err
+	}
+
+	txns := []MultisigTransaction{}
+	var txn multisig.Transaction
+	err = pending.ForEach(&txn, func(key string) error {
+		id, err := adt.ParseIntKey(key)
+		if err != nil {
+			return err
+		}
+		txns = append(txns, MultisigTransaction{ID: id, Transaction: txn})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(txns, func(i, j int) bool { return txns[i].ID < txns[j].ID })
+	return txns, nil
+}
+
 func (v *View) loadPowerClaim(ctx context.Context, powerState *power.State, miner addr.Address) (*power.Claim, error) {
 	claims, err := v.asMap(ctx, powerState.Claims)
 	if err != nil {
@@ -624,6 +731,16 @@ func (v *View) loadMarketActor(ctx context.Context) (*market.State, error) {
 	return &state, err
 }
 
+func (v *View) loadRewardActor(ctx context.Context) (*reward.State, error) {
+	actr, err := v.loadActor(ctx, builtin.RewardActorAddr)
+	if err != nil {
+		return nil, err
+	}
+	var state reward.State
+	err = v.ipldStore.Get(ctx, actr.Head.Cid, &state)
+	return &state, err
+}
+
 func (v *View) loadAccountActor(ctx context.Context, a addr.Address) (*account.State, error) {
 	resolvedAddr, err := v.InitResolveAddress(ctx, a)
 	if err != nil {
@@ -16,6 +16,7 @@ const Protocol0 = 0
 func ConfigureProtocolVersions(network string) (*ProtocolVersionTable, error) {
 	return NewProtocolVersionTableBuilder(network).
 		Add("alpha2", Protocol0, abi.ChainEpoch(0)).
+		Add("devnet", Protocol0, abi.ChainEpoch(0)).
 		Add("interop", Protocol0, abi.ChainEpoch(0)).
 		Add("localnet", Protocol0, abi.ChainEpoch(0)).
 		Add("testnet", Protocol0, abi.ChainEpoch(0)).
@@ -0,0 +1,168 @@
+package wallet
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	dsq "github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+)
+
+// addressBookPrefix is the namespace of the address book in the repo
+// datastore. It is kept out of the wallet datastore, whose every key older
+// binaries read as an address.
+const addressBookPrefix = "/addressbook"
+
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// AddressBookEntry is a label given to an address.
+type AddressBookEntry struct {
+	Label   string
+	Address address.Address
+}
+
+// AddressBook maps human readable labels to addresses, both of the wallet and
+// external ones. Each label names one address and each address has at most
+// one label.
+type AddressBook struct {
+	lk sync.RWMutex
+	ds ds.Batching
+
+	byLabel map[string]address.Address
+	byAddr  map[address.Address]string
+}
+
+// NewAddressBook loads the address book kept in a repo datastore.
+func NewAddressBook(repoDs ds.Batching) (*AddressBook, error) {
+	book := &AddressBook{
+		ds:      namespace.Wrap(repoDs, ds.NewKey(addressBookPrefix)),
+		byLabel: map[string]address.Address{},
+		byAddr:  map[address.Address]string{},
+	}
+
+	result, err := book.ds.Query(dsq.Query{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query address book")
+	}
+	entries, err := result.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read address book")
+	}
+	for _, e := range entries {
+		addr, err := address.NewFromBytes(e.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid address for label %s", e.Key)
+		}
+		label := strings.TrimPrefix(e.Key, "/")
+		book.byLabel[label] = addr
+		book.byAddr[addr] = label
+	}
+	return book, nil
+}
+
+// Set labels an address, replacing any other label of the address. It fails
+// if the label names another address.
+func (b *AddressBook) Set(label string, addr address.Address) error {
+	if err := validateLabel(label); err != nil {
+		return err
+	}
+
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	if other, ok := b.byLabel[label]; ok && other != addr {
+		return fmt.Errorf("label %s already names %s", label, other)
+	}
+	if old, ok := b.byAddr[addr]; ok && old != label {
+		if err := b.ds.Delete(ds.NewKey(old)); err != nil {
+			return errors.Wrap(err, "failed to remove previous label")
+		}
+		delete(b.byLabel, old)
+	}
+	if err := b.ds.Put(ds.NewKey(label), addr.Bytes()); err != nil {
+		return errors.Wrap(err, "failed to store label")
+	}
+	b.byLabel[label] = addr
+	b.byAddr[addr] = label
+	return nil
+}
+
+// Remove deletes a label.
+func (b *AddressBook) Remove(label string) error {
+	b.lk.Lock()
+	defer b.lk.Unlock()
+
+	addr, ok := b.byLabel[label]
+	if !ok {
+		return fmt.Errorf("no address labelled %s", label)
+	}
+	if err := b.ds.Delete(ds.NewKey(label)); err != nil {
+		return errors.Wrap(err, "failed to remove label")
+	}
+	delete(b.byLabel, label)
+	delete(b.byAddr, addr)
+	return nil
+}
+
+// Lookup returns the address a label names.
+func (b *AddressBook) Lookup(label string) (address.Address, bool) {
+	b.lk.RLock()
+	defer b.lk.RUnlock()
+
+	addr, ok := b.byLabel[label]
+	return addr, ok
+}
+
+// Label returns the label of an address.
+func (b *AddressBook) Label(addr address.Address) (string, bool) {
+	b.lk.RLock()
+	defer b.lk.RUnlock()
+
+	label, ok := b.byAddr[addr]
+	return label, ok
+}
+
+// Entries returns all labelled addresses, sorted by label.
+func (b *AddressBook) Entries() []AddressBookEntry {
+	b.lk.RLock()
+	defer b.lk.RUnlock()
+
+	out := make([]AddressBookEntry, 0, len(b.byLabel))
+	for label, addr := range b.byLabel {
+		out = append(out, AddressBookEntry{Label: label, Address: addr})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Label < out[j].Label
+	})
+	return out
+}
+
+// Resolve parses an address, or looks it up by label if s is not an address.
+func (b *AddressBook) Resolve(s string) (address.Address, error) {
+	addr, err := address.NewFromString(s)
+	if err == nil {
+		return addr, nil
+	}
+	if addr, ok := b.Lookup(s); ok {
+		return addr, nil
+	}
+	return address.Undef, fmt.Errorf("%s is neither an address nor a label in the address book", s)
+}
+
+// validateLabel checks that a label is a single datastore key component and
+// cannot be mistaken for an address.
+func validateLabel(label string) error {
+	if !labelPattern.MatchString(label) {
+		return fmt.Errorf("invalid label %q: labels are letters, digits, '.', '_' and '-', starting with a letter or digit", label)
+	}
+	if _, err := address.NewFromString(label); err == nil {
+		return fmt.Errorf("invalid label %q: labels may not be addresses", label)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestAddressBook(t *testing.T) {
+	tf.UnitTest(t)
+
+	walletDs, repoDs := datastore.NewMapDatastore(), datastore.NewMapDatastore()
+	backend, err := NewDSBackend(walletDs)
+	require.NoError(t, err)
+	owned, err := backend.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+	external, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	book, err := NewAddressBook(repoDs)
+	require.NoError(t, err)
+	require.NoError(t, book.Set("hot", owned))
+	require.NoError(t, book.Set("exchange", external))
+
+	t.Log("labels resolve and raw addresses pass through")
+	addr, err := book.Resolve("hot")
+	require.NoError(t, err)
+	assert.Equal(t, owned, addr)
+	addr, err = book.Resolve(external.String())
+	require.NoError(t, err)
+	assert.Equal(t, external, addr)
+	_, err = book.Resolve("cold")
+	assert.Error(t, err)
+
+	t.Log("a label names one address and relabelling replaces the old label")
+	assert.Error(t, book.Set("hot", external))
+	require.NoError(t, book.Set("ops", owned))
+	_, ok := book.Lookup("hot")
+	assert.False(t, ok)
+	label, ok := book.Label(owned)
+	assert.True(t, ok)
+	assert.Equal(t, "ops", label)
+
+	t.Log("labels may not look like addresses")
+	assert.Error(t, book.Set(external.String(), owned))
+	assert.Error(t, book.Set("a/b", owned))
+
+	t.Log("the book reloads from the repo datastore and leaves the wallet datastore alone")
+	backend2, err := NewDSBackend(walletDs)
+	require.NoError(t, err)
+	assert.Equal(t, []address.Address{owned}, backend2.Addresses())
+	book2, err := NewAddressBook(repoDs)
+	require.NoError(t, err)
+	assert.Equal(t, []AddressBookEntry{{"exchange", external}, {"ops", owned}}, book2.Entries())
+
+	require.NoError(t, book2.Remove("ops"))
+	assert.Error(t, book2.Remove("ops"))
+	_, ok = book2.Label(owned)
+	assert.False(t, ok)
+}
@@ -27,6 +27,12 @@ type DSBackend struct {
 
 	// TODO: proper cache
 	cache map[address.Address]struct{}
+
+	// hd holds the HD seed and derivation indexes, nil when the backend does
+	// not derive keys.
+	hd ds.Datastore
+	// hdLk serializes the derivation of HD keys.
+	hdLk sync.Mutex
 }
 
 var _ Backend = (*DSBackend)(nil)
@@ -89,7 +95,23 @@ func (backend *DSBackend) HasAddress(addr address.Address) bool {
 
 // NewAddress creates a new address and stores it.
 // Safe for concurrent access.
+// When the backend has a seed, the address is the next one of its derivation
+// path.
 func (backend *DSBackend) NewAddress(protocol address.Protocol) (address.Address, error) {
+	hasSeed, err := backend.HasSeed()
+	if err != nil {
+		return address.Undef, err
+	}
+	if hasSeed {
+		backend.hdLk.Lock()
+		defer backend.hdLk.Unlock()
+		next, err := backend.nextIndex(protocol)
+		if err != nil {
+			return address.Undef, err
+		}
+		return backend.importDerived(protocol, next)
+	}
+
 	switch protocol {
 	case address.BLS:
 		return backend.newBLSAddress()
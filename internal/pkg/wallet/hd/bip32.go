@@ -0,0 +1,80 @@
+package hd
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	secp256k1 "github.com/ipsn/go-secp256k1"
+)
+
+// HardenedOffset is added to an index to derive a hardened child.
+const HardenedOffset uint32 = 0x80000000
+
+var errInvalidSecpKey = errors.New("derived an invalid secp256k1 key, use the next index")
+
+// secpKey is a BIP-32 extended private key.
+type secpKey struct {
+	key       []byte
+	chainCode []byte
+}
+
+// secpMaster derives the BIP-32 master key of a seed.
+func secpMaster(seed []byte) (secpKey, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	_, _ = mac.Write(seed)
+	sum := mac.Sum(nil)
+	if !validSecpScalar(sum[:32]) {
+		return secpKey{}, errInvalidSecpKey
+	}
+	return secpKey{key: sum[:32], chainCode: sum[32:]}, nil
+}
+
+// child derives the child key at index, which is hardened from HardenedOffset.
+func (k secpKey) child(index uint32) (secpKey, error) {
+	var data []byte
+	if index >= HardenedOffset {
+		data = append([]byte{0}, k.key...)
+	} else {
+		data = compressedSecpPublicKey(k.key)
+	}
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[len(data)-4:], index)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	_, _ = mac.Write(data)
+	sum := mac.Sum(nil)
+	if !validSecpScalar(sum[:32]) {
+		return secpKey{}, errInvalidSecpKey
+	}
+
+	n := secp256k1.S256().N
+	child := new(big.Int).SetBytes(sum[:32])
+	child.Add(child, new(big.Int).SetBytes(k.key))
+	child.Mod(child, n)
+	if child.Sign() == 0 {
+		return secpKey{}, errInvalidSecpKey
+	}
+	return secpKey{key: leftPad32(child.Bytes()), chainCode: sum[32:]}, nil
+}
+
+func validSecpScalar(b []byte) bool {
+	i := new(big.Int).SetBytes(b)
+	return i.Sign() != 0 && i.Cmp(secp256k1.S256().N) < 0
+}
+
+// compressedSecpPublicKey serializes the public key of a private key in the
+// 33 byte compressed form.
+func compressedSecpPublicKey(sk []byte) []byte {
+	x, y := secp256k1.S256().ScalarBaseMult(sk)
+	return append([]byte{2 + byte(y.Bit(0))}, leftPad32(x.Bytes())...)
+}
+
+// leftPad32 pads a big-endian integer to 32 bytes.
+func leftPad32(b []byte) []byte {
+	out := make([]byte, 32)
+	copy(out[32-len(b):], b)
+	return out
+}
@@ -0,0 +1,78 @@
+package hd
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// blsCurveOrder is the order r of the BLS12-381 subgroups.
+var blsCurveOrder, _ = new(big.Int).SetString("73eda753299d7d483339d80809a1d80553bda402fffe5bfeffffffff00000001", 16)
+
+// blsMaster derives the EIP-2333 master secret key of a seed.
+func blsMaster(seed []byte) (*big.Int, error) {
+	if len(seed) < 32 {
+		return nil, errors.New("seed must be at least 32 bytes")
+	}
+	return hkdfModR(seed), nil
+}
+
+// blsChild derives the EIP-2333 child secret key at index.
+func blsChild(parent *big.Int, index uint32) *big.Int {
+	return hkdfModR(parentToLamportPK(parent, index))
+}
+
+// hkdfModR is the HKDF_mod_r function of EIP-2333.
+func hkdfModR(ikm []byte) *big.Int {
+	salt := []byte("BLS-SIG-KEYGEN-SALT-")
+	sk := new(big.Int)
+	for sk.Sign() == 0 {
+		h := sha256.Sum256(salt)
+		salt = h[:]
+		okm := make([]byte, 48)
+		r := hkdf.New(sha256.New, append(append([]byte{}, ikm...), 0), salt, []byte{0, 48})
+		if _, err := io.ReadFull(r, okm); err != nil {
+			panic(err) // 48 bytes are well within the output limit of HKDF-SHA256
+		}
+		sk.SetBytes(okm)
+		sk.Mod(sk, blsCurveOrder)
+	}
+	return sk
+}
+
+// parentToLamportPK is the parent_SK_to_lamport_PK function of EIP-2333.
+func parentToLamportPK(parent *big.Int, index uint32) []byte {
+	salt := make([]byte, 4)
+	binary.BigEndian.PutUint32(salt, index)
+	ikm := leftPad32(parent.Bytes())
+	notIkm := make([]byte, len(ikm))
+	for i, b := range ikm {
+		notIkm[i] = ^b
+	}
+
+	pk := sha256.New()
+	for _, chunks := range [][][]byte{ikmToLamportSK(ikm, salt), ikmToLamportSK(notIkm, salt)} {
+		for _, chunk := range chunks {
+			h := sha256.Sum256(chunk)
+			_, _ = pk.Write(h[:])
+		}
+	}
+	return pk.Sum(nil)
+}
+
+// ikmToLamportSK is the IKM_to_lamport_SK function of EIP-2333.
+func ikmToLamportSK(ikm, salt []byte) [][]byte {
+	okm := make([]byte, 32*255)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, salt, nil), okm); err != nil {
+		panic(err) // 255 blocks is the output limit of HKDF-SHA256
+	}
+	chunks := make([][]byte, 255)
+	for i := range chunks {
+		chunks[i] = okm[32*i : 32*(i+1)]
+	}
+	return chunks
+}
@@ -0,0 +1,101 @@
+// Package hd derives wallet keys from a BIP-39 mnemonic. Secp256k1 keys follow
+// BIP-32 along the BIP-44 path m/44'/461'/0'/0/i, 461 being the coin type of
+// Filecoin, and BLS keys follow EIP-2333 along the EIP-2334 path
+// m/12381/461/0/i.
+package hd
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/pkg/errors"
+	bip39 "github.com/tyler-smith/go-bip39"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+)
+
+// FilecoinCoinType is the SLIP-44 coin type of Filecoin.
+const FilecoinCoinType = 461
+
+// mnemonicEntropyBits gives 24 word mnemonics.
+const mnemonicEntropyBits = 256
+
+// NewMnemonic generates a new random mnemonic.
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// SeedFromMnemonic checks a mnemonic and returns the seed it encodes, protected
+// by an optional passphrase.
+func SeedFromMnemonic(mnemonic, passphrase string) ([]byte, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid mnemonic")
+	}
+	return seed, nil
+}
+
+// Path returns the derivation path of the key of a protocol at an index.
+func Path(protocol address.Protocol, index uint32) (string, error) {
+	switch protocol {
+	case address.SECP256K1:
+		return fmt.Sprintf("m/44'/%d'/0'/0/%d", FilecoinCoinType, index), nil
+	case address.BLS:
+		return fmt.Sprintf("m/12381/%d/0/%d", FilecoinCoinType, index), nil
+	default:
+		return "", fmt.Errorf("cannot derive keys of address protocol %d", protocol)
+	}
+}
+
+// DeriveKey derives the key of a protocol at an index of the derivation path.
+func DeriveKey(seed []byte, protocol address.Protocol, index uint32) (*crypto.KeyInfo, error) {
+	switch protocol {
+	case address.SECP256K1:
+		key, err := deriveSecp(seed, []uint32{44 + HardenedOffset, FilecoinCoinType + HardenedOffset, HardenedOffset, 0, index})
+		if err != nil {
+			return nil, err
+		}
+		return &crypto.KeyInfo{PrivateKey: key, SigType: crypto.SigTypeSecp256k1}, nil
+	case address.BLS:
+		sk, err := blsMaster(seed)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range []uint32{12381, FilecoinCoinType, 0, index} {
+			sk = blsChild(sk, i)
+		}
+		return &crypto.KeyInfo{PrivateKey: blsPrivateKeyBytes(sk), SigType: crypto.SigTypeBLS}, nil
+	default:
+		return nil, fmt.Errorf("cannot derive keys of address protocol %d", protocol)
+	}
+}
+
+func deriveSecp(seed []byte, path []uint32) ([]byte, error) {
+	k, err := secpMaster(seed)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range path {
+		if k, err = k.child(i); err != nil {
+			return nil, err
+		}
+	}
+	return k.key, nil
+}
+
+// blsPrivateKeyBytes serializes a BLS secret key as filecoin-ffi expects it,
+// in little-endian order.
+func blsPrivateKeyBytes(sk *big.Int) []byte {
+	out := leftPad32(sk.Bytes())
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package hd
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestBIP32Vector(t *testing.T) {
+	tf.UnitTest(t)
+
+	// Test vector 1 of BIP-32.
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	key, err := deriveSecp(seed, []uint32{HardenedOffset})
+	require.NoError(t, err)
+	assert.Equal(t, "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea", hex.EncodeToString(key))
+
+	key, err = deriveSecp(seed, []uint32{HardenedOffset, 1})
+	require.NoError(t, err)
+	assert.Equal(t, "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368", hex.EncodeToString(key))
+}
+
+func TestEIP2333Vector(t *testing.T) {
+	tf.UnitTest(t)
+
+	// Test case 0 of EIP-2333, whose seed is that of the all "abandon" mnemonic
+	// of BIP-39 with passphrase "TREZOR".
+	seed, err := SeedFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "TREZOR")
+	require.NoError(t, err)
+	assert.Equal(t, "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04", hex.EncodeToString(seed))
+
+	master, err := blsMaster(seed)
+	require.NoError(t, err)
+	expected, _ := new(big.Int).SetString("6083874454709270928345386274498605044986640685124978867557563392430687146096", 10)
+	assert.Equal(t, expected, master)
+
+	expected, _ = new(big.Int).SetString("20397789859736650942317412262472558107875392172444076792671091975210932703118", 10)
+	assert.Equal(t, expected, blsChild(master, 0))
+}
+
+func TestDeriveKey(t *testing.T) {
+	tf.UnitTest(t)
+
+	mnemonic, err := NewMnemonic()
+	require.NoError(t, err)
+	seed, err := SeedFromMnemonic(mnemonic, "")
+	require.NoError(t, err)
+
+	for _, protocol := range []address.Protocol{address.SECP256K1, address.BLS} {
+		first, err := DeriveKey(seed, protocol, 0)
+		require.NoError(t, err)
+		again, err := DeriveKey(seed, protocol, 0)
+		require.NoError(t, err)
+		second, err := DeriveKey(seed, protocol, 1)
+		require.NoError(t, err)
+		assert.True(t, first.Equals(again))
+		assert.False(t, first.Equals(second))
+		assert.Len(t, first.PrivateKey, 32)
+	}
+
+	_, err = SeedFromMnemonic("abandon abandon abandon", "")
+	assert.Error(t, err)
+	_, err = DeriveKey(seed, address.ID, 0)
+	assert.Error(t, err)
+}
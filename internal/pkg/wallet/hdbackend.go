@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/filecoin-project/go-address"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet/hd"
+)
+
+// hdPrefix is the namespace of the HD seed and derivation indexes in the repo
+// datastore. They are kept out of the wallet datastore, whose every key older
+// binaries read as an address.
+const hdPrefix = "/hdwallet"
+
+var seedKey = ds.NewKey("/seed")
+
+// ErrNoSeed is returned when deriving keys in a wallet without an HD seed.
+var ErrNoSeed = errors.New("wallet has no HD seed")
+
+func nextIndexKey(protocol address.Protocol) ds.Key {
+	return ds.NewKey(fmt.Sprintf("/next/%d", protocol))
+}
+
+// NewDSBackendWithHD constructs a backend storing keys in the wallet datastore
+// that derives new addresses from an HD seed, once set, kept in a repo
+// datastore.
+func NewDSBackendWithHD(walletDs repo.Datastore, repoDs ds.Batching) (*DSBackend, error) {
+	backend, err := NewDSBackend(walletDs)
+	if err != nil {
+		return nil, err
+	}
+	backend.hd = namespace.Wrap(repoDs, ds.NewKey(hdPrefix))
+	return backend, nil
+}
+
+// SetSeed makes the backend derive new addresses from a seed. A backend has a
+// single seed, which cannot be replaced.
+func (backend *DSBackend) SetSeed(seed []byte) error {
+	if backend.hd == nil {
+		return errors.New("wallet backend does not support HD seeds")
+	}
+	backend.lk.Lock()
+	defer backend.lk.Unlock()
+
+	existing, err := backend.hd.Get(seedKey)
+	if err == nil {
+		if bytes.Equal(existing, seed) {
+			return nil
+		}
+		return errors.New("wallet already has a different HD seed")
+	}
+	if err != ds.ErrNotFound {
+		return err
+	}
+	return backend.hd.Put(seedKey, seed)
+}
+
+// HasSeed returns whether the backend has an HD seed.
+func (backend *DSBackend) HasSeed() (bool, error) {
+	if backend.hd == nil {
+		return false, nil
+	}
+	return backend.hd.Has(seedKey)
+}
+
+// DeriveKey derives the key of a protocol at an index of the seed's derivation
+// path, without storing it.
+func (backend *DSBackend) DeriveKey(protocol address.Protocol, index uint32) (*crypto.KeyInfo, error) {
+	if backend.hd == nil {
+		return nil, ErrNoSeed
+	}
+	seed, err := backend.hd.Get(seedKey)
+	if err == ds.ErrNotFound {
+		return nil, ErrNoSeed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return hd.DeriveKey(seed, protocol, index)
+}
+
+// ImportDerived derives the key of a protocol at an index and stores it. New
+// addresses are derived after the highest index imported.
+func (backend *DSBackend) ImportDerived(protocol address.Protocol, index uint32) (address.Address, error) {
+	backend.hdLk.Lock()
+	defer backend.hdLk.Unlock()
+	return backend.importDerived(protocol, index)
+}
+
+func (backend *DSBackend) importDerived(protocol address.Protocol, index uint32) (address.Address, error) {
+	ki, err := backend.DeriveKey(protocol, index)
+	if err != nil {
+		return address.Undef, err
+	}
+	if err := backend.putKeyInfo(ki); err != nil {
+		return address.Undef, err
+	}
+	next, err := backend.nextIndex(protocol)
+	if err != nil {
+		return address.Undef, err
+	}
+	if index >= next {
+		if err := backend.hd.Put(nextIndexKey(protocol), []byte(strconv.FormatUint(uint64(index)+1, 10))); err != nil {
+			return address.Undef, err
+		}
+	}
+	return ki.Address()
+}
+
+func (backend *DSBackend) nextIndex(protocol address.Protocol) (uint32, error) {
+	b, err := backend.hd.Get(nextIndexKey(protocol))
+	if err == ds.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	next, err := strconv.ParseUint(string(b), 10, 32)
+	return uint32(next), err
+}
@@ -0,0 +1,80 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet/hd"
+)
+
+func TestDSBackendHD(t *testing.T) {
+	tf.UnitTest(t)
+
+	mnemonic, err := hd.NewMnemonic()
+	require.NoError(t, err)
+	seed, err := hd.SeedFromMnemonic(mnemonic, "")
+	require.NoError(t, err)
+
+	walletDs, repoDs := datastore.NewMapDatastore(), datastore.NewMapDatastore()
+	backend, err := NewDSBackendWithHD(walletDs, repoDs)
+	require.NoError(t, err)
+	_, err = backend.DeriveKey(address.SECP256K1, 0)
+	assert.Equal(t, ErrNoSeed, err)
+	require.NoError(t, backend.SetSeed(seed))
+
+	t.Log("new addresses follow the derivation path")
+	for i := uint32(0); i < 2; i++ {
+		addr, err := backend.NewAddress(address.SECP256K1)
+		require.NoError(t, err)
+		ki, err := backend.DeriveKey(address.SECP256K1, i)
+		require.NoError(t, err)
+		expected, err := ki.Address()
+		require.NoError(t, err)
+		assert.Equal(t, expected, addr)
+	}
+
+	t.Log("the same seed recovers the same addresses in another wallet")
+	other, err := NewDSBackendWithHD(datastore.NewMapDatastore(), datastore.NewMapDatastore())
+	require.NoError(t, err)
+	require.NoError(t, other.SetSeed(seed))
+	recovered, err := other.ImportDerived(address.SECP256K1, 1)
+	require.NoError(t, err)
+	assert.True(t, backend.HasAddress(recovered))
+
+	t.Log("new addresses follow the highest imported index")
+	_, err = other.ImportDerived(address.SECP256K1, 5)
+	require.NoError(t, err)
+	next, err := other.NewAddress(address.SECP256K1)
+	require.NoError(t, err)
+	ki, err := other.DeriveKey(address.SECP256K1, 6)
+	require.NoError(t, err)
+	expected, err := ki.Address()
+	require.NoError(t, err)
+	assert.Equal(t, expected, next)
+
+	t.Log("the seed cannot be replaced")
+	require.NoError(t, backend.SetSeed(seed))
+	assert.Error(t, backend.SetSeed(append([]byte{1}, seed[1:]...)))
+
+	t.Log("the seed survives reloading and is kept out of the wallet datastore")
+	reloaded, err := NewDSBackendWithHD(walletDs, repoDs)
+	require.NoError(t, err)
+	assert.Len(t, reloaded.Addresses(), 2)
+	hasSeed, err := reloaded.HasSeed()
+	require.NoError(t, err)
+	assert.True(t, hasSeed)
+	plain, err := NewDSBackend(walletDs)
+	require.NoError(t, err)
+	assert.Len(t, plain.Addresses(), 2)
+
+	t.Log("backends without an HD datastore have no seed")
+	hasSeed, err = plain.HasSeed()
+	require.NoError(t, err)
+	assert.False(t, hasSeed)
+	assert.Error(t, plain.SetSeed(seed))
+}
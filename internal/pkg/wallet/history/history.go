@@ -0,0 +1,665 @@
+// Package history indexes the on-chain transactions affecting a set of
+// addresses, such as those of a wallet and its miner, as the chain head moves.
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	initact "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	dsq "github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/block"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/state"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+)
+
+// historyPrefix is the key prefix under which the index is kept in the repo
+// datastore.
+const historyPrefix = "/history"
+
+var (
+	// tipKey holds the height of the latest indexed tipset.
+	tipKey = ds.NewKey("/tip")
+	// tipSetsPrefix holds the key of the tipset indexed at each height.
+	tipSetsPrefix = ds.NewKey("/tipsets")
+	// trackedPrefix marks the addresses whose history has been indexed.
+	trackedPrefix = ds.NewKey("/tracked")
+	// entriesPrefix holds the entries of each address at each height.
+	entriesPrefix = ds.NewKey("/entries")
+	// backfillPrefix holds the height down to which the history of an address
+	// that started being tracked has been indexed, until it reaches genesis.
+	backfillPrefix = ds.NewKey("/backfill")
+)
+
+// ErrNotTracked is returned when asking for the history of an address that is
+// not indexed.
+var ErrNotTracked = errors.New("address history is not tracked")
+
+// Kind is the way an entry affects an address.
+type Kind string
+
+const (
+	// Send is a message sent by the address, which pays its gas.
+	Send Kind = "send"
+	// Receive is a message sent to the address.
+	Receive Kind = "receive"
+	// Reward is the reward for a block mined by the address, credited by the
+	// reward actor as locked funds.
+	Reward Kind = "reward"
+)
+
+// Entry is a transaction affecting an address. Value only moves when the exit
+// code is Ok, while the gas of a send is paid regardless.
+type Entry struct {
+	Kind   Kind
+	Epoch  abi.ChainEpoch
+	TipSet block.TipSetKey
+	// Message is the cid of the message, undefined for rewards.
+	Message  cid.Cid
+	From     address.Address
+	To       address.Address
+	Value    types.AttoFIL
+	Method   abi.MethodNum
+	ExitCode exitcode.ExitCode
+	GasUsed  gas.Unit
+	// GasPaid is the fee paid by the sender, zero for receives and rewards.
+	GasPaid types.AttoFIL
+}
+
+// StateView is the state the index reads to match addresses and derive rewards.
+type StateView interface {
+	InitResolveAddress(ctx context.Context, a address.Address) (address.Address, error)
+	RewardBlockReward(ctx context.Context) (abi.TokenAmount, error)
+}
+
+// StateViewer provides state views at state roots.
+type StateViewer interface {
+	HistoryStateView(root cid.Cid) StateView
+}
+
+// DefaultStateViewer adapts a state viewer to the history state viewer.
+type DefaultStateViewer struct {
+	*state.Viewer
+}
+
+// AsDefaultStateViewer adapts a state viewer to the history state viewer.
+func AsDefaultStateViewer(v *state.Viewer) DefaultStateViewer {
+	return DefaultStateViewer{v}
+}
+
+// HistoryStateView returns a history state view for a state root.
+func (v DefaultStateViewer) HistoryStateView(root cid.Cid) StateView {
+	return v.Viewer.StateView(root)
+}
+
+type chainReader interface {
+	GetTipSet(block.TipSetKey) (block.TipSet, error)
+}
+
+type messageLoader interface {
+	LoadMessages(context.Context, cid.Cid) ([]*types.SignedMessage, []*types.UnsignedMessage, error)
+	LoadReceipts(context.Context, cid.Cid) ([]vm.MessageReceipt, error)
+}
+
+// Index records the transactions affecting the addresses returned by a
+// tracking function. It is brought up to date with each new head, reverting
+// the tipsets dropped by reorgs, and indexes the whole chain for addresses
+// that start being tracked.
+//
+// A tipset is indexed once a child carries the receipts of its messages, so
+// the index trails the head by one tipset.
+type Index struct {
+	chain    chainReader
+	messages messageLoader
+	views    StateViewer
+	tracked  func() []address.Address
+
+	lk sync.Mutex
+	ds ds.Batching
+}
+
+// NewIndex creates an index kept in the repo datastore.
+func NewIndex(repoDs ds.Batching, chn chainReader, messages messageLoader, views StateViewer, tracked func() []address.Address) *Index {
+	return &Index{
+		chain:    chn,
+		messages: messages,
+		views:    views,
+		tracked:  tracked,
+		ds:       namespace.Wrap(repoDs, ds.NewKey(historyPrefix)),
+	}
+}
+
+// History returns the entries of a tracked address between two epochs,
+// inclusive, in chain order.
+func (idx *Index) History(addr address.Address, from, to abi.ChainEpoch) ([]Entry, error) {
+	idx.lk.Lock()
+	defer idx.lk.Unlock()
+
+	tracked, err := idx.ds.Has(trackedPrefix.ChildString(addr.String()))
+	if err != nil {
+		return nil, err
+	}
+	if !tracked {
+		return nil, errors.Wrapf(ErrNotTracked, "no history of %s", addr)
+	}
+
+	result, err := idx.ds.Query(dsq.Query{
+		Prefix: entriesPrefix.ChildString(addr.String()).String(),
+		Orders: []dsq.Order{dsq.OrderByKey{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	records, err := result.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	out := []Entry{}
+	for _, r := range records {
+		height, err := heightOfKey(ds.RawKey(r.Key).BaseNamespace())
+		if err != nil {
+			return nil, err
+		}
+		if height < from || height > to {
+			continue
+		}
+		var entries []Entry
+		if err := json.Unmarshal(r.Value, &entries); err != nil {
+			return nil, errors.Wrapf(err, "invalid history of %s at %d", addr, height)
+		}
+		out = append(out, entries...)
+	}
+	return out, nil
+}
+
+// indexChunkSize is the number of tipsets indexed between commits of the
+// index, bounding the writes held in memory while indexing a long chain, as on
+// the first run, and the work lost if indexing is interrupted.
+const indexChunkSize = 500
+
+// indexPair is a tipset along with the child carrying its receipts.
+type indexPair struct {
+	ts    block.TipSet
+	child block.TipSet
+}
+
+// HandleNewHead brings the index up to date with a new head. Progress is
+// committed every indexChunkSize tipsets, so that an interrupted run resumes
+// from the last commit.
+func (idx *Index) HandleNewHead(ctx context.Context, head block.TipSet) error {
+	idx.lk.Lock()
+	defer idx.lk.Unlock()
+
+	var all, added []address.Address
+	for _, addr := range idx.tracked() {
+		marked, err := idx.ds.Has(trackedPrefix.ChildString(addr.String()))
+		if err != nil {
+			return err
+		}
+		if !marked {
+			added = append(added, addr)
+		}
+		all = append(all, addr)
+	}
+
+	// Walk back from the head to the latest tipset already indexed, or to
+	// genesis, collecting the keys of the tipsets to index, newest first.
+	var pending []block.TipSetKey
+	var ancestor indexPair
+	child := head
+	for {
+		parentKey, err := child.Parents()
+		if err != nil {
+			return err
+		}
+		if parentKey.Empty() {
+			break
+		}
+		ts, err := idx.chain.GetTipSet(parentKey)
+		if err != nil {
+			return err
+		}
+		indexed, err := idx.isIndexed(ts)
+		if err != nil {
+			return err
+		}
+		if indexed {
+			ancestor = indexPair{ts, child}
+			break
+		}
+		pending = append(pending, parentKey)
+		child = ts
+	}
+
+	batch, err := idx.ds.Batch()
+	if err != nil {
+		return err
+	}
+	w := &chunkWriter{ds: idx.ds, batch: batch}
+
+	tip := abi.ChainEpoch(-1)
+	if ancestor.ts.Defined() {
+		if tip, err = ancestor.ts.Height(); err != nil {
+			return err
+		}
+	}
+	if err := idx.revertAbove(w.batch, tip, added); err != nil {
+		return err
+	}
+
+	// Addresses that start being tracked get the history of the chain below
+	// the pending tipsets, which is otherwise indexed for others only.
+	if ancestor.ts.Defined() && len(added) > 0 {
+		if err := idx.backfill(ctx, w, ancestor, added); err != nil {
+			return err
+		}
+	}
+	// Added addresses now have their history down to genesis, or will with
+	// the first chunk of pending tipsets.
+	for _, addr := range added {
+		if err := w.batch.Put(trackedPrefix.ChildString(addr.String()), []byte{}); err != nil {
+			return err
+		}
+		if err := w.batch.Delete(backfillPrefix.ChildString(addr.String())); err != nil {
+			return err
+		}
+	}
+
+	for i := len(pending) - 1; i >= 0; i-- {
+		ts, err := idx.chain.GetTipSet(pending[i])
+		if err != nil {
+			return err
+		}
+		child := head
+		if i > 0 {
+			if child, err = idx.chain.GetTipSet(pending[i-1]); err != nil {
+				return err
+			}
+		}
+		if err := idx.indexTipSet(ctx, w.batch, ts, child, all, true); err != nil {
+			return err
+		}
+		if tip, err = ts.Height(); err != nil {
+			return err
+		}
+		if err := w.indexed(ctx, func(b ds.Batch) error { return putTip(b, tip) }); err != nil {
+			return err
+		}
+	}
+	if err := putTip(w.batch, tip); err != nil {
+		return err
+	}
+	return w.batch.Commit()
+}
+
+// backfill indexes the chain from a tipset down to genesis for addresses that
+// start being tracked. The height each address is indexed down to is committed
+// along with each chunk, and the tipsets above it are skipped when resuming.
+func (idx *Index) backfill(ctx context.Context, w *chunkWriter, from indexPair, addrs []address.Address) error {
+	top, err := from.ts.Height()
+	if err != nil {
+		return err
+	}
+	progress := make(map[address.Address]abi.ChainEpoch, len(addrs))
+	for _, addr := range addrs {
+		progress[addr] = top + 1
+		val, err := idx.ds.Get(backfillPrefix.ChildString(addr.String()))
+		if err == ds.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		h, err := heightOfKey(string(val))
+		if err != nil {
+			return err
+		}
+		if h < progress[addr] {
+			progress[addr] = h
+		}
+	}
+	putProgress := func(b ds.Batch) error {
+		for addr, h := range progress {
+			if err := b.Put(backfillPrefix.ChildString(addr.String()), []byte(strconv.FormatInt(int64(h), 10))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	p := from
+	for {
+		height, err := p.ts.Height()
+		if err != nil {
+			return err
+		}
+		var needed []address.Address
+		for _, addr := range addrs {
+			if height < progress[addr] {
+				needed = append(needed, addr)
+			}
+		}
+		if len(needed) > 0 {
+			if err := idx.indexTipSet(ctx, w.batch, p.ts, p.child, needed, false); err != nil {
+				return err
+			}
+			for _, addr := range needed {
+				progress[addr] = height
+			}
+			if err := w.indexed(ctx, putProgress); err != nil {
+				return err
+			}
+		}
+		parentKey, err := p.ts.Parents()
+		if err != nil {
+			return err
+		}
+		if parentKey.Empty() {
+			return nil
+		}
+		parent, err := idx.chain.GetTipSet(parentKey)
+		if err != nil {
+			return err
+		}
+		p = indexPair{parent, p.ts}
+	}
+}
+
+// chunkWriter commits the writes of an indexing run every indexChunkSize
+// tipsets.
+type chunkWriter struct {
+	ds    ds.Batching
+	batch ds.Batch
+	count int
+}
+
+// indexed counts a tipset written to the batch and, at the end of a chunk,
+// commits the batch along with the progress written by `progress`.
+func (w *chunkWriter) indexed(ctx context.Context, progress func(ds.Batch) error) error {
+	w.count++
+	if w.count%indexChunkSize != 0 {
+		return nil
+	}
+	if err := progress(w.batch); err != nil {
+		return err
+	}
+	if err := w.batch.Commit(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	batch, err := w.ds.Batch()
+	if err != nil {
+		return err
+	}
+	w.batch = batch
+	return nil
+}
+
+func putTip(batch ds.Batch, tip abi.ChainEpoch) error {
+	return batch.Put(tipKey, []byte(strconv.FormatInt(int64(tip), 10)))
+}
+
+// isIndexed returns whether a tipset is the one indexed at its height.
+func (idx *Index) isIndexed(ts block.TipSet) (bool, error) {
+	height, err := ts.Height()
+	if err != nil {
+		return false, err
+	}
+	val, err := idx.ds.Get(tipSetsPrefix.ChildString(heightKey(height)))
+	if err == ds.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var key block.TipSetKey
+	if err := json.Unmarshal(val, &key); err != nil {
+		return false, err
+	}
+	return key.Equals(ts.Key()), nil
+}
+
+// revertAbove removes the tipsets indexed above a height, along with their
+// entries for tracked addresses and those being backfilled.
+func (idx *Index) revertAbove(batch ds.Batch, height abi.ChainEpoch, backfilling []address.Address) error {
+	val, err := idx.ds.Get(tipKey)
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	tip, err := strconv.ParseInt(string(val), 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "invalid history tip")
+	}
+
+	result, err := idx.ds.Query(dsq.Query{Prefix: trackedPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	marked, err := result.Rest()
+	if err != nil {
+		return err
+	}
+	addrs := make([]string, 0, len(marked)+len(backfilling))
+	for _, m := range marked {
+		addrs = append(addrs, ds.RawKey(m.Key).BaseNamespace())
+	}
+	for _, addr := range backfilling {
+		addrs = append(addrs, addr.String())
+	}
+	for h := height + 1; h <= abi.ChainEpoch(tip); h++ {
+		if err := batch.Delete(tipSetsPrefix.ChildString(heightKey(h))); err != nil {
+			return err
+		}
+		for _, addr := range addrs {
+			if err := batch.Delete(entriesPrefix.ChildString(addr).ChildString(heightKey(h))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// indexTipSet records the entries of a tipset affecting some addresses, and
+// the tipset itself if `record` is set.
+func (idx *Index) indexTipSet(ctx context.Context, batch ds.Batch, ts, child block.TipSet, addrs []address.Address, record bool) error {
+	height, err := ts.Height()
+	if err != nil {
+		return err
+	}
+	if record {
+		key, err := json.Marshal(ts.Key())
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(tipSetsPrefix.ChildString(heightKey(height)), key); err != nil {
+			return err
+		}
+	}
+
+	// Messages may address actors by their ID, resolved in the state after
+	// the tipset's messages, where actors they create exist.
+	after := idx.views.HistoryStateView(child.At(0).StateRoot.Cid)
+	owners := make(map[address.Address]address.Address, 2*len(addrs))
+	for _, addr := range addrs {
+		owners[addr] = addr
+		id, err := after.InitResolveAddress(ctx, addr)
+		if err == initact.ErrAddressNotFound || err == types.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve %s", addr)
+		}
+		owners[id] = addr
+	}
+
+	msgs, err := idx.executionOrder(ctx, ts)
+	if err != nil {
+		return err
+	}
+	receipts, err := idx.messages.LoadReceipts(ctx, child.At(0).MessageReceipts.Cid)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load receipts for tipset %s", ts.Key())
+	}
+	if len(receipts) != len(msgs) {
+		return fmt.Errorf("tipset %s has %d messages but %d receipts", ts.Key(), len(msgs), len(receipts))
+	}
+
+	entries := make(map[address.Address][]Entry)
+	fees := make([]abi.TokenAmount, ts.Len())
+	for i := range fees {
+		fees[i] = big.Zero()
+	}
+	for i, m := range msgs {
+		receipt := receipts[i]
+		fee := receipt.GasUsed.ToTokens(m.msg.GasPrice)
+		fees[m.block] = big.Add(fees[m.block], fee)
+
+		entry := Entry{
+			Epoch:    height,
+			TipSet:   ts.Key(),
+			Message:  m.cid,
+			From:     m.msg.From,
+			To:       m.msg.To,
+			Value:    m.msg.Value,
+			Method:   m.msg.Method,
+			ExitCode: receipt.ExitCode,
+			GasUsed:  receipt.GasUsed,
+			GasPaid:  big.Zero(),
+		}
+		if owner, ok := owners[m.msg.From]; ok {
+			send := entry
+			send.Kind = Send
+			send.GasPaid = fee
+			entries[owner] = append(entries[owner], send)
+		}
+		if owner, ok := owners[m.msg.To]; ok {
+			receive := entry
+			receive.Kind = Receive
+			entries[owner] = append(entries[owner], receive)
+		}
+	}
+
+	// Each block's miner is paid the block reward, read from the state the
+	// tipset's messages apply to, plus the gas fees of the messages first
+	// included by the block. Penalties for invalid messages are not deducted.
+	var blockReward abi.TokenAmount
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		owner, ok := owners[blk.Miner]
+		if !ok {
+			continue
+		}
+		if blockReward.Nil() {
+			blockReward, err = idx.views.HistoryStateView(blk.StateRoot.Cid).RewardBlockReward(ctx)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load block reward for tipset %s", ts.Key())
+			}
+		}
+		entries[owner] = append(entries[owner], Entry{
+			Kind:    Reward,
+			Epoch:   height,
+			TipSet:  ts.Key(),
+			From:    builtin.RewardActorAddr,
+			To:      blk.Miner,
+			Value:   big.Add(blockReward, fees[i]),
+			GasPaid: big.Zero(),
+		})
+	}
+
+	for owner, es := range entries {
+		val, err := json.Marshal(es)
+		if err != nil {
+			return err
+		}
+		if err := batch.Put(entriesPrefix.ChildString(owner.String()).ChildString(heightKey(height)), val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type indexedMessage struct {
+	msg   *types.UnsignedMessage
+	cid   cid.Cid
+	block int
+}
+
+// executionOrder lists the distinct messages of a tipset in the order the VM
+// applies them, along with the index of the block first including each. Secp
+// messages are identified by the cid of the signed message.
+func (idx *Index) executionOrder(ctx context.Context, ts block.TipSet) ([]indexedMessage, error) {
+	var msgs []indexedMessage
+	seen := make(map[cid.Cid]struct{})
+	add := func(m *types.UnsignedMessage, c cid.Cid, blk int) error {
+		u, err := m.Cid()
+		if err != nil {
+			return err
+		}
+		if _, ok := seen[u]; ok {
+			return nil
+		}
+		seen[u] = struct{}{}
+		msgs = append(msgs, indexedMessage{msg: m, cid: c, block: blk})
+		return nil
+	}
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		secpMsgs, blsMsgs, err := idx.messages.LoadMessages(ctx, blk.Messages.Cid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load messages for block %s", blk.Cid())
+		}
+		for _, m := range blsMsgs {
+			c, err := m.Cid()
+			if err != nil {
+				return nil, err
+			}
+			if err := add(m, c, i); err != nil {
+				return nil, err
+			}
+		}
+		for _, sm := range secpMsgs {
+			c, err := sm.Cid()
+			if err != nil {
+				return nil, err
+			}
+			if err := add(&sm.Message, c, i); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return msgs, nil
+}
+
+// heightKey formats a height so that keys sort in chain order.
+func heightKey(h abi.ChainEpoch) string {
+	return fmt.Sprintf("%020d", h)
+}
+
+func heightOfKey(k string) (abi.ChainEpoch, error) {
+	h, err := strconv.ParseInt(k, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid height key %s", k)
+	}
+	return abi.ChainEpoch(h), nil
+}
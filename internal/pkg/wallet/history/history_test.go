@@ -0,0 +1,246 @@
+package history_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	initact "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-filecoin/internal/pkg/chain"
+	tf "github.com/filecoin-project/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/types"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm"
+	vmaddr "github.com/filecoin-project/go-filecoin/internal/pkg/vm/address"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/vm/gas"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/wallet/history"
+)
+
+// fakeViewer resolves addresses from a fixed map and pays a fixed block reward.
+type fakeViewer struct {
+	ids    map[address.Address]address.Address
+	reward abi.TokenAmount
+}
+
+func (v *fakeViewer) HistoryStateView(cid.Cid) history.StateView {
+	return v
+}
+
+func (v *fakeViewer) InitResolveAddress(_ context.Context, a address.Address) (address.Address, error) {
+	if a.Protocol() == address.ID {
+		return a, nil
+	}
+	id, ok := v.ids[a]
+	if !ok {
+		return address.Undef, initact.ErrAddressNotFound
+	}
+	return id, nil
+}
+
+func (v *fakeViewer) RewardBlockReward(context.Context) (abi.TokenAmount, error) {
+	return v.reward, nil
+}
+
+func TestIndexHistory(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	miner := vmaddr.RequireIDAddress(t, 1000)
+	addrGetter := vmaddr.NewForTestGetter()
+	alice, bob := addrGetter(), addrGetter()
+	aliceID := vmaddr.RequireIDAddress(t, 100)
+	builder := chain.NewBuilder(t, miner)
+	viewer := &fakeViewer{
+		ids:    map[address.Address]address.Address{alice: aliceID},
+		reward: abi.NewTokenAmount(10),
+	}
+	tracked := []address.Address{alice, miner}
+	idx := history.NewIndex(datastore.NewMapDatastore(), builder, builder, viewer, func() []address.Address { return tracked })
+
+	price := abi.NewTokenAmount(2)
+	pay := types.NewMeteredMessage(alice, bob, 0, abi.NewTokenAmount(5), 0, nil, price, gas.NewGas(100))
+	refund := types.NewMeteredMessage(bob, aliceID, 0, abi.NewTokenAmount(7), 0, nil, price, gas.NewGas(100))
+	payCid, err := pay.Cid()
+	require.NoError(t, err)
+	refundCid, err := refund.Cid()
+	require.NoError(t, err)
+
+	genesis := builder.NewGenesis()
+	withMsgs := builder.BuildOneOn(genesis, func(bb *chain.BlockBuilder) {
+		bb.AddMessages([]*types.SignedMessage{}, []*types.UnsignedMessage{pay, refund})
+	})
+	executed := builder.BuildOneOn(withMsgs, func(bb *chain.BlockBuilder) {
+		bb.SetReceipts([]vm.MessageReceipt{
+			{ExitCode: exitcode.Ok, GasUsed: gas.NewGas(3)},
+			{ExitCode: exitcode.ErrInsufficientFunds, GasUsed: gas.NewGas(4)},
+		})
+	})
+	head := builder.AppendOn(executed, 1)
+	require.NoError(t, idx.HandleNewHead(ctx, head))
+
+	t.Log("sends and receives are indexed for either form of an address")
+	entries, err := idx.History(alice, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, history.Send, entries[0].Kind)
+	assert.Equal(t, payCid, entries[0].Message)
+	assert.Equal(t, abi.ChainEpoch(1), entries[0].Epoch)
+	assert.Equal(t, withMsgs.Key(), entries[0].TipSet)
+	assert.Equal(t, abi.NewTokenAmount(5), entries[0].Value)
+	assert.Equal(t, abi.NewTokenAmount(6), entries[0].GasPaid)
+	assert.Equal(t, history.Receive, entries[1].Kind)
+	assert.Equal(t, refundCid, entries[1].Message)
+	assert.Equal(t, exitcode.ErrInsufficientFunds, entries[1].ExitCode)
+	assert.Equal(t, abi.NewTokenAmount(0), entries[1].GasPaid)
+
+	t.Log("block rewards include the gas fees of the block's messages")
+	entries, err = idx.History(miner, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, history.Reward, entries[0].Kind)
+	assert.Equal(t, abi.NewTokenAmount(10+6+8), entries[0].Value)
+
+	t.Log("the head's tipset waits for its receipts")
+	entries, err = idx.History(miner, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	t.Log("untracked addresses have no history")
+	_, err = idx.History(bob, 0, 10)
+	assert.Equal(t, history.ErrNotTracked, errors.Cause(err))
+
+	t.Log("newly tracked addresses get the history of the whole chain")
+	tracked = append(tracked, bob)
+	require.NoError(t, idx.HandleNewHead(ctx, head))
+	entries, err = idx.History(bob, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, history.Receive, entries[0].Kind)
+	assert.Equal(t, history.Send, entries[1].Kind)
+	assert.Equal(t, abi.NewTokenAmount(8), entries[1].GasPaid)
+}
+
+func TestIndexRevertsReorgs(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	addrGetter := vmaddr.NewForTestGetter()
+	alice, bob := addrGetter(), addrGetter()
+	builder := chain.NewBuilder(t, vmaddr.RequireIDAddress(t, 1000))
+	viewer := &fakeViewer{reward: abi.NewTokenAmount(10)}
+	idx := history.NewIndex(datastore.NewMapDatastore(), builder, builder, viewer, func() []address.Address { return []address.Address{alice} })
+
+	price := abi.NewTokenAmount(1)
+	dropped := types.NewMeteredMessage(alice, bob, 0, abi.NewTokenAmount(5), 0, nil, price, gas.NewGas(100))
+	kept := types.NewMeteredMessage(alice, bob, 0, abi.NewTokenAmount(9), 0, nil, price, gas.NewGas(100))
+	keptCid, err := kept.Cid()
+	require.NoError(t, err)
+	receipts := []vm.MessageReceipt{{ExitCode: exitcode.Ok, GasUsed: gas.NewGas(1)}}
+
+	common := builder.AppendOn(builder.NewGenesis(), 1)
+	oldHead := builder.BuildOneOn(common, func(bb *chain.BlockBuilder) {
+		bb.AddMessages([]*types.SignedMessage{}, []*types.UnsignedMessage{dropped})
+	})
+	oldHead = builder.BuildOneOn(oldHead, func(bb *chain.BlockBuilder) { bb.SetReceipts(receipts) })
+	oldHead = builder.AppendOn(oldHead, 1)
+	require.NoError(t, idx.HandleNewHead(ctx, oldHead))
+	entries, err := idx.History(alice, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, abi.NewTokenAmount(5), entries[0].Value)
+
+	newHead := builder.BuildOneOn(common, func(bb *chain.BlockBuilder) {
+		bb.IncHeight(1)
+		bb.AddMessages([]*types.SignedMessage{}, []*types.UnsignedMessage{kept})
+	})
+	newHead = builder.BuildOneOn(newHead, func(bb *chain.BlockBuilder) { bb.SetReceipts(receipts) })
+	require.NoError(t, idx.HandleNewHead(ctx, newHead))
+
+	entries, err = idx.History(alice, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, keptCid, entries[0].Message)
+	assert.Equal(t, abi.ChainEpoch(3), entries[0].Epoch)
+}
+
+// interruptingLoader counts the tipsets whose messages are loaded, failing
+// after a number of them when set.
+type interruptingLoader struct {
+	*chain.Builder
+	loads     int
+	failAfter int
+}
+
+func (l *interruptingLoader) LoadMessages(ctx context.Context, c cid.Cid) ([]*types.SignedMessage, []*types.UnsignedMessage, error) {
+	l.loads++
+	if l.failAfter > 0 && l.loads > l.failAfter {
+		return nil, nil, errors.New("interrupted")
+	}
+	return l.Builder.LoadMessages(ctx, c)
+}
+
+func TestIndexResumesInterruptedIndexing(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	addrGetter := vmaddr.NewForTestGetter()
+	alice, bob := addrGetter(), addrGetter()
+	builder := chain.NewBuilder(t, vmaddr.RequireIDAddress(t, 1000))
+	viewer := &fakeViewer{reward: abi.NewTokenAmount(10)}
+	repoDs := datastore.NewMapDatastore()
+	tracked := []address.Address{alice}
+
+	pay := types.NewMeteredMessage(alice, bob, 0, abi.NewTokenAmount(5), 0, nil, abi.NewTokenAmount(1), gas.NewGas(100))
+	withMsgs := builder.BuildOneOn(builder.NewGenesis(), func(bb *chain.BlockBuilder) {
+		bb.AddMessages([]*types.SignedMessage{}, []*types.UnsignedMessage{pay})
+	})
+	executed := builder.BuildOneOn(withMsgs, func(bb *chain.BlockBuilder) {
+		bb.SetReceipts([]vm.MessageReceipt{{ExitCode: exitcode.Ok, GasUsed: gas.NewGas(1)}})
+	})
+	head := builder.AppendManyOn(1200, executed)
+	height, err := head.Height()
+	require.NoError(t, err)
+	run := func(failAfter int) (*history.Index, *interruptingLoader, error) {
+		loader := &interruptingLoader{Builder: builder, failAfter: failAfter}
+		idx := history.NewIndex(repoDs, builder, loader, viewer, func() []address.Address { return tracked })
+		return idx, loader, idx.HandleNewHead(ctx, head)
+	}
+
+	t.Log("indexing the chain commits its progress in chunks")
+	idx, _, err := run(700)
+	require.Error(t, err)
+	entries, err := idx.History(alice, 0, height)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	t.Log("indexing resumes from the last commit")
+	idx, loader, err := run(0)
+	require.NoError(t, err)
+	assert.Less(t, loader.loads, int(height))
+	entries, err = idx.History(alice, 0, height)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	t.Log("newly tracked addresses are not reported until their history reaches genesis")
+	tracked = append(tracked, bob)
+	idx, _, err = run(700)
+	require.Error(t, err)
+	_, err = idx.History(bob, 0, height)
+	assert.Equal(t, history.ErrNotTracked, errors.Cause(err))
+
+	t.Log("indexing their history resumes from the last commit")
+	idx, loader, err = run(0)
+	require.NoError(t, err)
+	assert.Less(t, loader.loads, int(height))
+	entries, err = idx.History(bob, 0, height)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, history.Receive, entries[0].Kind)
+}
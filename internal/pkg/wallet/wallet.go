@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"reflect"
 	"sort"
@@ -11,8 +12,11 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/filecoin-project/go-filecoin/internal/pkg/crypto"
+	"github.com/filecoin-project/go-filecoin/internal/pkg/metrics"
 )
 
+var signCnt = metrics.NewInt64Counter("wallet/sign", "Number of signing calls to the wallet", metrics.SigTypeKey)
+
 // Wallet manages the locally stored addresses.
 type Wallet struct {
 	lk sync.Mutex
@@ -93,6 +97,8 @@ func (w *Wallet) Backends(kind reflect.Type) []Backend {
 // SignBytes cryptographically signs `data` using the private key corresponding to
 // address `addr`
 func (w *Wallet) SignBytes(data []byte, addr address.Address) (crypto.Signature, error) {
+	signCnt.Inc(metrics.WithTag(context.Background(), metrics.SigTypeKey, sigType(addr)), 1)
+
 	// Check that we are storing the address to sign for.
 	backend, err := w.Find(addr)
 	if err != nil {
@@ -101,15 +107,66 @@ func (w *Wallet) SignBytes(data []byte, addr address.Address) (crypto.Signature,
 	return backend.SignBytes(data, addr)
 }
 
+// sigType names the type of signature made by the key of an address.
+func sigType(addr address.Address) string {
+	switch addr.Protocol() {
+	case address.SECP256K1:
+		return "secp256k1"
+	case address.BLS:
+		return "bls"
+	default:
+		return "unknown"
+	}
+}
+
 // NewAddress creates a new account address on the default wallet backend.
 func NewAddress(w *Wallet, p address.Protocol) (address.Address, error) {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return address.Undef, err
+	}
+	return backend.NewAddress(p)
+}
+
+// SetSeed makes the wallet derive new addresses from an HD seed.
+func SetSeed(w *Wallet, seed []byte) error {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return err
+	}
+	return backend.SetSeed(seed)
+}
+
+// DeriveAddress returns the address at an index of the wallet's derivation
+// path for a protocol, without adding it to the wallet.
+func DeriveAddress(w *Wallet, p address.Protocol, index uint32) (address.Address, error) {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return address.Undef, err
+	}
+	ki, err := backend.DeriveKey(p, index)
+	if err != nil {
+		return address.Undef, err
+	}
+	return ki.Address()
+}
+
+// ImportDerived adds the address at an index of the wallet's derivation path
+// for a protocol to the wallet.
+func ImportDerived(w *Wallet, p address.Protocol, index uint32) (address.Address, error) {
+	backend, err := defaultBackend(w)
+	if err != nil {
+		return address.Undef, err
+	}
+	return backend.ImportDerived(p, index)
+}
+
+func defaultBackend(w *Wallet) (*DSBackend, error) {
 	backends := w.Backends(DSBackendType)
 	if len(backends) == 0 {
-		return address.Undef, fmt.Errorf("missing default ds backend")
+		return nil, fmt.Errorf("missing default ds backend")
 	}
-
-	backend := (backends[0]).(*DSBackend)
-	return backend.NewAddress(p)
+	return (backends[0]).(*DSBackend), nil
 }
 
 // GetPubKeyForAddress returns the public key in the keystore associated with
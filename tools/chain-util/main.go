@@ -2,13 +2,16 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	logging "github.com/ipfs/go-log/v2"
 	cli "gopkg.in/urfave/cli.v2"
 
 	export "github.com/filecoin-project/go-filecoin/tools/chain-util/pkg/export"
+	validate "github.com/filecoin-project/go-filecoin/tools/chain-util/pkg/validate"
 )
 
 var log = logging.Logger("chain-util")
@@ -20,6 +23,8 @@ func init() {
 const (
 	repoFlag = "repo"
 	outFlag  = "out"
+	fromFlag = "from"
+	toFlag   = "to"
 )
 
 var exportCmd = &cli.Command{
@@ -51,10 +56,64 @@ var exportCmd = &cli.Command{
 	},
 }
 
+var validateCmd = &cli.Command{
+	Name:  "validate",
+	Usage: "Re-validate a range of the stored chain and report the first divergence",
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:  repoFlag,
+			Usage: "the repo where go-filecoin was initialized",
+		},
+		&cli.Uint64Flag{
+			Name:  fromFlag,
+			Usage: "the lowest height to re-validate",
+			Value: 1,
+		},
+		&cli.Uint64Flag{
+			Name:  toFlag,
+			Usage: "the highest height to re-validate",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		repoPath := cctx.Path(repoFlag)
+		if repoPath == "" {
+			return fmt.Errorf("filecoin repo path required")
+		}
+		if !cctx.IsSet(toFlag) {
+			return fmt.Errorf("height to validate to required")
+		}
+
+		ctx := context.Background()
+		validator, err := validate.NewChainValidator(ctx, repoPath)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := validator.Close(); err != nil {
+				log.Warn(err)
+			}
+		}()
+
+		res, err := validator.Validate(ctx, abi.ChainEpoch(cctx.Uint64(fromFlag)), abi.ChainEpoch(cctx.Uint64(toFlag)))
+		if err != nil {
+			return err
+		}
+		out, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		if res.Divergence != nil {
+			return fmt.Errorf("chain diverged at height %d", res.Divergence.Height)
+		}
+		return nil
+	},
+}
+
 func main() {
 	app := &cli.App{
 		Name:     "chain-export",
-		Commands: []*cli.Command{exportCmd},
+		Commands: []*cli.Command{exportCmd, validateCmd},
 	}
 	app.Setup()
 
@@ -98,7 +98,7 @@ func (ce *ChainExporter) GetTipSet(key block.TipSetKey) (block.TipSet, error) {
 
 // ChainStateTree returns the state tree as a slice of IPLD nodes at the passed stateroot cid `c`.
 func (ce *ChainExporter) ChainStateTree(ctx context.Context, c cid.Cid) ([]format.Node, error) {
-	return plumbingDag.NewDAG(ce.dagserv).RecursiveGet(ctx, c)
+	return plumbingDag.NewDAG(ce.dagserv, ce.bstore).RecursiveGet(ctx, c)
 }
 
 func getDatastoreHeadTipSet(ds *badgerds.Datastore, bs blockstore.Blockstore) (block.TipSet, error) {
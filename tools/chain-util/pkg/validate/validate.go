@@ -0,0 +1,68 @@
+package validate
+
+import (
+	"context"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	logging "github.com/ipfs/go-log/v2"
+	errors "github.com/pkg/errors"
+
+	node "github.com/filecoin-project/go-filecoin/internal/app/go-filecoin/node"
+	chainreplay "github.com/filecoin-project/go-filecoin/internal/pkg/chainreplay"
+	repo "github.com/filecoin-project/go-filecoin/internal/pkg/repo"
+)
+
+var log = logging.Logger("chain-util/validate")
+
+// ChainValidator replays ranges of the chain stored in a repo without
+// running a daemon.
+type ChainValidator struct {
+	repo repo.Repo
+	node *node.Node
+}
+
+// NewChainValidator opens the repo at `repoPath` and builds an offline node
+// over it. The repo must not be in use by a running daemon.
+func NewChainValidator(ctx context.Context, repoPath string) (*ChainValidator, error) {
+	log.Infof("opening filecoin repo: %s", repoPath)
+	rep, err := repo.OpenFSRepo(repoPath, repo.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := node.OptionsFromRepo(rep)
+	if err != nil {
+		_ = rep.Close()
+		return nil, err
+	}
+	opts = append(opts,
+		node.OfflineMode(true),
+		node.MonkeyPatchNetworkParamsOption(rep.Config().NetworkParams),
+	)
+	nd, err := node.New(ctx, opts...)
+	if err != nil {
+		_ = rep.Close()
+		return nil, errors.Wrap(err, "failed to build offline node")
+	}
+	if err := nd.Chain().ChainReader.Load(ctx); err != nil {
+		_ = rep.Close()
+		return nil, errors.Wrap(err, "failed to load chain")
+	}
+
+	return &ChainValidator{
+		repo: rep,
+		node: nd,
+	}, nil
+}
+
+// Validate replays the tipsets with heights in [from, to] and reports the
+// first divergence from the stored state.
+func (cv *ChainValidator) Validate(ctx context.Context, from, to abi.ChainEpoch) (*chainreplay.Result, error) {
+	return cv.node.PorcelainAPI.ChainValidate(ctx, from, to)
+}
+
+// Close releases the repo.
+func (cv *ChainValidator) Close() error {
+	cv.node.Chain().ChainReader.Stop()
+	return cv.repo.Close()
+}
@@ -71,6 +71,7 @@ func main() {
 	outJSON := flag.String("out-json", "", "enables json output and writes it to the given file")
 	outCar := flag.String("out-car", "", "writes the generated car file to the give path, instead of stdout")
 	configFilePath := flag.String("config", "", "reads configuration from this json file, instead of stdin")
+	mockProofs := flag.Bool("mock-proofs", false, "marks the genesis block as belonging to a network using mock proofs")
 
 	_ = flag.Parse(os.Args[1:])
 
@@ -80,6 +81,9 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	if *mockProofs {
+		cfg.MockProofs = true
+	}
 
 	outfile := os.Stdout
 	if *outCar != "" {
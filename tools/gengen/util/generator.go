@@ -274,6 +274,11 @@ func (g *GenesisGenerator) genBlock(ctx context.Context) (cid.Cid, error) {
 		return cid.Undef, err
 	}
 
+	var forkSignaling uint64
+	if g.cfg.MockProofs {
+		forkSignaling |= genesis.MockProofsSignal
+	}
+
 	geneblk := &block.Block{
 		Miner:           builtin.SystemActorAddr,
 		Ticket:          genesis.Ticket,
@@ -286,7 +291,7 @@ func (g *GenesisGenerator) genBlock(ctx context.Context) (cid.Cid, error) {
 		MessageReceipts: e.NewCid(emptyAMTCid),
 		Messages:        e.NewCid(metaCid),
 		Timestamp:       g.cfg.Time,
-		ForkSignaling:   0,
+		ForkSignaling:   forkSignaling,
 	}
 
 	return g.cst.Put(ctx, geneblk)
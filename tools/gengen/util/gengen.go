@@ -95,6 +95,10 @@ type GenesisCfg struct {
 
 	// Time is the genesis block time in unix seconds
 	Time uint64
+
+	// MockProofs marks the network as using mock proofs, which nodes using
+	// real proofs will refuse to join
+	MockProofs bool
 }
 
 // RenderedGenInfo contains information about a genesis block creation
@@ -186,6 +190,15 @@ func MinerConfigs(minerCfgs []*CreateStorageMinerConfig) GenOption {
 
 var defaultGenTimeOpt = GenTime(123456789)
 
+// MockProofs marks the genesis block as belonging to a network that uses
+// mock proofs.
+func MockProofs() GenOption {
+	return func(gc *GenesisCfg) error {
+		gc.MockProofs = true
+		return nil
+	}
+}
+
 // MakeGenesisFunc returns a genesis function configured by a set of options.
 func MakeGenesisFunc(opts ...GenOption) genesis.InitFunc {
 	// Dragons: GenesisInitFunc should take in only a blockstore to remove the hidden